@@ -5,14 +5,23 @@ dist=dist
 bin=imladris
 
 function build {
-    GOOS=$1 GOARCH=$2 go build -o $bin
+    output=$bin
+    if [ "$1" = "windows" ]; then
+        output=$bin.exe
+    fi
+    GOOS=$1 GOARCH=$2 go build -ldflags "-X github.com/anduintransaction/imladris/deploy.imladrisVersion=$RELEASE -X github.com/anduintransaction/imladris/deploy.updatePublicKey=$UPDATE_PUBLIC_KEY" -o $output
     package=$bin-$RELEASE-$1-$2.tar.gz
-    tar cvzf $package $bin
+    tar cvzf $package $output
     mv $package $dist
-    rm $bin
+    rm $output
 }
 
 mkdir -p $dist
 go generate
 build darwin amd64
 build linux amd64
+build linux arm64
+build windows amd64
+# The release pipeline signs SHA256SUMS with the ed25519 release key and
+# uploads the base64 signature as SHA256SUMS.sig, self-update requires both
+(cd $dist && sha256sum *.tar.gz > SHA256SUMS)
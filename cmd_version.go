@@ -1,7 +0,0 @@
-package main
-
-import "fmt"
-
-func cmdVersion(args []string, config *appConfig) {
-	fmt.Println("0.13.1")
-}
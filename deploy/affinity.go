@@ -0,0 +1,113 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	app "k8s.io/api/apps/v1beta1"
+	v1batch "k8s.io/api/batch/v1"
+	v1beta1batch "k8s.io/api/batch/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	hostnameTopologyKey = "kubernetes.io/hostname"
+	zoneTopologyKey     = "failure-domain.beta.kubernetes.io/zone"
+)
+
+func getPodLabels(kind string, resourceData interface{}) map[string]string {
+	switch kind {
+	case "pod":
+		return resourceData.(*v1.Pod).Labels
+	case "deployment":
+		return resourceData.(*v1beta1.Deployment).Spec.Template.Labels
+	case "job":
+		return resourceData.(*v1batch.Job).Spec.Template.Labels
+	case "daemonset":
+		return resourceData.(*v1beta1.DaemonSet).Spec.Template.Labels
+	case "statefulset":
+		return resourceData.(*app.StatefulSet).Spec.Template.Labels
+	case "cronjob":
+		return resourceData.(*v1beta1batch.CronJob).Spec.JobTemplate.Spec.Template.Labels
+	default:
+		return nil
+	}
+}
+
+// affinityPreset expands a preset name into the affinity it stands for:
+// spread-across-zones prefers pods of the workload in different zones,
+// one-per-node never puts two of them on a node and colocate-with:<app> only
+// schedules on nodes running a pod labelled app=<app>. podLabels select the
+// workload's own pods for the anti-affinity presets.
+func affinityPreset(preset string, podLabels map[string]string) (*v1.Affinity, error) {
+	self := &apiv1.LabelSelector{MatchLabels: podLabels}
+	switch {
+	case preset == "spread-across-zones" || preset == "one-per-node":
+		if len(podLabels) == 0 {
+			return nil, fmt.Errorf("preset %s needs pod template labels to find the workload's pods", preset)
+		}
+		if preset == "one-per-node" {
+			return &v1.Affinity{PodAntiAffinity: &v1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+					{LabelSelector: self, TopologyKey: hostnameTopologyKey},
+				},
+			}}, nil
+		}
+		return &v1.Affinity{PodAntiAffinity: &v1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+				{Weight: 100, PodAffinityTerm: v1.PodAffinityTerm{LabelSelector: self, TopologyKey: zoneTopologyKey}},
+			},
+		}}, nil
+	case strings.HasPrefix(preset, "colocate-with:"):
+		target := strings.TrimPrefix(preset, "colocate-with:")
+		if target == "" {
+			return nil, fmt.Errorf("preset colocate-with needs an app, e.g. colocate-with:redis")
+		}
+		return &v1.Affinity{PodAffinity: &v1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+				{LabelSelector: &apiv1.LabelSelector{MatchLabels: map[string]string{"app": target}}, TopologyKey: hostnameTopologyKey},
+			},
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown affinity preset %q, expected spread-across-zones, one-per-node or colocate-with:<app>", preset)
+	}
+}
+
+// applyAffinityPresets sets the preset affinity of each matching workload,
+// unless its manifest has an affinity of the same type already
+func (p *Project) applyAffinityPresets() error {
+	patterns := []string{}
+	for pattern := range p.projectConfig.Affinity {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		filter, err := parseResourceFilter(pattern)
+		if err != nil {
+			return fmt.Errorf("affinity: %s", err.Error())
+		}
+		for _, asset := range p.assets() {
+			podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+			if podSpec == nil || !filter.matches(asset) {
+				continue
+			}
+			preset, err := affinityPreset(p.projectConfig.Affinity[pattern], getPodLabels(asset.Kind, asset.ResourceData))
+			if err != nil {
+				return fmt.Errorf("affinity of %s: %s", assetKey(asset), err.Error())
+			}
+			if podSpec.Affinity == nil {
+				podSpec.Affinity = &v1.Affinity{}
+			}
+			if podSpec.Affinity.PodAffinity == nil {
+				podSpec.Affinity.PodAffinity = preset.PodAffinity
+			}
+			if podSpec.Affinity.PodAntiAffinity == nil {
+				podSpec.Affinity.PodAntiAffinity = preset.PodAntiAffinity
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAffinityPresets(t *testing.T) {
+	req := require.New(t)
+	newDeployment := func(name string) *v1beta1.Deployment {
+		deployment := &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: name}}
+		deployment.Spec.Template.Labels = map[string]string{"app": name}
+		return deployment
+	}
+	web, cache, worker := newDeployment("web"), newDeployment("cache"), newDeployment("worker")
+	worker.Spec.Template.Spec.Affinity = &v1.Affinity{PodAffinity: &v1.PodAffinity{}}
+	p := &Project{
+		projectConfig: &ProjectConfig{Affinity: map[string]string{
+			"deployment/web":    "spread-across-zones",
+			"deploy/cache":      "one-per-node",
+			"deployment/worker": "colocate-with:cache",
+		}},
+		services: []*Asset{
+			{Kind: "deployment", ResourceData: web},
+			{Kind: "deployment", ResourceData: cache},
+			{Kind: "deployment", ResourceData: worker},
+		},
+	}
+	req.NoError(p.applyAffinityPresets())
+	term := web.Spec.Template.Spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0]
+	req.Equal(zoneTopologyKey, term.PodAffinityTerm.TopologyKey)
+	req.Equal(map[string]string{"app": "web"}, term.PodAffinityTerm.LabelSelector.MatchLabels)
+	required := cache.Spec.Template.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0]
+	req.Equal(hostnameTopologyKey, required.TopologyKey)
+	// The manifest's own pod affinity wins over the preset
+	req.Empty(worker.Spec.Template.Spec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution)
+
+	_, err := affinityPreset("one-per-zone", nil)
+	req.Error(err)
+	_, err = affinityPreset("one-per-node", nil)
+	req.Error(err)
+	affinity, err := affinityPreset("colocate-with:redis", nil)
+	req.NoError(err)
+	req.Equal("redis", affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].LabelSelector.MatchLabels["app"])
+}
@@ -0,0 +1,178 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// defaultRollbackWindow is how long after a release its alerts roll it back
+const defaultRollbackWindow = 30 * time.Minute
+
+// AutoRollbackConfig rolls back the latest release when one of Alerts
+// starts firing within Window seconds after it. Older releases are left
+// alone, the alert is then unlikely to be caused by the release. Labels
+// must all match the labels of the alert, to tell apart alerts of other
+// services sharing the alert name.
+type AutoRollbackConfig struct {
+	Alerts []string          `yaml:"alerts"`
+	Labels map[string]string `yaml:"labels"`
+	Window int               `yaml:"window"`
+}
+
+// Alert is a firing alert, StartsAt is when it started firing
+type Alert struct {
+	Name     string
+	Labels   map[string]string
+	StartsAt time.Time
+}
+
+// alertmanagerPayload is the part of an alertmanager webhook imladris reads
+type alertmanagerPayload struct {
+	Status string `json:"status"`
+	Alerts []struct {
+		Status   string            `json:"status"`
+		Labels   map[string]string `json:"labels"`
+		StartsAt time.Time         `json:"startsAt"`
+	} `json:"alerts"`
+}
+
+func (a *alertmanagerPayload) firing() []*Alert {
+	alerts := []*Alert{}
+	for _, alert := range a.Alerts {
+		if alert.Status == "firing" && alert.Labels["alertname"] != "" {
+			alerts = append(alerts, &Alert{
+				Name:     alert.Labels["alertname"],
+				Labels:   alert.Labels,
+				StartsAt: alert.StartsAt,
+			})
+		}
+	}
+	return alerts
+}
+
+// matches says whether the alert is one of the configured alerts and
+// carries all the configured labels
+func (config *AutoRollbackConfig) matches(alert *Alert) bool {
+	for name, value := range config.Labels {
+		if alert.Labels[name] != value {
+			return false
+		}
+	}
+	for _, configured := range config.Alerts {
+		if alert.Name == configured {
+			return true
+		}
+	}
+	return false
+}
+
+// autoRollbackReason says why the firing alerts roll back the latest
+// release, or is empty and the second value says why they do not. Only an
+// alert that started firing after the release rolls it back, one already
+// firing before was not caused by it.
+func autoRollbackReason(config *AutoRollbackConfig, firing []*Alert, records []*ReleaseRecord, now time.Time) (string, string) {
+	if config == nil {
+		return "", "auto_rollback is not configured"
+	}
+	matching := []*Alert{}
+	for _, alert := range firing {
+		if config.matches(alert) {
+			matching = append(matching, alert)
+		}
+	}
+	if len(matching) == 0 {
+		return "", "none of the firing alerts rolls back"
+	}
+	latest := latestRelease(records)
+	if latest == nil {
+		return "", "no release recorded"
+	}
+	if latest.Command == "rollback" {
+		return "", fmt.Sprintf("release %s is already a rollback", latest.Release)
+	}
+	window := defaultRollbackWindow
+	if config.Window > 0 {
+		window = time.Duration(config.Window) * time.Second
+	}
+	if now.Sub(latest.Time) > window {
+		return "", fmt.Sprintf("release %s is older than the %s observation window", latest.Release, window)
+	}
+	for _, alert := range matching {
+		if alert.StartsAt.After(latest.Time) {
+			return fmt.Sprintf("alert %q started firing %s after release %s", alert.Name, alert.StartsAt.Sub(latest.Time).Round(time.Second), latest.Release), ""
+		}
+	}
+	return "", fmt.Sprintf("alert %q was already firing before release %s", matching[0].Name, latest.Release)
+}
+
+// checkAutoRollback reads the history to decide whether the firing alerts
+// roll back the latest release
+func (p *Project) checkAutoRollback(firing []*Alert) (string, string, error) {
+	backend, err := p.historyBackend()
+	if err != nil {
+		return "", "", err
+	}
+	records, err := backend.read(p.projectConfig.Name)
+	if err != nil {
+		return "", "", err
+	}
+	reason, skipped := autoRollbackReason(p.projectConfig.AutoRollback, firing, records, time.Now())
+	return reason, skipped, nil
+}
+
+// handleAlertmanagerWebhook rolls back the project in the url when an alert
+// of its auto_rollback fires. The check runs in the queue of the target, a
+// deploy in progress finishes first.
+func (s *deployServer) handleAlertmanagerWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.webhookAuthorized(r) {
+		http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	project := query.Get("project")
+	if project == "" {
+		http.Error(w, "project query parameter is required", http.StatusBadRequest)
+		return
+	}
+	payload := &alertmanagerPayload{}
+	err := json.NewDecoder(r.Body).Decode(payload)
+	if err != nil {
+		http.Error(w, "invalid alertmanager payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	firing := payload.firing()
+	if len(firing) == 0 {
+		fmt.Fprintln(w, "no firing alerts")
+		return
+	}
+	job := &deployJob{
+		Project: project,
+		Action:  "auto-rollback",
+		Target:  s.webhookTarget(query),
+	}
+	job.run = func(out *printer) *deployResult {
+		result := &deployResult{Result: "succeeded"}
+		deployment, err := s.load(project, s.options(query.Get("context"), query.Get("namespace"), out))
+		if err == nil {
+			var rolledBack bool
+			rolledBack, result.Message, err = deployment.AutoRollback(firing)
+			if err == nil && !rolledBack {
+				out.Printf(ColorYellow, "Not rolling back %q: %s\n", project, result.Message)
+				result.Result = "skipped"
+			}
+		}
+		if err != nil {
+			out.ErrPrintln(ColorRed, err)
+			result.Result = "failed"
+			result.Error = err.Error()
+		}
+		return result
+	}
+	s.accept(w, job)
+}
@@ -0,0 +1,65 @@
+package deploy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoRollbackReason(t *testing.T) {
+	req := require.New(t)
+	now := time.Now()
+	config := &AutoRollbackConfig{
+		Alerts: []string{"HighErrorRate"},
+		Labels: map[string]string{"service": "web"},
+		Window: 600,
+	}
+	records := []*ReleaseRecord{
+		{Release: "a", Command: "up", Time: now.Add(-time.Hour)},
+		{Release: "b", Command: "update", Time: now.Add(-5 * time.Minute)},
+	}
+	alert := func(name, service string, startsAt time.Time) *Alert {
+		return &Alert{Name: name, Labels: map[string]string{"alertname": name, "service": service}, StartsAt: startsAt}
+	}
+	reason, _ := autoRollbackReason(config, []*Alert{
+		alert("DiskFull", "web", now),
+		alert("HighErrorRate", "web", now.Add(-2*time.Minute)),
+	}, records, now)
+	req.Equal(`alert "HighErrorRate" started firing 3m0s after release b`, reason)
+
+	firing := []*Alert{alert("HighErrorRate", "web", now)}
+	for _, skipped := range []struct {
+		config  *AutoRollbackConfig
+		firing  []*Alert
+		records []*ReleaseRecord
+		message string
+	}{
+		{nil, firing, records, "not configured"},
+		{config, []*Alert{alert("DiskFull", "web", now)}, records, "none of the firing alerts"},
+		{config, []*Alert{alert("HighErrorRate", "api", now)}, records, "none of the firing alerts"},
+		{config, []*Alert{alert("HighErrorRate", "web", now.Add(-10*time.Minute))}, records, `"HighErrorRate" was already firing before release b`},
+		{config, firing, nil, "no release recorded"},
+		{config, firing, records[:1], "older than the 10m0s observation window"},
+		{config, firing, append(records, &ReleaseRecord{Release: "c", Command: "rollback", Time: now}), "already a rollback"},
+	} {
+		reason, message := autoRollbackReason(skipped.config, skipped.firing, skipped.records, now)
+		req.Empty(reason)
+		req.Contains(message, skipped.message)
+	}
+}
+
+func TestAlertmanagerPayload(t *testing.T) {
+	req := require.New(t)
+	payload := &alertmanagerPayload{}
+	req.NoError(json.Unmarshal([]byte(`{"status": "firing", "alerts": [
+		{"status": "firing", "labels": {"alertname": "HighErrorRate", "service": "web"}, "startsAt": "2018-03-01T10:00:00Z"},
+		{"status": "resolved", "labels": {"alertname": "HighLatency"}}
+	]}`), payload))
+	req.Equal([]*Alert{{
+		Name:     "HighErrorRate",
+		Labels:   map[string]string{"alertname": "HighErrorRate", "service": "web"},
+		StartsAt: time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC),
+	}}, payload.firing())
+}
@@ -0,0 +1,185 @@
+package deploy
+
+import (
+	"io"
+	"time"
+)
+
+// Options are the settings the command line takes as flags, for programs
+// that deploy through this package instead of running imladris
+type Options struct {
+	Kubeconfig string
+	Context    string
+	Namespace  string
+	Timeout    time.Duration
+	Variables  map[string]string
+	Secrets    map[string]string
+	// Events posts kubernetes events for deploy start, success and failure
+	Events bool
+	// Strict rejects manifest fields unknown to the resource type
+	Strict bool
+	// Wait waits for workloads to be ready after applying, failing as soon
+	// as a new pod crashes
+	Wait bool
+	// Stdout and Stderr receive the deploy output, the process ones when nil
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Deployment is a project read from a folder and bound to a cluster
+type Deployment struct {
+	project *Project
+}
+
+// Change is what applying would do to one resource, Diff holds the changed
+// lines prefixed with + or - and is empty when the resource is up to date
+type Change struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Exists    bool
+	Diff      []string
+}
+
+type ResourceStatus struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Exists    bool
+	Ready     bool
+}
+
+func newAppConfig(options *Options) *appConfig {
+	if options == nil {
+		options = &Options{}
+	}
+	config := &appConfig{
+		configFile:     options.Kubeconfig,
+		context:        options.Context,
+		namespace:      options.Namespace,
+		timeout:        options.Timeout,
+		variables:      make(variableMap),
+		secrets:        make(variableMap),
+		onConflict:     "abort",
+		events:         options.Events,
+		output:         "text",
+		strict:         options.Strict,
+		waitRollout:    options.Wait,
+		nonInteractive: true,
+	}
+	if options.Stdout != nil || options.Stderr != nil {
+		config.printer = newPrinter(options.Stdout, options.Stderr)
+	}
+	if config.timeout == 0 {
+		config.timeout = 15 * time.Minute
+	}
+	for key, value := range options.Variables {
+		config.variables[key] = value
+	}
+	for key, value := range options.Secrets {
+		config.secrets[key] = value
+	}
+	return config
+}
+
+// Load reads the project in folder, options may be nil for the defaults.
+// There is nobody to answer prompts in an embedding program, so
+// confirmations are refused as in -non-interactive.
+func Load(folder string, options *Options) (*Deployment, error) {
+	return load(folder, newAppConfig(options))
+}
+
+func load(folder string, config *appConfig) (*Deployment, error) {
+	kubeClient, err := loadKubernetesClient(config)
+	if err != nil {
+		return nil, err
+	}
+	project, err := readProject(kubeClient, folder, config)
+	if err != nil {
+		return nil, err
+	}
+	return &Deployment{project: project}, nil
+}
+
+func (d *Deployment) Plan() ([]*Change, error) {
+	changes := []*Change{}
+	for _, asset := range d.project.assets() {
+		name := asset.ResourceData.(Meta).GetName()
+		exists, err := d.project.liveResources(asset).exists(asset.Kind, name)
+		if err != nil {
+			return nil, err
+		}
+		lines, err := d.project.diffAsset(asset)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, &Change{
+			Kind:      asset.Kind,
+			Name:      name,
+			Namespace: asset.Namespace(),
+			Exists:    exists,
+			Diff:      lines,
+		})
+	}
+	return changes, nil
+}
+
+// Apply creates the resources that are missing and updates the others
+func (d *Deployment) Apply() error {
+	started := d.project.startDeploy("apply")
+	err := d.project.deploy("apply", d.project.applyAsset)
+	d.project.finishDeploy("apply", started, err)
+	return err
+}
+
+// Rollback redeploys the release before the latest one, skipping the gates
+func (d *Deployment) Rollback() error {
+	started := d.project.startDeploy("rollback")
+	err := d.project.Rollback()
+	d.project.finishDeploy("rollback", started, err)
+	return err
+}
+
+// AutoRollback rolls back the latest release when one of the firing alerts
+// matches auto_rollback, started after the release and the release is
+// recent enough, and says why it did or did not
+func (d *Deployment) AutoRollback(firing []*Alert) (bool, string, error) {
+	reason, skipped, err := d.project.checkAutoRollback(firing)
+	if err != nil || reason == "" {
+		return false, skipped, err
+	}
+	d.project.printer.Printf(ColorYellow, "Rolling back %q: %s\n", d.project.projectConfig.Name, reason)
+	return true, reason, d.Rollback()
+}
+
+// Destroy removes the resources of the project
+func (d *Deployment) Destroy() error {
+	started := d.project.startDeploy("destroy")
+	err := d.project.Down()
+	d.project.finishDeploy("destroy", started, err)
+	return err
+}
+
+func (d *Deployment) Status() ([]*ResourceStatus, error) {
+	statuses := []*ResourceStatus{}
+	for _, asset := range d.project.assets() {
+		status := &ResourceStatus{
+			Kind:      asset.Kind,
+			Name:      asset.ResourceData.(Meta).GetName(),
+			Namespace: asset.Namespace(),
+		}
+		exists, err := d.project.liveResources(asset).exists(status.Kind, status.Name)
+		if err != nil {
+			return nil, err
+		}
+		status.Exists = exists
+		if exists {
+			status.Ready, err = workloadReady(d.project.clientFor(asset), d.project.plugins, status.Kind, status.Name, status.Namespace)
+			if err != nil {
+				return nil, err
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
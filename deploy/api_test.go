@@ -0,0 +1,63 @@
+package deploy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAppConfig(t *testing.T) {
+	req := require.New(t)
+	config := newAppConfig(&Options{
+		Context:   "staging",
+		Variables: map[string]string{"tag": "v1"},
+		Secrets:   map[string]string{"token": "hunter2"},
+	})
+	req.Equal("staging", config.context)
+	req.Equal(15*time.Minute, config.timeout)
+	req.Equal("abort", config.onConflict)
+	req.False(config.strict)
+	req.True(config.nonInteractive)
+	req.Equal("v1", config.variables["tag"])
+	req.Equal("hunter2", config.secrets["token"])
+
+	config = newAppConfig(&Options{Strict: true})
+	req.True(config.strict)
+
+	config = newAppConfig(nil)
+	req.Equal(15*time.Minute, config.timeout)
+	req.True(config.nonInteractive)
+}
+
+func TestDestroyIsAudited(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/api/v1/namespaces/web/configmaps/web", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web"}}`)
+	asset, err := parseAsset("web.yml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: web\n"))
+	req.Nil(err)
+	root := t.TempDir()
+	deployment := &Deployment{project: &Project{
+		kubeClient:    kubeClient,
+		config:        &appConfig{timeout: time.Minute},
+		projectConfig: &ProjectConfig{Name: "web", Namespace: "web", RootFolder: root, Audit: &AuditConfig{File: "audit.log"}},
+		resources:     []*Asset{asset},
+		startedAt:     time.Now(),
+	}}
+	req.Nil(deployment.Destroy())
+	req.Nil(cluster.get("/api/v1/namespaces/web/configmaps/web"))
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "audit.log"))
+	req.Nil(err)
+	actions := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		entry := &auditEntry{}
+		req.Nil(json.Unmarshal([]byte(line), entry))
+		actions = append(actions, entry.Action)
+	}
+	req.Equal([]string{"destroy", "delete", "destroy-finished"}, actions)
+}
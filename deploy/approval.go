@@ -0,0 +1,201 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	approvalContextsEnv  = "IMLADRIS_APPROVAL_CONTEXTS"
+	approvalURLEnv       = "IMLADRIS_APPROVAL_URL"
+	approvalTokenFileEnv = "IMLADRIS_APPROVAL_TOKEN_FILE"
+	approvalNamespaceEnv = "IMLADRIS_APPROVAL_NAMESPACE"
+	approvalIntervalEnv  = "IMLADRIS_APPROVAL_INTERVAL"
+)
+
+// approvalPolicy pauses deploys to the contexts matching contexts until a
+// human approves the release, either through an approval service polled at
+// url or by setting status=approved in a ConfigMap of namespace. Like the
+// signature policy it is set on the deploy host, and the ConfigMap lives
+// outside the deploy namespaces, so deployers can't skip or answer it.
+type approvalPolicy struct {
+	contexts  []string
+	url       string
+	tokenFile string
+	namespace string
+	interval  time.Duration
+}
+
+func readApprovalPolicy() *approvalPolicy {
+	policy := &approvalPolicy{
+		contexts:  contextPatterns(os.Getenv(approvalContextsEnv)),
+		url:       os.Getenv(approvalURLEnv),
+		tokenFile: os.Getenv(approvalTokenFileEnv),
+		namespace: os.Getenv(approvalNamespaceEnv),
+		interval:  15 * time.Second,
+	}
+	seconds, err := strconv.Atoi(os.Getenv(approvalIntervalEnv))
+	if err == nil && seconds > 0 {
+		policy.interval = time.Duration(seconds) * time.Second
+	}
+	return policy
+}
+
+// approvalResponse is what the approval service answers, status is pending,
+// approved or rejected
+type approvalResponse struct {
+	Status   string `json:"status"`
+	Approver string `json:"approver"`
+	Comment  string `json:"comment"`
+}
+
+func (p *Project) approvalRequest() url.Values {
+	return url.Values{
+		"project":   {p.projectConfig.Name},
+		"namespace": {p.projectConfig.Namespace},
+		"release":   {p.releaseID()},
+		"context":   {contextName(p.config)},
+		"actor":     {deployActor()},
+	}
+}
+
+func (p *Project) pollApprovalURL(policy *approvalPolicy) (*approvalResponse, error) {
+	headers := map[string]string{}
+	if policy.tokenFile != "" {
+		buf, err := ioutil.ReadFile(policy.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		headers["Authorization"] = "Bearer " + strings.TrimSpace(string(buf))
+	}
+	separator := "?"
+	if strings.Contains(policy.url, "?") {
+		separator = "&"
+	}
+	response := &approvalResponse{}
+	err := doJSONRequest("GET", policy.url+separator+p.approvalRequest().Encode(), headers, nil, response)
+	return response, err
+}
+
+// approvalConfigMap is named after the project and its namespace, projects
+// of every namespace share the approval namespace
+func (p *Project) approvalConfigMap() string {
+	return "imladris-approval-" + p.projectConfig.Namespace + "-" + p.projectConfig.Name
+}
+
+// pollApprovalConfigMap creates the approval configmap for this release, or
+// reads the status an approver set on it
+func (p *Project) pollApprovalConfigMap(policy *approvalPolicy) (*approvalResponse, error) {
+	name := p.approvalConfigMap()
+	configMaps := p.kubeClient.Core().ConfigMaps(policy.namespace)
+	configMap, err := configMaps.Get(name, apiv1.GetOptions{})
+	if errors.IsNotFound(err) {
+		configMap = &v1.ConfigMap{}
+		configMap.Name = name
+		configMap.Namespace = policy.namespace
+		configMap, err = configMaps.Create(configMap)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if configMap.Data["release"] != p.releaseID() {
+		configMap.Data = map[string]string{"status": "pending"}
+		for key, values := range p.approvalRequest() {
+			configMap.Data[key] = values[0]
+		}
+		_, err = configMaps.Update(configMap)
+		if err != nil {
+			return nil, err
+		}
+		p.printer.ErrPrintf(ColorPurple, "Approve with: kubectl -n %s patch configmap %s -p '{\"data\":{\"status\":\"approved\",\"approver\":\"<name>\"}}'\n", policy.namespace, name)
+		return &approvalResponse{Status: "pending"}, nil
+	}
+	return &approvalResponse{
+		Status:   configMap.Data["status"],
+		Approver: configMap.Data["approver"],
+		Comment:  configMap.Data["comment"],
+	}, nil
+}
+
+// selfApproved tells an approval by nobody, or by the one deploying
+func selfApproved(approver string) bool {
+	approver = strings.ToLower(strings.TrimSpace(approver))
+	actor := strings.ToLower(deployActor())
+	return approver == "" || approver == actor || approver == strings.SplitN(actor, "@", 2)[0]
+}
+
+// checkApprovalStore refuses a configmap store in a namespace the project
+// deploys to, its deployers could approve their own releases
+func (p *Project) checkApprovalStore(policy *approvalPolicy) error {
+	if policy.namespace == "" {
+		return validationError(fmt.Errorf("approval needs %s or %s", approvalURLEnv, approvalNamespaceEnv))
+	}
+	namespaces := map[string]bool{p.projectConfig.Namespace: true}
+	for _, asset := range p.assets() {
+		namespaces[asset.Namespace()] = true
+	}
+	if namespaces[policy.namespace] {
+		return validationError(fmt.Errorf("approval namespace %q is a namespace the project deploys to, its deployers could approve themselves", policy.namespace))
+	}
+	return nil
+}
+
+// waitForApproval polls until the release is approved, rejected or -timeout
+// runs out
+func (p *Project) waitForApproval() error {
+	policy := readApprovalPolicy()
+	if len(policy.contexts) == 0 {
+		return nil
+	}
+	context := contextName(p.config)
+	required, err := matchContexts(policy.contexts, context)
+	if err != nil || !required {
+		return err
+	}
+	if policy.url == "" {
+		err = p.checkApprovalStore(policy)
+		if err != nil {
+			return err
+		}
+	}
+	p.printer.Printf(ColorPurple, "Waiting for approval of release %s to %q\n", p.releaseID(), context)
+	deadline := time.Now().Add(p.config.timeout)
+	for {
+		var response *approvalResponse
+		if policy.url != "" {
+			response, err = p.pollApprovalURL(policy)
+		} else {
+			response, err = p.pollApprovalConfigMap(policy)
+		}
+		if err != nil {
+			p.printer.ErrPrintf(ColorRed, "====> Cannot read approval: %s\n", err.Error())
+		} else {
+			switch response.Status {
+			case "approved":
+				if selfApproved(response.Approver) {
+					p.audit("self-approve", "", "", nil, map[string]string{"approver": response.Approver, "comment": response.Comment})
+					return validationError(fmt.Errorf("release %s must be approved by someone else than %s", p.releaseID(), deployActor()))
+				}
+				p.printer.Printf(ColorGreen, "====> Approved by %s\n", response.Approver)
+				p.audit("approve", "", "", nil, map[string]string{"approver": response.Approver, "comment": response.Comment})
+				return nil
+			case "rejected":
+				p.audit("reject", "", "", nil, map[string]string{"approver": response.Approver, "comment": response.Comment})
+				return validationError(fmt.Errorf("release %s was rejected by %s", p.releaseID(), response.Approver))
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("release %s was not approved within %s", p.releaseID(), p.config.timeout)
+		}
+		p.sleep(policy.interval)
+	}
+}
@@ -0,0 +1,110 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForApproval(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("USER", "deployer")
+	polls := 0
+	approver := "alex"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		req.Equal("Bearer secret", r.Header.Get("Authorization"))
+		req.Equal("web", r.URL.Query().Get("project"))
+		req.Equal("prod-eu", r.URL.Query().Get("context"))
+		response := &approvalResponse{Status: "pending"}
+		if polls > 1 {
+			response = &approvalResponse{Status: r.URL.Query().Get("answer"), Approver: approver}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	req.Nil(ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600))
+	t.Setenv(approvalContextsEnv, "prod-.*")
+	t.Setenv(approvalURLEnv, server.URL+"?answer=approved")
+	t.Setenv(approvalTokenFileEnv, tokenFile)
+	t.Setenv(approvalIntervalEnv, "1")
+
+	slept := 0
+	p := &Project{
+		config:        &appConfig{context: "prod-eu", timeout: time.Minute, sleep: func(time.Duration) { slept++ }},
+		projectConfig: &ProjectConfig{Name: "web", Namespace: "web"},
+	}
+	req.Nil(p.waitForApproval())
+	req.Equal(2, polls)
+	req.Equal(1, slept)
+
+	polls = 0
+	approver = "Deployer"
+	err := p.waitForApproval()
+	req.Error(err)
+	req.Contains(err.Error(), "someone else")
+
+	polls = 0
+	approver = "alex"
+	t.Setenv(approvalURLEnv, server.URL+"?answer=rejected")
+	err = p.waitForApproval()
+	req.Error(err)
+	req.Contains(err.Error(), "rejected by alex")
+
+	polls = 0
+	p.config.context = "staging"
+	req.Nil(p.waitForApproval())
+	req.Equal(0, polls)
+}
+
+func TestWaitForApprovalConfigMap(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("USER", "deployer")
+	cluster, kubeClient := newFakeCluster(t)
+	t.Setenv(approvalContextsEnv, "prod")
+	t.Setenv(approvalNamespaceEnv, "approvals")
+	path := "/api/v1/namespaces/approvals/configmaps/imladris-approval-web-web"
+	out := &bytes.Buffer{}
+	p := &Project{
+		kubeClient:    kubeClient,
+		config:        &appConfig{context: "prod", timeout: time.Minute},
+		projectConfig: &ProjectConfig{Name: "web", Namespace: "web"},
+		startedAt:     time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC),
+		printer:       newPrinter(out, out),
+	}
+	answer := func(approver string) {
+		p.config.sleep = func(time.Duration) {
+			data := cluster.get(path)["data"].(map[string]interface{})
+			req.Equal("pending", data["status"])
+			req.Equal("web", data["namespace"])
+			cluster.add(path, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"imladris-approval-web-web","namespace":"approvals"},"data":{"release":"`+p.releaseID()+`","status":"approved","approver":"`+approver+`"}}`)
+		}
+	}
+
+	answer("alex")
+	req.Nil(p.waitForApproval())
+	req.Contains(out.String(), "kubectl -n approvals patch configmap imladris-approval-web-web")
+	req.Contains(out.String(), "Approved by alex")
+
+	p.startedAt = p.startedAt.Add(time.Hour)
+	answer("deployer")
+	err := p.waitForApproval()
+	req.Error(err)
+	req.Contains(err.Error(), "someone else")
+
+	// The store can't be where the deployers of the project have rights
+	t.Setenv(approvalNamespaceEnv, "web")
+	err = p.waitForApproval()
+	req.Error(err)
+	req.Contains(err.Error(), "approve themselves")
+	t.Setenv(approvalNamespaceEnv, "")
+	req.Error(p.waitForApproval())
+}
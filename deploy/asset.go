@@ -0,0 +1,271 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"bytes"
+
+	"gopkg.in/yaml.v2"
+	app "k8s.io/api/apps/v1beta1"
+	autoscaling "k8s.io/api/autoscaling/v1"
+	v1batch "k8s.io/api/batch/v1"
+	v1beta1batch "k8s.io/api/batch/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	rbac "k8s.io/api/rbac/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+type UnsupportedResource string
+
+func (err UnsupportedResource) Error() string {
+	return "unsupported resource: " + string(err)
+}
+
+type resourceType struct {
+	APIVersion string
+	Kind       string
+}
+
+var supportedKinds = []string{
+	"pod", "deployment", "service", "job", "persistentvolumeclaim", "configmap", "secret", "ingress",
+	"endpoints", "daemonset", "serviceaccount", "role", "clusterrole", "rolebinding", "clusterrolebinding", "statefulset",
+	"cronjob", "horizontalpodautoscaler",
+}
+
+var resourceTypes = map[string]resourceType{
+	"pod":                     {"v1", "Pod"},
+	"deployment":              {"extensions/v1beta1", "Deployment"},
+	"service":                 {"v1", "Service"},
+	"job":                     {"batch/v1", "Job"},
+	"persistentvolumeclaim":   {"v1", "PersistentVolumeClaim"},
+	"configmap":               {"v1", "ConfigMap"},
+	"secret":                  {"v1", "Secret"},
+	"ingress":                 {"extensions/v1beta1", "Ingress"},
+	"endpoints":               {"v1", "Endpoints"},
+	"daemonset":               {"extensions/v1beta1", "DaemonSet"},
+	"serviceaccount":          {"v1", "ServiceAccount"},
+	"role":                    {"rbac.authorization.k8s.io/v1beta1", "Role"},
+	"clusterrole":             {"rbac.authorization.k8s.io/v1beta1", "ClusterRole"},
+	"rolebinding":             {"rbac.authorization.k8s.io/v1beta1", "RoleBinding"},
+	"clusterrolebinding":      {"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding"},
+	"statefulset":             {"apps/v1beta1", "StatefulSet"},
+	"cronjob":                 {"batch/v1beta1", "CronJob"},
+	"horizontalpodautoscaler": {"autoscaling/v1", "HorizontalPodAutoscaler"},
+}
+
+// kindAliases maps the short names kubectl accepts to our kind names
+var kindAliases = map[string]string{
+	"po":     "pod",
+	"deploy": "deployment",
+	"svc":    "service",
+	"pvc":    "persistentvolumeclaim",
+	"cm":     "configmap",
+	"ing":    "ingress",
+	"ep":     "endpoints",
+	"ds":     "daemonset",
+	"sa":     "serviceaccount",
+	"sts":    "statefulset",
+	"cj":     "cronjob",
+	"hpa":    "horizontalpodautoscaler",
+}
+
+// canonicalKind accepts the canonical Kind as written in manifests
+// (PersistentVolumeClaim), its lowercase form and the kubectl short names
+func canonicalKind(kind string) string {
+	kind = strings.ToLower(kind)
+	alias, ok := kindAliases[kind]
+	if ok {
+		return alias
+	}
+	return kind
+}
+
+// contextAnnotation routes a resource to another kubeconfig context, e.g. a
+// shared ingress controller living in an infra cluster
+const contextAnnotation = "imladris/context"
+
+type Asset struct {
+	Kind         string `yaml:"kind"`
+	ResourceData interface{}
+	filename     string
+	data         []byte
+	context      string
+	group        int
+	policy       *ResourcePolicy
+}
+
+func parseAsset(filename string, data []byte) (*Asset, error) {
+	asset := &Asset{}
+	asset.filename = filename
+	asset.data = data
+	err := yaml.Unmarshal(data, asset)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse asset %q, error: %s", asset.filename, err.Error())
+	}
+	asset.Kind = canonicalKind(asset.Kind)
+	err = asset.parseResource(data, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse asset %q, error: %s", asset.filename, err.Error())
+	}
+	return asset, nil
+}
+
+func parseDocument(filename string, document *manifestDocument, strict bool, plugins pluginRegistry) (*Asset, error) {
+	asset := &Asset{}
+	asset.filename = filename
+	asset.data = document.data
+	err := yaml.Unmarshal(document.data, asset)
+	if err == nil {
+		asset.Kind = canonicalKind(asset.Kind)
+		err = asset.parseResource(document.data, strict, plugins)
+	}
+	if err != nil {
+		return nil, newManifestError(filename, document, err)
+	}
+	return asset, nil
+}
+
+// parseResource decodes data into the typed object for the asset kind. In
+// strict mode fields the type doesn't know about, usually misindented keys,
+// are rejected instead of silently dropped. Kinds imladris doesn't know are
+// only accepted when plugins has one for them.
+func (asset *Asset) parseResource(data []byte, strict bool, plugins pluginRegistry) error {
+	switch asset.Kind {
+	case "pod":
+		asset.ResourceData = &v1.Pod{}
+	case "deployment":
+		asset.ResourceData = &v1beta1.Deployment{}
+	case "service":
+		asset.ResourceData = &v1.Service{}
+	case "job":
+		asset.ResourceData = &v1batch.Job{}
+	case "persistentvolumeclaim":
+		asset.ResourceData = &v1.PersistentVolumeClaim{}
+	case "configmap":
+		asset.ResourceData = &v1.ConfigMap{}
+	case "secret":
+		asset.ResourceData = &v1.Secret{}
+	case "ingress":
+		asset.ResourceData = &v1beta1.Ingress{}
+	case "endpoints":
+		asset.ResourceData = &v1.Endpoints{}
+	case "daemonset":
+		asset.ResourceData = &v1beta1.DaemonSet{}
+	case "serviceaccount":
+		asset.ResourceData = &v1.ServiceAccount{}
+	case "role":
+		asset.ResourceData = &rbac.Role{}
+	case "clusterrole":
+		asset.ResourceData = &rbac.ClusterRole{}
+	case "rolebinding":
+		asset.ResourceData = &rbac.RoleBinding{}
+	case "clusterrolebinding":
+		asset.ResourceData = &rbac.ClusterRoleBinding{}
+	case "statefulset":
+		asset.ResourceData = &app.StatefulSet{}
+	case "cronjob":
+		asset.ResourceData = &v1beta1batch.CronJob{}
+	case "horizontalpodautoscaler":
+		asset.ResourceData = &autoscaling.HorizontalPodAutoscaler{}
+	default:
+		if plugins[asset.Kind] == nil {
+			return UnsupportedResource(asset.Kind)
+		}
+		asset.ResourceData = &unstructured.Unstructured{}
+	}
+	if !strict {
+		return kubeyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 1024).Decode(asset.ResourceData)
+	}
+	jsonData, err := kubeyaml.ToJSON(data)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(asset.ResourceData)
+}
+
+func (asset *Asset) UpdateNamespace(namespace string) {
+	objectMeta := asset.ResourceData.(Meta)
+	objectMeta.SetNamespace(namespace)
+}
+
+// DefaultNamespace sets namespace unless the manifest already has one
+func (asset *Asset) DefaultNamespace(namespace string) {
+	objectMeta := asset.ResourceData.(Meta)
+	if objectMeta.GetNamespace() == "" {
+		objectMeta.SetNamespace(namespace)
+	}
+}
+
+func (asset *Asset) Namespace() string {
+	return asset.ResourceData.(Meta).GetNamespace()
+}
+
+const checksumAnnotation = "imladris/checksum"
+
+// Checksum hashes the resource as it will be sent, so changes made after
+// reading the manifest (promoted images, injected settings) are included.
+// It hashes a copy, the resource may be read by another goroutine.
+func (asset *Asset) Checksum() string {
+	resource := asset.ResourceData.(runtime.Object).DeepCopyObject()
+	objectMeta := resource.(apiv1.Object)
+	annotations := objectMeta.GetAnnotations()
+	for key := range annotations {
+		if key == checksumAnnotation || key == changeNoteAnnotation || isProvenanceAnnotation(key) {
+			delete(annotations, key)
+		}
+	}
+	objectMeta.SetResourceVersion("")
+	data, err := json.Marshal(resource)
+	if err != nil {
+		data = asset.data
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func (asset *Asset) annotateChecksum() {
+	objectMeta := asset.ResourceData.(apiv1.Object)
+	checksum := asset.Checksum()
+	annotations := objectMeta.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[checksumAnnotation] = checksum
+	objectMeta.SetAnnotations(annotations)
+}
+
+func (asset *Asset) Debug() {
+	fmt.Println(scrub(string(asset.data)))
+}
+
+// registerSecretValues marks the values of Secret manifests as sensitive
+func (asset *Asset) registerSecretValues() {
+	secret, ok := asset.ResourceData.(*v1.Secret)
+	if !ok {
+		return
+	}
+	for _, value := range secret.Data {
+		registerSensitive(string(value))
+	}
+	for _, value := range secret.StringData {
+		registerSensitive(value)
+	}
+}
+
+type Meta interface {
+	GetName() string
+	GetNamespace() string
+	SetNamespace(namespace string)
+	GetResourceVersion() string
+	SetResourceVersion(version string)
+}
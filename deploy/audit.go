@@ -0,0 +1,133 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+type AuditConfig struct {
+	File string `yaml:"file"`
+	URL  string `yaml:"url"`
+	S3   string `yaml:"s3"`
+}
+
+type auditEntry struct {
+	Time      time.Time         `json:"time"`
+	Actor     string            `json:"actor"`
+	Action    string            `json:"action"`
+	Project   string            `json:"project"`
+	Release   string            `json:"release"`
+	Cluster   string            `json:"cluster"`
+	Namespace string            `json:"namespace"`
+	Kind      string            `json:"kind,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Result    string            `json:"result"`
+	Error     string            `json:"error,omitempty"`
+	Changes   map[string]string `json:"changes,omitempty"`
+}
+
+func (p *Project) auditAsset(action string, asset *Asset, err error) {
+	if p.projectConfig.Audit == nil {
+		return
+	}
+	assetName := asset.ResourceData.(Meta).GetName()
+	var changes map[string]string
+	podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+	if podSpec != nil && action != "delete" {
+		changes = make(map[string]string)
+		for _, container := range podSpec.Containers {
+			changes["image/"+container.Name] = container.Image
+		}
+	}
+	p.audit(action, asset.Kind, assetName, err, changes)
+}
+
+func (p *Project) audit(action, kind, name string, err error, changes map[string]string) {
+	if p.projectConfig.Audit == nil {
+		return
+	}
+	if p.cluster == "" {
+		p.cluster = describeCluster(p.config)
+	}
+	entry := &auditEntry{
+		Time:      time.Now().UTC(),
+		Actor:     deployActor(),
+		Action:    action,
+		Project:   p.projectConfig.Name,
+		Release:   p.releaseID(),
+		Cluster:   p.cluster,
+		Namespace: p.projectConfig.Namespace,
+		Kind:      kind,
+		Name:      name,
+		Result:    "success",
+		Changes:   changes,
+	}
+	if err != nil {
+		entry.Result = "failure"
+		entry.Error = err.Error()
+	}
+	writeErr := writeAuditEntry(p.projectConfig.Audit, p.projectConfig.RootFolder, entry)
+	if writeErr != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot write audit log: %s\n", writeErr.Error())
+	}
+}
+
+func writeAuditEntry(config *AuditConfig, rootFolder string, entry *auditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = []byte(scrub(string(data)))
+	if config.File != "" {
+		err = appendAuditFile(translateFilePath(rootFolder, config.File), data)
+		if err != nil {
+			return err
+		}
+	}
+	if config.URL != "" {
+		err = doJSONRequest("POST", config.URL, nil, json.RawMessage(data), nil)
+		if err != nil {
+			return err
+		}
+	}
+	if config.S3 != "" {
+		key := fmt.Sprintf("%s/%s-%d.json", strings.TrimRight(config.S3, "/"), entry.Release, entry.Time.UnixNano())
+		err = uploadS3(key, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendAuditFile(filename string, data []byte) error {
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.FileMode(0600))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func uploadS3(url string, data []byte) error {
+	err := requireNetwork(url)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("aws", "s3", "cp", "-", url)
+	cmd.Stdin = bytes.NewReader(data)
+	errBuffer := &bytes.Buffer{}
+	cmd.Stderr = errBuffer
+	err = cmd.Run()
+	if err != nil {
+		return errors.New(errBuffer.String())
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditFile(t *testing.T) {
+	req := require.New(t)
+	root := t.TempDir()
+	p := &Project{
+		config:        &appConfig{},
+		projectConfig: &ProjectConfig{Name: "web", Namespace: "web", RootFolder: root, Audit: &AuditConfig{File: "audit.log"}},
+		startedAt:     time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC),
+		cluster:       "staging",
+	}
+	asset, err := parseAsset("web.yml", []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: web\nspec:\n  containers:\n  - name: web\n    image: web:2\n"))
+	req.Nil(err)
+	p.auditAsset("update", asset, nil)
+	p.audit("update-finished", "", "", fmt.Errorf("timeout"), nil)
+
+	filename := filepath.Join(root, "audit.log")
+	info, err := os.Stat(filename)
+	req.Nil(err)
+	req.Equal(os.FileMode(0600), info.Mode().Perm())
+	data, err := ioutil.ReadFile(filename)
+	req.Nil(err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	req.Len(lines, 2)
+	entry := &auditEntry{}
+	req.Nil(json.Unmarshal([]byte(lines[0]), entry))
+	req.Equal("update", entry.Action)
+	req.Equal("pod", entry.Kind)
+	req.Equal("20170102-030405", entry.Release)
+	req.Equal("staging", entry.Cluster)
+	req.Equal("success", entry.Result)
+	req.Equal(map[string]string{"image/web": "web:2"}, entry.Changes)
+	req.Nil(json.Unmarshal([]byte(lines[1]), entry))
+	req.Equal("failure", entry.Result)
+	req.Equal("timeout", entry.Error)
+}
+
+func TestAuditURL(t *testing.T) {
+	req := require.New(t)
+	server, requests := newRecordingServer(t, `{}`)
+	entry := &auditEntry{Action: "down", Project: "web", Result: "success"}
+	req.Nil(writeAuditEntry(&AuditConfig{URL: server.URL + "/audit"}, t.TempDir(), entry))
+	recorded := requests()
+	req.Len(recorded, 1)
+	req.Equal("/audit", recorded[0].path)
+	req.Equal("down", recorded[0].body["action"])
+}
@@ -0,0 +1,278 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// backupLabel marks the ConfigMaps and Secrets holding backups
+const backupLabel = "imladris/backup"
+
+// backupResource saves the live resource to -backup-dir or -backup-configmap
+// before it changes
+func (p *Project) backupResource(asset *Asset, name string) error {
+	if p.config.backupDir == "" && !p.config.backupConfigMap {
+		return nil
+	}
+	return p.saveLiveResource(asset, name, p.config.backupDir)
+}
+
+// backupBeforeRecreate saves a resource about to be deleted and created
+// again even without -backup-dir, in ~/.imladris/backups, as whatever was
+// changed live is lost otherwise
+func (p *Project) backupBeforeRecreate(asset *Asset, name string) error {
+	backupDir := p.config.backupDir
+	if backupDir == "" {
+		backupDir = filepath.Join(homeDir(), ".imladris", "backups")
+	}
+	return p.saveLiveResource(asset, name, backupDir)
+}
+
+func (p *Project) saveLiveResource(asset *Asset, name, backupDir string) error {
+	kind := asset.Kind
+	namespace := asset.Namespace()
+	live, err := getResource(p.clientFor(asset), p.plugins, kind, name, namespace)
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	snapshot := p.startedAt.Format("20060102-150405")
+	key := kind + "-" + name + ".yml"
+	location := filepath.Join(backupDir, snapshot, namespace, key)
+	if p.config.backupConfigMap {
+		location = namespace + "/" + backupObjectName(snapshot) + "/" + key
+	}
+	if p.backedUp[location] {
+		return nil
+	}
+	data, err := exportManifest(kind, live)
+	if err != nil {
+		return err
+	}
+	if p.config.backupConfigMap {
+		err = saveBackupObject(p.clientFor(asset), kind == "secret", backupObjectName(snapshot), namespace, key, data)
+	} else {
+		err = os.MkdirAll(filepath.Dir(location), os.FileMode(0700))
+		if err == nil {
+			err = ioutil.WriteFile(location, data, os.FileMode(0600))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if p.backedUp == nil {
+		p.backedUp = make(map[string]bool)
+	}
+	p.backedUp[location] = true
+	p.printer.Printf(ColorPurple, "====> Backed up to %q\n", location)
+	return nil
+}
+
+func backupObjectName(snapshot string) string {
+	return "imladris-backup-" + snapshot
+}
+
+// saveBackupObject adds a backed up manifest to the ConfigMap of the
+// snapshot. Secrets go to a Secret of the same name, a ConfigMap would let
+// anyone reading ConfigMaps read them.
+func saveBackupObject(kubeClient *kubernetes.Clientset, secret bool, name, namespace, key string, data []byte) error {
+	labels := map[string]string{backupLabel: "true"}
+	if secret {
+		secrets := kubeClient.Core().Secrets(namespace)
+		backup, err := secrets.Get(name, apiv1.GetOptions{})
+		if isResourceNotExist(err) {
+			backup = &v1.Secret{ObjectMeta: apiv1.ObjectMeta{Name: name, Labels: labels}, Data: map[string][]byte{key: data}}
+			_, err = secrets.Create(backup)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if backup.Data == nil {
+			backup.Data = make(map[string][]byte)
+		}
+		backup.Data[key] = data
+		_, err = secrets.Update(backup)
+		return err
+	}
+	configMaps := kubeClient.Core().ConfigMaps(namespace)
+	backup, err := configMaps.Get(name, apiv1.GetOptions{})
+	if isResourceNotExist(err) {
+		backup = &v1.ConfigMap{ObjectMeta: apiv1.ObjectMeta{Name: name, Labels: labels}, Data: map[string]string{key: string(data)}}
+		_, err = configMaps.Create(backup)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if backup.Data == nil {
+		backup.Data = make(map[string]string)
+	}
+	backup.Data[key] = string(data)
+	_, err = configMaps.Update(backup)
+	return err
+}
+
+// loadBackupObjects reads the manifests of a snapshot saved with
+// -backup-configmap, from its ConfigMap and its Secret
+func loadBackupObjects(kubeClient *kubernetes.Clientset, name, namespace string) (map[string][]byte, error) {
+	manifests := make(map[string][]byte)
+	configMap, err := kubeClient.Core().ConfigMaps(namespace).Get(name, apiv1.GetOptions{})
+	if err != nil && !isResourceNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		for key, data := range configMap.Data {
+			manifests[key] = []byte(data)
+		}
+	}
+	secret, err := kubeClient.Core().Secrets(namespace).Get(name, apiv1.GetOptions{})
+	if err != nil && !isResourceNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		for key, data := range secret.Data {
+			manifests[key] = data
+		}
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no backup %q in namespace %q", name, namespace)
+	}
+	return manifests, nil
+}
+
+func restoreBackup(kubeClient *kubernetes.Clientset, snapshotFolder string, config *appConfig) error {
+	namespaces, err := ioutil.ReadDir(snapshotFolder)
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		if !namespace.IsDir() {
+			continue
+		}
+		err = checkNamespaceFreeze(kubeClient, namespace.Name(), config)
+		if err != nil {
+			return err
+		}
+		files, err := filepath.Glob(filepath.Join(snapshotFolder, namespace.Name(), "*.yml"))
+		if err != nil {
+			return err
+		}
+		for _, filename := range files {
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+			asset, err := parseAsset(filename, data)
+			if err != nil {
+				return err
+			}
+			asset.UpdateNamespace(namespace.Name())
+			err = restoreAsset(kubeClient, asset, namespace.Name(), config)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restoreBackupObjects restores a snapshot saved with -backup-configmap
+func restoreBackupObjects(kubeClient *kubernetes.Clientset, name, namespace string, config *appConfig) error {
+	err := checkNamespaceFreeze(kubeClient, namespace, config)
+	if err != nil {
+		return err
+	}
+	manifests, err := loadBackupObjects(kubeClient, name, namespace)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(manifests))
+	for key := range manifests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		asset, err := parseAsset(key, manifests[key])
+		if err != nil {
+			return err
+		}
+		asset.UpdateNamespace(namespace)
+		err = restoreAsset(kubeClient, asset, namespace, config)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func restoreAsset(kubeClient *kubernetes.Clientset, asset *Asset, namespace string, config *appConfig) error {
+	objectMeta := asset.ResourceData.(Meta)
+	assetName := objectMeta.GetName()
+	Printf(ColorYellow, "Restoring %s %q to namespace %q\n", asset.Kind, assetName, namespace)
+	existed, err := checkResourceExist(kubeClient, nil, asset.Kind, assetName, namespace)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !existed:
+		err = createResource(kubeClient, nil, asset.Kind, assetName, namespace, asset.ResourceData)
+	case asset.Kind == "job":
+		return restoreJob(kubeClient, asset.ResourceData.(*v1batch.Job), namespace, config)
+	case asset.Kind == "persistentvolumeclaim":
+		Println(ColorGray, "====> Existed, persistent volume claims are not updated")
+		return nil
+	default:
+		var resourceVersion string
+		resourceVersion, err = getResourceVersion(kubeClient, nil, asset.Kind, assetName, namespace)
+		if err != nil {
+			return err
+		}
+		objectMeta.SetResourceVersion(resourceVersion)
+		err = updateResource(kubeClient, nil, asset.Kind, assetName, namespace, asset.ResourceData)
+	}
+	if err == nil {
+		Println(ColorGreen, "====> Success")
+	}
+	return err
+}
+
+// restoreJob recreates a job whose template differs from the backup, the
+// template of a job can't be updated
+func restoreJob(kubeClient *kubernetes.Clientset, job *v1batch.Job, namespace string, config *appConfig) error {
+	live, err := kubeClient.Batch().Jobs(namespace).Get(job.Name, apiv1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if !jobTemplateChanged(live, job) {
+		Println(ColorGray, "====> Unchanged")
+		return nil
+	}
+	if !askConfirmation(config, fmt.Sprintf("Job %q differs from the backup, delete and recreate it?", job.Name)) {
+		return fmt.Errorf("restoring job %q was cancelled", job.Name)
+	}
+	err = destroyJob(kubeClient, job.Name, namespace)
+	if err != nil && !isResourceNotExist(err) {
+		return err
+	}
+	err = waitForJobDeletion(kubeClient, job.Name, namespace, config.timeout)
+	if err != nil {
+		return err
+	}
+	job.ResourceVersion = ""
+	err = createResource(kubeClient, nil, "job", job.Name, namespace, job)
+	if err == nil {
+		Println(ColorGreen, "====> Recreated")
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newBackupProject(t *testing.T, config *appConfig, manifest string) (*Project, *fakeCluster, *Asset) {
+	cluster, kubeClient := newFakeCluster(t)
+	asset, err := parseAsset("asset.yml", []byte(manifest))
+	require.Nil(t, err)
+	config.timeout = time.Minute
+	p := &Project{kubeClient: kubeClient, config: config, projectConfig: &ProjectConfig{}, startedAt: time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)}
+	return p, cluster, asset
+}
+
+func TestBackupAndRestoreFolder(t *testing.T) {
+	req := require.New(t)
+	backupDir := t.TempDir()
+	path := "/api/v1/namespaces/web/configmaps/web"
+	p, cluster, asset := newBackupProject(t, &appConfig{backupDir: backupDir}, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: web\ndata:\n  color: green\n")
+	cluster.add(path, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web"},"data":{"color":"blue"}}`)
+	req.Nil(p.backupResource(asset, "web"))
+	// Backed up once per deploy
+	req.Nil(p.backupResource(asset, "web"))
+	req.Len(cluster.requested("GET", path), 2)
+	files, err := filepath.Glob(filepath.Join(backupDir, "20170102-030405", "web", "*.yml"))
+	req.Nil(err)
+	req.Equal([]string{filepath.Join(backupDir, "20170102-030405", "web", "configmap-web.yml")}, files)
+
+	cluster.add(path, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web"},"data":{"color":"green"}}`)
+	req.Nil(restoreBackup(p.kubeClient, filepath.Join(backupDir, "20170102-030405"), &appConfig{timeout: time.Minute}))
+	req.Equal("blue", cluster.get(path)["data"].(map[string]interface{})["color"])
+}
+
+func TestBackupAndRestoreConfigMap(t *testing.T) {
+	req := require.New(t)
+	p, cluster, asset := newBackupProject(t, &appConfig{backupConfigMap: true}, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: web\n  namespace: web\n")
+	path := "/api/v1/namespaces/web/secrets/web"
+	cluster.add(path, `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"web","namespace":"web"},"data":{"password":"aHVudGVyMg=="}}`)
+	req.Nil(p.backupResource(asset, "web"))
+	// Secrets are never copied into a ConfigMap
+	req.Nil(cluster.get("/api/v1/namespaces/web/configmaps/imladris-backup-20170102-030405"))
+	backup := cluster.get("/api/v1/namespaces/web/secrets/imladris-backup-20170102-030405")
+	req.NotNil(backup)
+	req.Equal("true", backup["metadata"].(map[string]interface{})["labels"].(map[string]interface{})[backupLabel])
+
+	cluster.add(path, `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"web","namespace":"web"},"data":{"password":"Y2hhbmdlZA=="}}`)
+	req.Nil(restoreBackupObjects(p.kubeClient, "imladris-backup-20170102-030405", "web", &appConfig{timeout: time.Minute}))
+	req.Equal("aHVudGVyMg==", cluster.get(path)["data"].(map[string]interface{})["password"])
+
+	err := restoreBackupObjects(p.kubeClient, "imladris-backup-20170101-000000", "web", &appConfig{timeout: time.Minute})
+	req.Error(err)
+	req.Contains(err.Error(), "no backup")
+}
+
+func TestRestoreJob(t *testing.T) {
+	req := require.New(t)
+	path := "/apis/batch/v1/namespaces/web/jobs/migrate"
+	p, cluster, asset := newBackupProject(t, &appConfig{}, "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  namespace: web\nspec:\n  template:\n    spec:\n      containers:\n      - name: migrate\n        image: web:1\n      restartPolicy: Never\n")
+	cluster.add(path, `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"migrate","namespace":"web"},"spec":{"template":{"spec":{"containers":[{"name":"migrate","image":"web:1"}],"restartPolicy":"Never"}}}}`)
+	req.Nil(restoreAsset(p.kubeClient, asset, "web", &appConfig{timeout: time.Minute}))
+	req.Empty(cluster.requested("DELETE", path))
+
+	cluster.add(path, `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"migrate","namespace":"web"},"spec":{"template":{"spec":{"containers":[{"name":"migrate","image":"web:2"}],"restartPolicy":"Never"}}}}`)
+	err := restoreAsset(p.kubeClient, asset, "web", &appConfig{timeout: time.Minute, nonInteractive: true})
+	req.Error(err)
+	req.Equal("web:2", jobImage(cluster.get(path)))
+	req.Nil(restoreAsset(p.kubeClient, asset, "web", &appConfig{timeout: time.Minute, yes: true}))
+	req.Equal("web:1", jobImage(cluster.get(path)))
+}
@@ -0,0 +1,91 @@
+package deploy
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// resourceCache lists each kind once per namespace and serves lookups from
+// the listing, so large projects don't pay one GET per resource
+type resourceCache struct {
+	kubeClient *kubernetes.Clientset
+	plugins    pluginRegistry
+	printer    *printer
+	namespace  string
+	kinds      map[string]map[string]interface{}
+	stale      map[string]bool
+	unlistable map[string]bool
+}
+
+func newResourceCache(kubeClient *kubernetes.Clientset, plugins pluginRegistry, out *printer, namespace string) *resourceCache {
+	return &resourceCache{
+		kubeClient: kubeClient,
+		plugins:    plugins,
+		printer:    out,
+		namespace:  namespace,
+		kinds:      make(map[string]map[string]interface{}),
+		stale:      make(map[string]bool),
+		unlistable: make(map[string]bool),
+	}
+}
+
+func (c *resourceCache) get(kind, name string) (interface{}, bool, error) {
+	if !c.stale[kind+"/"+name] && !c.unlistable[kind] {
+		resources, err := c.list(kind)
+		if err == nil {
+			resource, ok := resources[name]
+			return resource, ok, nil
+		}
+		// Plugin kinds and kinds we may get but not list are read one by
+		// one, any other failure would fail the GET as well
+		switch err.(type) {
+		case UnsupportedResource:
+		default:
+			if errors.IsNotFound(err) {
+				return nil, false, nil
+			}
+			if !errors.IsForbidden(err) {
+				return nil, false, err
+			}
+			c.printer.Printf(ColorGray, "Cannot list %s in namespace %q, reading them one by one\n", kind, c.namespace)
+		}
+		c.unlistable[kind] = true
+	}
+	resource, err := getResource(c.kubeClient, c.plugins, kind, name, c.namespace)
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return resource, true, nil
+}
+
+func (c *resourceCache) list(kind string) (map[string]interface{}, error) {
+	resources, ok := c.kinds[kind]
+	if ok {
+		return resources, nil
+	}
+	list, err := listResources(c.kubeClient, kind, c.namespace, "")
+	if err != nil {
+		return nil, err
+	}
+	resources = make(map[string]interface{})
+	for _, resource := range list {
+		resources[resource.(Meta).GetName()] = resource
+	}
+	c.kinds[kind] = resources
+	return resources, nil
+}
+
+func (c *resourceCache) invalidate(kind, name string) {
+	c.stale[kind+"/"+name] = true
+}
+
+func (c *resourceCache) exists(kind, name string) (bool, error) {
+	resource, found, err := c.get(kind, name)
+	if err != nil || !found {
+		return false, err
+	}
+	return resourceExists(resource)
+}
@@ -0,0 +1,61 @@
+package deploy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceCache(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/api/v1/namespaces/web/configmaps/a", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"web"}}`)
+	cluster.add("/api/v1/namespaces/web/configmaps/b", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"b","namespace":"web"}}`)
+	cache := newResourceCache(kubeClient, nil, nil, "web")
+	for _, name := range []string{"a", "b", "c"} {
+		_, found, err := cache.get("configmap", name)
+		req.Nil(err)
+		req.Equal(name != "c", found)
+	}
+	req.Equal([]string{"GET /api/v1/namespaces/web/configmaps"}, cluster.requested("GET", "/api/v1/namespaces/web/configmaps"))
+
+	// What this run changed is read again
+	cache.invalidate("configmap", "a")
+	_, found, err := cache.get("configmap", "a")
+	req.Nil(err)
+	req.True(found)
+	req.Len(cluster.requested("GET", "/api/v1/namespaces/web/configmaps/a"), 1)
+}
+
+func TestResourceCacheListFailures(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/api/v1/namespaces/web/secrets/a", `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"a","namespace":"web"}}`)
+	cluster.reject = func(method, path string) *fakeStatus {
+		switch {
+		case method == "GET" && path == "/api/v1/namespaces/web/secrets":
+			return &fakeStatus{http.StatusForbidden, "Forbidden", "secrets is forbidden: cannot list"}
+		case method == "GET" && strings.HasPrefix(path, "/api/v1/namespaces/web/services"):
+			return &fakeStatus{http.StatusInternalServerError, "InternalError", "etcd is down"}
+		}
+		return nil
+	}
+	cache := newResourceCache(kubeClient, nil, nil, "web")
+
+	// Allowed to get but not to list, the list is tried once
+	for _, name := range []string{"a", "b"} {
+		_, found, err := cache.get("secret", name)
+		req.Nil(err)
+		req.Equal(name == "a", found)
+	}
+	req.Len(cluster.requested("GET", "/api/v1/namespaces/web/secrets"), 3)
+	req.Len(cluster.requested("GET", "/api/v1/namespaces/web/secrets/"), 2)
+
+	// Any other failure is reported, not hidden behind a GET
+	_, _, err := cache.get("service", "web")
+	req.Error(err)
+	req.Contains(err.Error(), "etcd is down")
+	req.Empty(cluster.requested("GET", "/api/v1/namespaces/web/services/"))
+}
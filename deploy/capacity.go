@@ -0,0 +1,149 @@
+package deploy
+
+import (
+	"sort"
+
+	app "k8s.io/api/apps/v1beta1"
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podRequests is what the scheduler reserves for a pod: the sum of its
+// containers, or the largest init container when that is bigger
+func podRequests(podSpec *v1.PodSpec) v1.ResourceList {
+	total := v1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		addResources(total, container.Resources.Requests, 1)
+	}
+	for _, container := range podSpec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			current, ok := total[name]
+			if !ok || quantity.Cmp(current) > 0 {
+				total[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	return total
+}
+
+func addResources(total, requests v1.ResourceList, times int64) {
+	for name, quantity := range requests {
+		current, ok := total[name]
+		if !ok {
+			current = resource.Quantity{Format: quantity.Format}
+		}
+		for i := int64(0); i < times; i++ {
+			current.Add(quantity)
+		}
+		total[name] = current
+	}
+}
+
+// workloadReplicas is how many pods an asset runs at once. Daemon sets run
+// one per node.
+func workloadReplicas(asset *Asset, nodes int64) int64 {
+	replicas := func(value *int32) int64 {
+		if value == nil {
+			return 1
+		}
+		return int64(*value)
+	}
+	switch resource := asset.ResourceData.(type) {
+	case *v1beta1.Deployment:
+		return replicas(resource.Spec.Replicas)
+	case *app.StatefulSet:
+		return replicas(resource.Spec.Replicas)
+	case *v1batch.Job:
+		return replicas(resource.Spec.Parallelism)
+	case *v1beta1.DaemonSet:
+		return nodes
+	default:
+		return 1
+	}
+}
+
+type capacityTarget struct {
+	context   string
+	namespace string
+}
+
+// capacityPreflight warns when the project requests more cpu or memory than
+// the namespace quota or all schedulable nodes together could ever provide.
+// It only warns, usage changes while the rollout replaces old pods.
+func (p *Project) capacityPreflight() {
+	nodeCounts := make(map[string]int64)
+	allocatable := make(map[string]v1.ResourceList)
+	requests := make(map[capacityTarget]v1.ResourceList)
+	for _, asset := range p.assets() {
+		podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+		if podSpec == nil {
+			continue
+		}
+		if _, ok := allocatable[asset.context]; !ok {
+			allocatable[asset.context] = v1.ResourceList{}
+			nodes, err := p.clientFor(asset).Core().Nodes().List(apiv1.ListOptions{})
+			if err == nil {
+				for _, node := range nodes.Items {
+					if node.Spec.Unschedulable {
+						continue
+					}
+					nodeCounts[asset.context]++
+					addResources(allocatable[asset.context], node.Status.Allocatable, 1)
+				}
+			}
+		}
+		target := capacityTarget{asset.context, asset.Namespace()}
+		if requests[target] == nil {
+			requests[target] = v1.ResourceList{}
+		}
+		addResources(requests[target], podRequests(podSpec), workloadReplicas(asset, nodeCounts[asset.context]))
+	}
+	targets := []capacityTarget{}
+	for target := range requests {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].context+"/"+targets[i].namespace < targets[j].context+"/"+targets[j].namespace
+	})
+	for _, target := range targets {
+		requested := requests[target]
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			quantity, ok := requested[name]
+			if !ok {
+				continue
+			}
+			available, ok := allocatable[target.context][name]
+			if ok && quantity.Cmp(available) > 0 {
+				p.printer.ErrPrintf(ColorYellow, "Warning: project requests %s %s but schedulable nodes only have %s allocatable\n", quantity.String(), name, available.String())
+			}
+		}
+		kubeClient := p.kubeClient
+		if client, ok := p.targetClients[target.context]; ok {
+			kubeClient = client
+		}
+		quotas, err := kubeClient.Core().ResourceQuotas(target.namespace).List(apiv1.ListOptions{})
+		if err != nil {
+			continue
+		}
+		for _, quota := range quotas.Items {
+			for _, check := range []struct{ requested, hard v1.ResourceName }{
+				{v1.ResourceCPU, v1.ResourceRequestsCPU},
+				{v1.ResourceMemory, v1.ResourceRequestsMemory},
+				{v1.ResourceCPU, v1.ResourceCPU},
+				{v1.ResourceMemory, v1.ResourceMemory},
+			} {
+				quantity, ok := requested[check.requested]
+				hard, hasLimit := quota.Spec.Hard[check.hard]
+				if !ok || !hasLimit {
+					continue
+				}
+				if quantity.Cmp(hard) > 0 {
+					p.printer.ErrPrintf(ColorYellow, "Warning: project requests %s %s but quota %s/%s allows %s in total\n", quantity.String(), check.requested, target.namespace, quota.Name, hard.String())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPodRequests(t *testing.T) {
+	req := require.New(t)
+	podSpec := &v1.PodSpec{
+		InitContainers: []v1.Container{{
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceMemory: resource.MustParse("1Gi"),
+			}},
+		}},
+		Containers: []v1.Container{
+			{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("250m"),
+				v1.ResourceMemory: resource.MustParse("256Mi"),
+			}}},
+			{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU: resource.MustParse("500m"),
+			}}},
+		},
+	}
+	requests := podRequests(podSpec)
+	cpu := requests[v1.ResourceCPU]
+	memory := requests[v1.ResourceMemory]
+	req.Equal("750m", cpu.String())
+	req.Equal("1Gi", memory.String())
+
+	replicas := int32(3)
+	deployment := &Asset{Kind: "deployment", ResourceData: &v1beta1.Deployment{Spec: v1beta1.DeploymentSpec{Replicas: &replicas}}}
+	req.Equal(int64(3), workloadReplicas(deployment, 5))
+	daemonSet := &Asset{Kind: "daemonset", ResourceData: &v1beta1.DaemonSet{}}
+	req.Equal(int64(5), workloadReplicas(daemonSet, 5))
+	total := v1.ResourceList{}
+	addResources(total, requests, workloadReplicas(deployment, 5))
+	cpu = total[v1.ResourceCPU]
+	req.Equal("2250m", cpu.String())
+}
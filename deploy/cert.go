@@ -0,0 +1,239 @@
+package deploy
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CertificateConfig provisions the kubernetes.io/tls secret an ingress or
+// service refers to. Without a CA the certificate is self-signed; with an
+// issuer the ingresses using the secret are annotated for cert-manager and
+// no secret is generated.
+type CertificateConfig struct {
+	Name          string   `yaml:"name"`
+	Hosts         []string `yaml:"hosts"`
+	Days          int      `yaml:"days"`
+	CACert        string   `yaml:"ca_cert"`
+	CAKey         string   `yaml:"ca_key"`
+	Issuer        string   `yaml:"issuer"`
+	ClusterIssuer string   `yaml:"cluster_issuer"`
+}
+
+// certificateRenewBefore is how long before expiry an existing certificate
+// is replaced instead of reused
+const certificateRenewBefore = 30 * 24 * time.Hour
+
+func (p *Project) generateCertificates() ([]*Asset, error) {
+	assets := []*Asset{}
+	for _, certificate := range p.projectConfig.Certificates {
+		if certificate.Name == "" {
+			return nil, fmt.Errorf("certificate without a secret name")
+		}
+		if certificate.Issuer != "" || certificate.ClusterIssuer != "" {
+			err := p.requestCertificate(certificate)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if len(certificate.Hosts) == 0 {
+			return nil, fmt.Errorf("certificate %q has no hosts", certificate.Name)
+		}
+		secret, err := p.provisionCertificate(certificate)
+		if err != nil {
+			return nil, fmt.Errorf("unable to provision certificate %q: %s", certificate.Name, err.Error())
+		}
+		assets = append(assets, &Asset{Kind: "secret", ResourceData: secret})
+	}
+	return assets, nil
+}
+
+// requestCertificate leaves issuing to cert-manager's ingress shim, which
+// watches ingresses carrying an issuer annotation and fills their tls secrets
+func (p *Project) requestCertificate(certificate *CertificateConfig) error {
+	annotation, issuer := "certmanager.k8s.io/issuer", certificate.Issuer
+	if certificate.ClusterIssuer != "" {
+		annotation, issuer = "certmanager.k8s.io/cluster-issuer", certificate.ClusterIssuer
+	}
+	found := false
+	for _, asset := range append(p.resources, p.services...) {
+		ingress, ok := asset.ResourceData.(*v1beta1.Ingress)
+		if !ok {
+			continue
+		}
+		for _, ingressTLS := range ingress.Spec.TLS {
+			if ingressTLS.SecretName != certificate.Name {
+				continue
+			}
+			if ingress.Annotations == nil {
+				ingress.Annotations = make(map[string]string)
+			}
+			ingress.Annotations[annotation] = issuer
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("certificate %q: no ingress uses it as a tls secret", certificate.Name)
+	}
+	return nil
+}
+
+func (p *Project) provisionCertificate(certificate *CertificateConfig) (*v1.Secret, error) {
+	var caCert *x509.Certificate
+	var caKey interface{}
+	var caPEM []byte
+	if certificate.CACert != "" {
+		keyPair, err := tls.LoadX509KeyPair(translateFilePath(p.projectConfig.RootFolder, certificate.CACert), translateFilePath(p.projectConfig.RootFolder, certificate.CAKey))
+		if err != nil {
+			return nil, err
+		}
+		caCert, err = x509.ParseCertificate(keyPair.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+		caKey = keyPair.PrivateKey
+		caPEM, err = ioutil.ReadFile(translateFilePath(p.projectConfig.RootFolder, certificate.CACert))
+		if err != nil {
+			return nil, err
+		}
+	}
+	secret := &v1.Secret{
+		ObjectMeta: apiv1.ObjectMeta{
+			Name: certificate.Name,
+		},
+		Type: v1.SecretTypeTLS,
+	}
+	// Keep the live certificate while it is still good, otherwise every deploy
+	// would rotate it and break clients pinning it
+	if p.kubeClient != nil {
+		live, err := p.kubeClient.Core().Secrets(p.projectConfig.Namespace).Get(certificate.Name, apiv1.GetOptions{})
+		if err == nil && certificateUsable(live.Data[v1.TLSCertKey], certificate.Hosts, caCert) {
+			secret.Data = live.Data
+			return secret, nil
+		}
+	}
+	certPEM, keyPEM, err := issuedCertificates.issue(certificate, caCert, caKey)
+	if err != nil {
+		return nil, err
+	}
+	secret.Data = map[string][]byte{
+		v1.TLSCertKey:       certPEM,
+		v1.TLSPrivateKeyKey: keyPEM,
+	}
+	if caPEM != nil {
+		secret.Data["ca.crt"] = caPEM
+	}
+	return secret, nil
+}
+
+// issuedCertificates keeps what this process issued, so loading a project
+// again without a cluster to read the live secret from, as lint, render,
+// watch and the server do, neither pays for a new key nor renders a different
+// certificate each time
+var issuedCertificates = &certificateCache{certificates: make(map[string][2][]byte)}
+
+type certificateCache struct {
+	sync.Mutex
+	certificates map[string][2][]byte
+}
+
+func (c *certificateCache) issue(certificate *CertificateConfig, ca *x509.Certificate, caKey interface{}) ([]byte, []byte, error) {
+	key := fmt.Sprintf("%s\x00%s\x00%d", certificate.Name, strings.Join(certificate.Hosts, ","), certificate.Days)
+	if ca != nil {
+		key += "\x00" + string(ca.Raw)
+	}
+	c.Lock()
+	defer c.Unlock()
+	issued, ok := c.certificates[key]
+	if ok && certificateUsable(issued[0], certificate.Hosts, ca) {
+		return issued[0], issued[1], nil
+	}
+	certPEM, keyPEM, err := issueCertificate(certificate.Hosts, certificate.Days, ca, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.certificates[key] = [2][]byte{certPEM, keyPEM}
+	return certPEM, keyPEM, nil
+}
+
+// issueCertificate creates a certificate for hosts signed by ca, or
+// self-signed when ca is nil
+func issueCertificate(hosts []string, days int, ca *x509.Certificate, caKey interface{}) ([]byte, []byte, error) {
+	if days <= 0 {
+		days = 365
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Duration(days) * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	parent, signer := template, interface{}(key)
+	if ca != nil {
+		parent, signer = ca, caKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return certPEM, keyPEM, nil
+}
+
+func certificateUsable(certPEM []byte, hosts []string, ca *x509.Certificate) bool {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+	if time.Now().Add(certificateRenewBefore).After(cert.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if cert.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	if ca != nil {
+		return cert.CheckSignatureFrom(ca) == nil
+	}
+	return true
+}
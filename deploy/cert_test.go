@@ -0,0 +1,65 @@
+package deploy
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueCertificate(t *testing.T) {
+	req := require.New(t)
+	certPEM, keyPEM, err := issueCertificate([]string{"app.example.com", "10.0.0.1"}, 90, nil, nil)
+	req.NoError(err)
+	_, err = tls.X509KeyPair(certPEM, keyPEM)
+	req.NoError(err)
+	req.True(certificateUsable(certPEM, []string{"app.example.com", "10.0.0.1"}, nil))
+	req.False(certificateUsable(certPEM, []string{"other.example.com"}, nil))
+
+	// Too close to expiry to be reused
+	certPEM, _, err = issueCertificate([]string{"app.example.com"}, 7, nil, nil)
+	req.NoError(err)
+	req.False(certificateUsable(certPEM, []string{"app.example.com"}, nil))
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	req.NoError(err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	req.NoError(err)
+	ca, err := x509.ParseCertificate(der)
+	req.NoError(err)
+	certPEM, _, err = issueCertificate([]string{"app.example.com"}, 90, ca, caKey)
+	req.NoError(err)
+	req.True(certificateUsable(certPEM, []string{"app.example.com"}, ca))
+	selfSigned, _, err := issueCertificate([]string{"app.example.com"}, 90, nil, nil)
+	req.NoError(err)
+	req.False(certificateUsable(selfSigned, []string{"app.example.com"}, ca))
+}
+
+func TestProvisionCertificateWithoutCluster(t *testing.T) {
+	req := require.New(t)
+	provision := func(hosts ...string) map[string][]byte {
+		p := &Project{projectConfig: &ProjectConfig{Namespace: "web"}}
+		secret, err := p.provisionCertificate(&CertificateConfig{Name: "web-tls", Hosts: hosts})
+		req.NoError(err)
+		return secret.Data
+	}
+	// Loading the project again renders the same certificate
+	first := provision("cache.example.com")
+	req.Equal(first, provision("cache.example.com"))
+	req.NotEqual(first, provision("cache.example.com", "www.cache.example.com"))
+}
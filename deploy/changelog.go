@@ -0,0 +1,101 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ChangelogConfig publishes the changelog of each release, appended to File
+// and posted to Webhooks, which take slack compatible payloads
+type ChangelogConfig struct {
+	File     string   `yaml:"file"`
+	Webhooks []string `yaml:"webhooks"`
+}
+
+type changelogPayload struct {
+	Text    string   `json:"text"`
+	Project string   `json:"project"`
+	Release string   `json:"release"`
+	Changes []string `json:"changes"`
+}
+
+// buildChangelog compares a release with the previous record: the images that
+// changed, then the resources added, changed and removed. Records written
+// before checksums were recorded only compare images.
+func buildChangelog(previous, current *ReleaseRecord) []string {
+	if previous == nil {
+		return []string{"first recorded release"}
+	}
+	changes := []string{}
+	for _, key := range sortedKeys(current.Images) {
+		before, ok := previous.Images[key]
+		if ok && before != current.Images[key] {
+			changes = append(changes, fmt.Sprintf("image of %s: %s -> %s", key, before, current.Images[key]))
+		}
+	}
+	if previous.Checksums == nil {
+		return changes
+	}
+	for _, key := range sortedKeys(current.Checksums) {
+		before, ok := previous.Checksums[key]
+		switch {
+		case !ok:
+			changes = append(changes, "added "+key)
+		case before != current.Checksums[key]:
+			changes = append(changes, "changed "+key)
+		}
+	}
+	for _, key := range sortedKeys(previous.Checksums) {
+		if _, ok := current.Checksums[key]; !ok {
+			changes = append(changes, "removed "+key)
+		}
+	}
+	return changes
+}
+
+func (p *Project) releaseChecksums() map[string]string {
+	checksums := make(map[string]string)
+	for _, asset := range p.assets() {
+		checksums[assetKey(asset)] = asset.Checksum()
+	}
+	return checksums
+}
+
+// publishChangelog prints the changelog of this release and hands it to the
+// channels of the project file. The deploy already happened, failures only
+// warn.
+func (p *Project) publishChangelog(changes []string) {
+	title := fmt.Sprintf("Release %s of %q to %q by %s", p.releaseID(), p.projectConfig.Name, p.projectConfig.Namespace, deployActor())
+	if len(changes) == 0 {
+		changes = []string{"no changes"}
+	}
+	text := title + "\n- " + strings.Join(changes, "\n- ") + "\n"
+	p.printer.Printf(ColorBlue, "%s", text)
+	changelog := p.projectConfig.Changelog
+	if changelog == nil {
+		return
+	}
+	if changelog.File != "" {
+		filename := translateFilePath(p.projectConfig.RootFolder, changelog.File)
+		file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.FileMode(0600))
+		if err == nil {
+			_, err = file.WriteString(text + "\n")
+			file.Close()
+		}
+		if err != nil {
+			p.printer.ErrPrintf(ColorRed, "Cannot write changelog to %q: %s\n", filename, err.Error())
+		}
+	}
+	for _, webhook := range changelog.Webhooks {
+		err := doJSONRequest("POST", webhook, nil, &changelogPayload{
+			Text:    text,
+			Project: p.projectConfig.Name,
+			Release: p.releaseID(),
+			Changes: changes,
+		}, nil)
+		if err != nil {
+			p.printer.ErrPrintf(ColorRed, "Cannot post changelog: %s\n", err.Error())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildChangelog(t *testing.T) {
+	req := require.New(t)
+	current := &ReleaseRecord{
+		Images:    map[string]string{"deployment/web/web": "web:1.1", "deployment/web/proxy": "nginx:1", "deployment/api/api": "api:2"},
+		Checksums: map[string]string{"deployment/web": "b", "deployment/api": "c", "configmap/web": "d"},
+	}
+	req.Equal([]string{"first recorded release"}, buildChangelog(nil, current))
+
+	previous := &ReleaseRecord{
+		Images:    map[string]string{"deployment/web/web": "web:1.0", "deployment/web/proxy": "nginx:1"},
+		Checksums: map[string]string{"deployment/web": "a", "configmap/web": "d", "service/old": "e"},
+	}
+	req.Equal([]string{
+		"image of deployment/web/web: web:1.0 -> web:1.1",
+		"added deployment/api",
+		"changed deployment/web",
+		"removed service/old",
+	}, buildChangelog(previous, current))
+
+	previous.Checksums = nil
+	req.Equal([]string{"image of deployment/web/web: web:1.0 -> web:1.1"}, buildChangelog(previous, current))
+}
+
+func TestRecordReleasePublishesOnce(t *testing.T) {
+	req := require.New(t)
+	var posts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	_, kubeClient := newFakeCluster(t)
+	out := &bytes.Buffer{}
+	p := &Project{
+		kubeClient: kubeClient,
+		config:     &appConfig{},
+		printer:    newPrinter(out, out),
+		projectConfig: &ProjectConfig{
+			Name:       "web",
+			Namespace:  "web",
+			RootFolder: t.TempDir(),
+			Changelog:  &ChangelogConfig{File: "CHANGELOG", Webhooks: []string{server.URL}},
+		},
+	}
+
+	asset, err := parseAsset("web.yml", []byte("kind: ConfigMap\napiVersion: v1\nmetadata:\n  name: web\ndata:\n  key: value\n"))
+	req.NoError(err)
+	p.resources = []*Asset{asset}
+
+	// Watch mode re-applies unchanged manifests, only the first is a release
+	p.recordRelease("update")
+	p.recordRelease("update")
+	req.Equal(int32(1), atomic.LoadInt32(&posts))
+	req.Contains(out.String(), "nothing recorded")
+	records, err := (&configMapHistory{kubeClient: kubeClient, namespace: "web"}).read("web")
+	req.NoError(err)
+	req.Len(records, 1)
+
+	info, err := os.Stat(filepath.Join(p.projectConfig.RootFolder, "CHANGELOG"))
+	req.NoError(err)
+	req.Equal(os.FileMode(0600), info.Mode().Perm())
+}
@@ -0,0 +1,97 @@
+package deploy
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// ciEnvironment holds the build metadata of the CI system we run in
+type ciEnvironment struct {
+	sha         string
+	branch      string
+	tag         string
+	buildNumber string
+	pipelineURL string
+}
+
+// ciEnvVars lists, per CI system, the variables holding sha, branch, tag and
+// build number, in that order
+var ciEnvVars = [][4]string{
+	{"GITHUB_SHA", "GITHUB_HEAD_REF", "", "GITHUB_RUN_NUMBER"},
+	{"CI_COMMIT_SHA", "CI_COMMIT_BRANCH", "CI_COMMIT_TAG", "CI_PIPELINE_IID"},
+	{"CIRCLE_SHA1", "CIRCLE_BRANCH", "CIRCLE_TAG", "CIRCLE_BUILD_NUM"},
+	{"TRAVIS_COMMIT", "TRAVIS_BRANCH", "TRAVIS_TAG", "TRAVIS_BUILD_NUMBER"},
+	{"BUILDKITE_COMMIT", "BUILDKITE_BRANCH", "BUILDKITE_TAG", "BUILDKITE_BUILD_NUMBER"},
+	{"GIT_COMMIT", "GIT_BRANCH", "TAG_NAME", "BUILD_NUMBER"},
+}
+
+func detectCIEnvironment(rootFolder string) *ciEnvironment {
+	ci := &ciEnvironment{}
+	for _, vars := range ciEnvVars {
+		if os.Getenv(vars[0]) == "" {
+			continue
+		}
+		ci.sha = os.Getenv(vars[0])
+		ci.branch = os.Getenv(vars[1])
+		if vars[2] != "" {
+			ci.tag = os.Getenv(vars[2])
+		}
+		ci.buildNumber = os.Getenv(vars[3])
+		break
+	}
+	ci.pipelineURL = detectPipelineURL()
+	// GitHub only exposes the branch of pull requests directly
+	if ref := os.Getenv("GITHUB_REF"); ref != "" && ci.branch == "" && ci.tag == "" {
+		if strings.HasPrefix(ref, "refs/tags/") {
+			ci.tag = strings.TrimPrefix(ref, "refs/tags/")
+		} else {
+			ci.branch = strings.TrimPrefix(ref, "refs/heads/")
+		}
+	}
+	// Outside CI, ask git for whatever is missing
+	if ci.sha == "" || ci.tag == "" {
+		sha, branch, tag := gitHead(rootFolder)
+		if ci.sha == "" {
+			ci.sha = sha
+		}
+		if ci.branch == "" && ci.tag == "" {
+			ci.branch = branch
+		}
+		if ci.tag == "" {
+			ci.tag = tag
+		}
+	}
+	ci.branch = strings.TrimPrefix(ci.branch, "origin/")
+	return ci
+}
+
+// detectPipelineURL links to the CI run we're part of, GitHub has to be
+// pieced together
+func detectPipelineURL() string {
+	if os.Getenv("GITHUB_RUN_ID") != "" {
+		return os.Getenv("GITHUB_SERVER_URL") + "/" + os.Getenv("GITHUB_REPOSITORY") + "/actions/runs/" + os.Getenv("GITHUB_RUN_ID")
+	}
+	for _, name := range []string{"CI_PIPELINE_URL", "CIRCLE_BUILD_URL", "TRAVIS_BUILD_WEB_URL", "BUILDKITE_BUILD_URL", "BUILD_URL"} {
+		if os.Getenv(name) != "" {
+			return os.Getenv(name)
+		}
+	}
+	return ""
+}
+
+func (p *Project) setCIVariables() {
+	ci := detectCIEnvironment(p.projectConfig.RootFolder)
+	p.ci = ci
+	shortSHA := ci.sha
+	if len(shortSHA) > 7 {
+		shortSHA = shortSHA[:7]
+	}
+	p.projectConfig.Variables["app_var_git_sha"] = ci.sha
+	p.projectConfig.Variables["app_var_git_short_sha"] = shortSHA
+	p.projectConfig.Variables["app_var_git_branch"] = ci.branch
+	p.projectConfig.Variables["app_var_git_tag"] = ci.tag
+	p.projectConfig.Variables["app_var_build_number"] = ci.buildNumber
+	p.projectConfig.Variables["app_var_timestamp"] = p.startedAt.UTC().Format(time.RFC3339)
+	p.projectConfig.Variables["app_var_release"] = p.releaseID()
+}
@@ -0,0 +1,292 @@
+package deploy
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+type appConfig struct {
+	configFile       string
+	context          string
+	namespace        string
+	timeout          time.Duration
+	variables        variableMap
+	valueFiles       fileList
+	secrets          variableMap
+	onConflict       string
+	forceRecreate    bool
+	forceFinalize    bool
+	deletePVCs       bool
+	deleteNamespace  bool
+	selector         string
+	backupDir        string
+	backupConfigMap  bool
+	fromContext      string
+	promoteFrom      string
+	promoteTo        string
+	watch            bool
+	watchInterval    time.Duration
+	gitRef           string
+	syncInterval     time.Duration
+	metricsAddr      string
+	pushgateway      string
+	events           bool
+	output           string
+	keepGoing        bool
+	resume           bool
+	waitRollout      bool
+	skipUnchanged    bool
+	qps              float64
+	burst            int
+	strict           bool
+	terraformOutputs string
+	checkURLs        bool
+	preserveReplicas bool
+	only             string
+	skip             string
+	group            string
+	nonInteractive   bool
+	yes              bool
+	listen           string
+	dashboard        bool
+	noColor          bool
+	strictVersion    bool
+	skipPreflight    bool
+	offline          bool
+	overrideWindow   string
+	overrideFreeze   string
+	reviewNamespace  string
+	ttl              time.Duration
+	reviewCleanup    bool
+	notifyURL        string
+	notifyBefore     time.Duration
+	usageReport      bool
+	forensics        string
+	templateLint     bool
+	baseRef          string
+	parallel         int
+	// printer is what the projects loaded with this config print with, the
+	// process stdout and stderr when nil
+	printer *printer
+	// sleep waits out deploy windows, approvals, retries and canary pauses,
+	// time.Sleep when nil so tests can skip the waits
+	sleep func(d time.Duration)
+	// optionalImports lets commands that only read or remove the project
+	// run before the projects it imports from have published outputs
+	optionalImports bool
+}
+
+type variableMap map[string]string
+
+func (v *variableMap) String() string {
+	return fmt.Sprint(*v)
+}
+
+func (v *variableMap) Set(value string) error {
+	pieces := strings.SplitN(value, "=", 2)
+	if len(pieces) != 2 {
+		return nil
+	}
+	(*v)[pieces[0]] = pieces[1]
+	return nil
+}
+
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileList) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Main runs the imladris command line
+func Main() {
+	// check docker command
+	err := checkDockerCommand()
+	if err != nil {
+		ErrPrintln(ColorRed, "docker command not found, please install docker command line")
+		os.Exit(1)
+	}
+	config := &appConfig{
+		variables: make(variableMap),
+		secrets:   make(variableMap),
+	}
+	flag.StringVar(&config.configFile, "kubeconfig", "", "Kube config file (defaults to the merged $KUBECONFIG path list, then ~/.kube/config)")
+	flag.StringVar(&config.context, "context", "", "Kube context")
+	flag.StringVar(&config.namespace, "namespace", "", "Kube namespace")
+	flag.DurationVar(&config.timeout, "timeout", 15*time.Minute, "timeout duration")
+	flag.Var(&config.variables, "variable", "override variables")
+	flag.Var(&config.valueFiles, "values", "read variables from a yaml file, can be repeated, -variable wins over it")
+	flag.Var(&config.secrets, "set-secret", "set a template variable whose value is scrubbed from all output, as key=value")
+	flag.StringVar(&config.onConflict, "on-conflict", "abort", "what to do when a resource changed during update: abort or retry")
+	flag.StringVar(&config.backupDir, "backup-dir", "", "save live resources to this folder before updating or deleting them")
+	flag.BoolVar(&config.backupConfigMap, "backup-configmap", false, "save live resources before updating or deleting them to an imladris-backup-<time> ConfigMap (Secret for secrets) in their namespace, restore with restore configmap/imladris-backup-<time>")
+	flag.StringVar(&config.fromContext, "from-context", "", "kube context of the source environment when promoting")
+	flag.StringVar(&config.promoteFrom, "from", "", "project folder of the source environment when promoting")
+	flag.StringVar(&config.promoteTo, "to", "", "project folder of the target environment when promoting")
+	flag.BoolVar(&config.watch, "watch", false, "keep running and re-apply on manifest changes or cluster drift (update only)")
+	flag.DurationVar(&config.watchInterval, "watch-interval", 5*time.Second, "how often to check the cluster for drift in watch mode, manifest changes are picked up as they happen")
+	flag.StringVar(&config.gitRef, "git-ref", "origin/master", "git ref to reconcile in serve mode")
+	flag.IntVar(&config.parallel, "parallel", 1, "in deploy-all, how many projects that do not depend on each other are deployed at once, their output prefixed with their name and without prompts")
+	flag.StringVar(&config.baseRef, "base-ref", "", "in deploy-all, only deploy the projects changed since this git ref and the projects importing from them")
+	flag.DurationVar(&config.syncInterval, "sync-interval", time.Minute, "how often to pull and reconcile in serve mode, and to look for expired review environments in server mode")
+	flag.StringVar(&config.listen, "listen", ":8080", "address the deploy api listens on in server mode")
+	flag.StringVar(&config.metricsAddr, "metrics-addr", "", "serve prometheus metrics on this address, e.g. :9102")
+	flag.StringVar(&config.pushgateway, "pushgateway", "", "push deploy metrics to this prometheus pushgateway url")
+	flag.BoolVar(&config.events, "events", false, "post kubernetes events for deploy start, success and failure")
+	flag.StringVar(&config.output, "output", "text", "output format for errors: text or json")
+	flag.BoolVar(&config.keepGoing, "keep-going", false, "keep applying the remaining resources when one fails and report all failures")
+	flag.BoolVar(&config.resume, "resume", false, "skip resources that were applied successfully by the previous failed run")
+	flag.BoolVar(&config.waitRollout, "wait", false, "after applying, wait for workloads to be ready and fail as soon as a new pod crashes")
+	flag.BoolVar(&config.skipUnchanged, "skip-unchanged", false, "skip updating resources whose checksum annotation matches the manifest")
+	flag.Float64Var(&config.qps, "qps", 0, "maximum requests per second to the API server (0 uses the client default of 5)")
+	flag.IntVar(&config.burst, "burst", 0, "maximum burst of requests to the API server (0 uses the client default of 10)")
+	flag.BoolVar(&config.strict, "strict", false, "reject manifest fields that are unknown to the resource type")
+	flag.StringVar(&config.terraformOutputs, "terraform-outputs", "", "file written by terraform output -json, exposed as tf_<name> template variables")
+	flag.BoolVar(&config.checkURLs, "check-urls", false, "after deploying, poll ingress urls until they respond and report the time to available")
+	flag.BoolVar(&config.preserveReplicas, "preserve-replicas", false, "keep the live replica count of deployments scaled by a horizontal pod autoscaler")
+	flag.BoolVar(&config.usageReport, "usage-report", false, "after deploying, compare the cpu and memory usage of the pods with their requests and limits")
+	flag.StringVar(&config.forensics, "forensics", "", "when a deploy fails, write its resources, pod status, events and logs to this directory, or tarball when it ends in .tar.gz")
+	flag.StringVar(&config.only, "only", "", "only handle these resources, as comma separated kind/name patterns, e.g. deployment/web,cm/web-*")
+	flag.StringVar(&config.skip, "skip", "", "skip these resources, as comma separated kind/name patterns, e.g. job/*")
+	flag.StringVar(&config.group, "group", "", "only handle resources of these comma separated groups from the project file")
+	flag.StringVar(&config.selector, "selector", "", "label selector used instead of a project folder")
+	flag.BoolVar(&config.forceRecreate, "force-recreate", false, "delete and recreate resources whose immutable fields changed during update")
+	flag.BoolVar(&config.deletePVCs, "delete-pvcs", false, "teardown also deletes the persistent volume claims of the namespace")
+	flag.BoolVar(&config.deleteNamespace, "delete-namespace", false, "teardown also deletes the namespace")
+	flag.BoolVar(&config.forceFinalize, "force-finalize", false, "after confirmation, remove the finalizers of resources stuck terminating")
+	flag.BoolVar(&config.nonInteractive, "non-interactive", os.Getenv("IMLADRIS_NON_INTERACTIVE") == "1", "never prompt and disable colors, for workflow engines such as argo or tekton (also IMLADRIS_NON_INTERACTIVE=1)")
+	flag.BoolVar(&config.dashboard, "dashboard", false, "show a full screen view of resources, rollouts, events and failing pod logs while deploying")
+	flag.BoolVar(&config.strictVersion, "strict-version", false, "refuse to deploy to clusters outside the tested kubernetes versions instead of warning")
+	flag.BoolVar(&config.skipPreflight, "skip-preflight", false, "only warn when the pod security or scheduling preflight would reject a workload")
+	flag.BoolVar(&config.offline, "offline", os.Getenv("IMLADRIS_OFFLINE") == "1", "refuse every network access except the kubernetes api server, for air-gapped clusters (also IMLADRIS_OFFLINE=1)")
+	flag.StringVar(&config.overrideWindow, "override-window", "", "deploy outside the deploy windows of the project, the reason given is recorded in the audit log")
+	flag.StringVar(&config.overrideFreeze, "override-freeze", "", "deploy to namespaces under a change freeze, the reason given is recorded in the audit log")
+	flag.DurationVar(&config.ttl, "ttl", defaultReviewTTL, "how long a review environment made by env create lives before it expires")
+	flag.BoolVar(&config.reviewCleanup, "review-cleanup", false, "in server mode, destroy review environments once they expire")
+	flag.StringVar(&config.notifyURL, "notify-url", "", "webhook receiving the expiry warnings of review environments, slack compatible")
+	flag.DurationVar(&config.notifyBefore, "notify-before", 24*time.Hour, "warn the owner of a review environment this long before it expires")
+	flag.BoolVar(&config.noColor, "no-color", false, "print without colors, also done when NO_COLOR is set")
+	flag.BoolVar(&config.yes, "yes", false, "answer yes to every confirmation prompt")
+	flag.Parse()
+	err = validateFlags(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	noColor = config.noColor || config.nonInteractive
+	if config.offline {
+		enableOffline()
+	}
+
+	if config.metricsAddr != "" {
+		serveMetrics(config.metricsAddr)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		printUsage()
+	}
+	switch args[0] {
+	case "version":
+		cmdVersion(args[1:], config)
+	case "up":
+		cmdUp(args[1:], config)
+	case "down":
+		cmdDown(args[1:], config)
+	case "down-services":
+		cmdDownServices(args[1:], config)
+	case "down-jobs":
+		cmdDownJobs(args[1:], config)
+	case "update":
+		cmdUpdate(args[1:], config)
+	case "wait":
+		cmdWait(args[1:], config)
+	case "log":
+		cmdLog(args[1:], config)
+	case "data":
+		cmdData(args[1:], config)
+	case "generate":
+		cmdGenerate(args[1:], config)
+	case "autoupdate":
+		cmdAutoUpdate(args[1:], config)
+	case "debug":
+		cmdDebug(args[1:], config)
+	case "migrate":
+		cmdMigrate(args[1:], config)
+	case "export":
+		cmdExport(args[1:], config)
+	case "restore":
+		cmdRestore(args[1:], config)
+	case "promote":
+		cmdPromote(args[1:], config)
+	case "serve":
+		cmdServe(args[1:], config)
+	case "server":
+		cmdServer(args[1:], config)
+	case "diff":
+		cmdDiff(args[1:], config)
+	case "render":
+		cmdRender(args[1:], config)
+	case "contexts":
+		cmdContexts(args[1:], config)
+	case "namespaces":
+		cmdNamespaces(args[1:], config)
+	case "token":
+		cmdToken(args[1:], config)
+	case "provenance":
+		cmdProvenance(args[1:], config)
+	case "usage":
+		cmdUsage(args[1:], config)
+	case "deploy-all":
+		cmdDeployAll(args[1:], config)
+	case "template-lint":
+		cmdTemplateLint(args[1:], config)
+	case "teardown":
+		cmdTeardown(args[1:], config)
+	case "env":
+		cmdEnv(args[1:], config)
+	case "freeze":
+		cmdFreeze(args[1:], config)
+	case "unfreeze":
+		cmdUnfreeze(args[1:], config)
+	case "sign":
+		cmdSign(args[1:], config)
+	case "self-update":
+		cmdSelfUpdate(args[1:], config)
+	case "completion":
+		cmdCompletion(args[1:], config)
+	case "__complete":
+		cmdComplete(args[1:], config)
+	default:
+		printUsage()
+	}
+}
+
+// validateFlags rejects flag values with a fixed set of choices that are not
+// one of them
+func validateFlags(config *appConfig) error {
+	switch config.onConflict {
+	case "abort", "retry":
+	default:
+		return validationError(fmt.Errorf("unknown -on-conflict %q, expected abort or retry", config.onConflict))
+	}
+	switch config.output {
+	case "text", "json":
+	default:
+		return validationError(fmt.Errorf("unknown -output %q, expected text or json", config.output))
+	}
+	return nil
+}
+
+func printUsage() {
+	ErrPrintf(ColorWhite, "USAGE: %s <flag> [command] <folder>\n", os.Args[0])
+	ErrPrintf(ColorWhite, "Available commands: up, down, update, version, wait, log, data, generate, migrate, export, restore, promote, serve, server, diff, render, contexts, namespaces, token, sign, provenance, freeze, unfreeze, usage, template-lint, deploy-all, teardown, env, completion, self-update\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"fmt"
+	"regexp"
+
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClusterAssertion pins the cluster a project may be deployed to, either by
+// API server URL or by a value stored in a well-known ConfigMap. Server is a
+// regexp matched against the whole URL. Contexts pins the clusters resources
+// are routed to with imladris/context, each of them needs one.
+type ClusterAssertion struct {
+	Server    string                       `yaml:"server"`
+	ConfigMap *ClusterConfigMap            `yaml:"configmap"`
+	Contexts  map[string]*ClusterAssertion `yaml:"contexts"`
+}
+
+type ClusterConfigMap struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	Key       string `yaml:"key"`
+	Value     string `yaml:"value"`
+}
+
+func (p *Project) assertCluster() error {
+	assertion := p.projectConfig.Cluster
+	if assertion == nil {
+		return nil
+	}
+	return assertion.check(p.config, p.kubeClient, p.projectConfig.Name)
+}
+
+// assertTargetCluster checks the cluster of a context resources are routed to
+func (p *Project) assertTargetCluster(config *appConfig, kubeClient *kubernetes.Clientset) error {
+	assertion := p.projectConfig.Cluster
+	if assertion == nil {
+		return nil
+	}
+	target := assertion.Contexts[config.context]
+	if target == nil {
+		return newTypedError(ErrorTypeValidation, "refusing to route resources of project %q to context %q: cluster.contexts does not pin it", p.projectConfig.Name, config.context)
+	}
+	return target.check(config, kubeClient, p.projectConfig.Name)
+}
+
+func (assertion *ClusterAssertion) check(config *appConfig, kubeClient *kubernetes.Clientset, project string) error {
+	if assertion.Server != "" {
+		kubeConfig, err := kubeClientConfig(config).ClientConfig()
+		if err != nil {
+			return err
+		}
+		matched, err := regexp.MatchString("^(?:"+assertion.Server+")$", kubeConfig.Host)
+		if err != nil {
+			return newTypedError(ErrorTypeValidation, "invalid cluster server pattern %q: %s", assertion.Server, err.Error())
+		}
+		if !matched {
+			return newTypedError(ErrorTypeValidation, "refusing to use cluster %s for project %q: server does not match %q", describeCluster(config), project, assertion.Server)
+		}
+	}
+	if assertion.ConfigMap != nil {
+		expected := assertion.ConfigMap
+		namespace := expected.Namespace
+		if namespace == "" {
+			namespace = "kube-system"
+		}
+		configMap, err := kubeClient.Core().ConfigMaps(namespace).Get(expected.Name, apiv1.GetOptions{})
+		if err != nil {
+			return &TypedError{Type: classifyError(err), Err: fmt.Errorf("refusing to use cluster %s for project %q: cannot read configmap %s/%s: %s", describeCluster(config), project, namespace, expected.Name, err.Error())}
+		}
+		actual := configMap.Data[expected.Key]
+		if actual != expected.Value {
+			return newTypedError(ErrorTypeValidation, "refusing to use cluster %s for project %q: configmap %s/%s has %s=%q, expected %q", describeCluster(config), project, namespace, expected.Name, expected.Key, actual, expected.Value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeKubeconfig writes a kubeconfig with one context per cluster, named
+// after it
+func writeKubeconfig(t *testing.T, servers map[string]string) string {
+	clusters := "clusters:\n"
+	contexts := "contexts:\n"
+	for _, name := range sortedKeys(servers) {
+		clusters += "- name: " + name + "\n  cluster:\n    server: " + servers[name] + "\n"
+		contexts += "- name: " + name + "\n  context:\n    cluster: " + name + "\n"
+	}
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	require.Nil(t, os.WriteFile(kubeconfig, []byte("apiVersion: v1\nkind: Config\n"+clusters+contexts), 0600))
+	return kubeconfig
+}
+
+func TestCommandNamespace(t *testing.T) {
+	req := require.New(t)
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	req.Nil(os.WriteFile(kubeconfig, []byte("apiVersion: v1\nkind: Config\nclusters:\n- name: prod\n  cluster:\n    server: https://prod.example.com\n"+
+		"contexts:\n- name: prod\n  context:\n    cluster: prod\n    namespace: shop\n- name: staging\n  context:\n    cluster: prod\ncurrent-context: prod\n"), 0600))
+	req.Equal("shop", commandNamespace(&appConfig{configFile: kubeconfig}))
+	req.Equal("web", commandNamespace(&appConfig{configFile: kubeconfig, namespace: "web"}))
+	req.Equal("default", commandNamespace(&appConfig{configFile: kubeconfig, context: "staging"}))
+}
+
+func TestClusterServerPattern(t *testing.T) {
+	req := require.New(t)
+	kubeconfig := writeKubeconfig(t, map[string]string{"prod": "https://prod.example.com", "preprod": "https://preprod.example.com"})
+	assertCluster := func(context, server string) error {
+		p := &Project{
+			config:        &appConfig{configFile: kubeconfig, context: context},
+			projectConfig: &ProjectConfig{Name: "web", Cluster: &ClusterAssertion{Server: server}},
+		}
+		return p.assertCluster()
+	}
+	req.Nil(assertCluster("prod", `https://prod\.example\.com`))
+	req.Nil(assertCluster("prod", `https://(prod|eu)\.example\.com`))
+
+	// The pattern has to match the whole URL
+	err := assertCluster("preprod", `https://prod\.example\.com`)
+	req.Error(err)
+	req.Equal(ErrorTypeValidation, classifyError(err))
+	req.Error(assertCluster("preprod", `prod\.example\.com`))
+	req.Error(assertCluster("prod", `https://prod`))
+}
+
+func TestTargetClusterAssertion(t *testing.T) {
+	req := require.New(t)
+	cluster, _ := newFakeCluster(t)
+	cluster.add("/api/v1/namespaces/kube-system/configmaps/cluster-info", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cluster-info"},"data":{"env":"eu-production"}}`)
+	kubeconfig := writeKubeconfig(t, map[string]string{"prod": "https://prod.example.com", "eu": cluster.server.URL})
+	asset, err := parseAsset("ingress.yml", []byte("apiVersion: extensions/v1beta1\nkind: Ingress\nmetadata:\n  name: web\n  namespace: web\n"))
+	req.Nil(err)
+	asset.context = "eu"
+	loadTargetClients := func(assertion *ClusterAssertion) error {
+		p := &Project{
+			config:        &appConfig{configFile: kubeconfig, context: "prod"},
+			projectConfig: &ProjectConfig{Name: "web", Cluster: assertion},
+			resources:     []*Asset{asset},
+		}
+		return p.loadTargetClients()
+	}
+	req.Nil(loadTargetClients(nil))
+
+	// Once the project is pinned, routed contexts are too
+	err = loadTargetClients(&ClusterAssertion{Server: `https://prod\.example\.com`})
+	req.Error(err)
+	req.Equal(ErrorTypeValidation, classifyError(err))
+	req.Contains(err.Error(), `context "eu"`)
+
+	pin := func(value string) *ClusterAssertion {
+		return &ClusterAssertion{
+			Server: `https://prod\.example\.com`,
+			Contexts: map[string]*ClusterAssertion{
+				"eu": {ConfigMap: &ClusterConfigMap{Name: "cluster-info", Key: "env", Value: value}},
+			},
+		}
+	}
+	req.Nil(loadTargetClients(pin("eu-production")))
+	err = loadTargetClients(pin("eu-staging"))
+	req.Error(err)
+	req.Contains(err.Error(), "expected \"eu-staging\"")
+}
@@ -1,12 +1,9 @@
-package main
-
-import "os"
+package deploy
 
 func cmdAutoUpdate(args []string, config *appConfig) {
 	clientset, err := loadKubernetesClient(config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 	assetRoot := "."
 	if len(args) > 0 {
@@ -18,12 +15,10 @@ func cmdAutoUpdate(args []string, config *appConfig) {
 	}
 	project, err := readProject(clientset, assetRoot, config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 	err = project.AutoUpdate(newVersion)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 }
@@ -0,0 +1,37 @@
+package deploy
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func cmdCompletion(args []string, config *appConfig) {
+	if len(args) != 1 {
+		ErrPrintf(ColorWhite, "USAGE: %s completion bash|zsh|fish\n", os.Args[0])
+		os.Exit(2)
+	}
+	script, err := completionScript(args[0], completionFlags(flag.CommandLine))
+	if err != nil {
+		exitWithError(config, validationError(err))
+	}
+	fmt.Print(script)
+}
+
+// cmdComplete is called by the completion scripts, failures print nothing so
+// the shell just offers no candidates
+func cmdComplete(args []string, config *appConfig) {
+	if len(args) != 1 {
+		os.Exit(2)
+	}
+	console = newPrinter(nil, ioutil.Discard)
+	config.printer = console
+	values, err := completionValues(args[0], config)
+	if err != nil {
+		os.Exit(1)
+	}
+	for _, value := range values {
+		fmt.Println(value)
+	}
+}
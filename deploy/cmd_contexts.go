@@ -0,0 +1,33 @@
+package deploy
+
+import (
+	"sort"
+)
+
+func cmdContexts(args []string, config *appConfig) {
+	rawConfig, err := kubeClientConfig(config).RawConfig()
+	if err != nil {
+		exitWithError(config, err)
+	}
+	current := rawConfig.CurrentContext
+	if config.context != "" {
+		current = config.context
+	}
+	names := []string{}
+	for name := range rawConfig.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		context := rawConfig.Contexts[name]
+		namespace := context.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		if name == current {
+			Printf(ColorGreen, "* %s (cluster %q, namespace %q)\n", name, context.Cluster, namespace)
+		} else {
+			Printf(ColorWhite, "  %s (cluster %q, namespace %q)\n", name, context.Cluster, namespace)
+		}
+	}
+}
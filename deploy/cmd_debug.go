@@ -1,12 +1,9 @@
-package main
-
-import "os"
+package deploy
 
 func cmdDebug(args []string, config *appConfig) {
 	clientset, err := loadKubernetesClient(config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 	assetRoot := "."
 	if len(args) > 0 {
@@ -14,8 +11,7 @@ func cmdDebug(args []string, config *appConfig) {
 	}
 	project, err := readProject(clientset, assetRoot, config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 	project.Debug()
 }
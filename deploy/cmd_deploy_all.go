@@ -0,0 +1,34 @@
+package deploy
+
+import "path/filepath"
+
+func cmdDeployAll(args []string, config *appConfig) {
+	root := "."
+	if len(args) > 0 {
+		root = args[0]
+	}
+	projects, err := planDeployAll(root, config)
+	if err != nil {
+		exitWithError(config, validationError(err))
+	}
+	if len(projects) == 0 {
+		Printf(ColorGreen, "No project to deploy\n")
+		return
+	}
+	for i, project := range projects {
+		Printf(ColorWhite, "%d. %s (%s)\n", i+1, project.name, filepath.Dir(project.file))
+	}
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	results := runDeployAll(projects, config.parallel, config.keepGoing, func(project *monorepoProject) error {
+		return deployMonorepoProject(clientset, project, config)
+	})
+	printDeployAllSummary(results)
+	for _, result := range results {
+		if result.status == "failed" {
+			exitWithError(config, result.err)
+		}
+	}
+}
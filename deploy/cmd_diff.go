@@ -0,0 +1,21 @@
+package deploy
+
+func cmdDiff(args []string, config *appConfig) {
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	assetRoot := "."
+	if len(args) > 0 {
+		assetRoot = args[0]
+	}
+	config.optionalImports = true
+	project, err := readProject(clientset, assetRoot, config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = project.Diff()
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
@@ -1,25 +1,21 @@
-package main
-
-import "os"
+package deploy
 
 func cmdDownJobs(args []string, config *appConfig) {
 	clientset, err := loadKubernetesClient(config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 	assetRoot := "."
 	if len(args) > 0 {
 		assetRoot = args[0]
 	}
+	config.optionalImports = true
 	project, err := readProject(clientset, assetRoot, config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 	err = project.DownJobs()
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 }
@@ -0,0 +1,135 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+func envUsage() {
+	fmt.Fprintf(os.Stderr, "USAGE: %s [-ttl <duration>] env create [branch] [folder]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s env destroy [branch] [folder]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s env list\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s env prune\n", os.Args[0])
+	os.Exit(1)
+}
+
+func cmdEnv(args []string, config *appConfig) {
+	if len(args) < 1 {
+		envUsage()
+	}
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	switch args[0] {
+	case "create":
+		err = envCreate(clientset, args[1:], config)
+	case "destroy":
+		err = envDestroy(clientset, args[1:], config)
+	case "list":
+		err = envList(clientset)
+	case "prune":
+		var pruned []*reviewEnvironment
+		pruned, err = pruneReviewEnvironments(clientset, time.Now())
+		if err == nil {
+			Printf(ColorGreen, "Pruned %d expired review environments\n", len(pruned))
+		}
+	default:
+		envUsage()
+	}
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
+
+// envTarget resolves the branch and project folder arguments, the branch
+// defaults to the one checked out or built by the CI
+func envTarget(args []string, config *appConfig) (string, string, string, error) {
+	branch := ""
+	assetRoot := "."
+	if len(args) > 0 {
+		branch = args[0]
+	}
+	if len(args) > 1 {
+		assetRoot = args[1]
+	}
+	if branch == "" {
+		branch = detectCIEnvironment(assetRoot).branch
+	}
+	if reviewSlug(branch) == "" {
+		return "", "", "", validationError(fmt.Errorf("cannot detect the branch of the review environment, pass it as argument"))
+	}
+	name, err := reviewProjectName(assetRoot, config)
+	if err != nil {
+		return "", "", "", validationError(err)
+	}
+	return branch, assetRoot, reviewNamespace(name, branch), nil
+}
+
+// envCreate deploys the project into the review environment of a branch.
+// Templates see the branch as app_var_review_branch, e.g. for ingress hosts.
+// Running it again redeploys and pushes back the expiry.
+func envCreate(kubeClient *kubernetes.Clientset, args []string, config *appConfig) error {
+	branch, assetRoot, namespace, err := envTarget(args, config)
+	if err != nil {
+		return err
+	}
+	config.reviewNamespace = namespace
+	config.variables["app_var_review_branch"] = reviewSlug(branch)
+	project, err := readProject(kubeClient, assetRoot, config)
+	if err != nil {
+		return err
+	}
+	err = project.checkReviewNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	err = ensureReviewNamespace(kubeClient, namespace, project.projectConfig.Name, branch, config.ttl)
+	if err != nil {
+		return err
+	}
+	started := project.startDeploy("env-create")
+	err = project.deploy("apply", project.applyAsset)
+	project.finishDeploy("env-create", started, err)
+	if err != nil {
+		return err
+	}
+	Printf(ColorGreen, "Review environment %q of branch %q expires at %s\n", namespace, branch, time.Now().Add(config.ttl).Format(time.RFC3339))
+	return nil
+}
+
+func envDestroy(kubeClient *kubernetes.Clientset, args []string, config *appConfig) error {
+	branch, _, namespace, err := envTarget(args, config)
+	if err != nil {
+		return err
+	}
+	if !askConfirmation(config, fmt.Sprintf("Destroy review environment %q of branch %q on %s?", namespace, branch, describeCluster(config))) {
+		return fmt.Errorf("destroying review environment %q was cancelled", namespace)
+	}
+	return destroyReviewEnvironment(kubeClient, console, namespace)
+}
+
+func envList(kubeClient *kubernetes.Clientset) error {
+	envs, err := listReviewEnvironments(kubeClient)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	Printf(ColorWhite, "%-40s %-30s %-20s %-10s %s\n", "NAMESPACE", "BRANCH", "OWNER", "AGE", "EXPIRES")
+	for _, env := range envs {
+		age := now.Sub(env.created).Round(time.Minute).String()
+		if env.expired(now) {
+			Printf(ColorRed, "%-40s %-30s %-20s %-10s expired %s ago\n", env.namespace, env.branch, env.owner, age, now.Sub(env.expires).Round(time.Minute))
+			continue
+		}
+		expires := "never"
+		if !env.expires.IsZero() {
+			expires = "in " + env.expires.Sub(now).Round(time.Minute).String()
+		}
+		Printf(ColorWhite, "%-40s %-30s %-20s %-10s %s\n", env.namespace, env.branch, env.owner, age, expires)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package deploy
+
+import "os"
+
+func cmdExport(args []string, config *appConfig) {
+	if len(args) < 1 {
+		ErrPrintf(ColorWhite, "USAGE: %s [-selector label=value] export output-folder [project-folder]\n", os.Args[0])
+		os.Exit(1)
+	}
+	outputFolder := args[0]
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	if config.selector != "" {
+		err = exportBySelector(clientset, config.printer, commandNamespace(config), config.selector, outputFolder)
+		if err != nil {
+			exitWithError(config, err)
+		}
+		return
+	}
+	assetRoot := "."
+	if len(args) > 1 {
+		assetRoot = args[1]
+	}
+	config.optionalImports = true
+	project, err := readProject(clientset, assetRoot, config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = project.Export(outputFolder)
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
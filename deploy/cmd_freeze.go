@@ -0,0 +1,39 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func cmdFreeze(args []string, config *appConfig) {
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "USAGE: %s freeze <namespace> <reason>\n", os.Args[0])
+		os.Exit(1)
+	}
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = setFreeze(clientset, args[0], strings.Join(args[1:], " "))
+	if err != nil {
+		exitWithError(config, err)
+	}
+	Printf(ColorGreen, "Froze namespace %q\n", args[0])
+}
+
+func cmdUnfreeze(args []string, config *appConfig) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "USAGE: %s unfreeze <namespace>\n", os.Args[0])
+		os.Exit(1)
+	}
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = setFreeze(clientset, args[0], "")
+	if err != nil {
+		exitWithError(config, err)
+	}
+	Printf(ColorGreen, "Lifted the freeze annotation of namespace %q\n", args[0])
+}
@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/anduintransaction/imladris/templates"
+)
+
+// scaffolds are rendered with the resource name instead of being copied with
+// placeholders, so they parse and lint cleanly as generated
+var scaffolds = map[string]bool{
+	"deployment": true,
+	"service":    true,
+	"cronjob":    true,
+}
+
+func cmdGenerate(args []string, config *appConfig) {
+	if len(args) < 2 {
+		ErrPrintf(ColorWhite, "Usage: %s generate [project|pod|deployment|service|cronjob|job|persistentvolumeclaim|configmap] filename [name] [image]\n", os.Args[0])
+		os.Exit(1)
+	}
+	templateName := args[0]
+	filename := args[1]
+	switch args[0] {
+	case "project", "pod", "deployment", "service", "cronjob", "job", "persistentvolumeclaim", "configmap":
+		if _, ok := resourceTypes[templateName]; !ok && templateName != "project" {
+			exitWithError(config, validationError(fmt.Errorf("cannot generate %s, imladris does not deploy that kind", templateName)))
+		}
+		asset, err := templates.Asset("templates/files/" + templateName + ".yml")
+		if err != nil {
+			exitWithError(config, err)
+		}
+		if scaffolds[templateName] {
+			name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+			if len(args) > 2 {
+				name = args[2]
+			}
+			image := name + ":latest"
+			if len(args) > 3 {
+				image = args[3]
+			}
+			asset, err = renderScaffold(asset, name, image)
+		}
+		if err != nil {
+			exitWithError(config, err)
+		}
+		err = ioutil.WriteFile(filename, asset, os.FileMode(0644))
+		if err != nil {
+			exitWithError(config, err)
+		}
+	default:
+		ErrPrintf(ColorWhite, "Usage: %s generate [project|pod|deployment|service|cronjob|job|persistentvolumeclaim|configmap] filename [name] [image]\n", os.Args[0])
+		os.Exit(1)
+	}
+}
+
+// renderScaffold uses [[ ]] delimiters so the output can still carry imladris
+// {{ }} template variables
+func renderScaffold(scaffold []byte, name, image string) ([]byte, error) {
+	t, err := template.New("scaffold").Delims("[[", "]]").Option("missingkey=error").Parse(string(scaffold))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	err = t.Execute(buf, map[string]string{
+		"Name":  name,
+		"Image": image,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
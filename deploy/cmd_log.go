@@ -1,4 +1,4 @@
-package main
+package deploy
 
 import (
 	"fmt"
@@ -17,14 +17,10 @@ func cmdLog(args []string, config *appConfig) {
 		os.Exit(1)
 	}
 	podName := args[0]
-	namespace := "default"
-	if config.namespace != "" {
-		namespace = config.namespace
-	}
+	namespace := commandNamespace(config)
 	clientset, err := loadKubernetesClient(config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 
 	tail := "-1"
@@ -37,8 +33,7 @@ func cmdLog(args []string, config *appConfig) {
 		for {
 			pod, err := clientset.Core().Pods(namespace).Get(podName, apiv1.GetOptions{})
 			if err != nil {
-				ErrPrintln(ColorRed, err)
-				os.Exit(1)
+				exitWithError(config, err)
 			}
 
 			switch pod.Status.Phase {
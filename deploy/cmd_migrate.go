@@ -0,0 +1,19 @@
+package deploy
+
+import "os"
+
+func cmdMigrate(args []string, config *appConfig) {
+	if len(args) < 1 || args[0] != "petset" {
+		ErrPrintf(ColorWhite, "USAGE: %s migrate petset [name...]\n", os.Args[0])
+		os.Exit(1)
+	}
+	namespace := commandNamespace(config)
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = migratePetSets(clientset, namespace, args[1:], config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
@@ -0,0 +1,30 @@
+package deploy
+
+import (
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func cmdNamespaces(args []string, config *appConfig) {
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	namespaces, err := clientset.Core().Namespaces().List(apiv1.ListOptions{})
+	if err != nil {
+		exitWithError(config, err)
+	}
+	current := config.namespace
+	if current == "" {
+		current = contextNamespace(config)
+	}
+	if current == "" {
+		current = "default"
+	}
+	for _, namespace := range namespaces.Items {
+		if namespace.Name == current {
+			Printf(ColorGreen, "* %s (%s)\n", namespace.Name, namespace.Status.Phase)
+		} else {
+			Printf(ColorWhite, "  %s (%s)\n", namespace.Name, namespace.Status.Phase)
+		}
+	}
+}
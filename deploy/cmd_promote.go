@@ -0,0 +1,52 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+)
+
+func cmdPromote(args []string, config *appConfig) {
+	if config.promoteFrom == "" || config.promoteTo == "" || len(args) > 0 {
+		ErrPrintf(ColorWhite, "USAGE: %s -from from-project -to to-project [-from-context context] promote\n", os.Args[0])
+		os.Exit(1)
+	}
+	fromConfig := *config
+	if config.fromContext != "" {
+		fromConfig.context = config.fromContext
+	}
+	fromClientset, err := loadKubernetesClient(&fromConfig)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	fromProject, err := readProject(fromClientset, config.promoteFrom, &fromConfig)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	backend, err := fromProject.historyBackend()
+	if err != nil {
+		exitWithError(config, err)
+	}
+	history, err := backend.read(fromProject.projectConfig.Name)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	release := latestRelease(history)
+	if release == nil {
+		exitWithError(config, validationError(fmt.Errorf("no release recorded for project %q in namespace %q", fromProject.projectConfig.Name, fromProject.projectConfig.Namespace)))
+	}
+	Printf(ColorYellow, "Promoting release from %s of project %q\n", release.Time.Format("2006-01-02 15:04:05"), fromProject.projectConfig.Name)
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	project, err := readProject(clientset, config.promoteTo, config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	started := project.startDeploy("promote")
+	err = project.Promote(release)
+	project.finishDeploy("promote", started, err)
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
@@ -0,0 +1,33 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func cmdProvenance(args []string, config *appConfig) {
+	if len(args) < 1 || !strings.Contains(args[0], "/") {
+		fmt.Fprintf(os.Stderr, "USAGE: %s provenance <kind>/<name>\n", os.Args[0])
+		os.Exit(1)
+	}
+	pieces := strings.SplitN(args[0], "/", 2)
+	kind := canonicalKind(pieces[0])
+	namespace := commandNamespace(config)
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	resource, err := getResource(clientset, nil, kind, pieces[1], namespace)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	provenance := readProvenance(resource)
+	if len(provenance) == 0 {
+		Printf(ColorGray, "%s/%s in namespace %q has no provenance\n", kind, pieces[1], namespace)
+		return
+	}
+	for _, field := range provenance {
+		Printf(ColorWhite, "%-9s %s\n", field[0]+":", field[1])
+	}
+}
@@ -0,0 +1,23 @@
+package deploy
+
+import "os"
+
+func cmdRender(args []string, config *appConfig) {
+	assetRoot := "."
+	if len(args) > 0 {
+		assetRoot = args[0]
+	}
+	outputFolder := ""
+	if len(args) > 1 {
+		outputFolder = args[1]
+	}
+	// Rendering never talks to the cluster, so it works offline and in CI
+	project, err := readProject(nil, assetRoot, config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = project.Render(os.Stdout, outputFolder)
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
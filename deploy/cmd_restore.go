@@ -0,0 +1,29 @@
+package deploy
+
+import (
+	"os"
+	"strings"
+)
+
+func cmdRestore(args []string, config *appConfig) {
+	if len(args) < 1 {
+		ErrPrintf(ColorWhite, "USAGE: %s restore backup-folder|configmap/<backup-name>\n", os.Args[0])
+		os.Exit(1)
+	}
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = enforceFolderWindows(clientset, ".", config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	if strings.HasPrefix(args[0], "configmap/") {
+		err = restoreBackupObjects(clientset, strings.TrimPrefix(args[0], "configmap/"), commandNamespace(config), config)
+	} else {
+		err = restoreBackup(clientset, args[0], config)
+	}
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
@@ -0,0 +1,8 @@
+package deploy
+
+func cmdSelfUpdate(args []string, config *appConfig) {
+	err := selfUpdate(imladrisVersion)
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
@@ -0,0 +1,19 @@
+package deploy
+
+import "os"
+
+func cmdServe(args []string, config *appConfig) {
+	if len(args) < 1 {
+		ErrPrintf(ColorWhite, "USAGE: %s [-git-ref ref] [-sync-interval duration] serve repo-folder [project-path]\n", os.Args[0])
+		os.Exit(1)
+	}
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	projectPath := "."
+	if len(args) > 1 {
+		projectPath = args[1]
+	}
+	serveProject(clientset, args[0], projectPath, config)
+}
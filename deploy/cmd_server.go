@@ -0,0 +1,38 @@
+package deploy
+
+import (
+	"net/http"
+	"os"
+)
+
+func cmdServer(args []string, config *appConfig) {
+	if len(args) < 1 {
+		ErrPrintf(ColorWhite, "USAGE: %s [-listen addr] [-review-cleanup] server projects-folder\n", os.Args[0])
+		os.Exit(1)
+	}
+	server, err := newDeployServer(args[0], config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	if config.reviewCleanup {
+		clientset, err := loadKubernetesClient(config)
+		if err != nil {
+			exitWithError(config, err)
+		}
+		cleanup := &reviewCleanup{
+			kubeClient:   clientset,
+			notifyURL:    config.notifyURL,
+			notifyBefore: config.notifyBefore,
+			out:          newPrinter(os.Stdout, os.Stderr),
+		}
+		cleanup.destroy = func(env *reviewEnvironment) error {
+			return server.destroyReviewEnvironment(clientset, env)
+		}
+		go cleanup.run(config.syncInterval)
+	}
+	Printf(ColorYellow, "Serving deploys of projects in %q on %s\n", args[0], config.listen)
+	err = http.ListenAndServe(config.listen, server.handler())
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
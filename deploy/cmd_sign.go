@@ -0,0 +1,21 @@
+package deploy
+
+func cmdSign(args []string, config *appConfig) {
+	assetRoot := "."
+	if len(args) > 0 {
+		assetRoot = args[0]
+	}
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+	}
+	// Signing happens in the build pipeline, which has no cluster access
+	project, err := readProject(nil, assetRoot, config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = project.Sign(key)
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
@@ -0,0 +1,46 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+)
+
+func cmdTeardown(args []string, config *appConfig) {
+	namespace := config.namespace
+	if len(args) > 0 {
+		namespace = args[0]
+	}
+	if namespace == "" {
+		fmt.Fprintf(os.Stderr, "USAGE: %s [-delete-pvcs] [-delete-namespace] -namespace <namespace> teardown\n", os.Args[0])
+		os.Exit(1)
+	}
+	if systemNamespaces[namespace] {
+		exitWithError(config, fmt.Errorf("refusing to tear down system namespace %q", namespace))
+	}
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = checkNamespaceFreeze(clientset, namespace, config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	// The project in the current folder pins the cluster, has the windows,
+	// and declares what it deployed before the checksum annotation
+	project, err := readFolderProject(clientset, ".", config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	if !askConfirmation(config, fmt.Sprintf("Delete everything imladris applied in namespace %q on %s?", namespace, describeCluster(config))) {
+		exitWithError(config, fmt.Errorf("teardown of namespace %q was cancelled", namespace))
+	}
+	err = teardownNamespace(clientset, namespace, config, &teardownOptions{
+		deletePVCs:      config.deletePVCs,
+		deleteNamespace: config.deleteNamespace,
+		timeout:         config.timeout,
+		project:         project,
+	})
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
@@ -0,0 +1,19 @@
+package deploy
+
+func cmdTemplateLint(args []string, config *appConfig) {
+	assetRoot := "."
+	if len(args) > 0 {
+		assetRoot = args[0]
+	}
+	// Linting never talks to the cluster, imported variables are reported
+	// without being checked
+	config.templateLint = true
+	project, err := readProject(nil, assetRoot, config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = project.Lint()
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func cmdToken(args []string, config *appConfig) {
+	if len(args) == 0 {
+		ErrPrintln(ColorRed, "USAGE: token <serviceaccount> [kubeconfig]")
+		os.Exit(2)
+	}
+	name := args[0]
+	namespace := commandNamespace(config)
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	secret, err := serviceAccountSecret(clientset, name, namespace, config.timeout)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	if len(args) < 2 || args[1] != "kubeconfig" {
+		fmt.Println(string(secret.Data[v1.ServiceAccountTokenKey]))
+		return
+	}
+	kubeConfig, err := kubeClientConfig(config).ClientConfig()
+	if err != nil {
+		exitWithError(config, err)
+	}
+	data, err := serviceAccountKubeconfig(kubeConfig.Host, name, namespace, secret)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	fmt.Print(string(data))
+}
+
+// serviceAccountSecret returns the token secret of a service account, waiting
+// for the token controller when the account was only just deployed
+func serviceAccountSecret(kubeClient *kubernetes.Clientset, name, namespace string, timeout time.Duration) (*v1.Secret, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		serviceAccount, err := kubeClient.Core().ServiceAccounts(namespace).Get(name, apiv1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, reference := range serviceAccount.Secrets {
+			secret, err := kubeClient.Core().Secrets(namespace).Get(reference.Name, apiv1.GetOptions{})
+			if err != nil {
+				if isResourceNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			if secret.Type == v1.SecretTypeServiceAccountToken && len(secret.Data[v1.ServiceAccountTokenKey]) > 0 {
+				return secret, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for a token of service account %s/%s", namespace, name)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func serviceAccountKubeconfig(server, name, namespace string, secret *v1.Secret) ([]byte, error) {
+	contextName := namespace + "-" + name
+	kubeConfig := clientcmdapi.NewConfig()
+	kubeConfig.Clusters[contextName] = &clientcmdapi.Cluster{
+		Server:                   server,
+		CertificateAuthorityData: secret.Data[v1.ServiceAccountRootCAKey],
+	}
+	kubeConfig.AuthInfos[contextName] = &clientcmdapi.AuthInfo{
+		Token: string(secret.Data[v1.ServiceAccountTokenKey]),
+	}
+	kubeConfig.Contexts[contextName] = &clientcmdapi.Context{
+		Cluster:   contextName,
+		AuthInfo:  contextName,
+		Namespace: namespace,
+	}
+	kubeConfig.CurrentContext = contextName
+	return clientcmd.Write(*kubeConfig)
+}
@@ -0,0 +1,28 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestServiceAccountKubeconfig(t *testing.T) {
+	req := require.New(t)
+	secret := &v1.Secret{
+		Data: map[string][]byte{
+			v1.ServiceAccountTokenKey:  []byte("token-value"),
+			v1.ServiceAccountRootCAKey: []byte("ca-data"),
+		},
+	}
+	data, err := serviceAccountKubeconfig("https://kube.example.com", "ci", "build", secret)
+	req.NoError(err)
+	kubeConfig, err := clientcmd.Load(data)
+	req.NoError(err)
+	req.Equal("build-ci", kubeConfig.CurrentContext)
+	req.Equal("build", kubeConfig.Contexts["build-ci"].Namespace)
+	req.Equal("https://kube.example.com", kubeConfig.Clusters["build-ci"].Server)
+	req.Equal([]byte("ca-data"), kubeConfig.Clusters["build-ci"].CertificateAuthorityData)
+	req.Equal("token-value", kubeConfig.AuthInfos["build-ci"].Token)
+}
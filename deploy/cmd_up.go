@@ -1,12 +1,9 @@
-package main
-
-import "os"
+package deploy
 
 func cmdUp(args []string, config *appConfig) {
 	clientset, err := loadKubernetesClient(config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 	assetRoot := "."
 	if len(args) > 0 {
@@ -14,12 +11,12 @@ func cmdUp(args []string, config *appConfig) {
 	}
 	project, err := readProject(clientset, assetRoot, config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
+	started := project.startDeploy("up")
 	err = project.Up()
+	project.finishDeploy("up", started, err)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 }
@@ -1,25 +1,26 @@
-package main
-
-import "os"
+package deploy
 
 func cmdUpdate(args []string, config *appConfig) {
 	clientset, err := loadKubernetesClient(config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 	assetRoot := "."
 	if len(args) > 0 {
 		assetRoot = args[0]
 	}
+	if config.watch {
+		watchProject(clientset, assetRoot, config)
+		return
+	}
 	project, err := readProject(clientset, assetRoot, config)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
+	started := project.startDeploy("update")
 	err = project.Update()
+	project.finishDeploy("update", started, err)
 	if err != nil {
-		ErrPrintln(ColorRed, err)
-		os.Exit(1)
+		exitWithError(config, err)
 	}
 }
@@ -0,0 +1,20 @@
+package deploy
+
+func cmdUsage(args []string, config *appConfig) {
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	assetRoot := "."
+	if len(args) > 0 {
+		assetRoot = args[0]
+	}
+	project, err := readProject(clientset, assetRoot, config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	err = project.ReportUsage()
+	if err != nil {
+		exitWithError(config, err)
+	}
+}
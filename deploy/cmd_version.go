@@ -0,0 +1,10 @@
+package deploy
+
+import "fmt"
+
+// imladrisVersion is set at build time with -ldflags "-X ...imladrisVersion=..."
+var imladrisVersion = "dev"
+
+func cmdVersion(args []string, config *appConfig) {
+	fmt.Println(imladrisVersion)
+}
@@ -0,0 +1,109 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func cmdWait(args []string, config *appConfig) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "USAGE: %s jobname\n", os.Args[0])
+		os.Exit(1)
+	}
+	jobName := args[0]
+	namespace := commandNamespace(config)
+	Printf(ColorYellow, "Waiting for job %q from namespace %q\n", jobName, namespace)
+	clientset, err := loadKubernetesClient(config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+
+	job, err := clientset.Batch().Jobs(namespace).Get(jobName, apiv1.GetOptions{})
+	if err != nil {
+		exitWithError(config, err)
+	}
+	waitForJobStatus(config, job)
+	watcher, err := clientset.Batch().Jobs(namespace).Watch(apiv1.ListOptions{
+		FieldSelector: "metadata.name=" + jobName,
+	})
+	if err != nil {
+		exitWithError(config, err)
+	}
+	timer := time.NewTimer(config.timeout)
+	poller := time.NewTicker(time.Minute)
+	pollErrorCount := 0
+	for {
+		var job *v1batch.Job
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				// The api server ends watches after a while, start another one
+				watcher, err = clientset.Batch().Jobs(namespace).Watch(apiv1.ListOptions{
+					FieldSelector: "metadata.name=" + jobName,
+				})
+				if err != nil {
+					exitWithError(config, err)
+				}
+				continue
+			}
+			job, err = jobFromEvent(event)
+			if err != nil {
+				exitWithError(config, err)
+			}
+		case <-timer.C:
+			exitWithError(config, newTypedError(ErrorTypeTimeout, "timeout while waiting for job events"))
+		case <-poller.C:
+			job, err = clientset.Batch().Jobs(namespace).Get(jobName, apiv1.GetOptions{})
+			if err != nil {
+				pollErrorCount++
+				if pollErrorCount < 5 {
+					continue
+				}
+				exitWithError(config, err)
+			}
+		}
+		waitForJobStatus(config, job)
+	}
+}
+
+func jobFromEvent(event watch.Event) (*v1batch.Job, error) {
+	if event.Type == watch.Error {
+		return nil, errors.FromObject(event.Object)
+	}
+	job, ok := event.Object.(*v1batch.Job)
+	if !ok {
+		return nil, fmt.Errorf("cannot decode job from %s event", event.Type)
+	}
+	if event.Type == watch.Deleted {
+		return nil, newTypedError(ErrorTypeRolloutFailure, "job %q was deleted", job.Name)
+	}
+	return job, nil
+}
+
+// waitForJobStatus exits once the job completed or failed
+func waitForJobStatus(config *appConfig, job *v1batch.Job) {
+	done, err := checkJobStatus(job)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	if done {
+		Println(ColorGreen, "Job completed")
+		os.Exit(0)
+	}
+}
+
+func checkJobStatus(job *v1batch.Job) (bool, error) {
+	if len(job.Status.Conditions) == 0 {
+		return false, nil
+	}
+	if job.Status.Conditions[0].Type == v1batch.JobComplete {
+		return true, nil
+	}
+	return false, newTypedError(ErrorTypeRolloutFailure, "job failed: %s", job.Status.Conditions[0].Message)
+}
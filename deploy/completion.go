@@ -0,0 +1,137 @@
+package deploy
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var completionCommands = []string{
+	"up", "down", "down-services", "down-jobs", "update", "version", "wait", "log", "data", "generate", "autoupdate",
+	"debug", "migrate", "export", "restore", "promote", "serve", "server", "diff", "render", "contexts", "namespaces",
+	"token", "sign", "provenance", "freeze", "unfreeze", "usage", "template-lint", "deploy-all", "teardown", "env",
+	"completion", "self-update",
+}
+
+// completionSources name the __complete listing offered as values of a flag,
+// the scripts call back into imladris so contexts and resources stay current
+var completionSources = map[string]string{
+	"context":      "contexts",
+	"from-context": "contexts",
+	"only":         "resources",
+	"skip":         "resources",
+	"group":        "groups",
+}
+
+type completionFlag struct {
+	Name  string
+	Usage string
+	Bool  bool
+}
+
+func completionFlags(flags *flag.FlagSet) []*completionFlag {
+	result := []*completionFlag{}
+	flags.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface {
+			IsBoolFlag() bool
+		})
+		result = append(result, &completionFlag{
+			Name:  f.Name,
+			Usage: f.Usage,
+			Bool:  ok && boolFlag.IsBoolFlag(),
+		})
+	})
+	return result
+}
+
+func completionScript(shell string, flags []*completionFlag) (string, error) {
+	switch shell {
+	case "bash":
+		return bashCompletion(flags), nil
+	case "zsh":
+		// zsh runs the bash function through its bash compatibility layer
+		return "autoload -U +X bashcompinit && bashcompinit\n" + bashCompletion(flags), nil
+	case "fish":
+		return fishCompletion(flags), nil
+	default:
+		return "", fmt.Errorf("unsupported shell %q, expected bash, zsh or fish", shell)
+	}
+}
+
+func bashCompletion(flags []*completionFlag) string {
+	flagWords := []string{}
+	for _, f := range flags {
+		flagWords = append(flagWords, "-"+f.Name)
+	}
+	sources := []string{}
+	for name := range completionSources {
+		sources = append(sources, name)
+	}
+	sort.Strings(sources)
+	script := &strings.Builder{}
+	script.WriteString("_imladris() {\n")
+	script.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\" i\n")
+	script.WriteString("    case \"$prev\" in\n")
+	for _, name := range sources {
+		fmt.Fprintf(script, "        -%s|--%s)\n            COMPREPLY=($(compgen -W \"$(imladris __complete %s 2>/dev/null)\" -- \"$cur\"))\n            return;;\n", name, name, completionSources[name])
+	}
+	script.WriteString("    esac\n")
+	fmt.Fprintf(script, "    if [[ \"$cur\" == -* ]]; then\n        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n        return\n    fi\n", strings.Join(flagWords, " "))
+	script.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(script, "        case \"${COMP_WORDS[i]}\" in\n            %s)\n                COMPREPLY=($(compgen -f -- \"$cur\"))\n                return;;\n        esac\n", strings.Join(completionCommands, "|"))
+	script.WriteString("    done\n")
+	fmt.Fprintf(script, "    COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(completionCommands, " "))
+	script.WriteString("}\ncomplete -F _imladris imladris\n")
+	return script.String()
+}
+
+func fishCompletion(flags []*completionFlag) string {
+	script := &strings.Builder{}
+	fmt.Fprintf(script, "complete -c imladris -n __fish_use_subcommand -f -a '%s'\n", strings.Join(completionCommands, " "))
+	for _, f := range flags {
+		fmt.Fprintf(script, "complete -c imladris -o %s -d '%s'", f.Name, strings.Replace(f.Usage, "'", "\\'", -1))
+		source, ok := completionSources[f.Name]
+		if ok {
+			fmt.Fprintf(script, " -x -a '(imladris __complete %s 2>/dev/null)'", source)
+		} else if !f.Bool {
+			script.WriteString(" -r")
+		}
+		script.WriteString("\n")
+	}
+	return script.String()
+}
+
+// completionValues lists the candidates of a completion source. Resources
+// and groups come from the project in the current folder, read offline.
+func completionValues(source string, config *appConfig) ([]string, error) {
+	values := []string{}
+	switch source {
+	case "contexts":
+		rawConfig, err := kubeClientConfig(config).RawConfig()
+		if err != nil {
+			return nil, err
+		}
+		for name := range rawConfig.Contexts {
+			values = append(values, name)
+		}
+	case "resources", "groups":
+		project, err := readProject(nil, ".", config)
+		if err != nil {
+			return nil, err
+		}
+		if source == "groups" {
+			for _, group := range project.projectConfig.Groups {
+				values = append(values, group.Name)
+			}
+			break
+		}
+		for _, asset := range project.assets() {
+			values = append(values, asset.Kind+"/"+asset.ResourceData.(Meta).GetName())
+		}
+	default:
+		return nil, fmt.Errorf("unknown completion source %q", source)
+	}
+	sort.Strings(values)
+	return values, nil
+}
@@ -0,0 +1,45 @@
+package deploy
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionScript(t *testing.T) {
+	req := require.New(t)
+	flags := flag.NewFlagSet("imladris", flag.ContinueOnError)
+	flags.String("context", "", "Kube context")
+	flags.Bool("keep-going", false, "keep applying the remaining resources")
+	completionFlags := completionFlags(flags)
+	req.Equal([]*completionFlag{
+		{Name: "context", Usage: "Kube context"},
+		{Name: "keep-going", Usage: "keep applying the remaining resources", Bool: true},
+	}, completionFlags)
+
+	bash, err := completionScript("bash", completionFlags)
+	req.NoError(err)
+	req.Contains(bash, `-context|--context)`)
+	req.Contains(bash, `imladris __complete contexts`)
+	req.Contains(bash, `"-context -keep-going"`)
+	req.Contains(bash, "complete -F _imladris imladris")
+
+	zsh, err := completionScript("zsh", completionFlags)
+	req.NoError(err)
+	req.Contains(zsh, "bashcompinit")
+
+	fish, err := completionScript("fish", completionFlags)
+	req.NoError(err)
+	req.Contains(fish, "complete -c imladris -o context -d 'Kube context' -x -a '(imladris __complete contexts 2>/dev/null)'\n")
+	req.Contains(fish, "complete -c imladris -o keep-going -d 'keep applying the remaining resources'\n")
+
+	_, err = completionScript("powershell", completionFlags)
+	req.Error(err)
+}
+
+func TestCompletionValues(t *testing.T) {
+	req := require.New(t)
+	_, err := completionValues("pods", &appConfig{})
+	req.Error(err)
+}
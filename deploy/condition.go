@@ -0,0 +1,208 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// conditionExpression is a small boolean language for the when section of
+// the project file, e.g. .env == "production" && !.skip_debug
+// A .name operand is a template variable, falling back to the environment
+// variable of that name, and is empty when neither is set. A lone operand is
+// true unless it is empty, "false" or "0".
+type conditionExpression struct {
+	tokens    []string
+	position  int
+	variables map[string]string
+}
+
+func evaluateCondition(expression string, variables map[string]string) (bool, error) {
+	tokens, err := tokenizeCondition(expression)
+	if err != nil {
+		return false, err
+	}
+	e := &conditionExpression{tokens: tokens, variables: variables}
+	result, err := e.or()
+	if err != nil {
+		return false, err
+	}
+	if e.position < len(e.tokens) {
+		return false, fmt.Errorf("unexpected %q in condition %q", e.tokens[e.position], expression)
+	}
+	return result, nil
+}
+
+func tokenizeCondition(expression string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(expression[i:], "==") || strings.HasPrefix(expression[i:], "!=") ||
+			strings.HasPrefix(expression[i:], "&&") || strings.HasPrefix(expression[i:], "||"):
+			tokens = append(tokens, expression[i:i+2])
+			i += 2
+		case c == '!' || c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expression[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in condition %q", expression)
+			}
+			tokens = append(tokens, expression[i:i+end+2])
+			i += end + 2
+		default:
+			start := i
+			for i < len(expression) && (unicode.IsLetter(rune(expression[i])) || unicode.IsDigit(rune(expression[i])) || strings.IndexByte("._-", expression[i]) >= 0) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected %q in condition %q", string(c), expression)
+			}
+			tokens = append(tokens, expression[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+func (e *conditionExpression) peek() string {
+	if e.position < len(e.tokens) {
+		return e.tokens[e.position]
+	}
+	return ""
+}
+
+func (e *conditionExpression) next() string {
+	token := e.peek()
+	e.position++
+	return token
+}
+
+func (e *conditionExpression) or() (bool, error) {
+	result, err := e.and()
+	for err == nil && e.peek() == "||" {
+		e.next()
+		var right bool
+		right, err = e.and()
+		result = result || right
+	}
+	return result, err
+}
+
+func (e *conditionExpression) and() (bool, error) {
+	result, err := e.unary()
+	for err == nil && e.peek() == "&&" {
+		e.next()
+		var right bool
+		right, err = e.unary()
+		result = result && right
+	}
+	return result, err
+}
+
+func (e *conditionExpression) unary() (bool, error) {
+	if e.peek() == "!" {
+		e.next()
+		result, err := e.unary()
+		return !result, err
+	}
+	return e.primary()
+}
+
+func (e *conditionExpression) primary() (bool, error) {
+	if e.peek() == "(" {
+		e.next()
+		result, err := e.or()
+		if err != nil {
+			return false, err
+		}
+		if e.next() != ")" {
+			return false, fmt.Errorf("missing ) in condition")
+		}
+		return result, nil
+	}
+	left, err := e.operand()
+	if err != nil {
+		return false, err
+	}
+	switch e.peek() {
+	case "==", "!=":
+		operator := e.next()
+		right, err := e.operand()
+		if err != nil {
+			return false, err
+		}
+		return (left == right) == (operator == "=="), nil
+	}
+	return left != "" && left != "false" && left != "0", nil
+}
+
+func (e *conditionExpression) operand() (string, error) {
+	token := e.next()
+	switch {
+	case token == "" || strings.Contains("()!&&||==!=", token):
+		return "", fmt.Errorf("expected a value in condition, got %q", token)
+	case token[0] == '"' || token[0] == '\'':
+		return token[1 : len(token)-1], nil
+	case token[0] == '.':
+		name := token[1:]
+		value, ok := e.variables[name]
+		if !ok {
+			value = os.Getenv(name)
+		}
+		return value, nil
+	default:
+		return token, nil
+	}
+}
+
+// applyConditions drops the resources whose when expression is false
+func (p *Project) applyConditions() error {
+	if len(p.projectConfig.When) == 0 {
+		return nil
+	}
+	patterns := []string{}
+	for pattern := range p.projectConfig.When {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	excluded := make(map[*Asset]bool)
+	for _, pattern := range patterns {
+		filter, err := parseResourceFilter(pattern)
+		if err != nil {
+			return err
+		}
+		expression := p.projectConfig.When[pattern]
+		included, err := evaluateCondition(expression, p.projectConfig.Variables)
+		if err != nil {
+			return fmt.Errorf("when %q: %s", pattern, err.Error())
+		}
+		if included {
+			continue
+		}
+		for _, asset := range p.assets() {
+			if filter.matches(asset) && !excluded[asset] {
+				excluded[asset] = true
+				p.printer.ErrPrintf(ColorBlue, "Leaving out %s, condition %q is false\n", assetKey(asset), expression)
+			}
+		}
+	}
+	keep := func(assets []*Asset) []*Asset {
+		kept := []*Asset{}
+		for _, asset := range assets {
+			if !excluded[asset] {
+				kept = append(kept, asset)
+			}
+		}
+		return kept
+	}
+	p.resources = keep(p.resources)
+	p.jobs = keep(p.jobs)
+	p.services = keep(p.services)
+	return nil
+}
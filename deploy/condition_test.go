@@ -0,0 +1,31 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	req := require.New(t)
+	variables := map[string]string{"env": "production", "debug": "false", "replicas": "3"}
+	for expression, expected := range map[string]bool{
+		`.env == "production"`:                true,
+		`.env != 'production'`:                false,
+		`.debug`:                              false,
+		`!.debug`:                             true,
+		`.missing`:                            false,
+		`.missing == ""`:                      true,
+		`.env == "staging" || .replicas == 3`: true,
+		`.env == "production" && (.debug || !.replicas)`: false,
+		`true`: true,
+	} {
+		result, err := evaluateCondition(expression, variables)
+		req.NoError(err, expression)
+		req.Equal(expected, result, expression)
+	}
+	for _, expression := range []string{`.env ==`, `(.env`, `.env == "production`, `.env = "x"`, `== .env`} {
+		_, err := evaluateCondition(expression, variables)
+		req.Error(err, expression)
+	}
+}
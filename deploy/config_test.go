@@ -1,8 +1,9 @@
-package main
+package deploy
 
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	v1batch "k8s.io/api/batch/v1"
@@ -10,6 +11,11 @@ import (
 	"k8s.io/api/extensions/v1beta1"
 )
 
+func init() {
+	// Keep the developer's kubeconfig context namespace out of these tests
+	os.Setenv("KUBECONFIG", os.DevNull)
+}
+
 func TestDefaultVariables(t *testing.T) {
 	req := require.New(t)
 	config := &appConfig{}
@@ -18,12 +24,77 @@ func TestDefaultVariables(t *testing.T) {
 	req.NoError(err)
 	req.NotNil(project)
 	projectConfig := project.projectConfig
-	req.Equal(projectConfig.Variables, map[string]string{
+	for key, value := range map[string]string{
 		"app_var_home":      os.Getenv("HOME"),
 		"app_var_data_dir":  dataPath,
 		"app_var_cwd":       "test-assets/config-tests/simple",
 		"app_var_namespace": "default",
-	})
+		"app_var_release":   project.releaseID(),
+	} {
+		req.Equal(value, projectConfig.Variables[key])
+	}
+	for _, key := range []string{"app_var_git_sha", "app_var_git_short_sha", "app_var_git_branch", "app_var_git_tag", "app_var_build_number", "app_var_timestamp"} {
+		req.Contains(projectConfig.Variables, key)
+	}
+}
+
+// clearCIEnvironment hides the variables of the CI system running the tests
+func clearCIEnvironment(t *testing.T) {
+	for _, vars := range ciEnvVars {
+		for _, name := range vars {
+			if name != "" {
+				t.Setenv(name, "")
+			}
+		}
+	}
+	t.Setenv("GITHUB_REF", "")
+}
+
+func TestCIVariables(t *testing.T) {
+	req := require.New(t)
+	clearCIEnvironment(t)
+	t.Setenv("CI_COMMIT_SHA", "0123456789abcdef")
+	t.Setenv("CI_COMMIT_BRANCH", "master")
+	t.Setenv("CI_PIPELINE_IID", "42")
+	project, err := readProject(nil, "test-assets/config-tests/simple", &appConfig{})
+	req.NoError(err)
+	variables := project.projectConfig.Variables
+	req.Equal("0123456789abcdef", variables["app_var_git_sha"])
+	req.Equal("0123456", variables["app_var_git_short_sha"])
+	req.Equal("master", variables["app_var_git_branch"])
+	req.Equal("42", variables["app_var_build_number"])
+}
+
+func TestGitVariables(t *testing.T) {
+	req := require.New(t)
+	clearCIEnvironment(t)
+	t.Setenv("GIT_AUTHOR_NAME", "imladris")
+	t.Setenv("GIT_AUTHOR_EMAIL", "imladris@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "imladris")
+	t.Setenv("GIT_COMMITTER_EMAIL", "imladris@example.com")
+	folder := t.TempDir()
+	_, err := runGit(folder, "init", "-q")
+	req.Nil(err)
+	_, err = runGit(folder, "symbolic-ref", "HEAD", "refs/heads/release")
+	req.Nil(err)
+	commit := gitCommitFile(t, folder, "project.yml", "name: web\n")
+	_, err = runGit(folder, "tag", "v1.2.0")
+	req.Nil(err)
+
+	ci := detectCIEnvironment(folder)
+	req.Equal(commit, ci.sha)
+	req.Equal("release", ci.branch)
+	req.Equal("v1.2.0", ci.tag)
+
+	_, err = runGit(folder, "checkout", "-q", "--detach")
+	req.Nil(err)
+	gitCommitFile(t, folder, "project.yml", "name: api\n")
+	ci = detectCIEnvironment(folder)
+	req.Empty(ci.branch)
+	req.Empty(ci.tag)
+
+	ci = detectCIEnvironment(t.TempDir())
+	req.Empty(ci.sha)
 }
 
 func TestSimpleConfigError(t *testing.T) {
@@ -44,6 +115,7 @@ func TestSimpleConfigDefault(t *testing.T) {
 	projectConfig := project.projectConfig
 	req.Equal(appRoot, projectConfig.RootFolder)
 	req.Equal("default", projectConfig.Namespace)
+	req.Equal("simple", projectConfig.Name)
 	req.Len(project.services, 1)
 	req.Len(project.resources, 0)
 	req.Len(project.jobs, 0)
@@ -93,6 +165,21 @@ func TestSimpleConfigNamespaceFromVariable(t *testing.T) {
 	req.Equal("anduin-dep", deployment.Namespace)
 }
 
+func TestManifestNamespaces(t *testing.T) {
+	req := require.New(t)
+	namespaces := func(config *appConfig) map[string]string {
+		project, err := readProject(nil, "test-assets/config-tests/manifest-namespaces", config)
+		req.NoError(err)
+		result := make(map[string]string)
+		for _, asset := range project.services {
+			result[asset.ResourceData.(Meta).GetName()] = asset.Namespace()
+		}
+		return result
+	}
+	req.Equal(map[string]string{"web": "web", "exporter": "monitoring"}, namespaces(&appConfig{}))
+	req.Equal(map[string]string{"web": "staging", "exporter": "monitoring"}, namespaces(&appConfig{namespace: "staging"}))
+}
+
 func TestSimpleConfigBuild(t *testing.T) {
 	req := require.New(t)
 	config := &appConfig{}
@@ -117,6 +204,24 @@ func TestSimpleConfigBuild(t *testing.T) {
 	req.Equal(projectConfig.Variables["test_image_2"], "anduin/test2:3.1.4")
 }
 
+func TestSimpleConfigJobPolicies(t *testing.T) {
+	req := require.New(t)
+	config := &appConfig{}
+	appRoot := "test-assets/config-tests/simple/deployments/jobs.yml"
+	project, err := readProject(nil, appRoot, config)
+	req.NoError(err)
+	req.NotNil(project)
+	req.Equal(&JobPolicy{Name: "migrate", Recreate: true}, project.jobPolicy("migrate"))
+	req.Equal(&JobPolicy{Name: "seed", UniqueName: true, HistoryLimit: 3}, project.jobPolicy("seed"))
+	req.Nil(project.jobPolicy("busybox"))
+
+	now := time.Unix(1500000000, 0)
+	name := uniqueJobName("seed", []byte("kind: Job"), now)
+	req.Len(name, len("seed-")+8)
+	req.Equal(name, uniqueJobName("seed", []byte("kind: Job"), now))
+	req.NotEqual(name, uniqueJobName("seed", []byte("kind: Job"), now.Add(time.Second)))
+}
+
 func TestConfigNotSimpleLocal(t *testing.T) {
 	req := require.New(t)
 	config := &appConfig{
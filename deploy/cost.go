@@ -0,0 +1,234 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CostConfig prices the requested resources of long running workloads, CPU
+// per core and Memory per GiB, both per month
+type CostConfig struct {
+	CPU      float64 `yaml:"cpu"`
+	Memory   float64 `yaml:"memory"`
+	Currency string  `yaml:"currency"`
+}
+
+// longRunningKinds run until replaced, the cost of jobs depends on how long they
+// run
+var longRunningKinds = map[string]bool{
+	"pod":         true,
+	"deployment":  true,
+	"statefulset": true,
+	"daemonset":   true,
+}
+
+// monthlyCost prices what the scheduler reserves for the pods of a workload
+func (c *CostConfig) monthlyCost(podSpec *v1.PodSpec, replicas int64) float64 {
+	requests := podRequests(podSpec)
+	cost := 0.0
+	if cpu, ok := requests[v1.ResourceCPU]; ok {
+		cost += float64(cpu.MilliValue()) / 1000 * c.CPU
+	}
+	if memory, ok := requests[v1.ResourceMemory]; ok {
+		cost += float64(memory.Value()) / (1 << 30) * c.Memory
+	}
+	return cost * float64(replicas)
+}
+
+func (c *CostConfig) format(cost float64) string {
+	if c.Currency == "" {
+		return fmt.Sprintf("%.2f", cost)
+	}
+	return fmt.Sprintf("%.2f %s", cost, c.Currency)
+}
+
+// estimateCost prints the monthly cost of the resources this deploy changes,
+// before and after, from the cpu and memory they request. Like the capacity
+// check it only informs, a failure to estimate does not stop the deploy.
+func (p *Project) estimateCost() {
+	if p.projectConfig.Cost == nil {
+		return
+	}
+	err := p.printCostEstimate(p.projectConfig.Cost)
+	if err != nil {
+		p.printer.ErrPrintf(ColorYellow, "Warning: cannot estimate cost: %s\n", err.Error())
+	}
+}
+
+// costEstimate keeps what pricing the assets of a project needs from each
+// cluster and namespace, read once
+type costEstimate struct {
+	p           *Project
+	nodeCounts  map[string]int64
+	autoscalers map[string]map[string]bool
+}
+
+// nodes counts the schedulable nodes daemon sets run on. Listing nodes needs
+// a cluster role, without it daemon sets are left out of the estimate.
+func (e *costEstimate) nodes(asset *Asset) (int64, bool, error) {
+	count, ok := e.nodeCounts[asset.context]
+	if ok {
+		return count, count >= 0, nil
+	}
+	nodes, err := e.p.clientFor(asset).Core().Nodes().List(apiv1.ListOptions{})
+	if errors.IsForbidden(err) {
+		e.p.printer.ErrPrintf(ColorYellow, "Warning: cannot list nodes, daemon sets are left out of the cost estimate\n")
+		e.nodeCounts[asset.context] = -1
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	for _, node := range nodes.Items {
+		if !node.Spec.Unschedulable {
+			count++
+		}
+	}
+	e.nodeCounts[asset.context] = count
+	return count, true, nil
+}
+
+// autoscaled tells the workloads a horizontal pod autoscaler scales, their
+// replicas are what the autoscaler says whatever the manifest does
+func (e *costEstimate) autoscaled(asset *Asset) (bool, error) {
+	key := asset.context + "/" + asset.Namespace()
+	targets, ok := e.autoscalers[key]
+	if !ok {
+		autoscalers, err := e.p.clientFor(asset).Autoscaling().HorizontalPodAutoscalers(asset.Namespace()).List(apiv1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		targets = make(map[string]bool)
+		for _, autoscaler := range autoscalers.Items {
+			target := autoscaler.Spec.ScaleTargetRef
+			targets[strings.ToLower(target.Kind)+"/"+target.Name] = true
+		}
+		e.autoscalers[key] = targets
+	}
+	return targets[assetKey(asset)], nil
+}
+
+// cost prices an asset or, without desired, its live resource
+func (e *costEstimate) cost(pricing *CostConfig, asset *Asset, live interface{}, desired bool) (float64, bool, error) {
+	nodes := int64(0)
+	if asset.Kind == "daemonset" {
+		var ok bool
+		var err error
+		nodes, ok, err = e.nodes(asset)
+		if err != nil || !ok {
+			return 0, false, err
+		}
+	}
+	resource := live
+	if desired {
+		resource = asset.ResourceData
+	}
+	replicas := workloadReplicas(&Asset{Kind: asset.Kind, ResourceData: resource}, nodes)
+	if desired && live != nil {
+		autoscaled, err := e.autoscaled(asset)
+		if err != nil {
+			return 0, false, err
+		}
+		if autoscaled {
+			replicas = workloadReplicas(&Asset{Kind: asset.Kind, ResourceData: live}, nodes)
+		}
+	}
+	return pricing.monthlyCost(getPodSpec(asset.Kind, resource), replicas), true, nil
+}
+
+func (p *Project) printCostEstimate(pricing *CostConfig) error {
+	estimate := &costEstimate{p: p, nodeCounts: make(map[string]int64), autoscalers: make(map[string]map[string]bool)}
+	var before, after float64
+	lines := []string{}
+	declared := make(map[string]bool)
+	for _, asset := range p.assets() {
+		declared[assetKey(asset)] = true
+		if !longRunningKinds[asset.Kind] {
+			continue
+		}
+		live, found, err := p.liveResources(asset).get(asset.Kind, asset.ResourceData.(Meta).GetName())
+		if err != nil {
+			return err
+		}
+		if !found {
+			live = nil
+		}
+		desired, ok, err := estimate.cost(pricing, asset, live, true)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		current := 0.0
+		if found {
+			current, _, err = estimate.cost(pricing, asset, live, false)
+			if err != nil {
+				return err
+			}
+		}
+		before += current
+		after += desired
+		if desired != current {
+			lines = append(lines, fmt.Sprintf("  %-40s %s -> %s (%+.2f)", assetKey(asset), pricing.format(current), pricing.format(desired), desired-current))
+		}
+	}
+	// Workloads the last release deployed and the project no longer has
+	removed, err := p.removedWorkloads(declared)
+	if err != nil {
+		return err
+	}
+	for _, asset := range removed {
+		current, ok, err := estimate.cost(pricing, asset, asset.ResourceData, false)
+		if err != nil {
+			return err
+		}
+		if !ok || current == 0 {
+			continue
+		}
+		before += current
+		lines = append(lines, fmt.Sprintf("  %-40s %s -> %s (%+.2f) removed from the project", assetKey(asset), pricing.format(current), pricing.format(0), -current))
+	}
+	p.printer.Printf(ColorBlue, "Estimated monthly cost: %s -> %s (%+.2f)\n", pricing.format(before), pricing.format(after), after-before)
+	for _, line := range lines {
+		p.printer.Println(ColorBlue, line)
+	}
+	return nil
+}
+
+// removedWorkloads reads the live workloads of the last release that the
+// project doesn't declare anymore
+func (p *Project) removedWorkloads(declared map[string]bool) ([]*Asset, error) {
+	backend, err := p.historyBackend()
+	if err != nil {
+		return nil, err
+	}
+	records, err := backend.read(p.projectConfig.Name)
+	if err != nil {
+		return nil, err
+	}
+	latest := latestRelease(records)
+	if latest == nil {
+		return nil, nil
+	}
+	removed := []*Asset{}
+	for _, key := range sortedKeys(latest.Checksums) {
+		pieces := strings.SplitN(key, "/", 2)
+		if declared[key] || len(pieces) != 2 || !longRunningKinds[pieces[0]] {
+			continue
+		}
+		live, err := getResource(p.kubeClient, p.plugins, pieces[0], pieces[1], p.projectConfig.Namespace)
+		if isResourceNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		removed = append(removed, &Asset{Kind: pieces[0], ResourceData: live})
+	}
+	return removed, nil
+}
@@ -0,0 +1,78 @@
+package deploy
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestMonthlyCost(t *testing.T) {
+	req := require.New(t)
+	pricing := &CostConfig{CPU: 20, Memory: 4, Currency: "USD"}
+	podSpec := &v1.PodSpec{
+		Containers: []v1.Container{
+			{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("500m"),
+				v1.ResourceMemory: resource.MustParse("1Gi"),
+			}}},
+			{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("250m"),
+				v1.ResourceMemory: resource.MustParse("512Mi"),
+			}}},
+		},
+	}
+	req.InDelta(21.0, pricing.monthlyCost(podSpec, 1), 0.001)
+	req.InDelta(63.0, pricing.monthlyCost(podSpec, 3), 0.001)
+	req.Equal(0.0, pricing.monthlyCost(&v1.PodSpec{Containers: []v1.Container{{}}}, 2))
+	req.Equal("63.00 USD", pricing.format(63))
+}
+
+func TestPrintCostEstimate(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.reject = func(method, path string) *fakeStatus {
+		if path == "/api/v1/nodes" {
+			return &fakeStatus{http.StatusForbidden, "Forbidden", "nodes is forbidden"}
+		}
+		return nil
+	}
+	deployment := func(name string, replicas int) string {
+		return `{"apiVersion":"extensions/v1beta1","kind":"Deployment","metadata":{"name":"` + name + `","namespace":"web"},"spec":{"replicas":` + strconv.Itoa(replicas) +
+			`,"template":{"spec":{"containers":[{"name":"app","image":"app","resources":{"requests":{"cpu":"1"}}}]}}}}`
+	}
+	// web is scaled to 5 by its autoscaler, old was removed from the project
+	cluster.add("/apis/extensions/v1beta1/namespaces/web/deployments/web", deployment("web", 5))
+	cluster.add("/apis/extensions/v1beta1/namespaces/web/deployments/api", deployment("api", 1))
+	cluster.add("/apis/extensions/v1beta1/namespaces/web/deployments/old", deployment("old", 3))
+	cluster.add("/apis/autoscaling/v1/namespaces/web/horizontalpodautoscalers/web", `{"apiVersion":"autoscaling/v1","kind":"HorizontalPodAutoscaler","metadata":{"name":"web","namespace":"web"},"spec":{"scaleTargetRef":{"kind":"Deployment","name":"web"},"maxReplicas":10}}`)
+	assets := []*Asset{}
+	for _, manifest := range []string{deployment("web", 2), deployment("api", 2),
+		`{"apiVersion":"extensions/v1beta1","kind":"DaemonSet","metadata":{"name":"agent","namespace":"web"},"spec":{"template":{"spec":{"containers":[{"name":"agent","image":"agent","resources":{"requests":{"cpu":"1"}}}]}}}}`} {
+		asset, err := parseAsset("asset.yml", []byte(manifest))
+		req.Nil(err)
+		assets = append(assets, asset)
+	}
+	history := &configMapHistory{kubeClient: kubeClient, namespace: "web"}
+	req.Nil(history.write("shop", []*ReleaseRecord{{Checksums: map[string]string{"deployment/web": "a", "deployment/api": "b", "deployment/old": "c"}}}))
+	out := &bytes.Buffer{}
+	p := &Project{
+		kubeClient:    kubeClient,
+		config:        &appConfig{},
+		projectConfig: &ProjectConfig{Name: "shop", Namespace: "web"},
+		printer:       newPrinter(out, out),
+		services:      assets,
+	}
+
+	req.Nil(p.printCostEstimate(&CostConfig{CPU: 10}))
+	output := out.String()
+	req.Contains(output, "Estimated monthly cost: 90.00 -> 70.00 (-20.00)")
+	req.Contains(output, "deployment/api")
+	req.NotContains(output, "deployment/web")
+	req.Contains(output, "removed from the project")
+	req.Contains(output, "daemon sets are left out")
+}
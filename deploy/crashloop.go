@@ -0,0 +1,101 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podFailure describes why a pod will not become ready without a fix, or is
+// empty while it may still get there
+func podFailure(pod *v1.Pod) string {
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if waiting := status.State.Waiting; waiting != nil {
+			switch waiting.Reason {
+			case "CrashLoopBackOff":
+				return fmt.Sprintf("container %q is in CrashLoopBackOff%s", status.Name, describeTermination(status.LastTerminationState.Terminated))
+			case "ImagePullBackOff", "ErrImagePull", "InvalidImageName":
+				return fmt.Sprintf("container %q cannot pull image %q: %s", status.Name, status.Image, waiting.Message)
+			}
+		}
+		for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated != nil && terminated.Reason == "OOMKilled" {
+				return fmt.Sprintf("container %q was OOMKilled, exit code %d", status.Name, terminated.ExitCode)
+			}
+		}
+	}
+	return ""
+}
+
+func describeTermination(terminated *v1.ContainerStateTerminated) string {
+	if terminated == nil {
+		return ""
+	}
+	description := fmt.Sprintf(", last exit code %d", terminated.ExitCode)
+	if terminated.Reason != "" {
+		description += " (" + terminated.Reason + ")"
+	}
+	if message := strings.TrimSpace(terminated.Message); message != "" {
+		description += ": " + message
+	}
+	return description
+}
+
+func (p *Project) workloadPods(asset *Asset) (*v1.PodList, error) {
+	selector := "job-name=" + asset.ResourceData.(Meta).GetName()
+	if asset.Kind != "job" {
+		var err error
+		selector, err = workloadSelector(asset)
+		if selector == "" || err != nil {
+			return nil, err
+		}
+	}
+	return p.clientFor(asset).Core().Pods(asset.Namespace()).List(apiv1.ListOptions{LabelSelector: selector})
+}
+
+// recordPods notes the pods a workload has before it is applied. Pod
+// timestamps come from the cluster clock, they can't be compared with ours.
+func (p *Project) recordPods(asset *Asset) error {
+	if !isWorkloadKind(asset.Kind) {
+		return nil
+	}
+	pods, err := p.workloadPods(asset)
+	if err != nil || pods == nil {
+		return err
+	}
+	if p.podsBefore == nil {
+		p.podsBefore = make(map[string]map[string]bool)
+	}
+	before := make(map[string]bool)
+	for _, pod := range pods.Items {
+		before[string(pod.UID)] = true
+	}
+	p.podsBefore[versionKey(asset)] = before
+	return nil
+}
+
+// checkPodFailures fails the rollout of a workload as soon as one of the pods
+// this deploy started cannot run, instead of waiting for -timeout. Pods there
+// before it was applied are left out, they may be the broken release this
+// deploy fixes.
+func (p *Project) checkPodFailures(asset *Asset) error {
+	pods, err := p.workloadPods(asset)
+	if err != nil || pods == nil {
+		return err
+	}
+	before := p.podsBefore[versionKey(asset)]
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || before[string(pod.UID)] {
+			continue
+		}
+		failure := podFailure(pod)
+		if failure != "" {
+			return newTypedError(ErrorTypeRolloutFailure, "%s: pod %q %s", assetKey(asset), pod.Name, failure)
+		}
+	}
+	return nil
+}
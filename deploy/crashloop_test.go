@@ -0,0 +1,41 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+)
+
+func TestPodFailure(t *testing.T) {
+	req := require.New(t)
+	pod := &v1.Pod{}
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "web", Ready: true}}
+	req.Equal("", podFailure(pod))
+
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:  "web",
+		State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			ExitCode: 1,
+			Reason:   "Error",
+			Message:  "missing DATABASE_URL\n",
+		}},
+	}}
+	req.Equal(`container "web" is in CrashLoopBackOff, last exit code 1 (Error): missing DATABASE_URL`, podFailure(pod))
+
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:                 "web",
+		State:                v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+	}}
+	req.Equal(`container "web" was OOMKilled, exit code 137`, podFailure(pod))
+
+	pod.Status.ContainerStatuses = nil
+	pod.Status.InitContainerStatuses = []v1.ContainerStatus{{
+		Name:  "migrate",
+		Image: "registry/migrate:missing",
+		State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "manifest unknown"}},
+	}}
+	req.Equal(`container "migrate" cannot pull image "registry/migrate:missing": manifest unknown`, podFailure(pod))
+}
@@ -0,0 +1,325 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	app "k8s.io/api/apps/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	dashboardRefresh   = time.Second
+	dashboardEvents    = 6
+	dashboardLogLines  = 5
+	dashboardOutput    = 8
+	dashboardBarLength = 20
+)
+
+// dashboard redraws a full screen view of the deploy while applyAssets runs.
+// Everything printed meanwhile goes to the output pane instead of scrolling
+// the view away.
+type dashboard struct {
+	p      *Project
+	lock   sync.Mutex
+	keys   []string
+	states map[string]string
+	output *lineBuffer
+	// screen is the printer of the project before the dashboard took its
+	// output over
+	screen *printer
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// dashboardView is what one redraw shows, kept apart from the cluster reads
+// so the layout can be tested
+type dashboardView struct {
+	Title     string
+	Resources []string
+	States    map[string]string
+	Rollouts  map[string][2]int32
+	Events    []string
+	Logs      map[string][]string
+	Output    []string
+}
+
+func terminalAttached() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *Project) startDashboard(assets []*Asset) *dashboard {
+	if p.config.nonInteractive || !terminalAttached() {
+		p.printer.ErrPrintln(ColorPurple, "Not showing the dashboard without an interactive terminal")
+		return nil
+	}
+	d := &dashboard{
+		p:      p,
+		states: make(map[string]string),
+		output: &lineBuffer{max: dashboardOutput},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for _, asset := range assets {
+		key := assetKey(asset)
+		d.keys = append(d.keys, key)
+		d.states[key] = "pending"
+	}
+	d.screen = p.printer
+	p.printer = newPrinter(d.output, d.output)
+	go d.run()
+	return d
+}
+
+func (d *dashboard) setState(asset *Asset, state string) {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	d.states[assetKey(asset)] = state
+	d.lock.Unlock()
+}
+
+func (d *dashboard) finish() {
+	if d == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+	d.p.printer = d.screen
+	// Leave the last output on screen for the summary that follows
+	for _, line := range d.output.lines() {
+		fmt.Fprintln(d.screen.Stdout(), line)
+	}
+}
+
+func (d *dashboard) run() {
+	defer close(d.done)
+	ticker := time.NewTicker(dashboardRefresh)
+	defer ticker.Stop()
+	for {
+		fmt.Fprint(d.screen.Stdout(), "\033[H\033[2J"+d.view().render(terminalWidth()))
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *dashboard) view() *dashboardView {
+	d.lock.Lock()
+	view := &dashboardView{
+		Title:     fmt.Sprintf("%s %s in namespace %q", d.p.projectConfig.Name, d.p.releaseID(), d.p.projectConfig.Namespace),
+		Resources: d.keys,
+		States:    make(map[string]string),
+		Rollouts:  make(map[string][2]int32),
+		Logs:      make(map[string][]string),
+		Output:    d.output.lines(),
+	}
+	for key, state := range d.states {
+		view.States[key] = state
+	}
+	d.lock.Unlock()
+	for _, asset := range d.p.assets() {
+		if view.States[assetKey(asset)] != "done" {
+			continue
+		}
+		ready, desired, ok := rolloutProgress(d.p.clientFor(asset), asset)
+		if ok {
+			view.Rollouts[assetKey(asset)] = [2]int32{ready, desired}
+		}
+	}
+	view.Events = d.recentEvents()
+	view.Logs = d.failingPodLogs()
+	return view
+}
+
+func (d *dashboard) recentEvents() []string {
+	events, err := d.p.kubeClient.Core().Events(d.p.projectConfig.Namespace).List(apiv1.ListOptions{})
+	if err != nil {
+		return []string{"cannot list events: " + err.Error()}
+	}
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LastTimestamp.Before(&items[j].LastTimestamp)
+	})
+	if len(items) > dashboardEvents {
+		items = items[len(items)-dashboardEvents:]
+	}
+	lines := []string{}
+	for _, event := range items {
+		lines = append(lines, fmt.Sprintf("%s %s/%s %s: %s", event.LastTimestamp.Format("15:04:05"), strings.ToLower(event.InvolvedObject.Kind), event.InvolvedObject.Name, event.Reason, event.Message))
+	}
+	return lines
+}
+
+func (d *dashboard) failingPodLogs() map[string][]string {
+	logs := make(map[string][]string)
+	pods, err := d.p.kubeClient.Core().Pods(d.p.projectConfig.Namespace).List(apiv1.ListOptions{})
+	if err != nil {
+		return logs
+	}
+	tailLines := int64(dashboardLogLines)
+	for _, pod := range pods.Items {
+		if !podFailing(&pod) {
+			continue
+		}
+		data, err := d.p.kubeClient.Core().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{TailLines: &tailLines}).Do().Raw()
+		if err != nil {
+			logs[pod.Name] = []string{"cannot read logs: " + err.Error()}
+			continue
+		}
+		logs[pod.Name] = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+	return logs
+}
+
+func podFailing(pod *v1.Pod) bool {
+	if pod.Status.Phase == v1.PodFailed {
+		return true
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+			return true
+		}
+		if status.State.Terminated != nil && status.State.Terminated.ExitCode != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// rolloutProgress reports ready out of desired replicas for workloads
+func rolloutProgress(kubeClient *kubernetes.Clientset, asset *Asset) (int32, int32, bool) {
+	switch asset.Kind {
+	case "deployment", "statefulset", "daemonset":
+	default:
+		return 0, 0, false
+	}
+	resource, err := getResource(kubeClient, nil, asset.Kind, asset.ResourceData.(Meta).GetName(), asset.Namespace())
+	if err != nil {
+		return 0, 0, false
+	}
+	replicas := func(value *int32) int32 {
+		if value == nil {
+			return 1
+		}
+		return *value
+	}
+	switch resource := resource.(type) {
+	case *v1beta1.Deployment:
+		return resource.Status.AvailableReplicas, replicas(resource.Spec.Replicas), true
+	case *app.StatefulSet:
+		return resource.Status.ReadyReplicas, replicas(resource.Spec.Replicas), true
+	case *v1beta1.DaemonSet:
+		return resource.Status.NumberReady, resource.Status.DesiredNumberScheduled, true
+	}
+	return 0, 0, false
+}
+
+func terminalWidth() int {
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width <= 0 {
+		return 100
+	}
+	return width
+}
+
+func progressBar(ready, desired int32, length int) string {
+	filled := length
+	if desired > 0 && ready < desired {
+		filled = int(ready) * length / int(desired)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", length-filled) + "]"
+}
+
+func (view *dashboardView) render(width int) string {
+	buf := &bytes.Buffer{}
+	line := func(format string, v ...interface{}) {
+		text := scrub(fmt.Sprintf(format, v...))
+		if len(text) > width {
+			text = text[:width]
+		}
+		fmt.Fprintln(buf, text)
+	}
+	line("%s", view.Title)
+	line("%s", strings.Repeat("=", width))
+	for _, key := range view.Resources {
+		rollout, ok := view.Rollouts[key]
+		if ok {
+			line("%-8s %-40s %s %d/%d", view.States[key], key, progressBar(rollout[0], rollout[1], dashboardBarLength), rollout[0], rollout[1])
+		} else {
+			line("%-8s %s", view.States[key], key)
+		}
+	}
+	line("")
+	line("Events")
+	line("%s", strings.Repeat("-", width))
+	for _, event := range view.Events {
+		line("%s", event)
+	}
+	pods := []string{}
+	for pod := range view.Logs {
+		pods = append(pods, pod)
+	}
+	sort.Strings(pods)
+	for _, pod := range pods {
+		line("")
+		line("Logs of failing pod %s", pod)
+		line("%s", strings.Repeat("-", width))
+		for _, log := range view.Logs[pod] {
+			line("  %s", log)
+		}
+	}
+	line("")
+	line("Output")
+	line("%s", strings.Repeat("-", width))
+	for _, output := range view.Output {
+		line("%s", output)
+	}
+	return buf.String()
+}
+
+// lineBuffer keeps the last max lines written to it
+type lineBuffer struct {
+	lock    sync.Mutex
+	max     int
+	buffer  []string
+	partial string
+}
+
+func (b *lineBuffer) Write(data []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	text := b.partial + string(data)
+	pieces := strings.Split(text, "\n")
+	b.partial = pieces[len(pieces)-1]
+	for _, piece := range pieces[:len(pieces)-1] {
+		b.buffer = append(b.buffer, piece)
+	}
+	if len(b.buffer) > b.max {
+		b.buffer = b.buffer[len(b.buffer)-b.max:]
+	}
+	return len(data), nil
+}
+
+func (b *lineBuffer) lines() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	lines := append([]string{}, b.buffer...)
+	if b.partial != "" {
+		lines = append(lines, b.partial)
+	}
+	return lines
+}
@@ -0,0 +1,56 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+)
+
+func TestProgressBar(t *testing.T) {
+	req := require.New(t)
+	req.Equal("[....]", progressBar(0, 4, 4))
+	req.Equal("[##..]", progressBar(2, 4, 4))
+	req.Equal("[####]", progressBar(5, 4, 4))
+	req.Equal("[####]", progressBar(0, 0, 4))
+}
+
+func TestLineBuffer(t *testing.T) {
+	req := require.New(t)
+	buffer := &lineBuffer{max: 2}
+	fmt.Fprint(buffer, "Creating deployment \"web\"\n====> Suc")
+	req.Equal([]string{"Creating deployment \"web\"", "====> Suc"}, buffer.lines())
+	fmt.Fprint(buffer, "cess\nCreating service \"web\"\n")
+	req.Equal([]string{"====> Success", "Creating service \"web\""}, buffer.lines())
+}
+
+func TestPodFailing(t *testing.T) {
+	req := require.New(t)
+	pod := &v1.Pod{}
+	req.False(podFailing(pod))
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}}}
+	req.True(podFailing(pod))
+}
+
+func TestDashboardRender(t *testing.T) {
+	req := require.New(t)
+	view := &dashboardView{
+		Title:     "billing",
+		Resources: []string{"configmap/web", "deployment/web"},
+		States:    map[string]string{"configmap/web": "done", "deployment/web": "done"},
+		Rollouts:  map[string][2]int32{"deployment/web": {1, 2}},
+		Events:    []string{"12:00:00 pod/web-1 BackOff: restarting"},
+		Logs:      map[string][]string{"web-1": {"panic: boom"}},
+		Output:    []string{"====> Success"},
+	}
+	screen := view.render(80)
+	req.Contains(screen, "deployment/web")
+	req.Contains(screen, "[##########..........] 1/2")
+	req.Contains(screen, "Logs of failing pod web-1\n")
+	req.Contains(screen, "  panic: boom\n")
+	for _, line := range strings.Split(screen, "\n") {
+		req.True(len(line) <= 80)
+	}
+}
@@ -0,0 +1,169 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DataGenerator declares a ConfigMap or Secret built from files, folders and
+// literals instead of a hand written data block, like kubectl create
+// --from-file/--from-literal
+type DataGenerator struct {
+	Name        string            `yaml:"name"`
+	Type        string            `yaml:"type"`
+	Labels      map[string]string `yaml:"labels"`
+	FromFile    []string          `yaml:"from_file"`
+	FromLiteral []string          `yaml:"from_literal"`
+}
+
+// collect reads every source into key/value pairs. A from_file entry is a
+// file (keyed by its base name), a folder (each regular file in it) or
+// key=path.
+func (g *DataGenerator) collect(rootFolder string) (map[string][]byte, error) {
+	data := make(map[string][]byte)
+	add := func(key string, value []byte) error {
+		if _, ok := data[key]; ok {
+			return fmt.Errorf("%q: duplicate key %q", g.Name, key)
+		}
+		data[key] = value
+		return nil
+	}
+	for _, source := range g.FromFile {
+		key := ""
+		if pieces := strings.SplitN(source, "=", 2); len(pieces) == 2 {
+			key, source = pieces[0], pieces[1]
+		}
+		path := translateFilePath(rootFolder, source)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %s", g.Name, err.Error())
+		}
+		if !info.IsDir() {
+			if key == "" {
+				key = filepath.Base(path)
+			}
+			value, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			err = add(key, value)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if key != "" {
+			return nil, fmt.Errorf("%q: cannot name folder %q with a key", g.Name, source)
+		}
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !file.Mode().IsRegular() {
+				continue
+			}
+			value, err := ioutil.ReadFile(filepath.Join(path, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+			err = add(file.Name(), value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, literal := range g.FromLiteral {
+		pieces := strings.SplitN(literal, "=", 2)
+		if len(pieces) != 2 {
+			return nil, fmt.Errorf("%q: literal %q is not key=value", g.Name, literal)
+		}
+		err := add(pieces[0], []byte(pieces[1]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func (p *Project) generateDataAssets() ([]*Asset, error) {
+	assets := []*Asset{}
+	for _, generator := range p.projectConfig.ConfigMaps {
+		data, err := generator.collect(p.projectConfig.RootFolder)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate configmap %s", err.Error())
+		}
+		configMap := &v1.ConfigMap{
+			ObjectMeta: apiv1.ObjectMeta{
+				Name:   generator.Name,
+				Labels: generator.Labels,
+			},
+		}
+		configMap.Data, err = configMapData(generator.Name, data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate configmap %s", err.Error())
+		}
+		assets = append(assets, &Asset{Kind: "configmap", ResourceData: configMap})
+	}
+	for _, generator := range p.projectConfig.Secrets {
+		data, err := generator.collect(p.projectConfig.RootFolder)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate secret %s", err.Error())
+		}
+		secret := &v1.Secret{
+			ObjectMeta: apiv1.ObjectMeta{
+				Name:   generator.Name,
+				Labels: generator.Labels,
+			},
+			Type: v1.SecretType(generator.Type),
+			Data: data,
+		}
+		if secret.Type == "" {
+			secret.Type = v1.SecretTypeOpaque
+		}
+		assets = append(assets, &Asset{Kind: "secret", ResourceData: secret})
+	}
+	certificates, err := p.generateCertificates()
+	if err != nil {
+		return nil, err
+	}
+	assets = append(assets, certificates...)
+	sort.SliceStable(assets, func(i, j int) bool {
+		return assets[i].Kind == "configmap" && assets[j].Kind != "configmap"
+	})
+	for _, asset := range assets {
+		asset.filename = filepath.Join(p.projectConfig.RootFolder, "project.yml")
+		p.placeAsset(asset)
+		asset.registerSecretValues()
+		rendered, err := renderManifest(asset.Kind, asset.ResourceData)
+		if err != nil {
+			return nil, err
+		}
+		asset.data = rendered
+	}
+	return assets, nil
+}
+
+// configMapData converts collected values to ConfigMap data. The ConfigMap
+// type we build against has no binaryData field and a string would mangle
+// certificates or keystores, so binary values are refused; secrets carry
+// them base64 encoded.
+func configMapData(name string, data map[string][]byte) (map[string]string, error) {
+	result := make(map[string]string)
+	for key, value := range data {
+		if !utf8.Valid(value) || bytes.IndexByte(value, 0) >= 0 {
+			return nil, fmt.Errorf("%q: key %q holds binary data, declare it under secrets instead", name, key)
+		}
+		result[key] = string(value)
+	}
+	return result, nil
+}
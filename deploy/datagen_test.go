@@ -0,0 +1,56 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataGeneratorCollect(t *testing.T) {
+	req := require.New(t)
+	rootFolder, err := ioutil.TempDir("", "imladris-datagen")
+	req.NoError(err)
+	defer os.RemoveAll(rootFolder)
+	req.NoError(os.Mkdir(filepath.Join(rootFolder, "conf"), 0755))
+	req.NoError(os.Mkdir(filepath.Join(rootFolder, "conf", "nested"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(rootFolder, "conf", "app.ini"), []byte("debug=false"), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(rootFolder, "conf", "nginx.conf"), []byte("worker_processes 1;"), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(rootFolder, "key.pem"), []byte("PEM"), 0644))
+
+	generator := &DataGenerator{
+		Name:        "app",
+		FromFile:    []string{"conf", "tls.key=key.pem"},
+		FromLiteral: []string{"mode=production", "empty="},
+	}
+	data, err := generator.collect(rootFolder)
+	req.NoError(err)
+	req.Equal(map[string][]byte{
+		"app.ini":    []byte("debug=false"),
+		"nginx.conf": []byte("worker_processes 1;"),
+		"tls.key":    []byte("PEM"),
+		"mode":       []byte("production"),
+		"empty":      []byte(""),
+	}, data)
+
+	generator = &DataGenerator{Name: "app", FromFile: []string{"conf/app.ini"}, FromLiteral: []string{"app.ini=x"}}
+	_, err = generator.collect(rootFolder)
+	req.Error(err)
+
+	generator = &DataGenerator{Name: "app", FromLiteral: []string{"novalue"}}
+	_, err = generator.collect(rootFolder)
+	req.Error(err)
+}
+
+func TestConfigMapDataBinary(t *testing.T) {
+	req := require.New(t)
+	data, err := configMapData("app", map[string][]byte{"app.ini": []byte("debug=false")})
+	req.NoError(err)
+	req.Equal(map[string]string{"app.ini": "debug=false"}, data)
+	_, err = configMapData("app", map[string][]byte{"keystore.jks": {0xfe, 0xed, 0xfe, 0xed, 0x00, 0x02}})
+	req.Error(err)
+	_, err = configMapData("app", map[string][]byte{"blob": {'a', 0x00, 'b'}})
+	req.Error(err)
+}
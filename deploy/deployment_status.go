@@ -0,0 +1,176 @@
+package deploy
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+type DeploymentStatusConfig struct {
+	Environment    string `yaml:"environment"`
+	EnvironmentURL string `yaml:"environment_url"`
+}
+
+type deploymentReporter interface {
+	Start() error
+	Finish(success bool) error
+}
+
+func detectDeploymentReporter(config *DeploymentStatusConfig, namespace string) deploymentReporter {
+	if config == nil {
+		return nil
+	}
+	environment := config.Environment
+	if environment == "" {
+		environment = namespace
+	}
+	if os.Getenv("GITHUB_ACTIONS") == "true" && os.Getenv("GITHUB_TOKEN") != "" {
+		apiURL := os.Getenv("GITHUB_API_URL")
+		if apiURL == "" {
+			apiURL = "https://api.github.com"
+		}
+		return &githubDeployment{
+			apiURL:         apiURL,
+			repository:     os.Getenv("GITHUB_REPOSITORY"),
+			sha:            os.Getenv("GITHUB_SHA"),
+			token:          os.Getenv("GITHUB_TOKEN"),
+			environment:    environment,
+			environmentURL: config.EnvironmentURL,
+			logURL:         fmt.Sprintf("%s/%s/actions/runs/%s", os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")),
+		}
+	}
+	if os.Getenv("GITLAB_CI") == "true" && os.Getenv("GITLAB_TOKEN") != "" {
+		return &gitlabDeployment{
+			apiURL:      os.Getenv("CI_API_V4_URL"),
+			projectID:   os.Getenv("CI_PROJECT_ID"),
+			sha:         os.Getenv("CI_COMMIT_SHA"),
+			ref:         os.Getenv("CI_COMMIT_REF_NAME"),
+			tag:         os.Getenv("CI_COMMIT_TAG") != "",
+			token:       os.Getenv("GITLAB_TOKEN"),
+			environment: environment,
+		}
+	}
+	return nil
+}
+
+type githubDeployment struct {
+	apiURL         string
+	repository     string
+	sha            string
+	token          string
+	environment    string
+	environmentURL string
+	logURL         string
+	id             int64
+}
+
+func (d *githubDeployment) headers() map[string]string {
+	return map[string]string{
+		"Authorization": "token " + d.token,
+		"Accept":        "application/vnd.github.flash-preview+json, application/vnd.github.ant-man-preview+json",
+	}
+}
+
+func (d *githubDeployment) Start() error {
+	deployment := &struct {
+		ID int64 `json:"id"`
+	}{}
+	err := doJSONRequest("POST", d.apiURL+"/repos/"+d.repository+"/deployments", d.headers(), map[string]interface{}{
+		"ref":               d.sha,
+		"environment":       d.environment,
+		"auto_merge":        false,
+		"required_contexts": []string{},
+	}, deployment)
+	if err != nil {
+		return err
+	}
+	d.id = deployment.ID
+	return d.setStatus("in_progress")
+}
+
+func (d *githubDeployment) Finish(success bool) error {
+	if d.id == 0 {
+		return nil
+	}
+	if success {
+		return d.setStatus("success")
+	}
+	return d.setStatus("failure")
+}
+
+func (d *githubDeployment) setStatus(state string) error {
+	return doJSONRequest("POST", fmt.Sprintf("%s/repos/%s/deployments/%d/statuses", d.apiURL, d.repository, d.id), d.headers(), map[string]interface{}{
+		"state":           state,
+		"environment_url": d.environmentURL,
+		"log_url":         d.logURL,
+	}, nil)
+}
+
+type gitlabDeployment struct {
+	apiURL      string
+	projectID   string
+	sha         string
+	ref         string
+	tag         bool
+	token       string
+	environment string
+	id          int64
+}
+
+func (d *gitlabDeployment) headers() map[string]string {
+	return map[string]string{
+		"PRIVATE-TOKEN": d.token,
+	}
+}
+
+func (d *gitlabDeployment) Start() error {
+	deployment := &struct {
+		ID int64 `json:"id"`
+	}{}
+	err := doJSONRequest("POST", d.apiURL+"/projects/"+url.PathEscape(d.projectID)+"/deployments", d.headers(), map[string]interface{}{
+		"environment": d.environment,
+		"sha":         d.sha,
+		"ref":         d.ref,
+		"tag":         d.tag,
+		"status":      "running",
+	}, deployment)
+	if err != nil {
+		return err
+	}
+	d.id = deployment.ID
+	return nil
+}
+
+func (d *gitlabDeployment) Finish(success bool) error {
+	if d.id == 0 {
+		return nil
+	}
+	status := "failed"
+	if success {
+		status = "success"
+	}
+	return doJSONRequest("PUT", fmt.Sprintf("%s/projects/%s/deployments/%d", d.apiURL, url.PathEscape(d.projectID), d.id), d.headers(), map[string]interface{}{
+		"status": status,
+	}, nil)
+}
+
+func (p *Project) startDeploymentStatus() {
+	p.deployment = detectDeploymentReporter(p.projectConfig.DeploymentStatus, p.projectConfig.Namespace)
+	if p.deployment == nil {
+		return
+	}
+	err := p.deployment.Start()
+	if err != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot create deployment status: %s\n", err.Error())
+	}
+}
+
+func (p *Project) finishDeploymentStatus(success bool) {
+	if p.deployment == nil {
+		return
+	}
+	err := p.deployment.Finish(success)
+	if err != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot update deployment status: %s\n", err.Error())
+	}
+}
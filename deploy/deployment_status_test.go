@@ -0,0 +1,108 @@
+package deploy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func clearDeploymentStatusEnv(t *testing.T) {
+	for _, name := range []string{"GITHUB_ACTIONS", "GITHUB_TOKEN", "GITHUB_API_URL", "GITHUB_REPOSITORY", "GITHUB_SHA", "GITHUB_SERVER_URL", "GITHUB_RUN_ID",
+		"GITLAB_CI", "GITLAB_TOKEN", "CI_API_V4_URL", "CI_PROJECT_ID", "CI_COMMIT_SHA", "CI_COMMIT_REF_NAME", "CI_COMMIT_TAG"} {
+		t.Setenv(name, "")
+	}
+}
+
+type recordedRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   map[string]interface{}
+}
+
+func newRecordingServer(t *testing.T, response string) (*httptest.Server, func() []recordedRequest) {
+	lock := sync.Mutex{}
+	requests := []recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]interface{})
+		json.NewDecoder(r.Body).Decode(&body)
+		lock.Lock()
+		requests = append(requests, recordedRequest{r.Method, r.URL.Path, r.Header, body})
+		lock.Unlock()
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []recordedRequest {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]recordedRequest{}, requests...)
+	}
+}
+
+func TestDetectDeploymentReporter(t *testing.T) {
+	req := require.New(t)
+	clearDeploymentStatusEnv(t)
+	config := &DeploymentStatusConfig{}
+	req.Nil(detectDeploymentReporter(config, "web"))
+	req.Nil(detectDeploymentReporter(nil, "web"))
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	req.Nil(detectDeploymentReporter(config, "web"), "no token")
+	t.Setenv("GITHUB_TOKEN", "token")
+	t.Setenv("GITHUB_REPOSITORY", "anduin/web")
+	reporter, ok := detectDeploymentReporter(config, "web").(*githubDeployment)
+	req.True(ok)
+	req.Equal("https://api.github.com", reporter.apiURL)
+	req.Equal("web", reporter.environment)
+
+	clearDeploymentStatusEnv(t)
+	t.Setenv("GITLAB_CI", "true")
+	t.Setenv("GITLAB_TOKEN", "token")
+	t.Setenv("CI_COMMIT_TAG", "v1.2.0")
+	gitlab, ok := detectDeploymentReporter(&DeploymentStatusConfig{Environment: "production"}, "web").(*gitlabDeployment)
+	req.True(ok)
+	req.True(gitlab.tag)
+	req.Equal("production", gitlab.environment)
+}
+
+func TestGithubDeployment(t *testing.T) {
+	req := require.New(t)
+	server, requests := newRecordingServer(t, `{"id":42}`)
+	deployment := &githubDeployment{apiURL: server.URL, repository: "anduin/web", sha: "abc123", token: "secret", environment: "web", environmentURL: "https://web.example.com"}
+	req.Nil(deployment.Start())
+	req.Nil(deployment.Finish(false))
+	recorded := requests()
+	req.Len(recorded, 3)
+	req.Equal("/repos/anduin/web/deployments", recorded[0].path)
+	req.Equal("abc123", recorded[0].body["ref"])
+	req.Equal("token secret", recorded[0].header.Get("Authorization"))
+	req.Equal("/repos/anduin/web/deployments/42/statuses", recorded[1].path)
+	req.Equal("in_progress", recorded[1].body["state"])
+	req.Equal("failure", recorded[2].body["state"])
+	req.Equal("https://web.example.com", recorded[2].body["environment_url"])
+
+	// Nothing to finish when the deployment was never created
+	req.Nil((&githubDeployment{apiURL: server.URL}).Finish(true))
+	req.Len(requests(), 3)
+}
+
+func TestGitlabDeployment(t *testing.T) {
+	req := require.New(t)
+	server, requests := newRecordingServer(t, `{"id":7}`)
+	deployment := &gitlabDeployment{apiURL: server.URL, projectID: "anduin/web", sha: "abc123", ref: "master", token: "secret", environment: "web"}
+	req.Nil(deployment.Start())
+	req.Nil(deployment.Finish(true))
+	recorded := requests()
+	req.Len(recorded, 2)
+	req.Equal("POST", recorded[0].method)
+	req.Equal("/projects/anduin/web/deployments", recorded[0].path)
+	req.Equal("running", recorded[0].body["status"])
+	req.Equal("secret", recorded[0].header.Get("PRIVATE-TOKEN"))
+	req.Equal("PUT", recorded[1].method)
+	req.Equal("/projects/anduin/web/deployments/7", recorded[1].path)
+	req.Equal("success", recorded[1].body["status"])
+}
@@ -0,0 +1,348 @@
+package deploy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// manifestMapSlice converts a typed resource to an ordered document with
+// apiVersion and kind first and the always-empty fields removed
+func manifestMapSlice(kind string, resource interface{}) (yaml.MapSlice, error) {
+	resourceType, ok := lookupResourceType(kind, resource)
+	if !ok {
+		return nil, UnsupportedResource(kind)
+	}
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	document := yaml.MapSlice{}
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+	manifest := yaml.MapSlice{
+		{Key: "apiVersion", Value: resourceType.APIVersion},
+		{Key: "kind", Value: resourceType.Kind},
+	}
+	for _, item := range document {
+		switch item.Key {
+		case "apiVersion", "kind", "status":
+			continue
+		}
+		manifest = append(manifest, yaml.MapItem{Key: item.Key, Value: removeNullValues(item.Value)})
+	}
+	return manifest, nil
+}
+
+// pruneLike keeps only the parts of live that desired also sets, so fields
+// defaulted by the server don't show up as differences
+func pruneLike(live, desired interface{}) interface{} {
+	switch desired := desired.(type) {
+	case yaml.MapSlice:
+		liveMap, ok := live.(yaml.MapSlice)
+		if !ok {
+			return live
+		}
+		result := yaml.MapSlice{}
+		for _, item := range desired {
+			value := getMapSliceItem(liveMap, item.Key.(string))
+			if value == nil {
+				continue
+			}
+			result = append(result, yaml.MapItem{Key: item.Key, Value: pruneLike(value, item.Value)})
+		}
+		return result
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok {
+			return live
+		}
+		result := []interface{}{}
+		for i, value := range liveList {
+			if i < len(desired) {
+				value = pruneLike(value, desired[i])
+			}
+			result = append(result, value)
+		}
+		return result
+	}
+	return live
+}
+
+// redactionKeySecret holds the key secret values are hashed with, one per
+// cluster: hashes compare between runs and people, but a pasted diff can't
+// be brute forced back to short values without the key
+const redactionKeySecret = "imladris-redaction-key"
+
+// secretHash identifies a secret value without revealing it
+func secretHash(key, value []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(value)
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// redactionKey returns the key of the cluster the asset goes to. Without
+// access to it a random key still hides the values, its hashes just don't
+// compare with other runs.
+func (p *Project) redactionKey(asset *Asset) []byte {
+	key, ok := p.redactionKeys[asset.context]
+	if ok {
+		return key
+	}
+	kubeClient := p.clientFor(asset)
+	var err error
+	if kubeClient != nil {
+		key, err = loadRedactionKey(kubeClient)
+	}
+	if kubeClient == nil || err != nil {
+		if err != nil {
+			p.printer.ErrPrintf(ColorYellow, "Warning: cannot read secret kube-system/%s, secret hashes only compare within this run: %s\n", redactionKeySecret, err.Error())
+		}
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	if p.redactionKeys == nil {
+		p.redactionKeys = make(map[string][]byte)
+	}
+	p.redactionKeys[asset.context] = key
+	return key
+}
+
+// loadRedactionKey reads the key of the cluster, creating it on first use
+func loadRedactionKey(kubeClient *kubernetes.Clientset) ([]byte, error) {
+	secrets := kubeClient.Core().Secrets("kube-system")
+	secret, err := secrets.Get(redactionKeySecret, apiv1.GetOptions{})
+	if isResourceNotExist(err) {
+		key := make([]byte, 32)
+		_, err = rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+		secret, err = secrets.Create(&v1.Secret{ObjectMeta: apiv1.ObjectMeta{Name: redactionKeySecret}, Data: map[string][]byte{"key": key}})
+		if errors.IsAlreadyExists(err) {
+			secret, err = secrets.Get(redactionKeySecret, apiv1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(secret.Data["key"]) == 0 {
+		return nil, fmt.Errorf("secret %s has no key", redactionKeySecret)
+	}
+	return secret.Data["key"], nil
+}
+
+// redactSecret replaces every secret value with its hash; stringData is
+// folded into data the way the API server does
+func redactSecret(document yaml.MapSlice, key []byte) yaml.MapSlice {
+	data := yaml.MapSlice{}
+	if encoded, ok := getMapSliceItem(document, "data").(yaml.MapSlice); ok {
+		for _, item := range encoded {
+			value, err := base64.StdEncoding.DecodeString(fmt.Sprint(item.Value))
+			if err != nil {
+				value = []byte(fmt.Sprint(item.Value))
+			}
+			data = setMapSliceItem(data, fmt.Sprint(item.Key), secretHash(key, value))
+		}
+	}
+	if plain, ok := getMapSliceItem(document, "stringData").(yaml.MapSlice); ok {
+		for _, item := range plain {
+			data = setMapSliceItem(data, fmt.Sprint(item.Key), secretHash(key, []byte(fmt.Sprint(item.Value))))
+		}
+	}
+	document = removeMapSliceKeys(document, "stringData")
+	if len(data) == 0 {
+		return removeMapSliceKeys(document, "data")
+	}
+	return setMapSliceItem(document, "data", data)
+}
+
+// secretChanges lists added, removed and changed keys with value hashes
+func secretChanges(live, desired *v1.Secret, hashKey []byte) []string {
+	desiredData := make(map[string][]byte)
+	for key, value := range desired.Data {
+		desiredData[key] = value
+	}
+	for key, value := range desired.StringData {
+		desiredData[key] = []byte(value)
+	}
+	keys := []string{}
+	for key := range desiredData {
+		keys = append(keys, key)
+	}
+	for key := range live.Data {
+		if _, ok := desiredData[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	changes := []string{}
+	for _, key := range keys {
+		desiredValue, inDesired := desiredData[key]
+		liveValue, inLive := live.Data[key]
+		switch {
+		case !inLive:
+			changes = append(changes, fmt.Sprintf("+ %s (%s)", key, secretHash(hashKey, desiredValue)))
+		case !inDesired:
+			changes = append(changes, fmt.Sprintf("- %s (%s)", key, secretHash(hashKey, liveValue)))
+		case string(liveValue) != string(desiredValue):
+			changes = append(changes, fmt.Sprintf("~ %s (%s -> %s)", key, secretHash(hashKey, liveValue), secretHash(hashKey, desiredValue)))
+		}
+	}
+	return changes
+}
+
+// diffLines returns a line diff of a and b with a few lines of context around
+// each change, or nil when they are equal
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	lines := []string{}
+	changed := false
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+a[i])
+			changed = true
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			changed = true
+			j++
+		}
+	}
+	if !changed {
+		return nil
+	}
+	const context = 2
+	result := []string{}
+	last := -1
+	for k, line := range lines {
+		near := false
+		for c := k - context; c <= k+context; c++ {
+			if c >= 0 && c < len(lines) && !strings.HasPrefix(lines[c], "  ") {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if last >= 0 && k > last+1 {
+			result = append(result, "  ...")
+		}
+		result = append(result, line)
+		last = k
+	}
+	return result
+}
+
+// diffAsset compares the desired resource with the live one. Secret values
+// are shown as hashes only, so the output is safe to paste anywhere.
+func (p *Project) diffAsset(asset *Asset) ([]string, error) {
+	desired, err := manifestMapSlice(asset.Kind, asset.ResourceData)
+	if err != nil {
+		return nil, err
+	}
+	desired = removeMapSliceKeys(desired, "status")
+	var current yaml.MapSlice
+	live, found, err := p.liveResources(asset).get(asset.Kind, asset.ResourceData.(Meta).GetName())
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		current, err = manifestMapSlice(asset.Kind, live)
+		if err != nil {
+			return nil, err
+		}
+		current = pruneLike(current, desired).(yaml.MapSlice)
+	}
+	if asset.Kind == "secret" {
+		key := p.redactionKey(asset)
+		desired = redactSecret(desired, key)
+		if current != nil {
+			current = redactSecret(current, key)
+		}
+	}
+	desiredData, err := yaml.Marshal(desired)
+	if err != nil {
+		return nil, err
+	}
+	currentLines := []string{}
+	if current != nil {
+		currentData, err := yaml.Marshal(current)
+		if err != nil {
+			return nil, err
+		}
+		currentLines = strings.Split(strings.TrimRight(string(currentData), "\n"), "\n")
+	}
+	return diffLines(currentLines, strings.Split(strings.TrimRight(string(desiredData), "\n"), "\n")), nil
+}
+
+func (p *Project) Diff() error {
+	for _, asset := range p.assets() {
+		p.printer.Printf(ColorYellow, "Diffing %s %q from namespace %q\n", asset.Kind, asset.ResourceData.(Meta).GetName(), asset.Namespace())
+		lines, err := p.diffAsset(asset)
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			p.printer.Println(ColorGray, "====> No changes")
+			continue
+		}
+		for _, line := range lines {
+			switch line[0] {
+			case '+':
+				p.printer.Println(ColorGreen, line)
+			case '-':
+				p.printer.Println(ColorRed, line)
+			default:
+				p.printer.Println(ColorGray, line)
+			}
+		}
+	}
+	p.estimateCost()
+	return nil
+}
+
+func (p *Project) logSecretChanges(asset *Asset) {
+	live, found, err := p.liveResources(asset).get(asset.Kind, asset.ResourceData.(Meta).GetName())
+	if err != nil || !found {
+		return
+	}
+	for _, change := range secretChanges(live.(*v1.Secret), asset.ResourceData.(*v1.Secret), p.redactionKey(asset)) {
+		p.printer.Printf(ColorPurple, "====> %s\n", change)
+	}
+}
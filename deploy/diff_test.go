@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/core/v1"
+)
+
+func TestDiffLines(t *testing.T) {
+	req := require.New(t)
+	req.Nil(diffLines([]string{"a", "b"}, []string{"a", "b"}))
+	lines := diffLines([]string{"a", "b", "c", "d", "e", "f", "g"}, []string{"a", "b", "c", "x", "e", "f", "g"})
+	req.Equal([]string{"  b", "  c", "- d", "+ x", "  e", "  f"}, lines)
+}
+
+func TestSecretRedaction(t *testing.T) {
+	req := require.New(t)
+	live := &v1.Secret{Data: map[string][]byte{"password": []byte("hunter2"), "old": []byte("x")}}
+	desired := &v1.Secret{Data: map[string][]byte{"password": []byte("hunter3")}, StringData: map[string]string{"token": "abc"}}
+	key := []byte("cluster key")
+	changes := secretChanges(live, desired, key)
+	req.Len(changes, 3)
+	req.True(strings.HasPrefix(changes[0], "- old (hmac:"))
+	req.True(strings.HasPrefix(changes[1], "~ password (hmac:"))
+	req.True(strings.HasPrefix(changes[2], "+ token (hmac:"))
+	req.NotEqual(secretChanges(live, desired, []byte("other key")), changes)
+	manifest, err := manifestMapSlice("secret", desired)
+	req.NoError(err)
+	data, err := yaml.Marshal(redactSecret(manifest, key))
+	req.NoError(err)
+	req.NotContains(string(data), "hunter3")
+	req.NotContains(string(data), "abc")
+	req.Contains(string(data), "token: "+secretHash(key, []byte("abc")))
+}
+
+func TestRedactionKey(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	asset := &Asset{Kind: "secret", ResourceData: &v1.Secret{}}
+
+	// Created on first use, then shared by every run against the cluster
+	key := (&Project{kubeClient: kubeClient}).redactionKey(asset)
+	req.Len(key, 32)
+	req.NotNil(cluster.get("/api/v1/namespaces/kube-system/secrets/" + redactionKeySecret))
+	req.Equal(key, (&Project{kubeClient: kubeClient}).redactionKey(asset))
+
+	// Not allowed to read it, values stay hidden behind a key of this run
+	cluster.reject = func(method, path string) *fakeStatus {
+		return &fakeStatus{http.StatusForbidden, "Forbidden", "secrets is forbidden"}
+	}
+	p := &Project{kubeClient: kubeClient}
+	other := p.redactionKey(asset)
+	req.Len(other, 32)
+	req.NotEqual(key, other)
+	req.Equal(other, p.redactionKey(asset))
+}
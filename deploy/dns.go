@@ -0,0 +1,143 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	externalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+	externalDNSTTLAnnotation      = "external-dns.alpha.kubernetes.io/ttl"
+)
+
+// DNSRecord points a hostname at the address of a LoadBalancer service.
+// With the external-dns provider the service is only annotated and
+// external-dns does the rest; with a script the script is run once the
+// address is known, with IMLADRIS_DNS_HOSTNAME, IMLADRIS_DNS_ADDRESS and
+// IMLADRIS_DNS_TTL set, e.g. to call the route53 or gcloud cli.
+type DNSRecord struct {
+	Service  string `yaml:"service"`
+	Hostname string `yaml:"hostname"`
+	TTL      int    `yaml:"ttl"`
+	Provider string `yaml:"provider"`
+	Script   string `yaml:"script"`
+}
+
+// annotateExternalDNS runs at load time so the annotations are part of the
+// applied manifest
+func (p *Project) annotateExternalDNS() error {
+	for _, record := range p.projectConfig.DNS {
+		switch record.Provider {
+		case "external-dns":
+		case "script", "":
+			if record.Script == "" {
+				return fmt.Errorf("dns record %q has no script", record.Hostname)
+			}
+			continue
+		default:
+			return fmt.Errorf("dns record %q: unknown provider %q, expected external-dns or script", record.Hostname, record.Provider)
+		}
+		asset := p.findAsset("service", record.Service)
+		if asset == nil {
+			return fmt.Errorf("dns record %q: service %q not found in project", record.Hostname, record.Service)
+		}
+		err := requireNetwork(fmt.Sprintf("dns script for %q", record.Hostname))
+		if err != nil {
+			return err
+		}
+		service := asset.ResourceData.(*v1.Service)
+		if service.Annotations == nil {
+			service.Annotations = make(map[string]string)
+		}
+		service.Annotations[externalDNSHostnameAnnotation] = record.Hostname
+		if record.TTL > 0 {
+			service.Annotations[externalDNSTTLAnnotation] = strconv.Itoa(record.TTL)
+		}
+	}
+	return nil
+}
+
+func (p *Project) updateDNSRecords() error {
+	for _, record := range p.projectConfig.DNS {
+		if record.Provider == "external-dns" {
+			continue
+		}
+		asset := p.findAsset("service", record.Service)
+		if asset == nil {
+			return fmt.Errorf("dns record %q: service %q not found in project", record.Hostname, record.Service)
+		}
+		p.printer.Printf(ColorYellow, "==> Waiting for load balancer address of service %q\n", record.Service)
+		address, err := waitForLoadBalancer(p.clientFor(asset), record.Service, asset.Namespace(), p.config.timeout)
+		if err != nil {
+			return err
+		}
+		p.printer.Printf(ColorYellow, "==> Pointing %s at %s\n", record.Hostname, address)
+		ttl := record.TTL
+		if ttl <= 0 {
+			ttl = 300
+		}
+		cmd := shellCommand(record.Script)
+		cmd.Dir = p.projectConfig.RootFolder
+		cmd.Env = append(os.Environ(),
+			"IMLADRIS_DNS_HOSTNAME="+record.Hostname,
+			"IMLADRIS_DNS_ADDRESS="+address,
+			"IMLADRIS_DNS_TTL="+strconv.Itoa(ttl),
+		)
+		cmd.Stdout = p.printer.Stdout()
+		cmd.Stderr = p.printer.Stderr()
+		err = cmd.Run()
+		if err != nil {
+			return fmt.Errorf("dns script for %q failed: %s", record.Hostname, err.Error())
+		}
+		p.printer.Println(ColorGreen, "====> Success")
+	}
+	return nil
+}
+
+func loadBalancerAddress(service *v1.Service) string {
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return ingress.IP
+		}
+		if ingress.Hostname != "" {
+			return ingress.Hostname
+		}
+	}
+	return ""
+}
+
+// waitForLoadBalancer returns the address the cloud provider assigned to a
+// LoadBalancer service, which can take minutes after creation
+func waitForLoadBalancer(kubeClient *kubernetes.Clientset, name, namespace string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		service, err := kubeClient.Core().Services(namespace).Get(name, apiv1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if service.Spec.Type != v1.ServiceTypeLoadBalancer {
+			return "", fmt.Errorf("service %q is of type %s, not LoadBalancer", name, service.Spec.Type)
+		}
+		address := loadBalancerAddress(service)
+		if address != "" {
+			return address, nil
+		}
+		if time.Now().After(deadline) {
+			return "", newTypedError(ErrorTypeTimeout, "timeout while waiting for the load balancer of service %q", name)
+		}
+		watchUntil(kubeClient, "service", namespace, apiv1.ListOptions{
+			FieldSelector:   "metadata.name=" + name,
+			ResourceVersion: service.ResourceVersion,
+		}, deadline, func(event watch.Event) bool {
+			service, ok := event.Object.(*v1.Service)
+			return ok && loadBalancerAddress(service) != ""
+		})
+	}
+}
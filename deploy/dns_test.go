@@ -0,0 +1,31 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAnnotateExternalDNS(t *testing.T) {
+	req := require.New(t)
+	service := &v1.Service{ObjectMeta: apiv1.ObjectMeta{Name: "api"}}
+	p := &Project{
+		projectConfig: &ProjectConfig{DNS: []*DNSRecord{
+			{Service: "api", Hostname: "api.example.com", TTL: 60, Provider: "external-dns"},
+		}},
+		services: []*Asset{{Kind: "service", ResourceData: service}},
+	}
+	req.NoError(p.annotateExternalDNS())
+	req.Equal("api.example.com", service.Annotations[externalDNSHostnameAnnotation])
+	req.Equal("60", service.Annotations[externalDNSTTLAnnotation])
+
+	p.projectConfig.DNS = []*DNSRecord{{Service: "api", Hostname: "api.example.com"}}
+	req.Error(p.annotateExternalDNS())
+	p.projectConfig.DNS = []*DNSRecord{{Service: "web", Hostname: "web.example.com", Provider: "external-dns"}}
+	req.Error(p.annotateExternalDNS())
+
+	service.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{Hostname: "abc.elb.amazonaws.com"}}
+	req.Equal("abc.elb.amazonaws.com", loadBalancerAddress(service))
+}
@@ -1,10 +1,9 @@
-package main
+package deploy
 
 import (
 	"bytes"
 	"errors"
 	"io/ioutil"
-	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -15,11 +14,16 @@ func checkDockerCommand() error {
 	return cmd.Run()
 }
 
-func dockerBuildImage(buildContext, tag string) error {
-	Printf(ColorYellow, "Building docker image %q in %q\n", tag, buildContext)
-	cmd := exec.Command("docker", "build", "-t", tag, buildContext)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func dockerBuildImage(out *printer, buildContext, tag string) error {
+	out.Printf(ColorYellow, "Building docker image %q in %q\n", tag, buildContext)
+	args := []string{"build", "-t", tag}
+	if offline {
+		// Base images must already be on the host, RUN steps get no network
+		args = append(args, "--network", "none", "--pull=false")
+	}
+	cmd := exec.Command("docker", append(args, buildContext)...)
+	cmd.Stdout = out.Stdout()
+	cmd.Stderr = out.Stderr()
 	err := cmd.Run()
 	if err == nil {
 		return nil
@@ -27,7 +31,11 @@ func dockerBuildImage(buildContext, tag string) error {
 	return errors.New("cannot build docker image")
 }
 
-func dockerLogin(rootFolder string, credential *DockerCredential) error {
+func dockerLogin(out *printer, rootFolder string, credential *DockerCredential) error {
+	err := requireNetwork("docker login")
+	if err != nil {
+		return err
+	}
 	host := credential.Host
 	username := credential.Username
 	password := credential.Password
@@ -41,23 +49,23 @@ func dockerLogin(rootFolder string, credential *DockerCredential) error {
 	}
 	var cmd *exec.Cmd
 	if host == "" {
-		Println(ColorPurple, "Logging in to default docker registry")
+		out.Println(ColorPurple, "Logging in to default docker registry")
 		cmd = exec.Command("docker", "login", "-u", username, "-p", password)
 	} else {
-		Printf(ColorPurple, "Logging in to docker registry %q\n", host)
+		out.Printf(ColorPurple, "Logging in to docker registry %q\n", host)
 		cmd = exec.Command("docker", "login", "-u", username, "-p", password, host)
 	}
 	errBuffer := &bytes.Buffer{}
 	cmd.Stderr = errBuffer
-	err := cmd.Run()
+	err = cmd.Run()
 	if err == nil {
 		return nil
 	}
 	return errors.New(errBuffer.String())
 }
 
-func dockerRmi(name string) error {
-	Printf(ColorYellow, "Auto clean image %s\n", name)
+func dockerRmi(out *printer, name string) error {
+	out.Printf(ColorYellow, "Auto clean image %s\n", name)
 	var stdErr string
 	for i := 0; i < 20; i++ {
 		cmd := exec.Command("docker", "rmi", name)
@@ -65,7 +73,7 @@ func dockerRmi(name string) error {
 		cmd.Stderr = errBuffer
 		err := cmd.Run()
 		if err == nil {
-			Printf(ColorGreen, "====> Success\n")
+			out.Printf(ColorGreen, "====> Success\n")
 			return nil
 		}
 		stdErr = errBuffer.String()
@@ -91,24 +99,28 @@ func dockerImageExistLocally(name string) bool {
 	return false
 }
 
-func dockerPull(name string) error {
+func dockerPull(out *printer, name string) error {
 	if dockerImageExistLocally(name) {
 		return nil
 	}
-	Printf(ColorYellow, "Pulling image %s\n", name)
+	err := requireNetwork("pull image " + name)
+	if err != nil {
+		return err
+	}
+	out.Printf(ColorYellow, "Pulling image %s\n", name)
 	cmd := exec.Command("docker", "pull", name)
 	errBuffer := &bytes.Buffer{}
 	cmd.Stderr = errBuffer
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+	cmd.Stdout = out.Stdout()
+	err = cmd.Run()
 	if err == nil {
 		return err
 	}
 	return errors.New(errBuffer.String())
 }
 
-func dockerPush(name string, pushLatest bool) error {
-	err := doDockerPush(name)
+func dockerPush(out *printer, name string, pushLatest bool) error {
+	err := doDockerPush(out, name)
 	if err != nil {
 		return err
 	}
@@ -118,32 +130,36 @@ func dockerPush(name string, pushLatest bool) error {
 	pieces := strings.Split(name, ":")
 	imageName := pieces[0]
 	latestImage := imageName + ":latest"
-	err = dockerTag(name, latestImage)
+	err = dockerTag(out, name, latestImage)
 	if err != nil {
 		return err
 	}
-	return doDockerPush(latestImage)
+	return doDockerPush(out, latestImage)
 }
 
-func doDockerPush(name string) error {
-	Printf(ColorYellow, "Pushing image %s\n", name)
+func doDockerPush(out *printer, name string) error {
+	err := requireNetwork("push image " + name)
+	if err != nil {
+		return err
+	}
+	out.Printf(ColorYellow, "Pushing image %s\n", name)
 	cmd := exec.Command("docker", "push", name)
 	errBuffer := &bytes.Buffer{}
 	cmd.Stderr = errBuffer
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+	cmd.Stdout = out.Stdout()
+	err = cmd.Run()
 	if err != nil {
 		return errors.New(errBuffer.String())
 	}
 	return nil
 }
 
-func dockerTag(name, alias string) error {
-	Printf(ColorYellow, "Tagging %q as %q\n", name, alias)
+func dockerTag(out *printer, name, alias string) error {
+	out.Printf(ColorYellow, "Tagging %q as %q\n", name, alias)
 	cmd := exec.Command("docker", "tag", name, alias)
 	errBuffer := &bytes.Buffer{}
 	cmd.Stderr = errBuffer
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = out.Stdout()
 	err := cmd.Run()
 	if err != nil {
 		return errors.New(errBuffer.String())
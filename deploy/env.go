@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+)
+
+// CommonEnv is an env block merged into the containers of every matching
+// workload. Resources defaults to all workloads and containers to all
+// containers. Variables a container already sets are left alone.
+type CommonEnv struct {
+	Resources  []string      `yaml:"resources"`
+	Containers []string      `yaml:"containers"`
+	Env        []interface{} `yaml:"env"`
+}
+
+func (p *Project) applyCommonEnv() error {
+	for _, commonEnv := range p.projectConfig.CommonEnv {
+		patterns := commonEnv.Resources
+		if len(patterns) == 0 {
+			patterns = []string{"*/*"}
+		}
+		filter, err := parseResourceFilter(strings.Join(patterns, ","))
+		if err != nil {
+			return fmt.Errorf("common_env: %s", err.Error())
+		}
+		env := []v1.EnvVar{}
+		for _, value := range commonEnv.Env {
+			envVar := v1.EnvVar{}
+			err = decodeKubeObject(value, &envVar)
+			if err != nil {
+				return fmt.Errorf("common_env: %s", err.Error())
+			}
+			env = append(env, envVar)
+		}
+		for _, asset := range p.assets() {
+			podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+			if podSpec == nil || !filter.matches(asset) {
+				continue
+			}
+			for i := range podSpec.Containers {
+				if len(commonEnv.Containers) == 0 || containsString(commonEnv.Containers, podSpec.Containers[i].Name) {
+					mergeEnv(&podSpec.Containers[i], env)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mergeEnv appends the variables the container doesn't define itself
+func mergeEnv(container *v1.Container, env []v1.EnvVar) {
+	existing := make(map[string]bool)
+	for _, envVar := range container.Env {
+		existing[envVar.Name] = true
+	}
+	for _, envVar := range env {
+		if !existing[envVar.Name] {
+			container.Env = append(container.Env, *envVar.DeepCopy())
+		}
+	}
+}
+
+// StampConfig adds standard env vars to every container: pod identity from
+// the downward API, and the build the deploy was made from
+type StampConfig struct {
+	DownwardAPI bool `yaml:"downward_api"`
+	Build       bool `yaml:"build"`
+}
+
+func downwardAPIEnv() []v1.EnvVar {
+	fieldRef := func(name, path string) v1.EnvVar {
+		return v1.EnvVar{Name: name, ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: path}}}
+	}
+	return []v1.EnvVar{
+		fieldRef("POD_NAME", "metadata.name"),
+		fieldRef("POD_NAMESPACE", "metadata.namespace"),
+		fieldRef("POD_IP", "status.podIP"),
+		fieldRef("NODE_NAME", "spec.nodeName"),
+	}
+}
+
+// buildEnv reads the CI variables, so the values change on every deploy and
+// with them the pod template: stamped workloads roll out each time
+func (p *Project) buildEnv() []v1.EnvVar {
+	env := []v1.EnvVar{}
+	for _, stamp := range []struct{ name, variable string }{
+		{"GIT_SHA", "app_var_git_sha"},
+		{"GIT_BRANCH", "app_var_git_branch"},
+		{"GIT_TAG", "app_var_git_tag"},
+		{"BUILD_NUMBER", "app_var_build_number"},
+		{"RELEASE", "app_var_release"},
+	} {
+		value := p.projectConfig.Variables[stamp.variable]
+		if value != "" {
+			env = append(env, v1.EnvVar{Name: stamp.name, Value: value})
+		}
+	}
+	return env
+}
+
+func (p *Project) stampMetadata() error {
+	stamp := p.projectConfig.Stamp
+	if stamp == nil {
+		return nil
+	}
+	env := []v1.EnvVar{}
+	if stamp.DownwardAPI {
+		env = append(env, downwardAPIEnv()...)
+	}
+	if stamp.Build {
+		env = append(env, p.buildEnv()...)
+	}
+	for _, asset := range p.assets() {
+		podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+		if podSpec == nil {
+			continue
+		}
+		for i := range podSpec.Containers {
+			mergeEnv(&podSpec.Containers[i], env)
+		}
+	}
+	return nil
+}
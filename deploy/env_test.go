@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCommonEnv(t *testing.T) {
+	req := require.New(t)
+	commonEnv := []*CommonEnv{}
+	req.NoError(yaml.Unmarshal([]byte(`
+- env:
+  - name: SENTRY_DSN
+    value: https://key@sentry.example.com/1
+  - name: LOG_LEVEL
+    value: info
+- resources: [deployment/web]
+  containers: [web]
+  env:
+  - name: DB_PASSWORD
+    valueFrom:
+      secretKeyRef:
+        name: db
+        key: password
+`), &commonEnv))
+	web := &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "web"}}
+	web.Spec.Template.Spec.Containers = []v1.Container{
+		{Name: "web", Env: []v1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}}},
+		{Name: "proxy"},
+	}
+	p := &Project{
+		projectConfig: &ProjectConfig{CommonEnv: commonEnv},
+		services:      []*Asset{{Kind: "deployment", ResourceData: web}},
+	}
+	req.NoError(p.applyCommonEnv())
+	env := web.Spec.Template.Spec.Containers[0].Env
+	req.Len(env, 3)
+	req.Equal(v1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}, env[0])
+	req.Equal("SENTRY_DSN", env[1].Name)
+	req.Equal("password", env[2].ValueFrom.SecretKeyRef.Key)
+	req.Len(web.Spec.Template.Spec.Containers[1].Env, 2)
+}
+
+func TestStampMetadata(t *testing.T) {
+	req := require.New(t)
+	web := &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "web"}}
+	web.Spec.Template.Spec.Containers = []v1.Container{{Name: "web", Env: []v1.EnvVar{{Name: "RELEASE", Value: "v2"}}}}
+	p := &Project{
+		projectConfig: &ProjectConfig{
+			Stamp: &StampConfig{DownwardAPI: true, Build: true},
+			Variables: map[string]string{
+				"app_var_git_sha": "0123456789abcdef",
+				"app_var_release": "20180102-030405",
+			},
+		},
+		services: []*Asset{{Kind: "deployment", ResourceData: web}},
+	}
+	req.NoError(p.stampMetadata())
+	env := make(map[string]v1.EnvVar)
+	for _, envVar := range web.Spec.Template.Spec.Containers[0].Env {
+		env[envVar.Name] = envVar
+	}
+	req.Equal("metadata.namespace", env["POD_NAMESPACE"].ValueFrom.FieldRef.FieldPath)
+	req.Equal("spec.nodeName", env["NODE_NAME"].ValueFrom.FieldRef.FieldPath)
+	req.Equal("0123456789abcdef", env["GIT_SHA"].Value)
+	req.Equal("v2", env["RELEASE"].Value)
+	_, ok := env["GIT_TAG"]
+	req.False(ok)
+}
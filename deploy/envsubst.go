@@ -0,0 +1,101 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// EnvsubstConfig replaces text/template with plain ${VAR} substitution, for
+// manifests written for envsubst. Allow lists the variables to substitute,
+// a trailing * matches a prefix, and is required: the environment holds
+// secrets a manifest should not read by accident. Other ${...} are left
+// alone, like the shell scripts of a configmap. $${VAR} is written as ${VAR}.
+type EnvsubstConfig struct {
+	Allow []string `yaml:"allow"`
+}
+
+var envsubstPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+func (c *EnvsubstConfig) allowed(name string) bool {
+	for _, allowed := range c.Allow {
+		if allowed == name || strings.HasSuffix(allowed, "*") && strings.HasPrefix(name, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// references lists the allowed ${VAR} of a manifest with their
+// file:line:col
+func (c *EnvsubstConfig) references(filename string, data []byte) []templateReference {
+	references := []templateReference{}
+	for _, match := range envsubstPattern.FindAllSubmatchIndex(data, -1) {
+		if match[2] < 0 {
+			continue
+		}
+		name := string(data[match[2]:match[3]])
+		if !c.allowed(name) {
+			continue
+		}
+		line := bytes.Count(data[:match[0]], []byte("\n")) + 1
+		column := match[0] - bytes.LastIndex(data[:match[0]], []byte("\n"))
+		references = append(references, templateReference{name: name, location: fmt.Sprintf("%s:%d:%d", filename, line, column)})
+	}
+	return references
+}
+
+// substitute replaces the allowed ${VAR} of a manifest, every variable must
+// be in variables
+func (c *EnvsubstConfig) substitute(filename string, data []byte, variables map[string]string) (*bytes.Buffer, error) {
+	err := undefinedVariablesError(c.references(filename, data), variables)
+	if err != nil {
+		return nil, err
+	}
+	rendered := envsubstPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		if string(match) == "$${" {
+			return []byte("${")
+		}
+		name := string(match[2 : len(match)-1])
+		if !c.allowed(name) {
+			return match
+		}
+		return []byte(variables[name])
+	})
+	return bytes.NewBuffer(rendered), nil
+}
+
+// envsubstVariables are the environment, overridden by the template
+// variables so -variable still wins. Only the names the manifests use are
+// read, the rest of the environment never reaches a manifest.
+func (p *Project) envsubstVariables(names []string) map[string]string {
+	variables := make(map[string]string)
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			variables[name] = value
+		}
+	}
+	for key, value := range p.projectConfig.Variables {
+		variables[key] = value
+	}
+	return variables
+}
+
+func (p *Project) envsubst(filename string, data []byte) (*bytes.Buffer, error) {
+	config := p.projectConfig.Envsubst
+	if len(config.Allow) == 0 {
+		return nil, validationError(fmt.Errorf("envsubst substitutes nothing without an allow list"))
+	}
+	references := config.references(filename, data)
+	if p.lint != nil {
+		p.lint.record(references)
+		return nil, nil
+	}
+	names := []string{}
+	for _, reference := range references {
+		names = append(names, reference.name)
+	}
+	return config.substitute(filename, data, p.envsubstVariables(names))
+}
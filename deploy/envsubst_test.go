@@ -0,0 +1,52 @@
+package deploy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+)
+
+func TestEnvsubst(t *testing.T) {
+	req := require.New(t)
+	config := &EnvsubstConfig{Allow: []string{"TAG", "APP_*"}}
+	data := []byte("a: ${TAG}\nb: ${APP_NAME} ${HOME} $${TAG}\n")
+	buf, err := config.substitute("web.yml", data, map[string]string{"TAG": "1.0", "APP_NAME": "web"})
+	req.NoError(err)
+	req.Equal("a: 1.0\nb: web ${HOME} ${TAG}\n", buf.String())
+
+	_, err = config.substitute("web.yml", data, map[string]string{})
+	req.Error(err)
+	req.Contains(err.Error(), `"TAG" at web.yml:1:4`)
+	req.Contains(err.Error(), `"APP_NAME" at web.yml:2:4`)
+}
+
+func TestEnvsubstDeniesByDefault(t *testing.T) {
+	req := require.New(t)
+	config := &EnvsubstConfig{}
+	buf, err := config.substitute("web.yml", []byte("a: ${HOME}\n"), map[string]string{})
+	req.NoError(err)
+	req.Equal("a: ${HOME}\n", buf.String())
+
+	p := &Project{projectConfig: &ProjectConfig{Envsubst: config}}
+	_, err = p.renderManifest("web.yml", []byte("a: ${HOME}\n"))
+	req.Error(err)
+	req.Contains(err.Error(), "allow list")
+}
+
+func TestEnvsubstProject(t *testing.T) {
+	req := require.New(t)
+	os.Setenv("IMAGE_TAG", "1.0")
+	defer os.Unsetenv("IMAGE_TAG")
+	_, err := readProject(nil, "test-assets/envsubst-tests", &appConfig{})
+	req.Error(err)
+	req.Contains(err.Error(), `"WEB_REPLICAS"`)
+
+	project, err := readProject(nil, "test-assets/envsubst-tests", &appConfig{variables: variableMap{"WEB_REPLICAS": "2"}})
+	req.NoError(err)
+	data := project.services[0].ResourceData.(*v1.ConfigMap).Data
+	req.Equal("busybox:1.0", data["image"])
+	req.Equal("2", data["replicas"])
+	req.Equal("echo ${HOME} ${IMAGE_TAG} {{ .not_a_template }}\n", data["start.sh"])
+}
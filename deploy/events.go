@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const eventSource = "imladris"
+
+func (p *Project) postEvent(involvedObject v1.ObjectReference, eventType, reason, message string) {
+	if !p.config.events {
+		return
+	}
+	now := apiv1.Now()
+	event := &v1.Event{
+		ObjectMeta: apiv1.ObjectMeta{
+			GenerateName: involvedObject.Name + ".",
+			Namespace:    p.projectConfig.Namespace,
+		},
+		InvolvedObject: involvedObject,
+		Reason:         reason,
+		Message:        scrub(message),
+		Source: v1.EventSource{
+			Component: eventSource,
+		},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           eventType,
+	}
+	_, err := p.kubeClient.Core().Events(p.projectConfig.Namespace).Create(event)
+	if err != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot post %s event for %q: %s\n", reason, involvedObject.Name, err.Error())
+	}
+}
+
+func (p *Project) postResourceEvent(asset *Asset, applyErr error) {
+	if !p.config.events {
+		return
+	}
+	assetName := asset.ResourceData.(Meta).GetName()
+	involvedObject := v1.ObjectReference{
+		Kind:       resourceTypes[asset.Kind].Kind,
+		APIVersion: resourceTypes[asset.Kind].APIVersion,
+		Name:       assetName,
+		Namespace:  asset.Namespace(),
+	}
+	// kubectl describe matches events by uid, so point at the live object when there is one
+	live, err := getResource(p.clientFor(asset), p.plugins, asset.Kind, assetName, asset.Namespace())
+	if err == nil {
+		involvedObject.UID = live.(apiv1.Object).GetUID()
+		involvedObject.ResourceVersion = live.(apiv1.Object).GetResourceVersion()
+	}
+	if applyErr != nil {
+		p.postEvent(involvedObject, v1.EventTypeWarning, "DeployFailed", fmt.Sprintf("Release %s by %s failed: %s", p.releaseID(), deployActor(), applyErr.Error()))
+		return
+	}
+	p.postEvent(involvedObject, v1.EventTypeNormal, "Deployed", fmt.Sprintf("Deployed release %s by %s", p.releaseID(), deployActor()))
+}
+
+func (p *Project) postDeployEvent(eventType, reason, message string) {
+	if !p.config.events {
+		return
+	}
+	involvedObject := v1.ObjectReference{
+		Kind:       "Namespace",
+		APIVersion: "v1",
+		Name:       p.projectConfig.Namespace,
+	}
+	ns, err := p.kubeClient.Core().Namespaces().Get(p.projectConfig.Namespace, apiv1.GetOptions{})
+	if err != nil {
+		// Nothing to attach the event to before the first deploy creates the namespace
+		return
+	}
+	involvedObject.UID = ns.UID
+	p.postEvent(involvedObject, eventType, reason, message)
+}
@@ -0,0 +1,31 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostResourceEvent(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/api/v1/namespaces/web/configmaps/web", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web","uid":"1234"}}`)
+	asset, err := parseAsset("web.yml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: web\n"))
+	req.Nil(err)
+
+	// Off unless asked for, posting events needs its own RBAC
+	req.False(newAppConfig(&Options{}).events)
+	p := &Project{kubeClient: kubeClient, config: newAppConfig(&Options{}), projectConfig: &ProjectConfig{Namespace: "web"}}
+	p.postResourceEvent(asset, nil)
+	req.Empty(cluster.requested("POST", "/api/v1/namespaces/web/events"))
+
+	p.config = newAppConfig(&Options{Events: true})
+	p.postResourceEvent(asset, nil)
+	req.Len(cluster.requested("POST", "/api/v1/namespaces/web/events"), 1)
+	events := cluster.paths("/api/v1/namespaces/web/events/")
+	req.Len(events, 1)
+	event := cluster.get(events[0])
+	req.Equal("Deployed", event["reason"])
+	req.Equal("Normal", event["type"])
+	req.Equal("1234", event["involvedObject"].(map[string]interface{})["uid"])
+}
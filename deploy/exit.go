@@ -0,0 +1,120 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	exitCodeError          = 1
+	exitCodeValidation     = 3
+	exitCodeAuth           = 4
+	exitCodeTimeout        = 5
+	exitCodeRolloutFailure = 6
+	exitCodePartialFailure = 7
+)
+
+type ErrorType string
+
+const (
+	ErrorTypeGeneric        ErrorType = "error"
+	ErrorTypeValidation     ErrorType = "validation"
+	ErrorTypeAuth           ErrorType = "auth"
+	ErrorTypeTimeout        ErrorType = "timeout"
+	ErrorTypeRolloutFailure ErrorType = "rollout_failure"
+	ErrorTypePartialFailure ErrorType = "partial_failure"
+)
+
+var exitCodes = map[ErrorType]int{
+	ErrorTypeGeneric:        exitCodeError,
+	ErrorTypeValidation:     exitCodeValidation,
+	ErrorTypeAuth:           exitCodeAuth,
+	ErrorTypeTimeout:        exitCodeTimeout,
+	ErrorTypeRolloutFailure: exitCodeRolloutFailure,
+	ErrorTypePartialFailure: exitCodePartialFailure,
+}
+
+type TypedError struct {
+	Type ErrorType
+	Err  error
+}
+
+func (err *TypedError) Error() string {
+	return err.Err.Error()
+}
+
+func newTypedError(errorType ErrorType, format string, v ...interface{}) error {
+	return &TypedError{
+		Type: errorType,
+		Err:  fmt.Errorf(format, v...),
+	}
+}
+
+func validationError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*TypedError); ok {
+		return err
+	}
+	return &TypedError{Type: ErrorTypeValidation, Err: err}
+}
+
+// projectError marks what went wrong reading a project as a validation
+// error, unless the cluster failed
+func projectError(err error) error {
+	if _, ok := err.(*errors.StatusError); ok {
+		return err
+	}
+	return validationError(err)
+}
+
+func classifyError(err error) ErrorType {
+	switch err := err.(type) {
+	case *TypedError:
+		return err.Type
+	case UnsupportedResource:
+		return ErrorTypeValidation
+	case *errors.StatusError:
+		switch err.Status().Code {
+		case 401, 403:
+			return ErrorTypeAuth
+		case 408, 504:
+			return ErrorTypeTimeout
+		case 422:
+			return ErrorTypeValidation
+		}
+	}
+	if strings.Contains(err.Error(), "Unauthorized") {
+		return ErrorTypeAuth
+	}
+	return ErrorTypeGeneric
+}
+
+type errorOutput struct {
+	Error struct {
+		Type     ErrorType `json:"type"`
+		Message  string    `json:"message"`
+		ExitCode int       `json:"exit_code"`
+	} `json:"error"`
+}
+
+func exitWithError(config *appConfig, err error) {
+	errorType := classifyError(err)
+	exitCode := exitCodes[errorType]
+	if config.output == "json" {
+		output := &errorOutput{}
+		output.Error.Type = errorType
+		output.Error.Message = scrub(err.Error())
+		output.Error.ExitCode = exitCode
+		data, _ := json.Marshal(output)
+		fmt.Println(string(data))
+	} else {
+		ErrPrintln(ColorRed, err)
+	}
+	os.Exit(exitCode)
+}
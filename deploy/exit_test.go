@@ -0,0 +1,81 @@
+package deploy
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestClassifyError(t *testing.T) {
+	req := require.New(t)
+	req.Equal(ErrorTypeGeneric, classifyError(fmt.Errorf("boom")))
+	req.Equal(ErrorTypeValidation, classifyError(UnsupportedResource("cronjob")))
+	req.Equal(ErrorTypeTimeout, classifyError(newTypedError(ErrorTypeTimeout, "too slow")))
+	req.Equal(ErrorTypeAuth, classifyError(errors.NewForbidden(schema.GroupResource{Resource: "pods"}, "consul", fmt.Errorf("denied"))))
+	req.Equal(ErrorTypeAuth, classifyError(errors.NewUnauthorized("who are you")))
+
+	config := &appConfig{}
+	_, err := readProject(nil, "test-assets/config-tests/simples", config)
+	req.Error(err)
+	req.Equal(ErrorTypeValidation, classifyError(err))
+	req.Equal(exitCodeValidation, exitCodes[classifyError(err)])
+}
+
+func TestClusterErrorTypes(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	p := &Project{kubeClient: kubeClient, config: &appConfig{}, projectConfig: &ProjectConfig{
+		Name:    "web",
+		Cluster: &ClusterAssertion{ConfigMap: &ClusterConfigMap{Name: "cluster-info", Key: "env", Value: "production"}},
+	}}
+	cluster.add("/api/v1/namespaces/kube-system/configmaps/cluster-info", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cluster-info"},"data":{"env":"staging"}}`)
+	err := p.assertCluster()
+	req.Error(err)
+	req.Equal(ErrorTypeValidation, classifyError(err))
+
+	// Not allowed to read the configmap is an auth failure, not a wrong project
+	cluster.reject = func(method, path string) *fakeStatus {
+		return &fakeStatus{http.StatusForbidden, "Forbidden", "configmaps is forbidden"}
+	}
+	err = p.assertCluster()
+	req.Error(err)
+	req.Contains(err.Error(), "refusing to use cluster")
+	req.Equal(ErrorTypeAuth, classifyError(err))
+
+	_, err = kubeClient.Core().ConfigMaps("web").Get("web", apiv1.GetOptions{})
+	req.Equal(ErrorTypeAuth, classifyError(projectError(err)))
+	req.Equal(ErrorTypeValidation, classifyError(projectError(fmt.Errorf("yaml: line 3: did not find expected key"))))
+}
+
+func TestWaitJobStatus(t *testing.T) {
+	req := require.New(t)
+	job := &v1batch.Job{}
+	job.Name = "migrate"
+	done, err := checkJobStatus(job)
+	req.False(done)
+	req.Nil(err)
+	job.Status.Conditions = []v1batch.JobCondition{{Type: v1batch.JobFailed, Message: "BackoffLimitExceeded"}}
+	_, err = checkJobStatus(job)
+	req.EqualError(err, "job failed: BackoffLimitExceeded")
+	req.Equal(ErrorTypeRolloutFailure, classifyError(err))
+	job.Status.Conditions = []v1batch.JobCondition{{Type: v1batch.JobComplete}}
+	done, err = checkJobStatus(job)
+	req.True(done)
+	req.Nil(err)
+
+	decoded, err := jobFromEvent(watch.Event{Type: watch.Modified, Object: job})
+	req.Nil(err)
+	req.Equal(job, decoded)
+	_, err = jobFromEvent(watch.Event{Type: watch.Deleted, Object: job})
+	req.EqualError(err, `job "migrate" was deleted`)
+	req.Equal(ErrorTypeRolloutFailure, classifyError(err))
+	_, err = jobFromEvent(watch.Event{Type: watch.Error, Object: &apiv1.Status{Status: apiv1.StatusFailure, Code: 401, Reason: apiv1.StatusReasonUnauthorized, Message: "who are you"}})
+	req.Equal(ErrorTypeAuth, classifyError(err))
+}
@@ -0,0 +1,260 @@
+package deploy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var serverPopulatedMetadata = []string{"namespace", "uid", "selfLink", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp", "deletionGracePeriodSeconds"}
+
+var serverPopulatedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+}
+
+func exportManifest(kind string, resource interface{}) ([]byte, error) {
+	resourceType, ok := lookupResourceType(kind, resource)
+	if !ok {
+		return nil, UnsupportedResource(kind)
+	}
+	// Go through JSON so the output keeps kubernetes field names and order
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	document := yaml.MapSlice{}
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+	manifest := yaml.MapSlice{
+		{Key: "apiVersion", Value: resourceType.APIVersion},
+		{Key: "kind", Value: resourceType.Kind},
+	}
+	document = removeNulls(document).(yaml.MapSlice)
+	for _, item := range document {
+		switch item.Key {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			item.Value = cleanExportedMetadata(item.Value)
+		case "spec":
+			item.Value = cleanExportedSpec(kind, item.Value)
+		}
+		manifest = append(manifest, item)
+	}
+	return yaml.Marshal(manifest)
+}
+
+func cleanExportedMetadata(value interface{}) interface{} {
+	metadata, ok := value.(yaml.MapSlice)
+	if !ok {
+		return value
+	}
+	metadata = removeMapSliceKeys(metadata, serverPopulatedMetadata...)
+	annotations, ok := getMapSliceItem(metadata, "annotations").(yaml.MapSlice)
+	if !ok {
+		return metadata
+	}
+	annotations = removeMapSliceKeys(annotations, serverPopulatedAnnotations...)
+	if len(annotations) == 0 {
+		return removeMapSliceKeys(metadata, "annotations")
+	}
+	return setMapSliceItem(metadata, "annotations", annotations)
+}
+
+func cleanExportedSpec(kind string, value interface{}) interface{} {
+	spec, ok := value.(yaml.MapSlice)
+	if !ok {
+		return value
+	}
+	switch kind {
+	case "service":
+		return removeMapSliceKeys(spec, "clusterIP")
+	case "statefulset":
+		claims, ok := getMapSliceItem(spec, "volumeClaimTemplates").([]interface{})
+		if !ok {
+			return spec
+		}
+		for i, claim := range claims {
+			if claim, ok := claim.(yaml.MapSlice); ok {
+				claims[i] = removeMapSliceKeys(claim, "status")
+			}
+		}
+		return setMapSliceItem(spec, "volumeClaimTemplates", claims)
+	case "job":
+		spec = removeMapSliceKeys(spec, "selector")
+		template, ok := getMapSliceItem(spec, "template").(yaml.MapSlice)
+		if !ok {
+			return spec
+		}
+		metadata, ok := getMapSliceItem(template, "metadata").(yaml.MapSlice)
+		if !ok {
+			return spec
+		}
+		labels, ok := getMapSliceItem(metadata, "labels").(yaml.MapSlice)
+		if !ok {
+			return spec
+		}
+		labels = removeMapSliceKeys(labels, "controller-uid", "job-name")
+		metadata = setMapSliceItem(metadata, "labels", labels)
+		template = setMapSliceItem(template, "metadata", metadata)
+		return setMapSliceItem(spec, "template", template)
+	}
+	return spec
+}
+
+// removeNulls drops the keys set to null, like the creationTimestamp of pod
+// templates, a manifest leaves them out
+func removeNulls(value interface{}) interface{} {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		result := yaml.MapSlice{}
+		for _, item := range value {
+			if item.Value == nil {
+				continue
+			}
+			result = append(result, yaml.MapItem{Key: item.Key, Value: removeNulls(item.Value)})
+		}
+		return result
+	case []interface{}:
+		for i, item := range value {
+			value[i] = removeNulls(item)
+		}
+	}
+	return value
+}
+
+// skipExport tells what a cluster creates by itself: endpoints follow their
+// service and service account tokens are issued by the controller
+func skipExport(kind string, resource interface{}) bool {
+	switch kind {
+	case "endpoints":
+		return true
+	case "secret":
+		secret, ok := resource.(*v1.Secret)
+		return ok && secret.Type == v1.SecretTypeServiceAccountToken
+	}
+	return false
+}
+
+func getMapSliceItem(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func setMapSliceItem(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+func removeMapSliceKeys(m yaml.MapSlice, keys ...string) yaml.MapSlice {
+	removed := make(map[string]struct{})
+	for _, key := range keys {
+		removed[key] = struct{}{}
+	}
+	result := yaml.MapSlice{}
+	for _, item := range m {
+		key, ok := item.Key.(string)
+		if ok {
+			if _, ok := removed[key]; ok {
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+func writeExportedManifest(out *printer, outputFolder, kind, name string, resource interface{}) error {
+	data, err := exportManifest(kind, resource)
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(outputFolder, kind+"-"+name+".yml")
+	mode := os.FileMode(0644)
+	if kind == "secret" {
+		mode = os.FileMode(0600)
+	}
+	err = ioutil.WriteFile(filename, data, mode)
+	if err == nil {
+		out.Printf(ColorGreen, "====> Written to %q\n", filename)
+	}
+	return err
+}
+
+func (p *Project) Export(outputFolder string) error {
+	err := os.MkdirAll(outputFolder, os.FileMode(0755))
+	if err != nil {
+		return err
+	}
+	for _, asset := range p.assets() {
+		assetName := asset.ResourceData.(Meta).GetName()
+		p.printer.Printf(ColorYellow, "Exporting %s %q from namespace %q\n", asset.Kind, assetName, asset.Namespace())
+		live, err := getResource(p.clientFor(asset), p.plugins, asset.Kind, assetName, asset.Namespace())
+		if err != nil {
+			if isResourceNotExist(err) {
+				p.printer.Println(ColorGray, "====> Not existed")
+				continue
+			}
+			return err
+		}
+		if asset.Kind == "secret" && skipExport(asset.Kind, live) {
+			p.printer.Println(ColorGray, "====> Skipped, issued by the cluster")
+			continue
+		}
+		err = writeExportedManifest(p.printer, outputFolder, asset.Kind, assetName, live)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportBySelector writes the resources of the namespace matching selector.
+// A kind this cluster doesn't serve, or we may not list, like cluster roles
+// for a service account of the namespace, is skipped with a warning.
+func exportBySelector(kubeClient *kubernetes.Clientset, out *printer, namespace, selector, outputFolder string) error {
+	err := os.MkdirAll(outputFolder, os.FileMode(0755))
+	if err != nil {
+		return err
+	}
+	for _, kind := range supportedKinds {
+		resources, err := listResources(kubeClient, kind, namespace, selector)
+		if err != nil {
+			out.ErrPrintf(ColorYellow, "Skipping %s, unable to list them: %s\n", kind, err.Error())
+			continue
+		}
+		for _, resource := range resources {
+			objectMeta := resource.(apiv1.Object)
+			// Objects owned by a controller are recreated by it, so only export the owner
+			if len(objectMeta.GetOwnerReferences()) > 0 || skipExport(kind, resource) {
+				continue
+			}
+			out.Printf(ColorYellow, "Exporting %s %q from namespace %q\n", kind, objectMeta.GetName(), namespace)
+			err = writeExportedManifest(out, outputFolder, kind, objectMeta.GetName(), resource)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
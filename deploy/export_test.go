@@ -0,0 +1,101 @@
+package deploy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/apps/v1beta1"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExportManifestStripsServerFields(t *testing.T) {
+	req := require.New(t)
+	service := &v1.Service{
+		ObjectMeta: apiv1.ObjectMeta{
+			Name:            "consul",
+			Namespace:       "anduin",
+			UID:             "1234",
+			ResourceVersion: "42",
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: "10.0.0.1",
+			Ports: []v1.ServicePort{
+				{Name: "http", Port: 8500},
+			},
+		},
+	}
+	data, err := exportManifest("service", service)
+	req.NoError(err)
+	manifest := string(data)
+	req.Contains(manifest, "apiVersion: v1\nkind: Service\n")
+	req.Contains(manifest, "name: consul")
+	req.Contains(manifest, "port: 8500")
+	req.NotContains(manifest, "namespace")
+	req.NotContains(manifest, "uid")
+	req.NotContains(manifest, "resourceVersion")
+	req.NotContains(manifest, "annotations")
+	req.NotContains(manifest, "clusterIP")
+	req.NotContains(manifest, "status")
+
+	asset, err := parseAsset("service.yml", data)
+	req.NoError(err)
+	req.Equal("service", asset.Kind)
+	req.Equal("consul", asset.ResourceData.(*v1.Service).Name)
+}
+
+func TestExportManifestStripsNulls(t *testing.T) {
+	req := require.New(t)
+	statefulSet := &v1beta1.StatefulSet{}
+	statefulSet.Name = "db"
+	statefulSet.Spec.Template.Labels = map[string]string{"app": "db"}
+	statefulSet.Spec.VolumeClaimTemplates = []v1.PersistentVolumeClaim{{ObjectMeta: apiv1.ObjectMeta{Name: "data"}}}
+	statefulSet.Spec.VolumeClaimTemplates[0].Status.Phase = v1.ClaimPending
+	data, err := exportManifest("statefulset", statefulSet)
+	req.NoError(err)
+	manifest := string(data)
+	req.NotContains(manifest, "creationTimestamp")
+	req.NotContains(manifest, "null")
+	req.NotContains(manifest, "status")
+	req.NotContains(manifest, "Pending")
+	req.Contains(manifest, "name: data")
+}
+
+func TestExportBySelector(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/api/v1/namespaces/web/secrets/web", `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"web","namespace":"web"},"data":{"password":"aHVudGVyMg=="}}`)
+	cluster.add("/api/v1/namespaces/web/secrets/default-token-x1", `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"default-token-x1","namespace":"web"},"type":"kubernetes.io/service-account-token"}`)
+	cluster.add("/api/v1/namespaces/web/services/web", `{"apiVersion":"v1","kind":"Service","metadata":{"name":"web","namespace":"web"},"spec":{"ports":[{"port":80}]}}`)
+	cluster.add("/api/v1/namespaces/web/endpoints/web", `{"apiVersion":"v1","kind":"Endpoints","metadata":{"name":"web","namespace":"web"}}`)
+	// A service account of the namespace may not list cluster wide kinds
+	cluster.reject = func(method, path string) *fakeStatus {
+		if strings.HasPrefix(path, "/apis/rbac.authorization.k8s.io/v1beta1/cluster") {
+			return &fakeStatus{http.StatusForbidden, "Forbidden", "clusterroles is forbidden"}
+		}
+		return nil
+	}
+	outputFolder := t.TempDir()
+	errors := &bytes.Buffer{}
+	req.Nil(exportBySelector(kubeClient, newPrinter(ioutil.Discard, errors), "web", "", outputFolder))
+	req.Contains(errors.String(), "Skipping clusterrole, unable to list them: clusterroles is forbidden")
+
+	files, err := filepath.Glob(filepath.Join(outputFolder, "*"))
+	req.Nil(err)
+	req.Equal([]string{filepath.Join(outputFolder, "secret-web.yml"), filepath.Join(outputFolder, "service-web.yml")}, files)
+	info, err := os.Stat(filepath.Join(outputFolder, "secret-web.yml"))
+	req.Nil(err)
+	req.Equal(os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(outputFolder, "service-web.yml"))
+	req.Nil(err)
+	req.Equal(os.FileMode(0644), info.Mode().Perm())
+}
@@ -0,0 +1,228 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// inheritedGlobs are added to the globs of the base project instead of
+// replacing them, each side falling back to the glob readAssets defaults to
+var inheritedGlobs = map[string]string{
+	"resources": "resources/*",
+	"services":  "services/*",
+	"jobs":      "jobs/*",
+	"partials":  "partials/*",
+	"excludes":  "",
+}
+
+func renderProjectFile(projectFile string, variables variableMap) (map[interface{}]interface{}, error) {
+	data, err := ioutil.ReadFile(projectFile)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(projectFile).Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	t = t.Option("missingkey=error")
+	buf := &bytes.Buffer{}
+	err = t.Execute(buf, variables)
+	if err != nil {
+		return nil, err
+	}
+	document := make(map[interface{}]interface{})
+	err = yaml.Unmarshal(buf.Bytes(), &document)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read project config %q: %s", projectFile, err.Error())
+	}
+	return document, nil
+}
+
+// readProjectFile renders a project file and the chain of base projects it
+// extends, rendered with the same variables
+func readProjectFile(projectFile string, variables variableMap, chain []string) (map[interface{}]interface{}, error) {
+	projectFile, err := filepath.Abs(projectFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, seen := range chain {
+		if seen == projectFile {
+			return nil, fmt.Errorf("project %q extends itself through %s", projectFile, strings.Join(chain, " -> "))
+		}
+	}
+	document, err := renderProjectFile(projectFile, variables)
+	if err != nil {
+		return nil, err
+	}
+	extends, ok := document["extends"].(string)
+	if !ok || extends == "" {
+		return document, nil
+	}
+	baseFile := translateFilePath(filepath.Dir(projectFile), extends)
+	if info, err := os.Stat(baseFile); err == nil && info.IsDir() {
+		baseFile = filepath.Join(baseFile, "project.yml")
+	}
+	base, err := readProjectFile(baseFile, variables, append(chain, projectFile))
+	if err != nil {
+		return nil, err
+	}
+	baseRoot := filepath.Dir(baseFile)
+	if rootFolder, ok := base["root_folder"].(string); ok && rootFolder != "" {
+		baseRoot = translateFilePath(baseRoot, rootFolder)
+	}
+	baseRoot, err = filepath.Abs(baseRoot)
+	if err != nil {
+		return nil, err
+	}
+	return extendProject(base, document, baseRoot), nil
+}
+
+// extendProject overrides the base project with the one extending it: maps
+// like variables are merged key by key, anything else the project sets
+// replaces the base. Manifest globs add up instead, the ones of the base
+// still pointing to its own folder.
+func extendProject(base, project map[interface{}]interface{}, baseRoot string) map[interface{}]interface{} {
+	delete(base, "name")
+	delete(base, "root_folder")
+	delete(base, "extends")
+	for key, defaultGlob := range inheritedGlobs {
+		globs := []interface{}{}
+		for _, glob := range stringList(base[key], defaultGlob) {
+			globs = append(globs, translateFilePath(baseRoot, glob))
+		}
+		for _, glob := range stringList(project[key], defaultGlob) {
+			globs = append(globs, glob)
+		}
+		delete(base, key)
+		if len(globs) > 0 {
+			project[key] = globs
+		}
+	}
+	rebasePaths(base, baseRoot)
+	return mergeDocuments(base, project).(map[interface{}]interface{})
+}
+
+// rebasePaths points the relative paths and the scripts of a base project at
+// its own folder, they would run from the folder extending it otherwise
+func rebasePaths(base map[interface{}]interface{}, baseRoot string) {
+	rebase := func(section interface{}, keys ...string) {
+		document, _ := section.(map[interface{}]interface{})
+		for _, key := range keys {
+			if path, ok := document[key].(string); ok && path != "" {
+				document[key] = translateFilePath(baseRoot, path)
+			}
+		}
+	}
+	each := func(key string, apply func(item map[interface{}]interface{})) {
+		items, _ := base[key].([]interface{})
+		for _, item := range items {
+			if document, ok := item.(map[interface{}]interface{}); ok {
+				apply(document)
+			}
+		}
+	}
+	rebase(base, "values_schema")
+	rebase(base["changelog"], "file")
+	rebase(base["audit"], "file")
+	if signing, ok := base["signing"].(map[interface{}]interface{}); ok {
+		rebase(signing, "signature")
+		// Only cosign takes a key file, a gpg key is an id and cosign also
+		// takes kms:// references
+		if key, _ := signing["key"].(string); signing["method"] == "cosign" && !strings.Contains(key, "://") {
+			rebase(signing, "key")
+		}
+	}
+	each("build", func(build map[interface{}]interface{}) {
+		rebase(build, "from")
+	})
+	for _, key := range []string{"credentials", "auto_update_credentials"} {
+		each(key, func(credential map[interface{}]interface{}) {
+			rebase(credential, "password_file")
+		})
+	}
+	each("certificates", func(certificate map[interface{}]interface{}) {
+		rebase(certificate, "ca_cert", "ca_key")
+	})
+	for _, key := range []string{"config_maps", "secrets"} {
+		each(key, func(generator map[interface{}]interface{}) {
+			sources := []interface{}{}
+			for _, source := range stringList(generator["from_file"], "") {
+				if pieces := strings.SplitN(source, "=", 2); len(pieces) == 2 {
+					sources = append(sources, pieces[0]+"="+translateFilePath(baseRoot, pieces[1]))
+				} else {
+					sources = append(sources, translateFilePath(baseRoot, source))
+				}
+			}
+			if len(sources) > 0 {
+				generator["from_file"] = sources
+			}
+		})
+	}
+	if files := stringList(base["terraform_outputs"], ""); len(files) > 0 {
+		rebased := []interface{}{}
+		for _, file := range files {
+			rebased = append(rebased, translateFilePath(baseRoot, file))
+		}
+		base["terraform_outputs"] = rebased
+	}
+	for _, key := range []string{"init_up", "init_down", "finalize_up", "finalize_down"} {
+		if scripts := stringList(base[key], ""); len(scripts) > 0 {
+			rebased := []interface{}{}
+			for _, script := range scripts {
+				rebased = append(rebased, inFolder(baseRoot, script))
+			}
+			base[key] = rebased
+		}
+	}
+	each("plugins", func(plugin map[interface{}]interface{}) {
+		if command, ok := plugin["command"].(string); ok && command != "" {
+			plugin["command"] = inFolder(baseRoot, command)
+		}
+	})
+	each("dns", func(record map[interface{}]interface{}) {
+		if script, ok := record["script"].(string); ok && script != "" {
+			record["script"] = inFolder(baseRoot, script)
+		}
+	})
+}
+
+func stringList(value interface{}, defaultValue string) []string {
+	list := []string{}
+	values, _ := value.([]interface{})
+	for _, item := range values {
+		list = append(list, fmt.Sprint(item))
+	}
+	if len(list) == 0 && defaultValue != "" {
+		list = append(list, defaultValue)
+	}
+	return list
+}
+
+func mergeDocuments(base, override interface{}) interface{} {
+	if override == nil {
+		return base
+	}
+	baseMap, baseIsMap := base.(map[interface{}]interface{})
+	overrideMap, overrideIsMap := override.(map[interface{}]interface{})
+	if !baseIsMap || !overrideIsMap {
+		return override
+	}
+	merged := make(map[interface{}]interface{})
+	for key, value := range baseMap {
+		merged[key] = value
+	}
+	for key, value := range overrideMap {
+		if existing, ok := merged[key]; ok {
+			value = mergeDocuments(existing, value)
+		}
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,104 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/core/v1"
+)
+
+func TestExtends(t *testing.T) {
+	req := require.New(t)
+	project, err := readProject(nil, "test-assets/extends-tests/web", &appConfig{})
+	req.NoError(err)
+	config := project.projectConfig
+	req.Equal("web", config.Name)
+	req.Equal("anduin", config.Namespace)
+	base, err := filepath.Abs("test-assets/extends-tests/base")
+	req.NoError(err)
+	req.Equal([]string{inFolder(base, "echo base")}, config.InitUp)
+	req.Equal("2", config.Variables["replicas"])
+	req.Equal("debug", config.Variables["log_level"])
+	req.Len(project.resources, 1)
+	req.Equal("debug", project.resources[0].ResourceData.(*v1.ConfigMap).Data["log_level"])
+	req.Len(project.services, 1)
+	req.Equal("2", project.services[0].ResourceData.(*v1.ConfigMap).Data["replicas"])
+}
+
+func TestExtendsCycle(t *testing.T) {
+	req := require.New(t)
+	folder, err := ioutil.TempDir("", "imladris-extends")
+	req.NoError(err)
+	defer os.RemoveAll(folder)
+	req.NoError(ioutil.WriteFile(filepath.Join(folder, "a.yml"), []byte("extends: b.yml\n"), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(folder, "b.yml"), []byte("extends: "+filepath.Join(folder, "a.yml")+"\n"), 0644))
+	// The same file reached through a relative and an absolute path
+	cwd, err := os.Getwd()
+	req.NoError(err)
+	relative, err := filepath.Rel(cwd, filepath.Join(folder, "a.yml"))
+	req.NoError(err)
+	_, err = readProject(nil, relative, &appConfig{})
+	req.Error(err)
+	req.Contains(err.Error(), "extends itself")
+}
+
+func TestRebasePaths(t *testing.T) {
+	req := require.New(t)
+	var base map[interface{}]interface{}
+	req.NoError(yaml.Unmarshal([]byte(`
+build:
+  - name: web
+    from: docker/web
+signing:
+  method: cosign
+  key: cosign.key
+  signature: manifests.sig
+changelog:
+  file: CHANGELOG
+terraform_outputs:
+  - outputs.json
+  - /etc/outputs.json
+config_maps:
+  - name: web
+    from_file:
+      - config
+      - nginx.conf=conf/nginx.conf
+init_up:
+  - ./migrate.sh
+plugins:
+  - kind: database
+    command: ./database {}
+`), &base))
+	rebasePaths(base, "/base")
+	document, err := yaml.Marshal(base)
+	req.NoError(err)
+	config := &ProjectConfig{}
+	req.NoError(yaml.Unmarshal(document, config))
+
+	req.Equal("/base/docker/web", config.Build[0].From)
+	req.Equal("/base/cosign.key", config.Signing.Key)
+	req.Equal("/base/manifests.sig", config.Signing.Signature)
+	req.Equal("/base/CHANGELOG", config.Changelog.File)
+	req.Equal([]string{"/base/outputs.json", "/etc/outputs.json"}, config.TerraformOutputs)
+	req.Equal([]string{"/base/config", "nginx.conf=/base/conf/nginx.conf"}, config.ConfigMaps[0].FromFile)
+	req.Equal([]string{inFolder("/base", "./migrate.sh")}, config.InitUp)
+	req.Equal(inFolder("/base", "./database {}"), config.Plugins[0].Command)
+}
+
+func TestMergeDocuments(t *testing.T) {
+	req := require.New(t)
+	merged := mergeDocuments(
+		map[interface{}]interface{}{"a": "1", "nested": map[interface{}]interface{}{"b": "2", "c": "3"}, "list": []interface{}{"x"}},
+		map[interface{}]interface{}{"nested": map[interface{}]interface{}{"c": "4"}, "list": []interface{}{"y"}, "empty": nil},
+	)
+	req.Equal(map[interface{}]interface{}{
+		"a":      "1",
+		"nested": map[interface{}]interface{}{"b": "2", "c": "4"},
+		"list":   []interface{}{"y"},
+		"empty":  nil,
+	}, merged)
+}
@@ -0,0 +1,83 @@
+package deploy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// resourceFilter holds kind/name patterns from -only and -skip. Names may
+// use shell globs, kinds may use the kubectl short names: svc/api-*, job/*
+type resourceFilter []string
+
+func parseResourceFilter(value string) (resourceFilter, error) {
+	filter := resourceFilter{}
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		pieces := strings.SplitN(pattern, "/", 2)
+		if len(pieces) != 2 {
+			return nil, fmt.Errorf("invalid resource filter %q, expected <kind>/<name>", pattern)
+		}
+		kind := pieces[0]
+		if kind != "*" {
+			kind = canonicalKind(kind)
+			if _, ok := resourceTypes[kind]; !ok {
+				return nil, UnsupportedResource(pieces[0])
+			}
+		}
+		_, err := path.Match(pieces[1], "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource filter %q: %s", pattern, err.Error())
+		}
+		filter = append(filter, kind+"/"+pieces[1])
+	}
+	return filter, nil
+}
+
+func (filter resourceFilter) matches(asset *Asset) bool {
+	key := assetKey(asset)
+	for _, pattern := range filter {
+		matched, _ := path.Match(pattern, key)
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Project) filterAssets() error {
+	only, err := parseResourceFilter(p.config.only)
+	if err != nil {
+		return err
+	}
+	skip, err := parseResourceFilter(p.config.skip)
+	if err != nil {
+		return err
+	}
+	if len(only) == 0 && len(skip) == 0 {
+		return nil
+	}
+	keep := func(assets []*Asset) []*Asset {
+		kept := []*Asset{}
+		for _, asset := range assets {
+			if len(only) > 0 && !only.matches(asset) {
+				continue
+			}
+			if skip.matches(asset) {
+				continue
+			}
+			kept = append(kept, asset)
+		}
+		return kept
+	}
+	p.resources = keep(p.resources)
+	p.jobs = keep(p.jobs)
+	p.services = keep(p.services)
+	if len(p.assets()) == 0 {
+		return fmt.Errorf("no resources left after applying -only %q and -skip %q", p.config.only, p.config.skip)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFilterAssets(t *testing.T) {
+	req := require.New(t)
+	newProject := func(only, skip string) *Project {
+		return &Project{
+			config:        &appConfig{only: only, skip: skip},
+			projectConfig: &ProjectConfig{},
+			resources: []*Asset{
+				{Kind: "configmap", ResourceData: &v1.ConfigMap{ObjectMeta: apiv1.ObjectMeta{Name: "web-config"}}},
+			},
+			jobs: []*Asset{
+				{Kind: "job", ResourceData: &v1batch.Job{ObjectMeta: apiv1.ObjectMeta{Name: "migrate"}}},
+			},
+			services: []*Asset{
+				{Kind: "deployment", ResourceData: &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "web"}}},
+				{Kind: "deployment", ResourceData: &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "worker"}}},
+			},
+		}
+	}
+	keys := func(p *Project) []string {
+		result := []string{}
+		for _, asset := range p.assets() {
+			result = append(result, assetKey(asset))
+		}
+		return result
+	}
+	p := newProject("deploy/web,cm/web-*", "")
+	req.NoError(p.filterAssets())
+	req.Equal([]string{"configmap/web-config", "deployment/web"}, keys(p))
+
+	p = newProject("", "job/*")
+	req.NoError(p.filterAssets())
+	req.Equal([]string{"configmap/web-config", "deployment/web", "deployment/worker"}, keys(p))
+
+	p = newProject("*/w*", "deployment/worker")
+	req.NoError(p.filterAssets())
+	req.Equal([]string{"configmap/web-config", "deployment/web"}, keys(p))
+
+	req.Error(newProject("deployment/api", "").filterAssets())
+	req.Error(newProject("widget/api", "").filterAssets())
+	req.Error(newProject("web", "").filterAssets())
+}
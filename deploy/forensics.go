@@ -0,0 +1,265 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// forensicLogLines is how much of each container log goes into a bundle
+const forensicLogLines = 1000
+
+// forensicBundle is what an engineer needs to debug a failed deploy without
+// cluster access: the live resources with their status, a description of
+// their pods, the events and the container logs. Secrets are left out and
+// every file is scrubbed.
+type forensicBundle struct {
+	files map[string][]byte
+}
+
+func (b *forensicBundle) add(name string, data []byte) {
+	b.files[name] = []byte(scrub(string(data)))
+}
+
+func (b *forensicBundle) names() []string {
+	names := []string{}
+	for name := range b.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// write saves the bundle as a gzipped tarball when target ends in .tar.gz
+// or .tgz, and as a directory otherwise
+func (b *forensicBundle) write(target string) error {
+	if !strings.HasSuffix(target, ".tar.gz") && !strings.HasSuffix(target, ".tgz") {
+		for _, name := range b.names() {
+			filename := filepath.Join(target, name)
+			err := os.MkdirAll(filepath.Dir(filename), os.FileMode(0755))
+			if err != nil {
+				return err
+			}
+			err = ioutil.WriteFile(filename, b.files[name], os.FileMode(0600))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range b.names() {
+		err := tarWriter.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(b.files[name])),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tarWriter.Write(b.files[name])
+		if err != nil {
+			return err
+		}
+	}
+	err := tarWriter.Close()
+	if err != nil {
+		return err
+	}
+	err = gzipWriter.Close()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(target, buf.Bytes(), os.FileMode(0600))
+}
+
+// liveYAML renders a live resource with its status, through JSON so the
+// kubernetes field names and order are kept
+func liveYAML(resource interface{}) ([]byte, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	document := yaml.MapSlice{}
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(document)
+}
+
+func describePod(pod *v1.Pod, events []v1.Event) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Pod:       %s\n", pod.Name)
+	fmt.Fprintf(buf, "Namespace: %s\n", pod.Namespace)
+	fmt.Fprintf(buf, "Node:      %s\n", pod.Spec.NodeName)
+	fmt.Fprintf(buf, "Phase:     %s\n", pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		fmt.Fprintf(buf, "Reason:    %s: %s\n", pod.Status.Reason, pod.Status.Message)
+	}
+	fmt.Fprintln(buf, "Conditions:")
+	for _, condition := range pod.Status.Conditions {
+		fmt.Fprintf(buf, "  %s=%s %s %s\n", condition.Type, condition.Status, condition.Reason, condition.Message)
+	}
+	fmt.Fprintln(buf, "Containers:")
+	describeState := func(state v1.ContainerState) string {
+		switch {
+		case state.Waiting != nil:
+			return fmt.Sprintf("waiting %s %s", state.Waiting.Reason, state.Waiting.Message)
+		case state.Running != nil:
+			return fmt.Sprintf("running since %s", state.Running.StartedAt.Format(time.RFC3339))
+		case state.Terminated != nil:
+			return fmt.Sprintf("terminated %s, exit code %d %s", state.Terminated.Reason, state.Terminated.ExitCode, strings.TrimSpace(state.Terminated.Message))
+		}
+		return "unknown"
+	}
+	for _, status := range append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		fmt.Fprintf(buf, "  %s: image %s, ready %t, %d restarts\n", status.Name, status.Image, status.Ready, status.RestartCount)
+		fmt.Fprintf(buf, "    state: %s\n", describeState(status.State))
+		if status.LastTerminationState.Terminated != nil {
+			fmt.Fprintf(buf, "    last state: %s\n", describeState(status.LastTerminationState))
+		}
+	}
+	fmt.Fprintln(buf, "Events:")
+	for _, event := range events {
+		if event.InvolvedObject.Kind == "Pod" && event.InvolvedObject.Name == pod.Name {
+			fmt.Fprintf(buf, "  %s %s %s: %s\n", event.LastTimestamp.Format(time.RFC3339), event.Type, event.Reason, event.Message)
+		}
+	}
+	return buf.String()
+}
+
+func collectPodForensics(bundle *forensicBundle, kubeClient *kubernetes.Clientset, namespace string, pod *v1.Pod, events []v1.Event) {
+	folder := filepath.Join("pods", namespace, pod.Name)
+	bundle.add(filepath.Join(folder, "describe.txt"), []byte(describePod(pod, events)))
+	tailLines := int64(forensicLogLines)
+	for _, status := range append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		previous := []bool{false}
+		if status.RestartCount > 0 {
+			previous = append(previous, true)
+		}
+		for _, previous := range previous {
+			filename := status.Name + ".log"
+			if previous {
+				filename = status.Name + ".previous.log"
+			}
+			data, err := kubeClient.Core().Pods(namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+				Container: status.Name,
+				Previous:  previous,
+				TailLines: &tailLines,
+			}).Do().Raw()
+			if err != nil {
+				data = []byte("cannot read logs: " + err.Error() + "\n")
+			}
+			bundle.add(filepath.Join(folder, filename), data)
+		}
+	}
+}
+
+// collectForensics gathers the bundle of a failed deploy. What cannot be read
+// is noted in the bundle instead of failing, the cluster may be the reason the
+// deploy failed.
+func (p *Project) collectForensics(deployErr error) *forensicBundle {
+	bundle := &forensicBundle{files: make(map[string][]byte)}
+	bundle.add("error.txt", []byte(fmt.Sprintf("project: %s\nrelease: %s\ncontext: %s\nactor: %s\nerror: %s\n",
+		p.projectConfig.Name, p.releaseID(), contextName(p.config), deployActor(), deployErr.Error())))
+	type target struct {
+		kubeClient *kubernetes.Clientset
+		namespace  string
+		selectors  []string
+	}
+	targets := make(map[string]*target)
+	for _, asset := range p.assets() {
+		namespace := asset.Namespace()
+		key := asset.context + "/" + namespace
+		if targets[key] == nil {
+			targets[key] = &target{kubeClient: p.clientFor(asset), namespace: namespace}
+		}
+		name := asset.ResourceData.(Meta).GetName()
+		if asset.Kind == "secret" {
+			continue
+		}
+		filename := filepath.Join("resources", namespace, asset.Kind+"-"+name+".yml")
+		live, err := getResource(p.clientFor(asset), p.plugins, asset.Kind, name, namespace)
+		if err == nil {
+			var data []byte
+			data, err = liveYAML(live)
+			if err == nil {
+				bundle.add(filename, data)
+			}
+		}
+		if err != nil {
+			bundle.add(filename+".error", []byte(err.Error()+"\n"))
+		}
+		selector := "job-name=" + name
+		if asset.Kind != "job" {
+			selector, _ = workloadSelector(asset)
+		}
+		if selector != "" {
+			targets[key].selectors = append(targets[key].selectors, selector)
+		}
+	}
+	for _, target := range targets {
+		events, err := target.kubeClient.Core().Events(target.namespace).List(apiv1.ListOptions{})
+		eventLines := &bytes.Buffer{}
+		if err != nil {
+			fmt.Fprintf(eventLines, "cannot list events: %s\n", err.Error())
+			events = &v1.EventList{}
+		}
+		sort.Slice(events.Items, func(i, j int) bool {
+			return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+		})
+		for _, event := range events.Items {
+			fmt.Fprintf(eventLines, "%s %s %s/%s %s: %s\n", event.LastTimestamp.Format(time.RFC3339), event.Type, strings.ToLower(event.InvolvedObject.Kind), event.InvolvedObject.Name, event.Reason, event.Message)
+		}
+		bundle.add(filepath.Join("events", target.namespace+".txt"), eventLines.Bytes())
+		seen := make(map[string]bool)
+		for _, selector := range target.selectors {
+			pods, err := target.kubeClient.Core().Pods(target.namespace).List(apiv1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				bundle.add(filepath.Join("pods", target.namespace, "error.txt"), []byte(err.Error()+"\n"))
+				continue
+			}
+			for i := range pods.Items {
+				pod := &pods.Items[i]
+				if seen[pod.Name] {
+					continue
+				}
+				seen[pod.Name] = true
+				collectPodForensics(bundle, target.kubeClient, target.namespace, pod, events.Items)
+			}
+		}
+	}
+	return bundle
+}
+
+// writeForensics saves the bundle of a failed deploy to -forensics
+func (p *Project) writeForensics(deployErr error) {
+	if p.config.forensics == "" {
+		return
+	}
+	p.printer.Printf(ColorYellow, "Collecting diagnostics of the failed deploy\n")
+	bundle := p.collectForensics(deployErr)
+	err := bundle.write(p.config.forensics)
+	if err != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot write diagnostics to %q: %s\n", p.config.forensics, err.Error())
+		return
+	}
+	p.printer.Printf(ColorPurple, "====> Wrote %d diagnostic files to %q\n", len(bundle.files), p.config.forensics)
+}
@@ -0,0 +1,75 @@
+package deploy
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+)
+
+func TestForensicBundle(t *testing.T) {
+	req := require.New(t)
+	isolateSensitiveValues(t)
+	registerSensitive("hunter2")
+	bundle := &forensicBundle{files: make(map[string][]byte)}
+	bundle.add("error.txt", []byte("password hunter2 rejected\n"))
+	bundle.add("pods/web/describe.txt", []byte("Pod: web\n"))
+
+	folder, err := ioutil.TempDir("", "imladris-forensics-")
+	req.Nil(err)
+	defer os.RemoveAll(folder)
+	req.Nil(bundle.write(filepath.Join(folder, "bundle")))
+	data, err := ioutil.ReadFile(filepath.Join(folder, "bundle", "error.txt"))
+	req.Nil(err)
+	req.Equal("password "+redactedValue+" rejected\n", string(data))
+
+	tarball := filepath.Join(folder, "bundle.tar.gz")
+	req.Nil(bundle.write(tarball))
+	file, err := os.Open(tarball)
+	req.Nil(err)
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	req.Nil(err)
+	tarReader := tar.NewReader(gzipReader)
+	names := []string{}
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+	req.Equal([]string{"error.txt", "pods/web/describe.txt"}, names)
+}
+
+func TestDescribePod(t *testing.T) {
+	req := require.New(t)
+	pod := &v1.Pod{}
+	pod.Name = "web-1"
+	pod.Status.Phase = v1.PodRunning
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:         "web",
+		Image:        "web:1.0",
+		RestartCount: 3,
+		State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			Reason:   "Error",
+			ExitCode: 2,
+		}},
+	}}
+	events := []v1.Event{
+		{InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "web-1"}, Type: "Warning", Reason: "BackOff", Message: "Back-off restarting failed container"},
+		{InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "other"}, Type: "Normal", Reason: "Pulled"},
+	}
+	description := describePod(pod, events)
+	req.Contains(description, "web: image web:1.0, ready false, 3 restarts")
+	req.Contains(description, "state: waiting CrashLoopBackOff")
+	req.Contains(description, "last state: terminated Error, exit code 2")
+	req.Contains(description, "BackOff: Back-off restarting failed container")
+	req.NotContains(description, "Pulled")
+}
@@ -0,0 +1,141 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// A change freeze is set by platform teams in the cluster, either with the
+// freeze annotation on a namespace or with the freeze configmap, in a
+// namespace or in kube-system for the whole cluster. Both hold the reason.
+const (
+	freezeAnnotation = "imladris/freeze"
+	freezeConfigMap  = "imladris-freeze"
+)
+
+// namespaceFreeze returns the reason namespace is frozen, or an empty string
+func namespaceFreeze(kubeClient *kubernetes.Clientset, namespace string) (string, error) {
+	ns, err := kubeClient.Core().Namespaces().Get(namespace, apiv1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	if err == nil {
+		if reason, ok := ns.Annotations[freezeAnnotation]; ok {
+			return freezeReason(reason, "namespace annotation "+freezeAnnotation), nil
+		}
+	}
+	for _, configMapNamespace := range []string{namespace, "kube-system"} {
+		configMap, err := kubeClient.Core().ConfigMaps(configMapNamespace).Get(freezeConfigMap, apiv1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return freezeReason(configMap.Data["reason"], "configmap "+configMapNamespace+"/"+freezeConfigMap), nil
+	}
+	return "", nil
+}
+
+func freezeReason(reason, marker string) string {
+	if reason == "" {
+		return "frozen by " + marker
+	}
+	return reason + " (" + marker + ")"
+}
+
+// checkFreeze refuses deploys to frozen namespaces unless -override-freeze
+// gives a reason, which goes to the audit log
+func (p *Project) checkFreeze() error {
+	frozen := make(map[string]string)
+	checked := make(map[string]bool)
+	for _, asset := range p.assets() {
+		key := asset.context + "/" + asset.Namespace()
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+		reason, err := namespaceFreeze(p.clientFor(asset), asset.Namespace())
+		if err != nil {
+			return fmt.Errorf("cannot check the change freeze of namespace %q: %s", asset.Namespace(), err.Error())
+		}
+		if reason != "" {
+			frozen[asset.Namespace()] = reason
+		}
+	}
+	namespaces, err := enforceFreeze(frozen, p.config, p.printer)
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		p.audit("override-freeze", "namespace", namespace, nil, map[string]string{"reason": p.config.overrideFreeze, "freeze": frozen[namespace]})
+	}
+	return nil
+}
+
+// enforceFreeze refuses to change the frozen namespaces, the keys of frozen,
+// unless -override-freeze gives a reason. It returns the namespaces changed
+// through the freeze.
+func enforceFreeze(frozen map[string]string, config *appConfig, out *printer) ([]string, error) {
+	if len(frozen) == 0 {
+		return nil, nil
+	}
+	namespaces := []string{}
+	for namespace := range frozen {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		out.ErrPrintf(ColorRed, "Namespace %q is frozen: %s\n", namespace, frozen[namespace])
+	}
+	if config.overrideFreeze == "" {
+		return nil, validationError(fmt.Errorf("refusing to change frozen namespaces; pass -override-freeze with a reason to change them anyway"))
+	}
+	out.ErrPrintf(ColorYellow, "Going through the change freeze: %s\n", config.overrideFreeze)
+	return namespaces, nil
+}
+
+// checkNamespaceFreeze is checkFreeze for the commands that change a
+// namespace without a project, like teardown and restore
+func checkNamespaceFreeze(kubeClient *kubernetes.Clientset, namespace string, config *appConfig) error {
+	reason, err := namespaceFreeze(kubeClient, namespace)
+	if err != nil {
+		return fmt.Errorf("cannot check the change freeze of namespace %q: %s", namespace, err.Error())
+	}
+	if reason == "" {
+		return nil
+	}
+	_, err = enforceFreeze(map[string]string{namespace: reason}, config, config.printer)
+	return err
+}
+
+// setFreeze sets or, with an empty reason, lifts the freeze annotation. The
+// namespace is read again when something else changed it in between.
+func setFreeze(kubeClient *kubernetes.Clientset, namespace, reason string) error {
+	var err error
+	for i := 0; i < 5; i++ {
+		var ns *v1.Namespace
+		ns, err = kubeClient.Core().Namespaces().Get(namespace, apiv1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			delete(ns.Annotations, freezeAnnotation)
+		} else {
+			if ns.Annotations == nil {
+				ns.Annotations = make(map[string]string)
+			}
+			ns.Annotations[freezeAnnotation] = reason
+		}
+		_, err = kubeClient.Core().Namespaces().Update(ns)
+		if !errors.IsConflict(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("cannot set the freeze of namespace %q, it keeps changing: %s", namespace, err.Error())
+}
@@ -0,0 +1,115 @@
+package deploy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestNamespaceFreeze(t *testing.T) {
+	req := require.New(t)
+	clusterFrozen := false
+	notFound := `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/namespaces/prod":
+			w.Write([]byte(`{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"prod","annotations":{"imladris/freeze":"end of quarter"}}}`))
+		case "/api/v1/namespaces/staging":
+			w.Write([]byte(`{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"staging"}}`))
+		case "/api/v1/namespaces/kube-system/configmaps/imladris-freeze":
+			if r.URL.Path == "/api/v1/namespaces/kube-system/configmaps/imladris-freeze" && !clusterFrozen {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(notFound))
+				return
+			}
+			w.Write([]byte(`{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"imladris-freeze"},"data":{"reason":"cluster upgrade"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(notFound))
+		}
+	}))
+	defer server.Close()
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	req.Nil(err)
+
+	reason, err := namespaceFreeze(kubeClient, "prod")
+	req.Nil(err)
+	req.Equal("end of quarter (namespace annotation imladris/freeze)", reason)
+	reason, err = namespaceFreeze(kubeClient, "staging")
+	req.Nil(err)
+	req.Equal("", reason)
+	reason, err = namespaceFreeze(kubeClient, "new-namespace")
+	req.Nil(err)
+	req.Equal("", reason)
+	clusterFrozen = true
+	reason, err = namespaceFreeze(kubeClient, "staging")
+	req.Nil(err)
+	req.Equal("cluster upgrade (configmap kube-system/imladris-freeze)", reason)
+}
+
+func TestFreezeEntryPoints(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/api/v1/namespaces/web", `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"web","annotations":{"imladris/freeze":"incident 42"}}}`)
+	asset, err := parseAsset("web.yml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: web\n"))
+	req.Nil(err)
+	out := &bytes.Buffer{}
+	config := &appConfig{context: "prod", printer: newPrinter(out, out)}
+	p := &Project{
+		kubeClient:    kubeClient,
+		config:        config,
+		projectConfig: &ProjectConfig{Namespace: "web"},
+		resources:     []*Asset{asset},
+		printer:       config.printer,
+	}
+
+	commands := map[string]func() error{
+		"down":          p.Down,
+		"down-services": p.DownServices,
+		"down-jobs":     p.DownJobs,
+		"auto-update":   func() error { return p.autoUpdate("web", "v2") },
+		"teardown":      func() error { return checkNamespaceFreeze(kubeClient, "web", config) },
+		"restore":       func() error { return restoreBackupObjects(kubeClient, "imladris-backup-1", "web", config) },
+	}
+	for name, command := range commands {
+		err := command()
+		req.Error(err, name)
+		req.Contains(err.Error(), "frozen", name)
+	}
+	req.Empty(cluster.requested("DELETE", "/"))
+	req.Contains(out.String(), "incident 42")
+
+	req.Nil(checkNamespaceFreeze(kubeClient, "staging", config))
+	config.overrideFreeze = "hotfix for incident 42"
+	req.Nil(checkNamespaceFreeze(kubeClient, "web", config))
+}
+
+func TestSetFreezeConflict(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/api/v1/namespaces/web", `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"web"}}`)
+	conflicts := 1
+	cluster.reject = func(method, path string) *fakeStatus {
+		if method == "PUT" && conflicts > 0 {
+			conflicts--
+			return &fakeStatus{http.StatusConflict, "Conflict", "the object has been modified"}
+		}
+		return nil
+	}
+	req.Nil(setFreeze(kubeClient, "web", "incident 42"))
+	req.Len(cluster.requested("PUT", "/api/v1/namespaces/web"), 2)
+	req.Len(cluster.requested("GET", "/api/v1/namespaces/web"), 2)
+	annotations := cluster.get("/api/v1/namespaces/web")["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+	req.Equal("incident 42", annotations[freezeAnnotation])
+
+	conflicts = 10
+	err := setFreeze(kubeClient, "web", "")
+	req.Error(err)
+	req.Contains(err.Error(), "keeps changing")
+}
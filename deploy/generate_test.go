@@ -0,0 +1,37 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/anduintransaction/imladris/templates"
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/extensions/v1beta1"
+)
+
+func TestGenerateScaffolds(t *testing.T) {
+	req := require.New(t)
+	_, kubeClient := newFakeCluster(t)
+	for kind := range scaffolds {
+		scaffold, err := templates.Asset("templates/files/" + kind + ".yml")
+		req.NoError(err)
+		data, err := renderScaffold(scaffold, "web", "nginx:1.13")
+		req.NoError(err)
+		asset, err := parseDocument(kind+".yml", &manifestDocument{index: 1, line: 1, data: data}, true, nil)
+		req.NoError(err)
+		req.Equal(kind, asset.Kind)
+		req.Equal("web", asset.ResourceData.(Meta).GetName())
+		// and it deploys as generated
+		req.NoError(createResource(kubeClient, nil, kind, "web", "default", asset.ResourceData))
+	}
+	scaffold, err := templates.Asset("templates/files/deployment.yml")
+	req.NoError(err)
+	data, err := renderScaffold(scaffold, "web", "nginx:1.13")
+	req.NoError(err)
+	asset, err := parseDocument("deployment.yml", &manifestDocument{index: 1, line: 1, data: data}, true, nil)
+	req.NoError(err)
+	container := asset.ResourceData.(*v1beta1.Deployment).Spec.Template.Spec.Containers[0]
+	req.Equal("nginx:1.13", container.Image)
+	req.NotNil(container.ReadinessProbe)
+	req.NotNil(container.LivenessProbe)
+	req.False(container.Resources.Limits.Memory().IsZero())
+}
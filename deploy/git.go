@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+func runGit(folder string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = folder
+	outBuffer := &bytes.Buffer{}
+	errBuffer := &bytes.Buffer{}
+	cmd.Stdout = outBuffer
+	cmd.Stderr = errBuffer
+	err := cmd.Run()
+	if err != nil {
+		return "", errors.New(strings.TrimSpace(errBuffer.String()))
+	}
+	return strings.TrimSpace(outBuffer.String()), nil
+}
+
+// gitHead describes HEAD with a single git call: its sha, its branch unless
+// it is detached and a tag pointing at it
+func gitHead(folder string) (string, string, string) {
+	output, err := runGit(folder, "log", "-1", "--format=%H%n%D", "HEAD")
+	if err != nil {
+		return "", "", ""
+	}
+	lines := strings.SplitN(output, "\n", 2)
+	branch, tag := "", ""
+	if len(lines) == 2 {
+		for _, ref := range strings.Split(lines[1], ", ") {
+			switch {
+			case strings.HasPrefix(ref, "HEAD -> "):
+				branch = strings.TrimPrefix(ref, "HEAD -> ")
+			case strings.HasPrefix(ref, "tag: ") && tag == "":
+				tag = strings.TrimPrefix(ref, "tag: ")
+			}
+		}
+	}
+	return lines[0], branch, tag
+}
+
+func gitSync(folder, ref string) (string, error) {
+	err := requireNetwork("git remote origin")
+	if err != nil {
+		return "", err
+	}
+	_, err = runGit(folder, "fetch", "--prune", "origin")
+	if err != nil {
+		return "", err
+	}
+	_, err = runGit(folder, "checkout", "--force", "--detach", ref)
+	if err != nil {
+		return "", err
+	}
+	return runGit(folder, "rev-parse", "HEAD")
+}
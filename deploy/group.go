@@ -0,0 +1,330 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	app "k8s.io/api/apps/v1beta1"
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ResourceGroup tags resources by kind/name patterns, same syntax as -only.
+// Groups are applied in the order they are declared, ungrouped resources
+// last, except ConfigMaps and Secrets which come first as any group may
+// mount them. With wait, the workloads of a group have to be ready before
+// the next group starts.
+type ResourceGroup struct {
+	Name      string   `yaml:"name"`
+	Resources []string `yaml:"resources"`
+	Wait      bool     `yaml:"wait"`
+}
+
+func (p *Project) assignGroups() error {
+	if len(p.projectConfig.Groups) == 0 {
+		return nil
+	}
+	filters := []resourceFilter{}
+	for _, group := range p.projectConfig.Groups {
+		filter, err := parseResourceFilter(strings.Join(group.Resources, ","))
+		if err != nil {
+			return fmt.Errorf("group %q: %s", group.Name, err.Error())
+		}
+		filters = append(filters, filter)
+	}
+	for _, asset := range p.assets() {
+		asset.group = len(p.projectConfig.Groups)
+		for i, filter := range filters {
+			if filter.matches(asset) {
+				asset.group = i
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// groupName is empty for ungrouped assets
+func (p *Project) groupName(asset *Asset) string {
+	if asset.group >= len(p.projectConfig.Groups) {
+		return ""
+	}
+	return p.projectConfig.Groups[asset.group].Name
+}
+
+func (p *Project) filterGroups() error {
+	if p.config.group == "" {
+		return nil
+	}
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(p.config.group, ",") {
+		found := false
+		for _, group := range p.projectConfig.Groups {
+			if group.Name == name {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown group %q", name)
+		}
+		selected[name] = true
+	}
+	keep := func(assets []*Asset) []*Asset {
+		kept := []*Asset{}
+		for _, asset := range assets {
+			if selected[p.groupName(asset)] {
+				kept = append(kept, asset)
+			}
+		}
+		return kept
+	}
+	p.resources = keep(p.resources)
+	p.jobs = keep(p.jobs)
+	p.services = keep(p.services)
+	return nil
+}
+
+// orderByGroup sorts assets by group, keeping resources, jobs and services
+// in that order inside each group. Ungrouped ConfigMaps and Secrets go
+// before every group.
+func orderByGroup(assets []*Asset, groups int) []*Asset {
+	rank := func(asset *Asset) int {
+		if asset.group >= groups && (asset.Kind == "configmap" || asset.Kind == "secret") {
+			return -1
+		}
+		return asset.group
+	}
+	sort.SliceStable(assets, func(i, j int) bool {
+		return rank(assets[i]) < rank(assets[j])
+	})
+	return assets
+}
+
+func (p *Project) groupWaits(group int) bool {
+	return group < len(p.projectConfig.Groups) && p.projectConfig.Groups[group].Wait
+}
+
+// waitForGroup waits until the workloads of a group are rolled out
+func (p *Project) waitForGroup(group int, assets []*Asset) error {
+	p.printer.Printf(ColorYellow, "==> Waiting for group %q to be ready\n", p.projectConfig.Groups[group].Name)
+	members := []*Asset{}
+	for _, asset := range assets {
+		if asset.group == group {
+			members = append(members, asset)
+		}
+	}
+	err := p.waitForRollout(members)
+	if err != nil {
+		return err
+	}
+	p.printer.Println(ColorGreen, "====> Ready")
+	return nil
+}
+
+// waitForRollout waits until workloads are rolled out, failing as soon as a
+// new pod crashes, with one watch per kind and namespace rather than reading
+// each workload in turn
+func (p *Project) waitForRollout(assets []*Asset) error {
+	deadline := time.Now().Add(p.config.timeout)
+	keys := []string{}
+	waits := make(map[string][]*Asset)
+	for _, asset := range assets {
+		key := asset.context + "/" + asset.Kind + "/" + asset.Namespace()
+		if waits[key] == nil {
+			keys = append(keys, key)
+		}
+		waits[key] = append(waits[key], asset)
+	}
+	for _, key := range keys {
+		err := p.waitForAssets(waits[key], deadline)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForAssets waits for assets of the same kind, namespace and cluster,
+// looking for crashing pods along the way
+func (p *Project) waitForAssets(assets []*Asset, deadline time.Time) error {
+	asset := assets[0]
+	names := []string{}
+	for _, asset := range assets {
+		names = append(names, asset.ResourceData.(Meta).GetName())
+	}
+	check := func() error {
+		for _, asset := range assets {
+			err := p.checkPodFailures(asset)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return waitForWorkloads(p.clientFor(asset), p.plugins, asset.Kind, asset.Namespace(), names, deadline, check)
+}
+
+// workloadCheckInterval is how long a watch on workloads lasts before check
+// runs again
+const workloadCheckInterval = 10 * time.Second
+
+// waitForWorkloads waits until the named workloads are ready. They share a
+// single watch, the api server pushes their changes instead of being asked for
+// each of them every few seconds. check runs before each watch.
+func waitForWorkloads(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, namespace string, names []string, deadline time.Time, check func() error) error {
+	pending := make(map[string]bool)
+	for _, name := range names {
+		pending[name] = true
+	}
+	for len(pending) > 0 {
+		err := check()
+		if err != nil {
+			return err
+		}
+		options := apiv1.ListOptions{}
+		if len(pending) == 1 {
+			options.FieldSelector = "metadata.name=" + pendingNames(pending)[0]
+		}
+		var watcher watch.Interface
+		if _, ok := plugins[kind]; !ok && isWorkloadKind(kind) {
+			watcher, err = watchResources(kubeClient, kind, namespace, options)
+		}
+		if watcher == nil || err != nil {
+			// plugin kinds, or not allowed to watch: read them one by one
+			err = pollWorkloads(kubeClient, plugins, kind, namespace, pending)
+		} else {
+			err = watchWorkloads(watcher, pending, deadline)
+		}
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 && time.Now().After(deadline) {
+			return newTypedError(ErrorTypeTimeout, "timeout while waiting for %s %s to be ready", kind, strings.Join(pendingNames(pending), ", "))
+		}
+	}
+	return nil
+}
+
+func pollWorkloads(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, namespace string, pending map[string]bool) error {
+	for _, name := range pendingNames(pending) {
+		ready, err := workloadReady(kubeClient, plugins, kind, name, namespace)
+		if err != nil {
+			return err
+		}
+		if ready {
+			delete(pending, name)
+		}
+	}
+	if len(pending) > 0 {
+		time.Sleep(2 * time.Second)
+	}
+	return nil
+}
+
+// watchWorkloads takes ready workloads off pending as their events come, until
+// none is left, the deadline or workloadCheckInterval passes or the server
+// closes the watch
+func watchWorkloads(watcher watch.Interface, pending map[string]bool, deadline time.Time) error {
+	defer watcher.Stop()
+	until := time.Now().Add(workloadCheckInterval)
+	if deadline.Before(until) {
+		until = deadline
+	}
+	timer := time.NewTimer(time.Until(until))
+	defer timer.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok || event.Type == watch.Error {
+				return nil
+			}
+			meta, ok := event.Object.(Meta)
+			if !ok {
+				continue
+			}
+			name := meta.GetName()
+			if _, wanted := pending[name]; !wanted {
+				continue
+			}
+			ready, err := resourceReady(name, event.Object)
+			if err != nil {
+				return err
+			}
+			if ready && event.Type != watch.Deleted {
+				delete(pending, name)
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+func pendingNames(pending map[string]bool) []string {
+	names := []string{}
+	for name := range pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isWorkloadKind(kind string) bool {
+	switch kind {
+	case "deployment", "statefulset", "daemonset", "job":
+		return true
+	}
+	return false
+}
+
+// workloadReady reports whether a workload finished rolling out. Other kinds
+// are ready as soon as they exist.
+func workloadReady(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, name, namespace string) (bool, error) {
+	plugin, ok := plugins[kind]
+	if ok {
+		return plugin.ready(name, namespace)
+	}
+	if !isWorkloadKind(kind) {
+		return true, nil
+	}
+	resource, err := getResource(kubeClient, plugins, kind, name, namespace)
+	if err != nil {
+		return false, err
+	}
+	return resourceReady(name, resource)
+}
+
+// resourceReady computes the readiness of a workload from its status
+func resourceReady(name string, resource interface{}) (bool, error) {
+	replicas := func(value *int32) int32 {
+		if value == nil {
+			return 1
+		}
+		return *value
+	}
+	switch resource := resource.(type) {
+	case *v1beta1.Deployment:
+		desired := replicas(resource.Spec.Replicas)
+		return resource.Status.ObservedGeneration >= resource.Generation && resource.Status.UpdatedReplicas == desired && resource.Status.AvailableReplicas == desired, nil
+	case *app.StatefulSet:
+		return resource.Status.ReadyReplicas == replicas(resource.Spec.Replicas), nil
+	case *v1beta1.DaemonSet:
+		return resource.Status.NumberReady == resource.Status.DesiredNumberScheduled, nil
+	case *v1batch.Job:
+		for _, condition := range resource.Status.Conditions {
+			if condition.Type == v1batch.JobFailed && condition.Status == v1.ConditionTrue {
+				return false, newTypedError(ErrorTypeRolloutFailure, "job %q failed: %s", name, condition.Message)
+			}
+		}
+		return resource.Status.Succeeded >= replicas(resource.Spec.Completions), nil
+	}
+	return true, nil
+}
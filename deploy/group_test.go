@@ -0,0 +1,150 @@
+package deploy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	app "k8s.io/api/apps/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGroups(t *testing.T) {
+	req := require.New(t)
+	newProject := func(group string) *Project {
+		return &Project{
+			config: &appConfig{group: group},
+			projectConfig: &ProjectConfig{Groups: []*ResourceGroup{
+				{Name: "database", Resources: []string{"statefulset/postgres", "svc/postgres"}, Wait: true},
+				{Name: "frontend", Resources: []string{"*/web*"}},
+			}},
+			resources: []*Asset{
+				{Kind: "configmap", ResourceData: &v1.ConfigMap{ObjectMeta: apiv1.ObjectMeta{Name: "web-config"}}},
+				{Kind: "configmap", ResourceData: &v1.ConfigMap{ObjectMeta: apiv1.ObjectMeta{Name: "shared"}}},
+			},
+			services: []*Asset{
+				{Kind: "deployment", ResourceData: &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "web"}}},
+				{Kind: "service", ResourceData: &v1.Service{ObjectMeta: apiv1.ObjectMeta{Name: "postgres"}}},
+				{Kind: "statefulset", ResourceData: &app.StatefulSet{ObjectMeta: apiv1.ObjectMeta{Name: "postgres"}}},
+			},
+		}
+	}
+	keys := func(p *Project) []string {
+		result := []string{}
+		for _, asset := range p.assets() {
+			result = append(result, assetKey(asset))
+		}
+		return result
+	}
+	p := newProject("")
+	req.NoError(p.assignGroups())
+	req.NoError(p.filterGroups())
+	req.Equal([]string{
+		"configmap/shared",
+		"service/postgres",
+		"statefulset/postgres",
+		"configmap/web-config",
+		"deployment/web",
+	}, keys(p))
+	req.True(p.groupWaits(0))
+	req.False(p.groupWaits(1))
+	req.False(p.groupWaits(2))
+
+	p = newProject("frontend")
+	req.NoError(p.assignGroups())
+	req.NoError(p.filterGroups())
+	req.Equal([]string{"configmap/web-config", "deployment/web"}, keys(p))
+
+	p = newProject("cache")
+	req.NoError(p.assignGroups())
+	req.Error(p.filterGroups())
+}
+
+func TestWaitForGroup(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	deployment := func(name string, available int) string {
+		return fmt.Sprintf(`{"apiVersion":"extensions/v1beta1","kind":"Deployment","metadata":{"name":%q,"namespace":"web","generation":1},"spec":{"replicas":2,"selector":{"matchLabels":{"app":%q}}},"status":{"observedGeneration":1,"updatedReplicas":2,"availableReplicas":%d}}`, name, name, available)
+	}
+	path := "/apis/extensions/v1beta1/namespaces/web/deployments/"
+	cluster.add(path+"web", deployment("web", 1))
+	cluster.add(path+"api", deployment("api", 0))
+	cluster.add(path+"worker", deployment("worker", 0))
+	newProject := func(timeout time.Duration) *Project {
+		services := []*Asset{}
+		for _, name := range []string{"web", "api"} {
+			asset, err := parseAsset(name+".yml", []byte("apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: "+name+"\n  namespace: web\nspec:\n  selector:\n    matchLabels:\n      app: "+name+"\n"))
+			req.Nil(err)
+			services = append(services, asset)
+		}
+		return &Project{
+			kubeClient:    kubeClient,
+			config:        &appConfig{timeout: timeout},
+			projectConfig: &ProjectConfig{Groups: []*ResourceGroup{{Name: "backend", Wait: true}}},
+			services:      services,
+		}
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		cluster.add(path+"web", deployment("web", 2))
+		time.Sleep(200 * time.Millisecond)
+		cluster.add(path+"api", deployment("api", 2))
+	}()
+	p := newProject(time.Minute)
+	req.Nil(p.waitForGroup(0, p.services))
+	// one watch for both, no reads of the deployments
+	req.Len(cluster.requested("WATCH", "/apis/extensions/v1beta1/namespaces/web/deployments"), 1)
+	req.Empty(cluster.requested("GET", path))
+
+	cluster.add(path+"api", deployment("api", 1))
+	p = newProject(300 * time.Millisecond)
+	err := p.waitForGroup(0, p.services)
+	req.Error(err)
+	req.Equal(ErrorTypeTimeout, classifyError(err))
+	req.Contains(err.Error(), "deployment api")
+
+	// The last group is waited for too, before the deploy reports success
+	t.Setenv("HOME", t.TempDir())
+	p = newProject(300 * time.Millisecond)
+	err = p.applyAssets(func(asset *Asset) error { return nil })
+	req.Error(err)
+	req.Equal(ErrorTypeTimeout, classifyError(err))
+}
+
+func TestWaitForRollout(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("HOME", t.TempDir())
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/apis/extensions/v1beta1/namespaces/web/deployments/web", `{"apiVersion":"extensions/v1beta1","kind":"Deployment","metadata":{"name":"web","namespace":"web","generation":1},"spec":{"replicas":1,"selector":{"matchLabels":{"app":"web"}}},"status":{"observedGeneration":1}}`)
+	crashing := func(name, uid string) string {
+		return fmt.Sprintf(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":%q,"namespace":"web","uid":%q,"labels":{"app":"web"},"creationTimestamp":"2030-01-01T00:00:00Z"},"status":{"containerStatuses":[{"name":"app","state":{"waiting":{"reason":"CrashLoopBackOff"}},"lastState":{"terminated":{"exitCode":3}}}]}}`, name, uid)
+	}
+	// The cluster clock is ahead, the old pod looks newer than the deploy
+	cluster.add("/api/v1/namespaces/web/pods/web-old", crashing("web-old", "1"))
+	asset, err := parseAsset("web.yml", []byte("apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: web\nspec:\n  selector:\n    matchLabels:\n      app: web\n"))
+	req.Nil(err)
+	p := &Project{
+		kubeClient:    kubeClient,
+		config:        &appConfig{timeout: 500 * time.Millisecond, waitRollout: true},
+		projectConfig: &ProjectConfig{},
+		services:      []*Asset{asset},
+	}
+
+	// Without groups the deploy waits with -wait, old pods don't fail it
+	err = p.applyAssets(func(asset *Asset) error { return nil })
+	req.Error(err)
+	req.Equal(ErrorTypeTimeout, classifyError(err))
+
+	p.config.timeout = time.Minute
+	err = p.applyAssets(func(asset *Asset) error {
+		cluster.add("/api/v1/namespaces/web/pods/web-new", crashing("web-new", "2"))
+		return nil
+	})
+	req.Error(err)
+	req.Equal(ErrorTypeRolloutFailure, classifyError(err))
+	req.Contains(err.Error(), `pod "web-new"`)
+}
@@ -0,0 +1,203 @@
+package deploy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	releaseHistoryPrefix = "imladris-releases-"
+	releaseHistoryKey    = "releases"
+	releaseHistoryLimit  = 20
+	// releaseManifestLimit is how many of the latest records keep their
+	// manifests, the history is a single configmap
+	releaseManifestLimit = 3
+)
+
+type ReleaseRecord struct {
+	Time      time.Time         `json:"time"`
+	Command   string            `json:"command"`
+	Release   string            `json:"release,omitempty"`
+	Images    map[string]string `json:"images"`
+	Checksums map[string]string `json:"checksums,omitempty"`
+	// Manifests are the resources as applied, gzipped json by assetKey, for
+	// a rollback to restore. Secrets and jobs are left out.
+	Manifests string `json:"manifests,omitempty"`
+}
+
+func releaseImageKey(kind, name, container string) string {
+	return kind + "/" + name + "/" + container
+}
+
+func (p *Project) releaseImages() map[string]string {
+	images := make(map[string]string)
+	for _, asset := range p.assets() {
+		podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+		if podSpec == nil {
+			continue
+		}
+		assetName := asset.ResourceData.(Meta).GetName()
+		for _, container := range podSpec.Containers {
+			images[releaseImageKey(asset.Kind, assetName, container.Name)] = container.Image
+		}
+	}
+	return images
+}
+
+func (p *Project) recordRelease(command string) {
+	if p.reconciling {
+		return
+	}
+	record := &ReleaseRecord{
+		Time:      time.Now().UTC(),
+		Command:   command,
+		Release:   p.releaseID(),
+		Images:    p.releaseImages(),
+		Checksums: p.releaseChecksums(),
+		Manifests: p.releaseManifests(),
+	}
+	backend, err := p.historyBackend()
+	if err != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot record release history: %s\n", err.Error())
+		return
+	}
+	records, err := backend.read(p.projectConfig.Name)
+	if err != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot record release history: %s\n", err.Error())
+		return
+	}
+	previous := latestRelease(records)
+	changes := buildChangelog(previous, record)
+	// Re-applying the same manifests, as watch mode does on every change in
+	// the folder, is not a new release
+	if previous != nil && previous.Checksums != nil && len(changes) == 0 {
+		p.printer.Printf(ColorGray, "No changes since release %s, nothing recorded\n", previous.Release)
+		return
+	}
+	p.publishChangelog(changes)
+	err = backend.write(p.projectConfig.Name, appendRecord(records, record))
+	if err != nil {
+		// Deploy already happened, only warn here
+		p.printer.ErrPrintf(ColorRed, "Cannot record release history: %s\n", err.Error())
+	}
+}
+
+func appendReleaseRecord(backend historyBackend, projectName string, record *ReleaseRecord) error {
+	records, err := backend.read(projectName)
+	if err != nil {
+		return err
+	}
+	return backend.write(projectName, appendRecord(records, record))
+}
+
+// appendRecord keeps the last releaseHistoryLimit records, the manifests
+// only in the last releaseManifestLimit
+func appendRecord(records []*ReleaseRecord, record *ReleaseRecord) []*ReleaseRecord {
+	records = append(records, record)
+	if len(records) > releaseHistoryLimit {
+		records = records[len(records)-releaseHistoryLimit:]
+	}
+	for i := 0; i < len(records)-releaseManifestLimit; i++ {
+		records[i].Manifests = ""
+	}
+	return records
+}
+
+// releaseManifests encodes the resources of this release for a rollback.
+// Secrets stay out of the history, and jobs are never re-run by a rollback.
+func (p *Project) releaseManifests() string {
+	manifests := make(map[string]json.RawMessage)
+	for _, asset := range p.assets() {
+		if asset.Kind == "secret" || asset.Kind == "job" {
+			continue
+		}
+		data, err := json.Marshal(asset.ResourceData)
+		if err == nil {
+			manifests[assetKey(asset)] = data
+		}
+	}
+	data, err := json.Marshal(manifests)
+	if err != nil {
+		return ""
+	}
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	writer.Write(data)
+	writer.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// configMapHistory keeps the history in a configmap next to the project,
+// lost with the cluster
+type configMapHistory struct {
+	kubeClient *kubernetes.Clientset
+	namespace  string
+}
+
+func (h *configMapHistory) read(projectName string) ([]*ReleaseRecord, error) {
+	configMap, err := h.kubeClient.Core().ConfigMaps(h.namespace).Get(releaseHistoryPrefix+projectName, apiv1.GetOptions{})
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return decodeReleaseHistory(projectName, []byte(configMap.Data[releaseHistoryKey]))
+}
+
+func (h *configMapHistory) write(projectName string, records []*ReleaseRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	configMap, err := h.kubeClient.Core().ConfigMaps(h.namespace).Get(releaseHistoryPrefix+projectName, apiv1.GetOptions{})
+	if err != nil {
+		if !isResourceNotExist(err) {
+			return err
+		}
+		configMap = &v1.ConfigMap{
+			ObjectMeta: apiv1.ObjectMeta{
+				Name:      releaseHistoryPrefix + projectName,
+				Namespace: h.namespace,
+			},
+			Data: map[string]string{
+				releaseHistoryKey: string(data),
+			},
+		}
+		_, err = h.kubeClient.Core().ConfigMaps(h.namespace).Create(configMap)
+		return err
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[releaseHistoryKey] = string(data)
+	_, err = h.kubeClient.Core().ConfigMaps(h.namespace).Update(configMap)
+	return err
+}
+
+func decodeReleaseHistory(projectName string, data []byte) ([]*ReleaseRecord, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	records := []*ReleaseRecord{}
+	err := json.Unmarshal(data, &records)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read release history of %q: %s", projectName, err.Error())
+	}
+	return records, nil
+}
+
+func latestRelease(records []*ReleaseRecord) *ReleaseRecord {
+	if len(records) == 0 {
+		return nil
+	}
+	return records[len(records)-1]
+}
@@ -0,0 +1,188 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// HistoryConfig moves the release history out of the cluster so it survives
+// a rebuild. URL is s3://bucket/prefix, gs://bucket/prefix or a postgres://
+// connection string, without it the history is a configmap of the project.
+// Outside of the cluster the history is kept per context and namespace, so
+// staging and prod releases of a project never mix.
+type HistoryConfig struct {
+	URL string `yaml:"url"`
+}
+
+// historyBackend stores the release records of projects
+type historyBackend interface {
+	read(projectName string) ([]*ReleaseRecord, error)
+	write(projectName string, records []*ReleaseRecord) error
+}
+
+func (p *Project) historyBackend() (historyBackend, error) {
+	config := p.projectConfig.History
+	if config == nil || config.URL == "" {
+		return &configMapHistory{kubeClient: p.kubeClient, namespace: p.projectConfig.Namespace}, nil
+	}
+	context := contextName(p.config)
+	namespace := p.projectConfig.Namespace
+	switch {
+	case strings.HasPrefix(config.URL, "s3://"), strings.HasPrefix(config.URL, "gs://"):
+		return &objectHistory{url: config.URL, context: context, namespace: namespace}, nil
+	case strings.HasPrefix(config.URL, "postgres://"), strings.HasPrefix(config.URL, "postgresql://"):
+		registerSensitive(config.URL)
+		dsn, err := url.Parse(config.URL)
+		if err != nil {
+			return nil, validationError(fmt.Errorf("invalid history url: %s", scrub(err.Error())))
+		}
+		// The password goes through the environment, the arguments of a
+		// process are visible to everyone on the host
+		password, _ := dsn.User.Password()
+		if dsn.User != nil {
+			dsn.User = url.User(dsn.User.Username())
+		}
+		return &postgresHistory{dsn: dsn.String(), password: password, context: context, namespace: namespace}, nil
+	}
+	return nil, validationError(fmt.Errorf("unsupported history url %q, use s3://, gs:// or postgres://", scrub(config.URL)))
+}
+
+func runHistoryCommand(stdin []byte, name string, args ...string) ([]byte, error) {
+	return runHistoryCmd(exec.Command(name, args...), stdin)
+}
+
+func runHistoryCmd(cmd *exec.Cmd, stdin []byte) ([]byte, error) {
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	outBuffer := &bytes.Buffer{}
+	errBuffer := &bytes.Buffer{}
+	cmd.Stdout = outBuffer
+	cmd.Stderr = errBuffer
+	err := cmd.Run()
+	if err != nil {
+		return nil, errors.New(strings.TrimSpace(errBuffer.String()))
+	}
+	return outBuffer.Bytes(), nil
+}
+
+// objectHistory keeps one json object per project in a bucket, through the
+// aws and gsutil command lines like the audit log
+type objectHistory struct {
+	url       string
+	context   string
+	namespace string
+}
+
+func (h *objectHistory) key(projectName string) string {
+	return strings.TrimRight(h.url, "/") + "/" + url.PathEscape(h.context) + "/" + url.PathEscape(h.namespace) + "/" + projectName + ".json"
+}
+
+func (h *objectHistory) read(projectName string) ([]*ReleaseRecord, error) {
+	key := h.key(projectName)
+	err := requireNetwork(key)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if strings.HasPrefix(key, "s3://") {
+		data, err = runHistoryCommand(nil, "aws", "s3", "cp", key, "-")
+	} else {
+		data, err = runHistoryCommand(nil, "gsutil", "cat", key)
+	}
+	if err != nil {
+		message := err.Error()
+		for _, notFound := range []string{"NoSuchKey", "(404)", "Not Found", "No URLs matched"} {
+			if strings.Contains(message, notFound) {
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot read release history from %q: %s", key, message)
+	}
+	return decodeReleaseHistory(projectName, data)
+}
+
+func (h *objectHistory) write(projectName string, records []*ReleaseRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	key := h.key(projectName)
+	if strings.HasPrefix(key, "s3://") {
+		return uploadS3(key, data)
+	}
+	err = requireNetwork(key)
+	if err != nil {
+		return err
+	}
+	_, err = runHistoryCommand(data, "gsutil", "cp", "-", key)
+	return err
+}
+
+// postgresHistory keeps the history in an imladris_release_history table,
+// through psql. The script and its values go through stdin and the password
+// through PGPASSWORD, nothing of them shows in the process list.
+type postgresHistory struct {
+	dsn       string
+	password  string
+	context   string
+	namespace string
+}
+
+const postgresHistoryTable = "create table if not exists imladris_release_history (context text not null, namespace text not null, project text not null, records text not null, primary key (context, namespace, project));\n"
+
+func (h *postgresHistory) psql(script string) ([]byte, error) {
+	err := requireNetwork("postgres")
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("psql", "-X", "-q", "-A", "-t", "-v", "ON_ERROR_STOP=1", "-d", h.dsn)
+	cmd.Env = os.Environ()
+	if h.password != "" {
+		cmd.Env = append(cmd.Env, "PGPASSWORD="+h.password)
+	}
+	output, err := runHistoryCmd(cmd, []byte(postgresHistoryTable+script))
+	if err != nil {
+		return nil, fmt.Errorf("cannot access release history in postgres: %s", scrub(err.Error()))
+	}
+	return output, nil
+}
+
+// where selects the history of a project in the context and namespace
+func (h *postgresHistory) where(projectName string) string {
+	return fmt.Sprintf("context = %s and namespace = %s and project = %s", dollarQuote(h.context), dollarQuote(h.namespace), dollarQuote(projectName))
+}
+
+func (h *postgresHistory) read(projectName string) ([]*ReleaseRecord, error) {
+	output, err := h.psql("select records from imladris_release_history where " + h.where(projectName) + ";\n")
+	if err != nil {
+		return nil, err
+	}
+	return decodeReleaseHistory(projectName, output)
+}
+
+func (h *postgresHistory) write(projectName string, records []*ReleaseRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	_, err = h.psql(fmt.Sprintf("insert into imladris_release_history (context, namespace, project, records) values (%s, %s, %s, %s) on conflict (context, namespace, project) do update set records = excluded.records;\n",
+		dollarQuote(h.context), dollarQuote(h.namespace), dollarQuote(projectName), dollarQuote(string(data))))
+	return err
+}
+
+// dollarQuote writes a postgres string constant as $tag$value$tag$, with a
+// tag the value doesn't contain, so nothing in the value needs escaping
+func dollarQuote(value string) string {
+	tag := "$imladris$"
+	for i := 0; strings.Contains(value, tag); i++ {
+		tag = fmt.Sprintf("$imladris%d$", i)
+	}
+	return tag + value + tag
+}
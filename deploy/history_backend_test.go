@@ -0,0 +1,103 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryHistory struct {
+	records map[string][]*ReleaseRecord
+}
+
+func (h *memoryHistory) read(projectName string) ([]*ReleaseRecord, error) {
+	return h.records[projectName], nil
+}
+
+func (h *memoryHistory) write(projectName string, records []*ReleaseRecord) error {
+	h.records[projectName] = records
+	return nil
+}
+
+func TestAppendReleaseRecord(t *testing.T) {
+	req := require.New(t)
+	backend := &memoryHistory{records: make(map[string][]*ReleaseRecord)}
+	for i := 0; i < releaseHistoryLimit+5; i++ {
+		req.NoError(appendReleaseRecord(backend, "web", &ReleaseRecord{Release: fmt.Sprint(i)}))
+	}
+	req.Len(backend.records["web"], releaseHistoryLimit)
+	req.Equal("5", backend.records["web"][0].Release)
+	req.Equal(fmt.Sprint(releaseHistoryLimit+4), latestRelease(backend.records["web"]).Release)
+}
+
+func TestHistoryBackend(t *testing.T) {
+	req := require.New(t)
+	isolateSensitiveValues(t)
+	project := &Project{config: &appConfig{context: "prod"}, projectConfig: &ProjectConfig{Namespace: "anduin"}}
+	backend, err := project.historyBackend()
+	req.NoError(err)
+	req.IsType(&configMapHistory{}, backend)
+
+	project.projectConfig.History = &HistoryConfig{URL: "s3://releases/imladris/"}
+	backend, err = project.historyBackend()
+	req.NoError(err)
+	req.Equal("s3://releases/imladris/prod/anduin/web.json", backend.(*objectHistory).key("web"))
+
+	project.projectConfig.History = &HistoryConfig{URL: "postgres://imladris:hunter2@db/releases"}
+	backend, err = project.historyBackend()
+	req.NoError(err)
+	req.Equal(&postgresHistory{dsn: "postgres://imladris@db/releases", password: "hunter2", context: "prod", namespace: "anduin"}, backend)
+	req.NotContains(scrub("postgres://imladris:hunter2@db/releases"), "hunter2")
+
+	project.projectConfig.History = &HistoryConfig{URL: "ftp://releases"}
+	_, err = project.historyBackend()
+	req.Error(err)
+}
+
+func TestPostgresHistory(t *testing.T) {
+	req := require.New(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("fake psql is a shell script")
+	}
+	folder := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\" > " + folder + "/args\necho \"$PGPASSWORD\" > " + folder + "/password\ncat > " + folder + "/stdin\n"
+	req.NoError(ioutil.WriteFile(filepath.Join(folder, "psql"), []byte(script), 0755))
+	t.Setenv("PATH", folder+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	history := &postgresHistory{dsn: "postgres://imladris@db/releases", password: "hunter2", context: "prod", namespace: "anduin"}
+	req.NoError(history.write("web", []*ReleaseRecord{{Release: "it's $imladris$"}}))
+	args, err := ioutil.ReadFile(filepath.Join(folder, "args"))
+	req.NoError(err)
+	req.NotContains(string(args), "hunter2")
+	req.NotContains(string(args), "it's")
+	password, err := ioutil.ReadFile(filepath.Join(folder, "password"))
+	req.NoError(err)
+	req.Equal("hunter2\n", string(password))
+	stdin, err := ioutil.ReadFile(filepath.Join(folder, "stdin"))
+	req.NoError(err)
+	req.Contains(string(stdin), `values ($imladris$prod$imladris$, $imladris$anduin$imladris$, $imladris$web$imladris$, $imladris0$[{"time":`)
+	req.Contains(string(stdin), `on conflict (context, namespace, project)`)
+}
+
+func TestDollarQuote(t *testing.T) {
+	req := require.New(t)
+	req.Equal("$imladris$it's$imladris$", dollarQuote("it's"))
+	req.Equal("$imladris1$a $imladris$ $imladris0$$imladris1$", dollarQuote("a $imladris$ $imladris0$"))
+}
+
+func TestDecodeReleaseHistory(t *testing.T) {
+	req := require.New(t)
+	records, err := decodeReleaseHistory("web", []byte("\n"))
+	req.NoError(err)
+	req.Nil(records)
+	records, err = decodeReleaseHistory("web", []byte(`[{"release":"abc","images":{}}]`+"\n"))
+	req.NoError(err)
+	req.Equal("abc", records[0].Release)
+	_, err = decodeReleaseHistory("web", []byte("not json"))
+	req.Error(err)
+}
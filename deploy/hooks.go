@@ -0,0 +1,46 @@
+package deploy
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+)
+
+func (p *Project) releaseID() string {
+	return p.startedAt.UTC().Format("20060102-150405")
+}
+
+func (p *Project) startDeploy(command string) time.Time {
+	p.startDeploymentStatus()
+	p.audit(command, "", "", nil, nil)
+	p.postDeployEvent(v1.EventTypeNormal, "DeployStarted", fmt.Sprintf("Release %s of %q started by %s (%s)", p.releaseID(), p.projectConfig.Name, deployActor(), command))
+	return time.Now()
+}
+
+func (p *Project) resourceApplied(action string, asset *Asset, err error) {
+	p.liveResources(asset).invalidate(asset.Kind, asset.ResourceData.(Meta).GetName())
+	p.observeResource(asset.Kind, err)
+	p.postResourceEvent(asset, err)
+	p.auditAsset(action, asset, err)
+}
+
+func (p *Project) resourceDestroyed(asset *Asset, err error) {
+	p.liveResources(asset).invalidate(asset.Kind, asset.ResourceData.(Meta).GetName())
+	p.auditAsset("delete", asset, err)
+}
+
+func (p *Project) finishDeploy(command string, started time.Time, err error) {
+	p.observeDeploy(command, started, err)
+	p.finishDeploymentStatus(err == nil)
+	p.audit(command+"-finished", "", "", err, map[string]string{
+		"duration": time.Since(started).String(),
+	})
+	if err != nil {
+		p.writeForensics(err)
+		p.postDeployEvent(v1.EventTypeWarning, "DeployFailed", fmt.Sprintf("Release %s of %q by %s failed: %s", p.releaseID(), p.projectConfig.Name, deployActor(), err.Error()))
+		return
+	}
+	p.postDeployEvent(v1.EventTypeNormal, "DeploySucceeded", fmt.Sprintf("Release %s of %q by %s succeeded", p.releaseID(), p.projectConfig.Name, deployActor()))
+	p.sendDeployMarkers()
+}
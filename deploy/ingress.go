@@ -0,0 +1,122 @@
+package deploy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/api/extensions/v1beta1"
+)
+
+// ingressURLs lists the externally reachable URLs of an ingress. Rules
+// without a host are served on the load balancer address, when known.
+func ingressURLs(ingress *v1beta1.Ingress, address string) []string {
+	tlsHosts := make(map[string]bool)
+	for _, ingressTLS := range ingress.Spec.TLS {
+		for _, host := range ingressTLS.Hosts {
+			tlsHosts[host] = true
+		}
+	}
+	urls := []string{}
+	seen := make(map[string]bool)
+	for _, rule := range ingress.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = address
+		}
+		if host == "" {
+			continue
+		}
+		scheme := "http"
+		if tlsHosts[rule.Host] {
+			scheme = "https"
+		}
+		paths := []string{"/"}
+		if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
+			paths = []string{}
+			for _, path := range rule.HTTP.Paths {
+				if path.Path == "" {
+					paths = append(paths, "/")
+				} else {
+					paths = append(paths, path.Path)
+				}
+			}
+		}
+		for _, path := range paths {
+			url := scheme + "://" + host + path
+			if !seen[url] {
+				seen[url] = true
+				urls = append(urls, url)
+			}
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func (p *Project) reportIngressURLs() error {
+	urls := []string{}
+	for _, asset := range p.assets() {
+		ingress, ok := asset.ResourceData.(*v1beta1.Ingress)
+		if !ok {
+			continue
+		}
+		address := ""
+		live, err := getResource(p.clientFor(asset), p.plugins, "ingress", ingress.Name, asset.Namespace())
+		if err == nil {
+			for _, lbIngress := range live.(*v1beta1.Ingress).Status.LoadBalancer.Ingress {
+				address = lbIngress.IP
+				if address == "" {
+					address = lbIngress.Hostname
+				}
+			}
+		}
+		urls = append(urls, ingressURLs(ingress, address)...)
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	p.printer.Println(ColorBlue, "==> Ingress URLs")
+	if !p.config.checkURLs {
+		for _, url := range urls {
+			p.printer.Printf(ColorWhite, "  %s\n", url)
+		}
+		return nil
+	}
+	unavailable := []string{}
+	for _, url := range urls {
+		available, err := waitForURL(url, time.Now().Add(p.config.timeout))
+		if err != nil {
+			p.printer.Printf(ColorRed, "  %s (not available: %s)\n", url, err.Error())
+			unavailable = append(unavailable, url)
+			continue
+		}
+		p.printer.Printf(ColorGreen, "  %s (available %s after deploy start)\n", url, available.Sub(p.startedAt).Round(time.Second))
+	}
+	if len(unavailable) > 0 {
+		return newTypedError(ErrorTypeTimeout, "ingress urls not available: %s", strings.Join(unavailable, ", "))
+	}
+	return nil
+}
+
+// waitForURL polls url until it answers with anything but a server error,
+// which is what a backend still starting behind an ingress returns
+func waitForURL(url string, deadline time.Time) (time.Time, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for {
+		response, err := client.Get(url)
+		if err == nil {
+			response.Body.Close()
+			if response.StatusCode < http.StatusInternalServerError {
+				return time.Now(), nil
+			}
+			err = fmt.Errorf("status %d", response.StatusCode)
+		}
+		if time.Now().After(deadline) {
+			return time.Time{}, err
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
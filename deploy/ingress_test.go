@@ -0,0 +1,33 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/extensions/v1beta1"
+)
+
+func TestIngressURLs(t *testing.T) {
+	req := require.New(t)
+	ingress := &v1beta1.Ingress{
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{Hosts: []string{"app.example.com"}, SecretName: "app-tls"}},
+			Rules: []v1beta1.IngressRule{
+				{
+					Host: "app.example.com",
+					IngressRuleValue: v1beta1.IngressRuleValue{HTTP: &v1beta1.HTTPIngressRuleValue{
+						Paths: []v1beta1.HTTPIngressPath{{Path: "/api"}, {Path: "/"}},
+					}},
+				},
+				{Host: "status.example.com"},
+				{},
+			},
+		},
+	}
+	req.Equal([]string{
+		"http://status.example.com/",
+		"https://app.example.com/",
+		"https://app.example.com/api",
+	}, ingressURLs(ingress, ""))
+	req.Contains(ingressURLs(ingress, "35.1.2.3"), "http://35.1.2.3/")
+}
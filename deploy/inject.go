@@ -0,0 +1,131 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/core/v1"
+	kubeyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// InjectConfig adds containers and volumes to the pod template of every
+// matching workload, e.g. a log shipper sidecar or a secrets agent init
+// container. Containers and volumes are written as in a pod manifest.
+// Resources defaults to all deployments.
+type InjectConfig struct {
+	Resources      []string      `yaml:"resources"`
+	InitContainers []interface{} `yaml:"init_containers"`
+	Sidecars       []interface{} `yaml:"sidecars"`
+	Volumes        []interface{} `yaml:"volumes"`
+}
+
+// decodeKubeObject converts a value read from the project file into a
+// kubernetes type, which only has json field names
+func decodeKubeObject(value interface{}, target interface{}) error {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	jsonData, err := kubeyaml.ToJSON(data)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(jsonData)))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(target)
+}
+
+func decodeContainers(values []interface{}) ([]v1.Container, error) {
+	containers := []v1.Container{}
+	for _, value := range values {
+		container := v1.Container{}
+		err := decodeKubeObject(value, &container)
+		if err != nil {
+			return nil, err
+		}
+		if container.Name == "" {
+			return nil, fmt.Errorf("injected container without a name")
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+func (p *Project) injectContainers() error {
+	inject := p.projectConfig.Inject
+	if inject == nil {
+		return nil
+	}
+	patterns := inject.Resources
+	if len(patterns) == 0 {
+		patterns = []string{"deployment/*"}
+	}
+	filter, err := parseResourceFilter(strings.Join(patterns, ","))
+	if err != nil {
+		return fmt.Errorf("inject: %s", err.Error())
+	}
+	initContainers, err := decodeContainers(inject.InitContainers)
+	if err != nil {
+		return fmt.Errorf("inject: %s", err.Error())
+	}
+	sidecars, err := decodeContainers(inject.Sidecars)
+	if err != nil {
+		return fmt.Errorf("inject: %s", err.Error())
+	}
+	volumes := []v1.Volume{}
+	for _, value := range inject.Volumes {
+		volume := v1.Volume{}
+		err = decodeKubeObject(value, &volume)
+		if err != nil {
+			return fmt.Errorf("inject: %s", err.Error())
+		}
+		volumes = append(volumes, volume)
+	}
+	for _, asset := range p.assets() {
+		podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+		if podSpec == nil || !filter.matches(asset) {
+			continue
+		}
+		// Manifests that already define a container or volume keep theirs
+		podSpec.InitContainers = mergeContainers(podSpec.InitContainers, initContainers, true)
+		podSpec.Containers = mergeContainers(podSpec.Containers, sidecars, false)
+		for _, volume := range volumes {
+			if !hasVolume(podSpec.Volumes, volume.Name) {
+				podSpec.Volumes = append(podSpec.Volumes, *volume.DeepCopy())
+			}
+		}
+	}
+	return nil
+}
+
+// mergeContainers adds the injected containers missing from containers.
+// Injected init containers run first, so a secrets agent is done before the
+// workload's own init containers start; sidecars go last, so the main
+// container stays the default one for kubectl logs and exec.
+func mergeContainers(containers, injected []v1.Container, first bool) []v1.Container {
+	existing := make(map[string]bool)
+	for _, container := range containers {
+		existing[container.Name] = true
+	}
+	added := []v1.Container{}
+	for _, container := range injected {
+		if !existing[container.Name] {
+			added = append(added, *container.DeepCopy())
+		}
+	}
+	if first {
+		return append(added, containers...)
+	}
+	return append(containers, added...)
+}
+
+func hasVolume(volumes []v1.Volume, name string) bool {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
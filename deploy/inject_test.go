@@ -0,0 +1,56 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInjectContainers(t *testing.T) {
+	req := require.New(t)
+	inject := &InjectConfig{}
+	req.NoError(yaml.Unmarshal([]byte(`
+init_containers:
+- name: vault-agent
+  image: vault:0.9
+  volumeMounts:
+  - name: secrets
+    mountPath: /secrets
+sidecars:
+- name: fluentd
+  image: fluentd:v1.0
+volumes:
+- name: secrets
+  emptyDir: {}
+`), inject))
+	web := &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "web"}}
+	web.Spec.Template.Spec.Containers = []v1.Container{{Name: "web"}}
+	worker := &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "worker"}}
+	worker.Spec.Template.Spec.Containers = []v1.Container{{Name: "worker"}, {Name: "fluentd", Image: "fluentd:custom"}}
+	job := &v1batch.Job{ObjectMeta: apiv1.ObjectMeta{Name: "migrate"}}
+	p := &Project{
+		projectConfig: &ProjectConfig{Inject: inject},
+		jobs:          []*Asset{{Kind: "job", ResourceData: job}},
+		services: []*Asset{
+			{Kind: "deployment", ResourceData: web},
+			{Kind: "deployment", ResourceData: worker},
+		},
+	}
+	req.NoError(p.injectContainers())
+	podSpec := web.Spec.Template.Spec
+	req.Equal("vault-agent", podSpec.InitContainers[0].Name)
+	req.Equal("/secrets", podSpec.InitContainers[0].VolumeMounts[0].MountPath)
+	req.Equal([]string{"web", "fluentd"}, []string{podSpec.Containers[0].Name, podSpec.Containers[1].Name})
+	req.NotNil(podSpec.Volumes[0].EmptyDir)
+	req.Len(worker.Spec.Template.Spec.Containers, 2)
+	req.Equal("fluentd:custom", worker.Spec.Template.Spec.Containers[1].Image)
+	req.Empty(job.Spec.Template.Spec.Containers)
+
+	p.projectConfig.Inject = &InjectConfig{Sidecars: []interface{}{map[interface{}]interface{}{"name": "x", "volume_mounts": nil}}}
+	req.Error(p.injectContainers())
+}
@@ -1,4 +1,4 @@
-package main
+package deploy
 
 import (
 	"os"
@@ -23,13 +23,13 @@ func TestInteg(t *testing.T) {
 	req.NotNil(project)
 	err = project.Up()
 	req.NoError(err)
-	ok, err := checkResourceExist(clientset, "deployment", "consul", "anduin")
+	ok, err := checkResourceExist(clientset, nil, "deployment", "consul", "anduin")
 	req.NoError(err)
 	req.True(ok)
-	ok, err = checkResourceExist(clientset, "service", "consul", "anduin")
+	ok, err = checkResourceExist(clientset, nil, "service", "consul", "anduin")
 	req.NoError(err)
 	req.True(ok)
-	ok, err = checkResourceExist(clientset, "job", "init", "anduin")
+	ok, err = checkResourceExist(clientset, nil, "job", "init", "anduin")
 	req.NoError(err)
 	req.True(ok)
 
@@ -37,13 +37,13 @@ func TestInteg(t *testing.T) {
 
 	err = project.Down()
 	req.NoError(err)
-	ok, err = checkResourceExist(clientset, "deployment", "consul", "anduin")
+	ok, err = checkResourceExist(clientset, nil, "deployment", "consul", "anduin")
 	req.NoError(err)
 	req.False(ok)
-	ok, err = checkResourceExist(clientset, "service", "consul", "anduin")
+	ok, err = checkResourceExist(clientset, nil, "service", "consul", "anduin")
 	req.NoError(err)
 	req.False(ok)
-	ok, err = checkResourceExist(clientset, "job", "init", "anduin")
+	ok, err = checkResourceExist(clientset, nil, "job", "init", "anduin")
 	req.NoError(err)
 	req.False(ok)
 }
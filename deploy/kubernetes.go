@@ -0,0 +1,1012 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	app "k8s.io/api/apps/v1beta1"
+	autoscaling "k8s.io/api/autoscaling/v1"
+	v1batch "k8s.io/api/batch/v1"
+	v1beta1batch "k8s.io/api/batch/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	rbac "k8s.io/api/rbac/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubeClientConfig loads -kubeconfig when given, otherwise merges every file
+// in $KUBECONFIG the way kubectl does, falling back to ~/.kube/config
+func kubeClientConfig(config *appConfig) clientcmd.ClientConfig {
+	clientConfigLoader := clientcmd.NewDefaultClientConfigLoadingRules()
+	clientConfigLoader.ExplicitPath = config.configFile
+	configOverrides := &clientcmd.ConfigOverrides{}
+	if config.context != "" {
+		configOverrides.CurrentContext = config.context
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientConfigLoader, configOverrides)
+}
+
+func loadKubernetesClient(config *appConfig) (*kubernetes.Clientset, error) {
+	err := resolveContext(config)
+	if err != nil {
+		return nil, err
+	}
+	kubeConfig, err := kubeClientConfig(config).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	kubeConfig.QPS = float32(config.qps)
+	kubeConfig.Burst = config.burst
+	kubeConfig.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		return newAdaptiveThrottle(apiServerTransport(rt))
+	}
+	return kubernetes.NewForConfig(kubeConfig)
+}
+
+// resolveContext uses -context as is when the kubeconfig has it. Otherwise it
+// asks which of the contexts containing it was meant, even when only one does:
+// prod silently becoming preprod is the accident it is here to prevent.
+func resolveContext(config *appConfig) error {
+	if config.context == "" {
+		return nil
+	}
+	rawConfig, err := kubeClientConfig(config).RawConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := rawConfig.Contexts[config.context]; ok {
+		return nil
+	}
+	matches := []string{}
+	for name := range rawConfig.Contexts {
+		if strings.Contains(name, config.context) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	if len(matches) == 0 {
+		return validationError(fmt.Errorf("context %q not found in kubeconfig", config.context))
+	}
+	context, err := pickOne(config, fmt.Sprintf("Context %q is not in kubeconfig, pick one", config.context), matches)
+	if err != nil {
+		return validationError(err)
+	}
+	config.context = context
+	ErrPrintf(ColorBlue, "Using context %q\n", config.context)
+	return nil
+}
+
+// contextName returns -context, or the current context of the kubeconfig
+func contextName(config *appConfig) string {
+	if config.context != "" {
+		return config.context
+	}
+	rawConfig, err := kubeClientConfig(config).RawConfig()
+	if err != nil {
+		return ""
+	}
+	return rawConfig.CurrentContext
+}
+
+// contextPatterns splits a comma separated list of context patterns
+func contextPatterns(value string) []string {
+	patterns := []string{}
+	for _, pattern := range strings.Split(value, ",") {
+		if strings.TrimSpace(pattern) != "" {
+			patterns = append(patterns, strings.TrimSpace(pattern))
+		}
+	}
+	return patterns
+}
+
+// matchContexts tells if context matches one of the patterns, settings
+// scoped to contexts apply to all of them when no patterns are given.
+// Patterns match the whole name, prod doesn't match preprod.
+func matchContexts(patterns []string, context string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := regexp.MatchString("^(?:"+pattern+")$", context)
+		if err != nil {
+			return false, fmt.Errorf("invalid context pattern %q: %s", pattern, err.Error())
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return len(patterns) == 0, nil
+}
+
+// contextNamespace returns the namespace set on the current kubeconfig
+// context, or an empty string when it has none
+func contextNamespace(config *appConfig) string {
+	rawConfig, err := kubeClientConfig(config).RawConfig()
+	if err != nil {
+		return ""
+	}
+	contextName := rawConfig.CurrentContext
+	if config.context != "" {
+		contextName = config.context
+	}
+	context, ok := rawConfig.Contexts[contextName]
+	if !ok {
+		return ""
+	}
+	return context.Namespace
+}
+
+// commandNamespace is the namespace of commands working without a project:
+// -namespace, else the one of the kubeconfig context, else "default"
+func commandNamespace(config *appConfig) string {
+	if config.namespace != "" {
+		return config.namespace
+	}
+	if namespace := contextNamespace(config); namespace != "" {
+		return namespace
+	}
+	return "default"
+}
+
+func describeCluster(config *appConfig) string {
+	clientConfig := kubeClientConfig(config)
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return "unknown"
+	}
+	context := rawConfig.CurrentContext
+	if config.context != "" {
+		context = config.context
+	}
+	kubeConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return context
+	}
+	return context + " (" + kubeConfig.Host + ")"
+}
+
+type KubernetesResource struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+func loadKubernetesResource(data []byte) (*KubernetesResource, error) {
+	r := &KubernetesResource{}
+	err := yaml.Unmarshal(data, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func createNamespace(kubeClient *kubernetes.Clientset, namespace string) error {
+	_, err := kubeClient.Core().Namespaces().Get(namespace, apiv1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !isResourceNotExist(err) {
+		return err
+	}
+	ns := &v1.Namespace{
+		ObjectMeta: apiv1.ObjectMeta{
+			Name: namespace,
+		},
+	}
+	_, err = kubeClient.Core().Namespaces().Create(ns)
+	return err
+}
+
+func deleteNamespace(kubeClient *kubernetes.Clientset, namespace string) error {
+	if namespace == "default" {
+		return nil
+	}
+	_, err := kubeClient.Core().Namespaces().Get(namespace, apiv1.GetOptions{})
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for i := 0; i < 10; i++ {
+		err = kubeClient.Core().Namespaces().Delete(namespace, &apiv1.DeleteOptions{})
+		if err == nil {
+			return nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func checkResourceExist(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, name, namespace string) (bool, error) {
+	resource, err := getResource(kubeClient, plugins, kind, name, namespace)
+	if err != nil {
+		if isResourceNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return resourceExists(resource)
+}
+
+// resourceExists treats finished pods as gone so they can be created again
+func resourceExists(resource interface{}) (bool, error) {
+	pod, ok := resource.(*v1.Pod)
+	if !ok {
+		return true, nil
+	}
+	switch pod.Status.Phase {
+	case v1.PodUnknown:
+		return false, fmt.Errorf("unknown pod status")
+	case v1.PodSucceeded, v1.PodFailed:
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+func getResource(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, name, namespace string) (interface{}, error) {
+	switch kind {
+	case "pod":
+		return kubeClient.Core().Pods(namespace).Get(name, apiv1.GetOptions{})
+	case "deployment":
+		return kubeClient.Extensions().Deployments(namespace).Get(name, apiv1.GetOptions{})
+	case "service":
+		return kubeClient.Core().Services(namespace).Get(name, apiv1.GetOptions{})
+	case "job":
+		return kubeClient.Batch().Jobs(namespace).Get(name, apiv1.GetOptions{})
+	case "persistentvolumeclaim":
+		return kubeClient.Core().PersistentVolumeClaims(namespace).Get(name, apiv1.GetOptions{})
+	case "configmap":
+		return kubeClient.Core().ConfigMaps(namespace).Get(name, apiv1.GetOptions{})
+	case "secret":
+		return kubeClient.Core().Secrets(namespace).Get(name, apiv1.GetOptions{})
+	case "ingress":
+		return kubeClient.Extensions().Ingresses(namespace).Get(name, apiv1.GetOptions{})
+	case "endpoints":
+		return kubeClient.Core().Endpoints(namespace).Get(name, apiv1.GetOptions{})
+	case "daemonset":
+		return kubeClient.Extensions().DaemonSets(namespace).Get(name, apiv1.GetOptions{})
+	case "serviceaccount":
+		return kubeClient.Core().ServiceAccounts(namespace).Get(name, apiv1.GetOptions{})
+	case "role":
+		return kubeClient.RbacV1beta1().Roles(namespace).Get(name, apiv1.GetOptions{})
+	case "clusterrole":
+		return kubeClient.RbacV1beta1().ClusterRoles().Get(name, apiv1.GetOptions{})
+	case "rolebinding":
+		return kubeClient.RbacV1beta1().RoleBindings(namespace).Get(name, apiv1.GetOptions{})
+	case "clusterrolebinding":
+		return kubeClient.RbacV1beta1().ClusterRoleBindings().Get(name, apiv1.GetOptions{})
+	case "statefulset":
+		return kubeClient.AppsV1beta1().StatefulSets(namespace).Get(name, apiv1.GetOptions{})
+	case "cronjob":
+		return kubeClient.BatchV1beta1().CronJobs(namespace).Get(name, apiv1.GetOptions{})
+	case "horizontalpodautoscaler":
+		return kubeClient.Autoscaling().HorizontalPodAutoscalers(namespace).Get(name, apiv1.GetOptions{})
+	case "poddisruptionbudget":
+		return kubeClient.Policy().PodDisruptionBudgets(namespace).Get(name, apiv1.GetOptions{})
+	case "networkpolicy":
+		return kubeClient.Networking().NetworkPolicies(namespace).Get(name, apiv1.GetOptions{})
+	default:
+		plugin, ok := plugins[kind]
+		if ok {
+			return plugin.get(name, namespace)
+		}
+		return nil, UnsupportedResource(kind)
+	}
+}
+
+func listResources(kubeClient *kubernetes.Clientset, kind, namespace, selector string) ([]interface{}, error) {
+	listOptions := apiv1.ListOptions{
+		LabelSelector: selector,
+	}
+	resources := []interface{}{}
+	switch kind {
+	case "pod":
+		list, err := kubeClient.Core().Pods(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "deployment":
+		list, err := kubeClient.Extensions().Deployments(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "service":
+		list, err := kubeClient.Core().Services(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "job":
+		list, err := kubeClient.Batch().Jobs(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "persistentvolumeclaim":
+		list, err := kubeClient.Core().PersistentVolumeClaims(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "configmap":
+		list, err := kubeClient.Core().ConfigMaps(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "secret":
+		list, err := kubeClient.Core().Secrets(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "ingress":
+		list, err := kubeClient.Extensions().Ingresses(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "endpoints":
+		list, err := kubeClient.Core().Endpoints(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "daemonset":
+		list, err := kubeClient.Extensions().DaemonSets(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "serviceaccount":
+		list, err := kubeClient.Core().ServiceAccounts(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "role":
+		list, err := kubeClient.RbacV1beta1().Roles(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "clusterrole":
+		list, err := kubeClient.RbacV1beta1().ClusterRoles().List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "rolebinding":
+		list, err := kubeClient.RbacV1beta1().RoleBindings(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "clusterrolebinding":
+		list, err := kubeClient.RbacV1beta1().ClusterRoleBindings().List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "statefulset":
+		list, err := kubeClient.AppsV1beta1().StatefulSets(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "cronjob":
+		list, err := kubeClient.BatchV1beta1().CronJobs(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "horizontalpodautoscaler":
+		list, err := kubeClient.Autoscaling().HorizontalPodAutoscalers(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "poddisruptionbudget":
+		list, err := kubeClient.Policy().PodDisruptionBudgets(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	case "networkpolicy":
+		list, err := kubeClient.Networking().NetworkPolicies(namespace).List(listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	default:
+		return nil, UnsupportedResource(kind)
+	}
+	return resources, nil
+}
+
+func getResourceVersion(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, name, namespace string) (string, error) {
+	resource, err := getResource(kubeClient, plugins, kind, name, namespace)
+	if err != nil {
+		return "", err
+	}
+	return resource.(Meta).GetResourceVersion(), nil
+}
+
+func createResource(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, name, namespace string, resourceData interface{}) error {
+	var err error
+	retry := 0
+	for {
+		switch kind {
+		case "pod":
+			// Delete if possible
+			deleteOptions := apiv1.NewDeleteOptions(0)
+			kubeClient.Core().Pods(namespace).Delete(name, deleteOptions)
+			_, err = kubeClient.Core().Pods(namespace).Create(resourceData.(*v1.Pod))
+		case "deployment":
+			_, err = kubeClient.Extensions().Deployments(namespace).Create(resourceData.(*v1beta1.Deployment))
+		case "service":
+			_, err = kubeClient.Core().Services(namespace).Create(resourceData.(*v1.Service))
+		case "job":
+			_, err = kubeClient.Batch().Jobs(namespace).Create(resourceData.(*v1batch.Job))
+		case "persistentvolumeclaim":
+			_, err = kubeClient.Core().PersistentVolumeClaims(namespace).Create(resourceData.(*v1.PersistentVolumeClaim))
+		case "configmap":
+			_, err = kubeClient.Core().ConfigMaps(namespace).Create(resourceData.(*v1.ConfigMap))
+		case "secret":
+			_, err = kubeClient.Core().Secrets(namespace).Create(resourceData.(*v1.Secret))
+		case "ingress":
+			_, err = kubeClient.Extensions().Ingresses(namespace).Create(resourceData.(*v1beta1.Ingress))
+		case "endpoints":
+			_, err = kubeClient.Core().Endpoints(namespace).Create(resourceData.(*v1.Endpoints))
+		case "daemonset":
+			_, err = kubeClient.Extensions().DaemonSets(namespace).Create(resourceData.(*v1beta1.DaemonSet))
+		case "serviceaccount":
+			_, err = kubeClient.Core().ServiceAccounts(namespace).Create(resourceData.(*v1.ServiceAccount))
+		case "role":
+			_, err = kubeClient.RbacV1beta1().Roles(namespace).Create(resourceData.(*rbac.Role))
+		case "clusterrole":
+			_, err = kubeClient.RbacV1beta1().ClusterRoles().Create(resourceData.(*rbac.ClusterRole))
+		case "rolebinding":
+			_, err = kubeClient.RbacV1beta1().RoleBindings(namespace).Create(resourceData.(*rbac.RoleBinding))
+		case "clusterrolebinding":
+			_, err = kubeClient.RbacV1beta1().ClusterRoleBindings().Create(resourceData.(*rbac.ClusterRoleBinding))
+		case "statefulset":
+			_, err = kubeClient.AppsV1beta1().StatefulSets(namespace).Create(resourceData.(*app.StatefulSet))
+		case "cronjob":
+			_, err = kubeClient.BatchV1beta1().CronJobs(namespace).Create(resourceData.(*v1beta1batch.CronJob))
+		case "horizontalpodautoscaler":
+			_, err = kubeClient.Autoscaling().HorizontalPodAutoscalers(namespace).Create(resourceData.(*autoscaling.HorizontalPodAutoscaler))
+		default:
+			plugin, ok := plugins[kind]
+			if ok {
+				return plugin.apply("create", name, namespace, resourceData)
+			}
+			return UnsupportedResource(kind)
+		}
+		if err == nil {
+			return nil
+		}
+		statusErr, ok := err.(*errors.StatusError)
+		if !ok {
+			return err
+		}
+		message := statusErr.Status().Message
+		if strings.Contains(message, "unable to create new content in namespace") && strings.Contains(message, "being terminated") {
+			retry++
+			if retry > 10 {
+				return err
+			}
+			time.Sleep(5 * time.Second)
+			continue
+		} else if strings.Contains(message, "namespaces") && strings.Contains(message, "not found") {
+			err = createNamespace(kubeClient, namespace)
+			if err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+
+	}
+	return err
+}
+
+func destroyResource(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, name, namespace string) error {
+	var err error
+	deleteOptions := apiv1.NewDeleteOptions(0)
+	switch kind {
+	case "pod":
+		return destroyPod(kubeClient, name, namespace)
+	case "deployment":
+		return destroyDeployment(kubeClient, name, namespace)
+	case "service":
+		err = kubeClient.Core().Services(namespace).Delete(name, deleteOptions)
+	case "job":
+		return destroyJob(kubeClient, name, namespace)
+	case "persistentvolumeclaim":
+		err = kubeClient.Core().PersistentVolumeClaims(namespace).Delete(name, deleteOptions)
+	case "configmap":
+		err = kubeClient.Core().ConfigMaps(namespace).Delete(name, deleteOptions)
+	case "secret":
+		err = kubeClient.Core().Secrets(namespace).Delete(name, deleteOptions)
+	case "ingress":
+		err = kubeClient.Extensions().Ingresses(namespace).Delete(name, deleteOptions)
+	case "endpoints":
+		err = kubeClient.Core().Endpoints(namespace).Delete(name, deleteOptions)
+	case "daemonset":
+		err = destroyDaemonSet(kubeClient, name, namespace)
+	case "serviceaccount":
+		err = kubeClient.Core().ServiceAccounts(namespace).Delete(name, deleteOptions)
+	case "role":
+		err = kubeClient.RbacV1beta1().Roles(namespace).Delete(name, deleteOptions)
+	case "clusterrole":
+		err = kubeClient.RbacV1beta1().ClusterRoles().Delete(name, deleteOptions)
+	case "rolebinding":
+		err = kubeClient.RbacV1beta1().RoleBindings(namespace).Delete(name, deleteOptions)
+	case "clusterrolebinding":
+		err = kubeClient.RbacV1beta1().ClusterRoleBindings().Delete(name, deleteOptions)
+	case "statefulset":
+		err = destroyStatefulSet(kubeClient, name, namespace)
+	case "cronjob":
+		// Take the jobs it started along
+		propagation := apiv1.DeletePropagationBackground
+		err = kubeClient.BatchV1beta1().CronJobs(namespace).Delete(name, &apiv1.DeleteOptions{PropagationPolicy: &propagation})
+	case "horizontalpodautoscaler":
+		err = kubeClient.Autoscaling().HorizontalPodAutoscalers(namespace).Delete(name, deleteOptions)
+	case "poddisruptionbudget":
+		err = kubeClient.Policy().PodDisruptionBudgets(namespace).Delete(name, deleteOptions)
+	case "networkpolicy":
+		err = kubeClient.Networking().NetworkPolicies(namespace).Delete(name, deleteOptions)
+	default:
+		plugin, ok := plugins[kind]
+		if ok {
+			return plugin.apply("delete", name, namespace, nil)
+		}
+		return UnsupportedResource(kind)
+	}
+	statusErr, ok := err.(*errors.StatusError)
+	if !ok {
+		return err
+	}
+	if statusErr.Status().Reason == apiv1.StatusReasonNotFound {
+		return nil
+	}
+	return err
+}
+
+func updateResource(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, name, namespace string, resourceData interface{}) error {
+	var err error
+	switch kind {
+	case "pod":
+		_, err = kubeClient.Core().Pods(namespace).Update(resourceData.(*v1.Pod))
+	case "deployment":
+		_, err = kubeClient.Extensions().Deployments(namespace).Update(resourceData.(*v1beta1.Deployment))
+	case "service":
+		return updateService(kubeClient, namespace, resourceData.(*v1.Service))
+	case "job":
+		return nil
+	case "persistentvolumeclaim":
+		return nil
+	case "configmap":
+		_, err = kubeClient.Core().ConfigMaps(namespace).Update(resourceData.(*v1.ConfigMap))
+	case "secret":
+		_, err = kubeClient.Core().Secrets(namespace).Update(resourceData.(*v1.Secret))
+	case "ingress":
+		_, err = kubeClient.Extensions().Ingresses(namespace).Update(resourceData.(*v1beta1.Ingress))
+	case "endpoints":
+		_, err = kubeClient.Core().Endpoints(namespace).Update(resourceData.(*v1.Endpoints))
+	case "daemonset":
+		_, err = kubeClient.Extensions().DaemonSets(namespace).Update(resourceData.(*v1beta1.DaemonSet))
+	case "serviceaccount":
+		_, err = kubeClient.Core().ServiceAccounts(namespace).Update(resourceData.(*v1.ServiceAccount))
+	case "role":
+		_, err = kubeClient.RbacV1beta1().Roles(namespace).Update(resourceData.(*rbac.Role))
+	case "clusterrole":
+		_, err = kubeClient.RbacV1beta1().ClusterRoles().Update(resourceData.(*rbac.ClusterRole))
+	case "rolebinding":
+		_, err = kubeClient.RbacV1beta1().RoleBindings(namespace).Update(resourceData.(*rbac.RoleBinding))
+	case "clusterrolebinding":
+		_, err = kubeClient.RbacV1beta1().ClusterRoleBindings().Update(resourceData.(*rbac.ClusterRoleBinding))
+	case "statefulset":
+		_, err = kubeClient.AppsV1beta1().StatefulSets(namespace).Update(resourceData.(*app.StatefulSet))
+	case "cronjob":
+		_, err = kubeClient.BatchV1beta1().CronJobs(namespace).Update(resourceData.(*v1beta1batch.CronJob))
+	case "horizontalpodautoscaler":
+		_, err = kubeClient.Autoscaling().HorizontalPodAutoscalers(namespace).Update(resourceData.(*autoscaling.HorizontalPodAutoscaler))
+	default:
+		plugin, ok := plugins[kind]
+		if ok {
+			return plugin.apply("update", name, namespace, resourceData)
+		}
+		return UnsupportedResource(kind)
+	}
+	return err
+}
+
+func updateService(kubeClient *kubernetes.Clientset, namespace string, service *v1.Service) error {
+	// Keep the allocated cluster IP and node ports unless the manifest asks for specific ones
+	live, err := kubeClient.Core().Services(namespace).Get(service.Name, apiv1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if service.Spec.ClusterIP == "" {
+		service.Spec.ClusterIP = live.Spec.ClusterIP
+	}
+	nodePorts := make(map[int32]int32)
+	for _, port := range live.Spec.Ports {
+		nodePorts[port.Port] = port.NodePort
+	}
+	for i, port := range service.Spec.Ports {
+		if port.NodePort == 0 {
+			service.Spec.Ports[i].NodePort = nodePorts[port.Port]
+		}
+	}
+	_, err = kubeClient.Core().Services(namespace).Update(service)
+	return err
+}
+
+func waitForResourceDeletion(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, name, namespace string, timeout time.Duration) error {
+	defer observeWait(kind, time.Now())
+	deadline := time.Now().Add(timeout)
+	for {
+		resource, err := getResource(kubeClient, plugins, kind, name, namespace)
+		if err != nil {
+			if isResourceNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if time.Now().After(deadline) {
+			return newTypedError(ErrorTypeTimeout, "timeout while waiting for %s %q to be deleted", kind, name)
+		}
+		watchUntil(kubeClient, kind, namespace, apiv1.ListOptions{
+			FieldSelector:   "metadata.name=" + name,
+			ResourceVersion: resource.(Meta).GetResourceVersion(),
+		}, deadline, func(event watch.Event) bool {
+			return event.Type == watch.Deleted
+		})
+	}
+}
+
+func waitForJobDeletion(kubeClient *kubernetes.Clientset, name, namespace string, timeout time.Duration) error {
+	err := waitForResourceDeletion(kubeClient, nil, "job", name, namespace, timeout)
+	if err != nil {
+		return err
+	}
+	return waitForJobPodsDeletion(kubeClient, name, namespace, timeout)
+}
+
+func waitForJobPodsDeletion(kubeClient *kubernetes.Clientset, name, namespace string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pods, err := kubeClient.Core().Pods(namespace).List(apiv1.ListOptions{
+			LabelSelector: "job-name=" + name,
+		})
+		if err != nil {
+			return err
+		}
+		if len(pods.Items) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return newTypedError(ErrorTypeTimeout, "timeout while waiting for pods of job %q to be deleted", name)
+		}
+		remaining := make(map[string]bool)
+		for _, pod := range pods.Items {
+			remaining[pod.Name] = true
+		}
+		watchUntil(kubeClient, "pod", namespace, apiv1.ListOptions{
+			LabelSelector:   "job-name=" + name,
+			ResourceVersion: pods.ResourceVersion,
+		}, deadline, func(event watch.Event) bool {
+			if event.Type == watch.Deleted {
+				delete(remaining, event.Object.(Meta).GetName())
+			}
+			return len(remaining) == 0
+		})
+	}
+}
+
+const jobRunLabel = "imladris-job"
+
+func uniqueJobName(baseName string, manifest []byte, now time.Time) string {
+	hash := sha256.New()
+	hash.Write(manifest)
+	hash.Write([]byte(strconv.FormatInt(now.UnixNano(), 10)))
+	return baseName + "-" + hex.EncodeToString(hash.Sum(nil))[:8]
+}
+
+func pruneJobRuns(kubeClient *kubernetes.Clientset, out *printer, baseName, namespace string, keep int) error {
+	jobs, err := kubeClient.Batch().Jobs(namespace).List(apiv1.ListOptions{
+		LabelSelector: jobRunLabel + "=" + baseName,
+	})
+	if err != nil {
+		return err
+	}
+	runs := jobs.Items
+	if len(runs) <= keep {
+		return nil
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[j].CreationTimestamp.Before(&runs[i].CreationTimestamp)
+	})
+	for _, run := range runs[keep:] {
+		out.Printf(ColorYellow, "Pruning job run %q\n", run.Name)
+		err = destroyJob(kubeClient, run.Name, namespace)
+		if err != nil && !isResourceNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func getPodSpec(kind string, resourceData interface{}) *v1.PodSpec {
+	switch kind {
+	case "pod":
+		return &resourceData.(*v1.Pod).Spec
+	case "deployment":
+		return &resourceData.(*v1beta1.Deployment).Spec.Template.Spec
+	case "job":
+		return &resourceData.(*v1batch.Job).Spec.Template.Spec
+	case "daemonset":
+		return &resourceData.(*v1beta1.DaemonSet).Spec.Template.Spec
+	case "statefulset":
+		return &resourceData.(*app.StatefulSet).Spec.Template.Spec
+	case "cronjob":
+		return &resourceData.(*v1beta1batch.CronJob).Spec.JobTemplate.Spec.Template.Spec
+	default:
+		return nil
+	}
+}
+
+func getResourceImages(kind string, resourceData interface{}) ([]string, error) {
+	var containers []v1.Container
+	switch kind {
+	case "pod":
+		containers = resourceData.(*v1.Pod).Spec.Containers
+	case "deployment":
+		containers = resourceData.(*v1beta1.Deployment).Spec.Template.Spec.Containers
+	case "job":
+		containers = resourceData.(*v1batch.Job).Spec.Template.Spec.Containers
+	case "daemonset":
+		containers = resourceData.(*v1beta1.DaemonSet).Spec.Template.Spec.Containers
+	case "statefulset":
+		containers = resourceData.(*app.StatefulSet).Spec.Template.Spec.Containers
+	case "cronjob":
+		containers = resourceData.(*v1beta1batch.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers
+	case "service", "persistentvolumeclaim", "configmap", "secret", "ingress", "endpoints", "serviceaccount", "role", "clusterrole", "rolebinding", "clusterrolebinding", "horizontalpodautoscaler":
+		return nil, nil
+	default:
+		if _, ok := resourceData.(*unstructured.Unstructured); ok {
+			// Plugin kinds, their images are theirs to know
+			return nil, nil
+		}
+		return nil, UnsupportedResource(kind)
+	}
+	images := []string{}
+	for _, container := range containers {
+		images = append(images, container.Image)
+	}
+	return images, nil
+}
+
+func destroyPod(kubeClient *kubernetes.Clientset, name, namespace string) error {
+	deleteOptions := apiv1.NewDeleteOptions(0)
+	err := kubeClient.Core().Pods(namespace).Delete(name, deleteOptions)
+	if err == nil {
+		return nil
+	}
+	statusErr, ok := err.(*errors.StatusError)
+	if !ok {
+		return err
+	}
+	if statusErr.Status().Code == 404 {
+		return nil
+	}
+	return err
+}
+
+func destroyDeployment(kubeClient *kubernetes.Clientset, name, namespace string) error {
+	deleteOptions := apiv1.NewDeleteOptions(0)
+	err := kubeClient.Extensions().Deployments(namespace).Delete(name, deleteOptions)
+	if err != nil {
+		return err
+	}
+	listOptions := apiv1.ListOptions{
+		LabelSelector: "name=" + name,
+	}
+	err = kubeClient.Extensions().ReplicaSets(namespace).DeleteCollection(deleteOptions, listOptions)
+	if err != nil {
+		return err
+	}
+	return kubeClient.Core().Pods(namespace).DeleteCollection(deleteOptions, listOptions)
+}
+
+func destroyDaemonSet(kubeClient *kubernetes.Clientset, name, namespace string) error {
+	deleteOptions := apiv1.NewDeleteOptions(0)
+	err := kubeClient.Extensions().DaemonSets(namespace).Delete(name, deleteOptions)
+	if err != nil {
+		return err
+	}
+	listOptions := apiv1.ListOptions{
+		LabelSelector: "name=" + name,
+	}
+	err = kubeClient.Core().Pods(namespace).DeleteCollection(deleteOptions, listOptions)
+	if err != nil {
+		return err
+	}
+	return kubeClient.Core().Pods(namespace).DeleteCollection(deleteOptions, listOptions)
+}
+
+func destroyStatefulSet(kubeClient *kubernetes.Clientset, name, namespace string) error {
+	deleteOptions := apiv1.NewDeleteOptions(0)
+	err := kubeClient.AppsV1beta1().StatefulSets(namespace).Delete(name, deleteOptions)
+	if err != nil {
+		return err
+	}
+	listOptions := apiv1.ListOptions{
+		LabelSelector: "name=" + name,
+	}
+	err = kubeClient.Core().Pods(namespace).DeleteCollection(deleteOptions, listOptions)
+	if err != nil {
+		return err
+	}
+	return kubeClient.Core().Pods(namespace).DeleteCollection(deleteOptions, listOptions)
+}
+
+func destroyJob(kubeClient *kubernetes.Clientset, name, namespace string) error {
+	deleteOptions := apiv1.NewDeleteOptions(0)
+	err := kubeClient.Batch().Jobs(namespace).Delete(name, deleteOptions)
+	if err != nil {
+		return err
+	}
+	pods, err := kubeClient.Core().Pods(namespace).List(apiv1.ListOptions{
+		LabelSelector: "job-name=" + name,
+	})
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		err = kubeClient.Core().Pods(namespace).Delete(pod.Name, deleteOptions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getLogFromPod(kubeClient *kubernetes.Clientset, namespace, podName string, follow bool) (io.ReadCloser, error) {
+	var stream io.ReadCloser
+	var err error
+	for {
+		stream, err = kubeClient.Core().Pods(namespace).GetLogs(podName, &v1.PodLogOptions{
+			Follow: follow,
+		}).Stream()
+		if err == nil {
+			break
+		}
+		if strings.Contains(err.Error(), "ContainerCreating") {
+			time.Sleep(time.Second)
+		} else {
+			return nil, err
+		}
+	}
+	return stream, nil
+}
+
+func getEvents(kubeClient *kubernetes.Clientset, namespace, podName string) ([]v1.Event, error) {
+	events, err := kubeClient.Core().Events(namespace).List(apiv1.ListOptions{
+		FieldSelector: "involvedObject.name=" + podName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events.Items, err
+}
+
+func getLastEvent(kubeClient *kubernetes.Clientset, namespace, podName string) (*v1.Event, error) {
+	events, err := getEvents(kubeClient, namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no event found")
+	}
+	return &events[len(events)-1], nil
+}
+
+func isResourceNotExist(err error) bool {
+	switch err := err.(type) {
+	case *errors.StatusError:
+		if err.Status().Code == 404 {
+			return true
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func isResourceConflict(err error) bool {
+	switch err := err.(type) {
+	case *errors.StatusError:
+		if err.Status().Code == 409 {
+			return true
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func isImmutableFieldChange(err error) bool {
+	statusErr, ok := err.(*errors.StatusError)
+	if !ok {
+		return false
+	}
+	if statusErr.Status().Reason != apiv1.StatusReasonInvalid {
+		return false
+	}
+	message := statusErr.Status().Message
+	return strings.Contains(message, "field is immutable") || strings.Contains(message, "may not change once set")
+}
@@ -0,0 +1,385 @@
+package deploy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// fakeCluster is an api server keeping objects in memory by url path, enough
+// for the typed clients to get, list, create, update, delete and watch. Every
+// request is logged as "METHOD path" for assertions, watches as "WATCH path".
+// Deleted objects with finalizers stay terminating until an update removes
+// the finalizers.
+type fakeCluster struct {
+	lock     sync.Mutex
+	objects  map[string]map[string]interface{}
+	version  int
+	requests []string
+	server   *httptest.Server
+	// reject lets a test fail requests, a non nil status is answered as is
+	reject func(method, path string) *fakeStatus
+	// ready rolls deployments out as soon as they are stored, in place of
+	// the controller
+	ready bool
+}
+
+type fakeStatus struct {
+	code    int
+	reason  string
+	message string
+}
+
+func newFakeCluster(t *testing.T) (*fakeCluster, *kubernetes.Clientset) {
+	cluster := &fakeCluster{objects: make(map[string]map[string]interface{})}
+	cluster.server = httptest.NewServer(http.HandlerFunc(cluster.serve))
+	t.Cleanup(cluster.server.Close)
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: cluster.server.URL, QPS: 1000, Burst: 1000})
+	require.Nil(t, err)
+	return cluster, kubeClient
+}
+
+// serveDiscovery answers the version and api discovery of a 1.8 cluster
+// serving the core group and apps/v1beta1, extensions/v1beta1
+func (c *fakeCluster) serveDiscovery() {
+	c.add("/version", `{"major":"1","minor":"8"}`)
+	c.add("/api", `{"kind":"APIVersions","versions":["v1"]}`)
+	c.add("/apis", `{"kind":"APIGroupList","groups":[`+
+		`{"name":"apps","versions":[{"groupVersion":"apps/v1beta1","version":"v1beta1"}]},`+
+		`{"name":"extensions","versions":[{"groupVersion":"extensions/v1beta1","version":"v1beta1"}]}]}`)
+}
+
+// isObjectPath tells objects from collections: /api/v1/namespaces/a is an
+// object and /api/v1/namespaces/a/pods a collection, /apis paths have one
+// more segment for the group
+func isObjectPath(path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if segments[0] == "apis" {
+		return len(segments)%2 == 1
+	}
+	return len(segments)%2 == 0
+}
+
+// add stores an object given as json at path
+func (c *fakeCluster) add(path, object string) {
+	decoded := make(map[string]interface{})
+	err := json.Unmarshal([]byte(object), &decoded)
+	if err != nil {
+		panic(err)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.store(path, decoded)
+}
+
+func (c *fakeCluster) get(path string) map[string]interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.objects[path]
+}
+
+func (c *fakeCluster) paths(prefix string) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	paths := []string{}
+	for path := range c.objects {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (c *fakeCluster) requested(method, prefix string) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	requests := []string{}
+	for _, request := range c.requests {
+		if strings.HasPrefix(request, method+" "+prefix) {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+func (c *fakeCluster) store(path string, object map[string]interface{}) {
+	c.version++
+	metadata, _ := object["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+		object["metadata"] = metadata
+	}
+	metadata["resourceVersion"] = fmt.Sprint(c.version)
+	if metadata["creationTimestamp"] == nil {
+		metadata["creationTimestamp"] = "2017-01-01T00:00:00Z"
+	}
+	if c.ready && strings.Contains(path, "/deployments/") {
+		replicas := 1.0
+		if spec, ok := object["spec"].(map[string]interface{}); ok && spec["replicas"] != nil {
+			replicas = spec["replicas"].(float64)
+		}
+		object["status"] = map[string]interface{}{"updatedReplicas": replicas, "availableReplicas": replicas}
+	}
+	c.objects[path] = object
+}
+
+func (c *fakeCluster) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" && r.URL.Query().Get("watch") == "true" {
+		c.watch(w, r)
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	path := r.URL.Path
+	c.requests = append(c.requests, r.Method+" "+path)
+	w.Header().Set("Content-Type", "application/json")
+	if c.reject != nil {
+		if status := c.reject(r.Method, path); status != nil {
+			writeFakeStatus(w, status)
+			return
+		}
+	}
+	notFound := &fakeStatus{http.StatusNotFound, "NotFound", path + " not found"}
+	var body map[string]interface{}
+	if r.Method == "POST" || r.Method == "PUT" {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = make(map[string]interface{})
+		if err := json.Unmarshal(data, &body); err != nil {
+			writeFakeStatus(w, &fakeStatus{http.StatusBadRequest, "BadRequest", err.Error()})
+			return
+		}
+	}
+	switch {
+	case r.Method == "GET" && (isObjectPath(path) || c.objects[path] != nil):
+		object, ok := c.objects[path]
+		if !ok {
+			writeFakeStatus(w, notFound)
+			return
+		}
+		json.NewEncoder(w).Encode(object)
+	case r.Method == "GET":
+		items := []interface{}{}
+		for _, objectPath := range sortedObjectPaths(c.objects) {
+			if strings.HasPrefix(objectPath, path+"/") && !strings.Contains(strings.TrimPrefix(objectPath, path+"/"), "/") &&
+				matchesFakeSelector(c.objects[objectPath], r.URL.Query().Get("labelSelector")) {
+				items = append(items, c.objects[objectPath])
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"metadata": map[string]interface{}{}, "items": items})
+	case r.Method == "POST":
+		metadata, _ := body["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		if generateName, _ := metadata["generateName"].(string); name == "" && generateName != "" {
+			name = fmt.Sprintf("%s%d", generateName, c.version+1)
+			metadata["name"] = name
+		}
+		objectPath := path + "/" + name
+		if _, ok := c.objects[objectPath]; ok {
+			writeFakeStatus(w, &fakeStatus{http.StatusConflict, "AlreadyExists", objectPath + " already exists"})
+			return
+		}
+		c.store(objectPath, body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body)
+	case r.Method == "PUT":
+		objectPath := strings.TrimSuffix(strings.TrimSuffix(path, "/status"), "/scale")
+		current, ok := c.objects[objectPath]
+		if !ok {
+			writeFakeStatus(w, notFound)
+			return
+		}
+		version, _ := body["metadata"].(map[string]interface{})["resourceVersion"].(string)
+		if version != "" && version != current["metadata"].(map[string]interface{})["resourceVersion"] {
+			writeFakeStatus(w, &fakeStatus{http.StatusConflict, "Conflict", "the object has been modified; please apply your changes to the latest version and try again"})
+			return
+		}
+		c.store(objectPath, body)
+		// The last finalizer gone, a terminating object goes
+		if metadata := body["metadata"].(map[string]interface{}); metadata["deletionTimestamp"] != nil && len(fakeFinalizers(body)) == 0 {
+			delete(c.objects, objectPath)
+		}
+		json.NewEncoder(w).Encode(body)
+	case r.Method == "DELETE" && isObjectPath(path):
+		object, ok := c.objects[path]
+		if !ok {
+			writeFakeStatus(w, notFound)
+			return
+		}
+		if len(fakeFinalizers(object)) > 0 {
+			object["metadata"].(map[string]interface{})["deletionTimestamp"] = "2017-01-01T00:00:00Z"
+			c.store(path, object)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Success"}`))
+			return
+		}
+		delete(c.objects, path)
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Success"}`))
+	case r.Method == "DELETE":
+		for _, objectPath := range sortedObjectPaths(c.objects) {
+			if strings.HasPrefix(objectPath, path+"/") && matchesFakeSelector(c.objects[objectPath], r.URL.Query().Get("labelSelector")) {
+				delete(c.objects, objectPath)
+			}
+		}
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Success"}`))
+	default:
+		writeFakeStatus(w, &fakeStatus{http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method + " is not supported"})
+	}
+}
+
+// watch streams the objects of a collection as they are when it starts, then
+// their changes. It ends after a second, clients watch again.
+func (c *fakeCluster) watch(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	query := r.URL.Query()
+	c.lock.Lock()
+	c.requests = append(c.requests, "WATCH "+path)
+	if c.reject != nil {
+		if status := c.reject("WATCH", path); status != nil {
+			c.lock.Unlock()
+			writeFakeStatus(w, status)
+			return
+		}
+	}
+	c.lock.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	sent := make(map[string]map[string]interface{})
+	end := time.After(time.Second)
+	for {
+		c.lock.Lock()
+		events := []map[string]interface{}{}
+		for _, objectPath := range sortedObjectPaths(c.objects) {
+			object := c.objects[objectPath]
+			if !strings.HasPrefix(objectPath, path+"/") || strings.Contains(strings.TrimPrefix(objectPath, path+"/"), "/") ||
+				!matchesFakeSelector(object, query.Get("labelSelector")) || !matchesFakeFieldSelector(object, query.Get("fieldSelector")) {
+				continue
+			}
+			switch previous := sent[objectPath]; {
+			case previous == nil:
+				events = append(events, map[string]interface{}{"type": "ADDED", "object": object})
+			case previous["metadata"].(map[string]interface{})["resourceVersion"] != object["metadata"].(map[string]interface{})["resourceVersion"]:
+				events = append(events, map[string]interface{}{"type": "MODIFIED", "object": object})
+			}
+			sent[objectPath] = object
+		}
+		for objectPath, object := range sent {
+			if c.objects[objectPath] == nil {
+				events = append(events, map[string]interface{}{"type": "DELETED", "object": object})
+				delete(sent, objectPath)
+			}
+		}
+		data := []byte{}
+		for _, event := range events {
+			encoded, _ := json.Marshal(event)
+			data = append(data, append(encoded, '\n')...)
+		}
+		c.lock.Unlock()
+		w.Write(data)
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-end:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func sortedObjectPaths(objects map[string]map[string]interface{}) []string {
+	paths := []string{}
+	for path := range objects {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// matchesFakeSelector understands equality selectors like a=b,c=d
+func matchesFakeSelector(object map[string]interface{}, selector string) bool {
+	if selector == "" {
+		return true
+	}
+	labels, _ := object["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+	for _, requirement := range strings.Split(selector, ",") {
+		pieces := strings.SplitN(requirement, "=", 2)
+		if len(pieces) != 2 || labels[pieces[0]] != pieces[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesFakeFieldSelector understands metadata.name=x, the only field
+// selector imladris uses
+func matchesFakeFieldSelector(object map[string]interface{}, selector string) bool {
+	if selector == "" {
+		return true
+	}
+	return "metadata.name="+fmt.Sprint(object["metadata"].(map[string]interface{})["name"]) == selector
+}
+
+func fakeFinalizers(object map[string]interface{}) []interface{} {
+	metadata, _ := object["metadata"].(map[string]interface{})
+	finalizers, _ := metadata["finalizers"].([]interface{})
+	return finalizers
+}
+
+func writeFakeStatus(w http.ResponseWriter, status *fakeStatus) {
+	w.WriteHeader(status.code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     "Failure",
+		"reason":     status.reason,
+		"message":    status.message,
+		"code":       status.code,
+	})
+}
+
+func TestResolveContext(t *testing.T) {
+	req := require.New(t)
+	defer func(reader *bufio.Reader) { stdinReader = reader }(stdinReader)
+	kubeconfig := writeKubeconfig(t, map[string]string{"preprod": "https://preprod.example.com", "prod": "https://prod.example.com", "staging": "https://staging.example.com"})
+	nonInteractive := false
+	resolve := func(context string) (string, error) {
+		config := &appConfig{configFile: kubeconfig, context: context, nonInteractive: nonInteractive}
+		err := resolveContext(config)
+		return config.context, err
+	}
+
+	// An exact name is used as is, never matched against preprod
+	context, err := resolve("prod")
+	req.Nil(err)
+	req.Equal("prod", context)
+
+	// A partial name is confirmed, even when a single context contains it
+	stdinReader = bufio.NewReader(strings.NewReader("1\n2\n"))
+	context, err = resolve("stag")
+	req.Nil(err)
+	req.Equal("staging", context)
+	context, err = resolve("pro")
+	req.Nil(err)
+	req.Equal("prod", context)
+
+	nonInteractive = true
+	_, err = resolve("stag")
+	req.Error(err)
+	req.Equal(ErrorTypeValidation, classifyError(err))
+	req.Contains(err.Error(), "candidates are staging")
+
+	_, err = resolve("dev")
+	req.Error(err)
+	req.Contains(err.Error(), "not found in kubeconfig")
+}
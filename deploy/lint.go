@@ -0,0 +1,227 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// builtinVariablePrefixes are the variables imladris sets itself, they are
+// never reported as unused
+var builtinVariablePrefixes = []string{"app_var_", "build_var_", "import_", "tf_"}
+
+// templateReference is a variable a template reads from its data, with the
+// file:line:col it is read at
+type templateReference struct {
+	name     string
+	location string
+}
+
+// templateReferences lists the variables a manifest reads as .name, $.name or
+// index . "name". Inside range and with the dot is something else, only $
+// still points at the variables there. Partials handed the variables with
+// include or template are walked too.
+func templateReferences(t *template.Template) []templateReference {
+	references := []templateReference{}
+	walked := make(map[string]bool)
+	var walkTree func(tree *parse.Tree)
+	walkPartial := func(name string, data parse.Node, topDot bool) {
+		if !passesVariables(data, topDot) || t.Lookup(name) == nil || walked[name] {
+			return
+		}
+		walked[name] = true
+		walkTree(t.Lookup(name).Tree)
+	}
+	walkTree = func(tree *parse.Tree) {
+		if tree == nil {
+			return
+		}
+		var walk func(node parse.Node, topDot bool)
+		add := func(node parse.Node, name string) {
+			location, _ := tree.ErrorContext(node)
+			references = append(references, templateReference{name: name, location: location})
+		}
+		walk = func(node parse.Node, topDot bool) {
+			switch node := node.(type) {
+			case *parse.ListNode:
+				if node == nil {
+					return
+				}
+				for _, child := range node.Nodes {
+					walk(child, topDot)
+				}
+			case *parse.ActionNode:
+				walk(node.Pipe, topDot)
+			case *parse.IfNode:
+				walk(node.Pipe, topDot)
+				walk(node.List, topDot)
+				walk(node.ElseList, topDot)
+			case *parse.RangeNode:
+				walk(node.Pipe, topDot)
+				walk(node.List, false)
+				walk(node.ElseList, topDot)
+			case *parse.WithNode:
+				walk(node.Pipe, topDot)
+				walk(node.List, false)
+				walk(node.ElseList, topDot)
+			case *parse.TemplateNode:
+				walk(node.Pipe, topDot)
+				if node.Pipe != nil && len(node.Pipe.Cmds) == 1 && len(node.Pipe.Cmds[0].Args) == 1 {
+					walkPartial(node.Name, node.Pipe.Cmds[0].Args[0], topDot)
+				}
+			case *parse.PipeNode:
+				if node == nil {
+					return
+				}
+				for _, command := range node.Cmds {
+					walk(command, topDot)
+				}
+			case *parse.CommandNode:
+				if len(node.Args) == 3 {
+					identifier, isIndex := node.Args[0].(*parse.IdentifierNode)
+					_, isDot := node.Args[1].(*parse.DotNode)
+					key, isString := node.Args[2].(*parse.StringNode)
+					if isIndex && identifier.Ident == "index" && isDot && isString && topDot {
+						add(node, key.Text)
+						return
+					}
+					if isIndex && identifier.Ident == "include" {
+						if name, ok := node.Args[1].(*parse.StringNode); ok {
+							walkPartial(name.Text, node.Args[2], topDot)
+						}
+					}
+				}
+				for _, arg := range node.Args {
+					walk(arg, topDot)
+				}
+			case *parse.FieldNode:
+				if topDot {
+					add(node, node.Ident[0])
+				}
+			case *parse.VariableNode:
+				if len(node.Ident) > 1 && node.Ident[0] == "$" {
+					add(node, node.Ident[1])
+				}
+			case *parse.ChainNode:
+				walk(node.Node, topDot)
+			}
+		}
+		walk(tree.Root, true)
+	}
+	walked[t.Name()] = true
+	walkTree(t.Tree)
+	return references
+}
+
+// passesVariables tells whether a template argument is the variables
+// themselves, . at the top or $
+func passesVariables(node parse.Node, topDot bool) bool {
+	switch node := node.(type) {
+	case *parse.DotNode:
+		return topDot
+	case *parse.VariableNode:
+		return len(node.Ident) == 1 && node.Ident[0] == "$"
+	}
+	return false
+}
+
+// undefinedVariablesError lists every variable a manifest reads but nobody
+// provides, instead of stopping at the first one or rendering it empty
+func undefinedVariablesError(references []templateReference, variables map[string]string) error {
+	missing := []string{}
+	for _, reference := range references {
+		if _, ok := variables[reference.name]; !ok {
+			missing = append(missing, fmt.Sprintf("%q at %s", reference.name, reference.location))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("undefined template variables: %s", strings.Join(missing, ", "))
+}
+
+// templateLint collects the variables of every manifest instead of rendering
+// them, for the template-lint command
+type templateLint struct {
+	references map[string][]string
+}
+
+func (l *templateLint) record(references []templateReference) {
+	for _, reference := range references {
+		l.references[reference.name] = append(l.references[reference.name], reference.location)
+	}
+}
+
+type lintResult struct {
+	name      string
+	status    string
+	locations []string
+}
+
+// results compares the variables used with the ones provided: undefined ones
+// break rendering, unused ones are provided by the project file or flags but
+// read by no manifest
+func (l *templateLint) results(variables map[string]string, imports []*ProjectImport) []*lintResult {
+	results := []*lintResult{}
+	for name, locations := range l.references {
+		result := &lintResult{name: name, status: "provided", locations: locations}
+		if _, ok := variables[name]; !ok {
+			result.status = "undefined"
+			for _, projectImport := range imports {
+				if strings.HasPrefix(name, importVariableName(projectImport.Project, "")) {
+					result.status = "imported"
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	for name := range variables {
+		if _, ok := l.references[name]; ok {
+			continue
+		}
+		builtin := false
+		for _, prefix := range builtinVariablePrefixes {
+			builtin = builtin || strings.HasPrefix(name, prefix)
+		}
+		if !builtin {
+			results = append(results, &lintResult{name: name, status: "unused"})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].name < results[j].name
+	})
+	return results
+}
+
+// Lint reports the variables the manifests use against the ones provided for
+// the current context and flags, and fails when one is undefined
+func (p *Project) Lint() error {
+	variables := p.projectConfig.Variables
+	if p.projectConfig.Envsubst != nil {
+		names := []string{}
+		for name := range p.lint.references {
+			names = append(names, name)
+		}
+		variables = p.envsubstVariables(names)
+	}
+	results := p.lint.results(variables, p.projectConfig.Imports)
+	p.printer.Printf(ColorWhite, "Template variables of %q for context %q:\n", p.projectConfig.Name, contextName(p.config))
+	undefined := 0
+	for _, result := range results {
+		color := ColorGreen
+		switch result.status {
+		case "undefined":
+			color = ColorRed
+			undefined++
+		case "unused", "imported":
+			color = ColorYellow
+		}
+		p.printer.Printf(color, "  %-40s %-10s %s\n", result.name, result.status, strings.Join(result.locations, ", "))
+	}
+	if undefined > 0 {
+		return validationError(fmt.Errorf("%d template variables are undefined", undefined))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package deploy
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateReferences(t *testing.T) {
+	req := require.New(t)
+	tpl, err := template.New("web.yml").Funcs(getFuncMap()).Parse("a: {{ .first }}\n{{ with .second }}{{ .inner }}{{ $.third }}{{ end }}\nb: {{ index . \"fourth\" | printf \"%q\" }}\n")
+	req.NoError(err)
+	names := []string{}
+	for _, reference := range templateReferences(tpl) {
+		names = append(names, reference.name)
+	}
+	req.Equal([]string{"first", "second", "third", "fourth"}, names)
+
+	err = undefinedVariablesError(templateReferences(tpl), map[string]string{"first": "1", "second": "2"})
+	req.Error(err)
+	req.Contains(err.Error(), `"third" at web.yml:2:`)
+	req.Contains(err.Error(), `"fourth" at web.yml:3:`)
+}
+
+func TestTemplateReferencesInPartials(t *testing.T) {
+	req := require.New(t)
+	p := &Project{partials: map[string]string{"probe": "path: {{ .health_path }}", "other": "{{ .inner }}"}}
+	tpl, err := p.parseManifest("web.yml", []byte("{{ include \"probe\" . | indent 8 }}\n{{ template \"probe\" $ }}\n{{ with .config }}{{ include \"other\" . }}{{ end }}\n"))
+	req.NoError(err)
+	names := []string{}
+	for _, reference := range templateReferences(tpl) {
+		names = append(names, reference.name)
+	}
+	req.Equal([]string{"health_path", "config"}, names)
+}
+
+func TestUndefinedVariableFailsRendering(t *testing.T) {
+	req := require.New(t)
+	p := &Project{projectConfig: &ProjectConfig{Variables: map[string]string{"name": "web"}}}
+	_, err := p.renderManifest("web.yml", []byte("name: {{ .name }}\nurl: {{ .database_url }}\n"))
+	req.Error(err)
+	req.Contains(err.Error(), `web.yml:2:8`)
+	req.Contains(err.Error(), `"database_url"`)
+
+	// Optional variables are read with index, which a missing one leaves empty
+	buf, err := p.renderManifest("web.yml", []byte("name: {{ .name }}\n{{ if index . \"optional\" }}extra: {{ .optional }}\n{{ end }}"))
+	req.NoError(err)
+	req.Equal("name: web\n", buf.String())
+}
+
+func TestTemplateLint(t *testing.T) {
+	req := require.New(t)
+	project, err := readProject(nil, "test-assets/lint-tests", &appConfig{templateLint: true})
+	req.NoError(err)
+	statuses := make(map[string]string)
+	for _, result := range project.lint.results(project.projectConfig.Variables, nil) {
+		statuses[result.name] = result.status
+	}
+	req.Equal(map[string]string{
+		"image_tag":    "provided",
+		"replicas":     "undefined",
+		"database_url": "undefined",
+		"debug":        "unused",
+	}, statuses)
+	req.Error(project.Lint())
+}
@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+)
+
+// loadBalancerPresets are the provider annotations for common load balancer
+// setups, selected per service under load_balancers in the project file
+var loadBalancerPresets = map[string]map[string]string{
+	"aws-elb": {},
+	"aws-internal": {
+		"service.beta.kubernetes.io/aws-load-balancer-internal": "0.0.0.0/0",
+	},
+	"aws-nlb": {
+		"service.beta.kubernetes.io/aws-load-balancer-type": "nlb",
+	},
+	"aws-nlb-internal": {
+		"service.beta.kubernetes.io/aws-load-balancer-type":     "nlb",
+		"service.beta.kubernetes.io/aws-load-balancer-internal": "0.0.0.0/0",
+	},
+	"gcp-internal": {
+		"cloud.google.com/load-balancer-type": "Internal",
+	},
+	"azure-internal": {
+		"service.beta.kubernetes.io/azure-load-balancer-internal": "true",
+	},
+}
+
+// applyLoadBalancerPresets turns the selected services into load balancers
+// with their preset annotations. Annotations set in the manifest win.
+func (p *Project) applyLoadBalancerPresets() error {
+	for serviceName, preset := range p.projectConfig.LoadBalancers {
+		annotations, ok := loadBalancerPresets[preset]
+		if !ok {
+			return fmt.Errorf("unknown load balancer preset %q for service %q, available: %s", preset, serviceName, strings.Join(loadBalancerPresetNames(), ", "))
+		}
+		found := false
+		for _, asset := range p.assets() {
+			service, ok := asset.ResourceData.(*v1.Service)
+			if !ok || service.Name != serviceName {
+				continue
+			}
+			found = true
+			if service.Spec.Type == "" || service.Spec.Type == v1.ServiceTypeClusterIP {
+				service.Spec.Type = v1.ServiceTypeLoadBalancer
+			}
+			if service.Annotations == nil {
+				service.Annotations = make(map[string]string)
+			}
+			for key, value := range annotations {
+				if _, ok := service.Annotations[key]; !ok {
+					service.Annotations[key] = value
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("load balancer preset %q: service %q not found in project", preset, serviceName)
+		}
+	}
+	return nil
+}
+
+func loadBalancerPresetNames() []string {
+	names := []string{}
+	for name := range loadBalancerPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
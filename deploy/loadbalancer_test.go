@@ -0,0 +1,34 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadBalancerPresets(t *testing.T) {
+	req := require.New(t)
+	service := &v1.Service{
+		ObjectMeta: apiv1.ObjectMeta{
+			Name: "api",
+			Annotations: map[string]string{
+				"service.beta.kubernetes.io/aws-load-balancer-internal": "10.0.0.0/8",
+			},
+		},
+	}
+	p := &Project{
+		projectConfig: &ProjectConfig{LoadBalancers: map[string]string{"api": "aws-nlb-internal"}},
+		services:      []*Asset{{Kind: "service", ResourceData: service}},
+	}
+	req.NoError(p.applyLoadBalancerPresets())
+	req.Equal(v1.ServiceTypeLoadBalancer, service.Spec.Type)
+	req.Equal("nlb", service.Annotations["service.beta.kubernetes.io/aws-load-balancer-type"])
+	req.Equal("10.0.0.0/8", service.Annotations["service.beta.kubernetes.io/aws-load-balancer-internal"])
+
+	p.projectConfig.LoadBalancers = map[string]string{"api": "digitalocean"}
+	req.Error(p.applyLoadBalancerPresets())
+	p.projectConfig.LoadBalancers = map[string]string{"web": "gcp-internal"}
+	req.Error(p.applyLoadBalancerPresets())
+}
@@ -0,0 +1,182 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// manifestDocument is one YAML document of a manifest file, with the line it
+// starts on so errors can point back into the file
+type manifestDocument struct {
+	index int
+	line  int
+	data  []byte
+}
+
+// documentReader splits a multi-document manifest on "---" separators one
+// document at a time, so large bundles never need to be decoded at once
+type documentReader struct {
+	reader *bufio.Reader
+	line   int
+	index  int
+}
+
+func newDocumentReader(r io.Reader) *documentReader {
+	return &documentReader{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Read returns the next non-empty document, or io.EOF
+func (r *documentReader) Read() (*manifestDocument, error) {
+	for {
+		buf := &bytes.Buffer{}
+		start := r.line + 1
+		empty := true
+		for {
+			line, err := r.reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if len(line) > 0 {
+				r.line++
+			}
+			if isDocumentSeparator(line) {
+				break
+			}
+			buf.Write(line)
+			if !isBlankYAMLLine(line) {
+				empty = false
+			}
+			if err == io.EOF {
+				if empty {
+					return nil, io.EOF
+				}
+				break
+			}
+		}
+		if empty {
+			continue
+		}
+		r.index++
+		return &manifestDocument{
+			index: r.index,
+			line:  start,
+			data:  buf.Bytes(),
+		}, nil
+	}
+}
+
+func isDocumentSeparator(line []byte) bool {
+	trimmed := strings.TrimRight(string(line), " \t\r\n")
+	return trimmed == "---" || strings.HasPrefix(trimmed, "--- ") || strings.HasPrefix(trimmed, "---\t")
+}
+
+func isBlankYAMLLine(line []byte) bool {
+	trimmed := strings.TrimSpace(string(line))
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+type manifestError struct {
+	filename string
+	document int
+	line     int
+	column   int
+	snippet  string
+	err      error
+}
+
+func newManifestError(filename string, document *manifestDocument, err error) *manifestError {
+	manifestErr := &manifestError{
+		filename: filename,
+		document: document.index,
+		line:     document.line,
+		err:      err,
+	}
+	line, column := locateError(document.data, err)
+	if line > 0 {
+		manifestErr.line = document.line + line - 1
+		manifestErr.column = column
+		manifestErr.snippet = errorSnippet(document.data, line, document.line)
+	}
+	return manifestErr
+}
+
+func (err *manifestError) Error() string {
+	location := fmt.Sprintf("%s:%d", err.filename, err.line)
+	if err.column > 0 {
+		location = fmt.Sprintf("%s:%d", location, err.column)
+	}
+	message := fmt.Sprintf("%s: document %d: %s", location, err.document, err.err.Error())
+	if err.snippet != "" {
+		message += "\n" + err.snippet
+	}
+	return message
+}
+
+var (
+	yamlErrorLocation = regexp.MustCompile(`line (\d+)(?:, column (\d+))?:`)
+	jsonFieldError    = regexp.MustCompile(`Go struct field ([\w.]+) of type`)
+	unknownFieldError = regexp.MustCompile(`unknown field "([^"]+)"`)
+)
+
+// locateError finds the line (and column when known) of err relative to the
+// start of the document. The yaml parser reports lines itself; type errors
+// from the typed decode only name a field, so we look for its key.
+func locateError(data []byte, err error) (int, int) {
+	if match := yamlErrorLocation.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		column, _ := strconv.Atoi(match[2])
+		return line, column
+	}
+	key := ""
+	if match := jsonFieldError.FindStringSubmatch(err.Error()); match != nil {
+		fields := strings.Split(match[1], ".")
+		key = fields[len(fields)-1]
+	} else if match := unknownFieldError.FindStringSubmatch(err.Error()); match != nil {
+		key = match[1]
+	} else if _, ok := err.(UnsupportedResource); ok {
+		key = "kind"
+	}
+	if key == "" {
+		return 0, 0
+	}
+	keyPattern := regexp.MustCompile(`^(\s*-?\s*)` + regexp.QuoteMeta(key) + `\s*:`)
+	for i, line := range strings.Split(string(data), "\n") {
+		if match := keyPattern.FindStringSubmatch(line); match != nil {
+			return i + 1, len(match[1]) + 1
+		}
+	}
+	return 0, 0
+}
+
+func errorSnippet(data []byte, line, firstLine int) string {
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	snippet := []string{}
+	for i := line - 2; i <= line+2; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		snippet = append(snippet, fmt.Sprintf("%s %4d | %s", marker, firstLine+i-1, lines[i-1]))
+	}
+	return strings.Join(snippet, "\n")
+}
+
+type manifestErrors []error
+
+func (errs manifestErrors) Error() string {
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d manifest errors:\n%s", len(errs), strings.Join(messages, "\n"))
+}
@@ -0,0 +1,116 @@
+package deploy
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentReader(t *testing.T) {
+	manifest := `# leading comment
+---
+kind: ConfigMap
+metadata:
+  name: first
+---
+
+---
+kind: Secret
+metadata:
+  name: second
+`
+	reader := newDocumentReader(strings.NewReader(manifest))
+	document, err := reader.Read()
+	require.Nil(t, err)
+	require.Equal(t, 1, document.index)
+	require.Equal(t, 3, document.line)
+	require.Contains(t, string(document.data), "name: first")
+	document, err = reader.Read()
+	require.Nil(t, err)
+	require.Equal(t, 2, document.index)
+	require.Equal(t, 9, document.line)
+	require.Contains(t, string(document.data), "name: second")
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestManifestErrorLocation(t *testing.T) {
+	manifest := `kind: ConfigMap
+metadata:
+  name: first
+---
+kind: Deployment
+metadata:
+  name: second
+spec:
+  replicas: many
+`
+	reader := newDocumentReader(strings.NewReader(manifest))
+	_, err := reader.Read()
+	require.Nil(t, err)
+	document, err := reader.Read()
+	require.Nil(t, err)
+	_, err = parseDocument("deployment.yml", document, true, nil)
+	require.NotNil(t, err)
+	manifestErr, ok := err.(*manifestError)
+	require.True(t, ok)
+	require.Equal(t, 2, manifestErr.document)
+	require.Equal(t, 9, manifestErr.line)
+	require.Contains(t, manifestErr.Error(), ">    9 |   replicas: many")
+}
+
+func TestStrictDecoding(t *testing.T) {
+	manifest := `kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  containers:
+  - name: web
+    image: nginx
+`
+	document, err := newDocumentReader(strings.NewReader(manifest)).Read()
+	require.Nil(t, err)
+	_, err = parseDocument("deployment.yml", document, false, nil)
+	require.Nil(t, err)
+	_, err = parseDocument("deployment.yml", document, true, nil)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "deployment.yml:6:3")
+}
+
+func TestKindAliases(t *testing.T) {
+	for kind, expected := range map[string]string{
+		"Deployment":            "deployment",
+		"PersistentVolumeClaim": "persistentvolumeclaim",
+		"svc":                   "service",
+		"CM":                    "configmap",
+	} {
+		document := &manifestDocument{index: 1, line: 1, data: []byte("kind: " + kind + "\nmetadata:\n  name: test\n")}
+		asset, err := parseDocument("test.yml", document, true, nil)
+		require.Nil(t, err)
+		require.Equal(t, expected, asset.Kind)
+	}
+}
+
+func TestScalingKinds(t *testing.T) {
+	req := require.New(t)
+	data := []byte(`apiVersion: autoscaling/v1
+kind: hpa
+metadata:
+  name: web
+spec:
+  scaleTargetRef:
+    apiVersion: extensions/v1beta1
+    kind: Deployment
+    name: web
+  minReplicas: 2
+  maxReplicas: 10
+  targetCPUUtilizationPercentage: 70
+`)
+	asset, err := parseDocument("hpa.yml", &manifestDocument{index: 1, line: 1, data: data}, true, nil)
+	req.NoError(err)
+	req.Equal("horizontalpodautoscaler", asset.Kind)
+	req.Nil(getPodSpec(asset.Kind, asset.ResourceData))
+}
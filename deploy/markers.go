@@ -0,0 +1,81 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+type DeployMarker struct {
+	Provider      string   `yaml:"provider"`
+	APIKey        string   `yaml:"api_key"`
+	APIKeyFile    string   `yaml:"api_key_file"`
+	Site          string   `yaml:"site"`
+	ApplicationID string   `yaml:"application_id"`
+	Version       string   `yaml:"version"`
+	Tags          []string `yaml:"tags"`
+}
+
+func (p *Project) sendDeployMarkers() {
+	for _, marker := range p.projectConfig.DeployMarkers {
+		err := p.sendDeployMarker(marker)
+		if err != nil {
+			p.printer.ErrPrintf(ColorRed, "Cannot send %s deploy marker: %s\n", marker.Provider, err.Error())
+		}
+	}
+}
+
+func (p *Project) sendDeployMarker(marker *DeployMarker) error {
+	apiKey := marker.APIKey
+	if apiKey == "" && marker.APIKeyFile != "" {
+		buf, err := ioutil.ReadFile(translateFilePath(p.projectConfig.RootFolder, marker.APIKeyFile))
+		if err != nil {
+			return err
+		}
+		apiKey = strings.TrimSpace(string(buf))
+	}
+	markerURL, headers, body, err := p.deployMarkerRequest(marker, apiKey)
+	if err != nil {
+		return err
+	}
+	return doJSONRequest("POST", markerURL, headers, body, nil)
+}
+
+// deployMarkerRequest builds the request posting the marker to its provider
+func (p *Project) deployMarkerRequest(marker *DeployMarker, apiKey string) (string, map[string]string, interface{}, error) {
+	version := marker.Version
+	if version == "" {
+		version = p.releaseID()
+	}
+	switch marker.Provider {
+	case "datadog":
+		site := marker.Site
+		if site == "" {
+			site = "datadoghq.com"
+		}
+		tags := append([]string{
+			"service:" + p.projectConfig.Name,
+			"env:" + p.projectConfig.Namespace,
+			"version:" + version,
+		}, marker.Tags...)
+		return "https://api." + site + "/api/v1/events", map[string]string{"DD-API-KEY": apiKey}, map[string]interface{}{
+			"title":      fmt.Sprintf("Deployed %s %s to %s", p.projectConfig.Name, version, p.projectConfig.Namespace),
+			"text":       fmt.Sprintf("Release %s deployed by %s", p.releaseID(), deployActor()),
+			"tags":       tags,
+			"alert_type": "info",
+		}, nil
+	case "newrelic":
+		if marker.ApplicationID == "" {
+			return "", nil, nil, fmt.Errorf("newrelic deploy marker needs an application_id")
+		}
+		return "https://api.newrelic.com/v2/applications/" + marker.ApplicationID + "/deployments.json", map[string]string{"X-Api-Key": apiKey}, map[string]interface{}{
+			"deployment": map[string]string{
+				"revision":    version,
+				"description": fmt.Sprintf("Deployed %s to %s", p.projectConfig.Name, p.projectConfig.Namespace),
+				"user":        deployActor(),
+			},
+		}, nil
+	default:
+		return "", nil, nil, fmt.Errorf("unknown deploy marker provider %q", marker.Provider)
+	}
+}
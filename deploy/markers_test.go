@@ -0,0 +1,32 @@
+package deploy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeployMarkerRequest(t *testing.T) {
+	req := require.New(t)
+	p := &Project{projectConfig: &ProjectConfig{Name: "web", Namespace: "production"}, startedAt: time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	markerURL, headers, body, err := p.deployMarkerRequest(&DeployMarker{Provider: "datadog", Site: "datadoghq.eu", Tags: []string{"team:core"}}, "dd-key")
+	req.Nil(err)
+	req.Equal("https://api.datadoghq.eu/api/v1/events", markerURL)
+	req.Equal(map[string]string{"DD-API-KEY": "dd-key"}, headers)
+	event := body.(map[string]interface{})
+	req.Equal("Deployed web 20170102-030405 to production", event["title"])
+	req.Equal([]string{"service:web", "env:production", "version:20170102-030405", "team:core"}, event["tags"])
+
+	markerURL, headers, body, err = p.deployMarkerRequest(&DeployMarker{Provider: "newrelic", ApplicationID: "1234", Version: "v1.2.0"}, "nr-key")
+	req.Nil(err)
+	req.Equal("https://api.newrelic.com/v2/applications/1234/deployments.json", markerURL)
+	req.Equal(map[string]string{"X-Api-Key": "nr-key"}, headers)
+	req.Equal("v1.2.0", body.(map[string]interface{})["deployment"].(map[string]string)["revision"])
+
+	_, _, _, err = p.deployMarkerRequest(&DeployMarker{Provider: "newrelic"}, "nr-key")
+	req.EqualError(err, "newrelic deploy marker needs an application_id")
+	_, _, _, err = p.deployMarkerRequest(&DeployMarker{Provider: "honeycomb"}, "key")
+	req.EqualError(err, `unknown deploy marker provider "honeycomb"`)
+}
@@ -0,0 +1,200 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type metricInfo struct {
+	Type string
+	Help string
+}
+
+var metricInfos = map[string]metricInfo{
+	"imladris_deploys_total":               {"counter", "Number of deploys by command and result."},
+	"imladris_deploy_duration_seconds":     {"gauge", "Duration of the last deploy."},
+	"imladris_resources_applied_total":     {"counter", "Number of resources created or updated."},
+	"imladris_resources_failed_total":      {"counter", "Number of resources that failed to apply."},
+	"imladris_drift_total":                 {"counter", "Number of times the cluster drifted from the manifests."},
+	"imladris_wait_duration_seconds_total": {"counter", "Total time spent waiting for resources."},
+	"imladris_waits_total":                 {"counter", "Number of waits for resources."},
+}
+
+type metricsRegistry struct {
+	sync.Mutex
+	values map[string]map[string]float64
+}
+
+var metrics = &metricsRegistry{
+	values: make(map[string]map[string]float64),
+}
+
+func formatMetricLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := []string{}
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", key, labels[key]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (m *metricsRegistry) add(name string, labels map[string]string, value float64) {
+	m.Lock()
+	defer m.Unlock()
+	series, ok := m.values[name]
+	if !ok {
+		series = make(map[string]float64)
+		m.values[name] = series
+	}
+	series[formatMetricLabels(labels)] += value
+}
+
+func (m *metricsRegistry) set(name string, labels map[string]string, value float64) {
+	m.Lock()
+	defer m.Unlock()
+	series, ok := m.values[name]
+	if !ok {
+		series = make(map[string]float64)
+		m.values[name] = series
+	}
+	series[formatMetricLabels(labels)] = value
+}
+
+func (m *metricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	return m.writeSeries(w, func(labels string) bool { return true })
+}
+
+// writeProject writes the series of one project only. Projects deployed by
+// the same process share the registry, and a push of one project must not
+// carry the others.
+func (m *metricsRegistry) writeProject(w io.Writer, projectName string) (int64, error) {
+	label := "project=" + strconv.Quote(projectName)
+	return m.writeSeries(w, func(labels string) bool {
+		return strings.Contains(labels, "{"+label) || strings.Contains(labels, ","+label)
+	})
+}
+
+func (m *metricsRegistry) writeSeries(w io.Writer, keep func(labels string) bool) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	buf := &bytes.Buffer{}
+	names := []string{}
+	for name := range m.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		series := []string{}
+		for labels := range m.values[name] {
+			if keep(labels) {
+				series = append(series, labels)
+			}
+		}
+		if len(series) == 0 {
+			continue
+		}
+		info := metricInfos[name]
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, info.Help, name, info.Type)
+		sort.Strings(series)
+		for _, labels := range series {
+			fmt.Fprintf(buf, "%s%s %g\n", name, labels, m.values[name][labels])
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+func (m *metricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			ErrPrintf(ColorRed, "Cannot serve metrics: %s\n", err.Error())
+		}
+	}()
+}
+
+func pushMetrics(pushgateway, projectName string) error {
+	buf := &bytes.Buffer{}
+	metrics.writeProject(buf, projectName)
+	url := strings.TrimRight(pushgateway, "/") + "/metrics/job/imladris/project/" + projectName
+	req, err := http.NewRequest("PUT", url, buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *Project) metricLabels() map[string]string {
+	return map[string]string{
+		"project":   p.projectConfig.Name,
+		"namespace": p.projectConfig.Namespace,
+	}
+}
+
+func (p *Project) observeResource(kind string, err error) {
+	labels := p.metricLabels()
+	labels["kind"] = kind
+	if err != nil {
+		metrics.add("imladris_resources_failed_total", labels, 1)
+	} else {
+		metrics.add("imladris_resources_applied_total", labels, 1)
+	}
+}
+
+func (p *Project) observeDrift() {
+	metrics.add("imladris_drift_total", p.metricLabels(), 1)
+}
+
+func (p *Project) observeDeploy(command string, started time.Time, err error) {
+	labels := p.metricLabels()
+	labels["command"] = command
+	metrics.set("imladris_deploy_duration_seconds", labels, time.Since(started).Seconds())
+	if err != nil {
+		labels["result"] = "failure"
+	} else {
+		labels["result"] = "success"
+	}
+	metrics.add("imladris_deploys_total", labels, 1)
+	if p.config.pushgateway == "" {
+		return
+	}
+	pushErr := pushMetrics(p.config.pushgateway, p.projectConfig.Name)
+	if pushErr != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot push metrics: %s\n", pushErr.Error())
+	}
+}
+
+func observeWait(kind string, started time.Time) {
+	labels := map[string]string{"kind": kind}
+	metrics.add("imladris_wait_duration_seconds_total", labels, time.Since(started).Seconds())
+	metrics.add("imladris_waits_total", labels, 1)
+}
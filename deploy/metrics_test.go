@@ -0,0 +1,47 @@
+package deploy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	req := require.New(t)
+	registry := &metricsRegistry{
+		values: make(map[string]map[string]float64),
+	}
+	labels := map[string]string{"project": "integ", "namespace": "anduin"}
+	registry.add("imladris_drift_total", labels, 1)
+	registry.add("imladris_drift_total", labels, 1)
+	registry.set("imladris_deploy_duration_seconds", map[string]string{"project": "integ"}, 2.5)
+	buf := &bytes.Buffer{}
+	_, err := registry.WriteTo(buf)
+	req.NoError(err)
+	req.Equal(`# HELP imladris_deploy_duration_seconds Duration of the last deploy.
+# TYPE imladris_deploy_duration_seconds gauge
+imladris_deploy_duration_seconds{project="integ"} 2.5
+# HELP imladris_drift_total Number of times the cluster drifted from the manifests.
+# TYPE imladris_drift_total counter
+imladris_drift_total{namespace="anduin",project="integ"} 2
+`, buf.String())
+}
+
+func TestMetricsOfProject(t *testing.T) {
+	req := require.New(t)
+	registry := &metricsRegistry{
+		values: make(map[string]map[string]float64),
+	}
+	registry.add("imladris_drift_total", map[string]string{"project": "web", "namespace": "shop"}, 1)
+	registry.add("imladris_drift_total", map[string]string{"project": "api", "namespace": "shop"}, 1)
+	registry.set("imladris_deploy_duration_seconds", map[string]string{"project": "api"}, 2.5)
+	registry.set("imladris_deploy_duration_seconds", map[string]string{"project": "web-admin"}, 1.5)
+	buf := &bytes.Buffer{}
+	_, err := registry.writeProject(buf, "web")
+	req.NoError(err)
+	req.Equal(`# HELP imladris_drift_total Number of times the cluster drifted from the manifests.
+# TYPE imladris_drift_total counter
+imladris_drift_total{namespace="shop",project="web"} 1
+`, buf.String())
+}
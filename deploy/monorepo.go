@@ -0,0 +1,370 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// monorepoProject is a project file found by deploy-all, with what decides
+// whether and when it is deployed
+type monorepoProject struct {
+	name      string
+	file      string
+	folders   []string
+	dependsOn []string
+}
+
+// discoverProjects finds the project.yml files under root. Projects that are
+// only extended by others are bases and not deployed themselves, a change to
+// their folder affects every project extending them.
+func discoverProjects(root string, variables variableMap) ([]*monorepoProject, error) {
+	files := []string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != root && (strings.HasPrefix(info.Name(), ".") || info.Name() == "node_modules" || info.Name() == "vendor") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name() == "project.yml" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	projects := []*monorepoProject{}
+	bases := make(map[string]bool)
+	for _, file := range files {
+		project, extended, err := readMonorepoProject(file, variables)
+		if err != nil {
+			return nil, err
+		}
+		for _, base := range extended {
+			bases[base] = true
+		}
+		projects = append(projects, project)
+	}
+	deployable := []*monorepoProject{}
+	names := make(map[string]string)
+	for _, project := range projects {
+		if bases[project.file] {
+			continue
+		}
+		if other, ok := names[project.name]; ok {
+			return nil, fmt.Errorf("project %q is defined twice, in %q and %q", project.name, other, project.file)
+		}
+		names[project.name] = project.file
+		deployable = append(deployable, project)
+	}
+	return deployable, nil
+}
+
+// readMonorepoProject reads the name, folders and dependencies of a project
+// without reading its manifests, and the base projects it extends. A project
+// depends on the projects it imports from and the ones in depends_on.
+func readMonorepoProject(file string, variables variableMap) (*monorepoProject, []string, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	project := &monorepoProject{file: file}
+	merged, err := readProjectFile(file, variables, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	project.name, _ = merged["name"].(string)
+	if project.name == "" {
+		rootFolder, _ := merged["root_folder"].(string)
+		project.name = defaultProjectName(translateFilePath(filepath.Dir(file), rootFolder))
+	}
+	imports, _ := merged["imports"].([]interface{})
+	for _, projectImport := range imports {
+		if importMap, ok := projectImport.(map[interface{}]interface{}); ok {
+			project.dependsOn = append(project.dependsOn, fmt.Sprint(importMap["project"]))
+		}
+	}
+	project.dependsOn = append(project.dependsOn, stringList(merged["depends_on"], "")...)
+	extended := []string{}
+	for current := file; current != ""; {
+		document, err := renderProjectFile(current, variables)
+		if err != nil {
+			return nil, nil, err
+		}
+		folder := filepath.Dir(current)
+		project.folders = append(project.folders, folder)
+		if rootFolder, ok := document["root_folder"].(string); ok && rootFolder != "" {
+			project.folders = append(project.folders, translateFilePath(folder, rootFolder))
+		}
+		extends, _ := document["extends"].(string)
+		if extends == "" {
+			break
+		}
+		base := translateFilePath(folder, extends)
+		if info, err := os.Stat(base); err == nil && info.IsDir() {
+			base = filepath.Join(base, "project.yml")
+		}
+		current, err = filepath.Abs(base)
+		if err != nil {
+			return nil, nil, err
+		}
+		extended = append(extended, current)
+	}
+	return project, extended, nil
+}
+
+// changedFiles lists the files changed since the merge base of baseRef and
+// HEAD, uncommitted and untracked changes included, as absolute paths
+func changedFiles(folder, baseRef string) ([]string, error) {
+	top, err := runGit(folder, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	mergeBase, err := runGit(folder, "merge-base", baseRef, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	output, err := runGit(folder, "diff", "--name-only", mergeBase)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit(top, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, line := range strings.Split(output+"\n"+untracked, "\n") {
+		if line != "" {
+			files = append(files, filepath.Join(top, line))
+		}
+	}
+	return files, nil
+}
+
+// affectedProjects keeps the projects with a changed file in one of their
+// folders, and the projects depending on them
+func affectedProjects(projects []*monorepoProject, changed []string) []*monorepoProject {
+	affected := make(map[string]bool)
+	for _, project := range projects {
+		for _, folder := range project.folders {
+			for _, file := range changed {
+				if file == folder || strings.HasPrefix(file, folder+string(filepath.Separator)) {
+					affected[project.name] = true
+				}
+			}
+		}
+	}
+	for grew := true; grew; {
+		grew = false
+		for _, project := range projects {
+			for _, dependency := range project.dependsOn {
+				if affected[dependency] && !affected[project.name] {
+					affected[project.name] = true
+					grew = true
+				}
+			}
+		}
+	}
+	result := []*monorepoProject{}
+	for _, project := range projects {
+		if affected[project.name] {
+			result = append(result, project)
+		}
+	}
+	return result
+}
+
+// deployOrder sorts projects so each comes after the projects it depends
+// on. Dependencies outside the list are expected to be deployed already.
+func deployOrder(projects []*monorepoProject) ([]*monorepoProject, error) {
+	byName := make(map[string]*monorepoProject)
+	names := []string{}
+	for _, project := range projects {
+		byName[project.name] = project
+		names = append(names, project.name)
+	}
+	sort.Strings(names)
+	ordered := []*monorepoProject{}
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("projects depend on each other: %s", strings.Join(append(path, name), " -> "))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		dependencies := append([]string{}, byName[name].dependsOn...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			if _, ok := byName[dependency]; !ok {
+				continue
+			}
+			err := visit(dependency, append(path, name))
+			if err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		ordered = append(ordered, byName[name])
+		return nil
+	}
+	for _, name := range names {
+		err := visit(name, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// planDeployAll lists the projects deploy-all deploys, in order: all of them,
+// or with -base-ref only the ones affected by the changes since that ref
+func planDeployAll(root string, config *appConfig) ([]*monorepoProject, error) {
+	projects, err := discoverProjects(root, config.variables)
+	if err != nil {
+		return nil, err
+	}
+	if config.baseRef != "" {
+		changed, err := changedFiles(root, config.baseRef)
+		if err != nil {
+			return nil, err
+		}
+		projects = affectedProjects(projects, changed)
+	}
+	return deployOrder(projects)
+}
+
+// deployMonorepoProject deploys one project of deploy-all. Next to other
+// projects its output is prefixed with its name, and it asks nothing: an
+// answer typed in could be meant for any of them.
+func deployMonorepoProject(clientset *kubernetes.Clientset, monorepoProject *monorepoProject, config *appConfig) error {
+	if config.parallel > 1 {
+		stdout := newPrefixWriter(config.printer.Stdout(), "["+monorepoProject.name+"] ")
+		stderr := newPrefixWriter(config.printer.Stderr(), "["+monorepoProject.name+"] ")
+		defer stdout.flush()
+		defer stderr.flush()
+		sideBySide := *config
+		sideBySide.printer = newPrinter(stdout, stderr)
+		sideBySide.nonInteractive = true
+		config = &sideBySide
+	}
+	config.printer.Printf(ColorPurple, "====> Deploying %s\n", monorepoProject.name)
+	project, err := readProject(clientset, monorepoProject.file, config)
+	if err != nil {
+		return err
+	}
+	// Up skips the resources that exist, the changes must be applied
+	started := project.startDeploy("apply")
+	err = project.deploy("apply", project.applyAsset)
+	project.finishDeploy("apply", started, err)
+	return err
+}
+
+// projectResult is the outcome of one project of deploy-all
+type projectResult struct {
+	project  *monorepoProject
+	status   string
+	duration time.Duration
+	err      error
+}
+
+// runDeployAll deploys each project once the projects it depends on
+// succeeded, up to parallel at a time, so independent branches go side by
+// side. A failure skips the projects depending on it, and without
+// -keep-going nothing new starts after it.
+func runDeployAll(projects []*monorepoProject, parallel int, keepGoing bool, deploy func(*monorepoProject) error) []*projectResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+	planned := make(map[string]bool)
+	for _, project := range projects {
+		planned[project.name] = true
+	}
+	results := make(map[string]*projectResult)
+	pending := append([]*monorepoProject{}, projects...)
+	done := make(chan *projectResult)
+	running := 0
+	stopped := false
+	for {
+		for i := 0; !stopped && i < len(pending) && running < parallel; {
+			project := pending[i]
+			ready, blockedBy := true, ""
+			for _, dependency := range project.dependsOn {
+				if !planned[dependency] {
+					continue
+				}
+				result, finished := results[dependency]
+				switch {
+				case !finished:
+					ready = false
+				case result.status != "succeeded":
+					blockedBy = dependency
+				}
+			}
+			switch {
+			case blockedBy != "":
+				results[project.name] = &projectResult{project: project, status: "skipped", err: fmt.Errorf("%s did not succeed", blockedBy)}
+				pending = append(pending[:i], pending[i+1:]...)
+			case !ready:
+				i++
+			default:
+				pending = append(pending[:i], pending[i+1:]...)
+				running++
+				go func(project *monorepoProject) {
+					started := time.Now()
+					err := deploy(project)
+					result := &projectResult{project: project, status: "succeeded", duration: time.Since(started), err: err}
+					if err != nil {
+						result.status = "failed"
+					}
+					done <- result
+				}(project)
+			}
+		}
+		if running == 0 {
+			break
+		}
+		result := <-done
+		running--
+		results[result.project.name] = result
+		if result.err != nil && !keepGoing {
+			stopped = true
+		}
+	}
+	ordered := []*projectResult{}
+	for _, project := range projects {
+		result, ok := results[project.name]
+		if !ok {
+			result = &projectResult{project: project, status: "skipped", err: fmt.Errorf("not started after a failure")}
+		}
+		ordered = append(ordered, result)
+	}
+	return ordered
+}
+
+func printDeployAllSummary(results []*projectResult) {
+	Println(ColorWhite, "=========> Summary <=========")
+	for _, result := range results {
+		color := ColorGreen
+		switch result.status {
+		case "failed":
+			color = ColorRed
+		case "skipped":
+			color = ColorYellow
+		}
+		line := fmt.Sprintf("%-30s %-10s %s", result.project.name, result.status, result.duration.Round(time.Second))
+		if result.err != nil {
+			line += "  " + result.err.Error()
+		}
+		Println(color, line)
+	}
+}
@@ -0,0 +1,195 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverProjects(t *testing.T) {
+	req := require.New(t)
+	projects, err := discoverProjects("test-assets/extends-tests", variableMap{})
+	req.NoError(err)
+	req.Len(projects, 1)
+	req.Equal("web", projects[0].name)
+	base, err := filepath.Abs("test-assets/extends-tests/base")
+	req.NoError(err)
+	req.Contains(projects[0].folders, base)
+
+	affected := affectedProjects(projects, []string{filepath.Join(base, "resources", "config.yml")})
+	req.Len(affected, 1)
+	req.Empty(affectedProjects(projects, []string{filepath.Join(base+"-other", "project.yml")}))
+}
+
+func TestAffectedProjectsAndOrder(t *testing.T) {
+	req := require.New(t)
+	projects := []*monorepoProject{
+		{name: "web", folders: []string{"/repo/web"}, dependsOn: []string{"api", "database"}},
+		{name: "api", folders: []string{"/repo/api"}, dependsOn: []string{"database"}},
+		{name: "database", folders: []string{"/repo/database"}},
+		{name: "docs", folders: []string{"/repo/docs"}},
+	}
+	affected := affectedProjects(projects, []string{"/repo/api/project.yml"})
+	names := []string{}
+	for _, project := range affected {
+		names = append(names, project.name)
+	}
+	req.Equal([]string{"web", "api"}, names)
+
+	ordered, err := deployOrder(projects)
+	req.NoError(err)
+	names = []string{}
+	for _, project := range ordered {
+		names = append(names, project.name)
+	}
+	req.Equal([]string{"database", "api", "docs", "web"}, names)
+
+	projects[2].dependsOn = []string{"web"}
+	_, err = deployOrder(projects)
+	req.Error(err)
+	req.Contains(err.Error(), "depend on each other")
+}
+
+func TestRunDeployAll(t *testing.T) {
+	req := require.New(t)
+	projects := []*monorepoProject{
+		{name: "database"},
+		{name: "cache"},
+		{name: "api", dependsOn: []string{"database", "cache"}},
+		{name: "web", dependsOn: []string{"api"}},
+		{name: "docs"},
+	}
+	lock := sync.Mutex{}
+	running, maxRunning := 0, 0
+	deployed := make(map[string]bool)
+	deploy := func(project *monorepoProject) error {
+		lock.Lock()
+		for _, dependency := range project.dependsOn {
+			req.True(deployed[dependency], "%s deployed before %s", project.name, dependency)
+		}
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		running--
+		deployed[project.name] = true
+		lock.Unlock()
+		if project.name == "api" {
+			return errors.New("api failed")
+		}
+		return nil
+	}
+	results := runDeployAll(projects, 3, true, deploy)
+	statuses := make(map[string]string)
+	for _, result := range results {
+		statuses[result.project.name] = result.status
+	}
+	req.Equal(map[string]string{
+		"database": "succeeded",
+		"cache":    "succeeded",
+		"api":      "failed",
+		"web":      "skipped",
+		"docs":     "succeeded",
+	}, statuses)
+	req.Equal(3, maxRunning)
+
+	deployed = make(map[string]bool)
+	results = runDeployAll(projects, 1, false, deploy)
+	req.Equal("failed", results[2].status)
+	req.Equal("skipped", results[4].status)
+}
+
+func TestChangedFilesIncludesUntracked(t *testing.T) {
+	req := require.New(t)
+	root := t.TempDir()
+	git := func(args ...string) {
+		_, err := runGit(root, append([]string{"-c", "user.name=imladris", "-c", "user.email=imladris@example.com"}, args...)...)
+		req.NoError(err)
+	}
+	git("init", "-q")
+	req.NoError(ioutil.WriteFile(filepath.Join(root, "tracked.yml"), []byte("a"), 0644))
+	git("add", "tracked.yml")
+	git("commit", "-q", "-m", "initial")
+	req.NoError(os.MkdirAll(filepath.Join(root, "web"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(root, "web", "new.yml"), []byte("b"), 0644))
+
+	files, err := changedFiles(root, "HEAD")
+	req.NoError(err)
+	top, err := runGit(root, "rev-parse", "--show-toplevel")
+	req.NoError(err)
+	req.Equal([]string{filepath.Join(top, "web", "new.yml")}, files)
+}
+
+// writeMonorepoProject writes a project deploying a configmap at version 2
+// and adds it to the cluster at version 1
+func writeMonorepoProject(t *testing.T, cluster *fakeCluster, name string) *monorepoProject {
+	req := require.New(t)
+	cluster.add("/api/v1/namespaces/"+name, `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"`+name+`"}}`)
+	cluster.add("/api/v1/namespaces/"+name+"/configmaps/"+name, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"`+name+`","namespace":"`+name+`"},"data":{"version":"1"}}`)
+	root := t.TempDir()
+	req.NoError(os.MkdirAll(filepath.Join(root, "services"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(root, "project.yml"), []byte("name: "+name+"\nnamespace: "+name+"\n"), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(root, "services", "config.yml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "+name+"\ndata:\n  version: \"2\"\n"), 0644))
+	return &monorepoProject{name: name, file: root}
+}
+
+func configMapVersion(cluster *fakeCluster, name string) interface{} {
+	return cluster.get("/api/v1/namespaces/" + name + "/configmaps/" + name)["data"].(map[string]interface{})["version"]
+}
+
+func TestDeployMonorepoProjectApplies(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.serveDiscovery()
+	project := writeMonorepoProject(t, cluster, "web")
+	out := &bytes.Buffer{}
+	err := deployMonorepoProject(kubeClient, project, &appConfig{printer: newPrinter(out, out)})
+	req.NoError(err)
+	req.Equal("2", configMapVersion(cluster, "web"))
+	req.NotContains(out.String(), "[web]")
+}
+
+func TestDeployMonorepoProjectsSideBySide(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.serveDiscovery()
+	projects := []*monorepoProject{writeMonorepoProject(t, cluster, "web"), writeMonorepoProject(t, cluster, "api")}
+	out := &lockedBuffer{}
+	config := &appConfig{parallel: 2, printer: newPrinter(out, out)}
+	results := runDeployAll(projects, config.parallel, false, func(project *monorepoProject) error {
+		return deployMonorepoProject(kubeClient, project, config)
+	})
+	for _, result := range results {
+		req.Equal("succeeded", result.status, "%s: %v", result.project.name, result.err)
+	}
+	req.Equal("2", configMapVersion(cluster, "web"))
+	req.Equal("2", configMapVersion(cluster, "api"))
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		req.True(strings.HasPrefix(line, "[web] ") || strings.HasPrefix(line, "[api] "), line)
+	}
+	req.Contains(out.String(), "[api] ")
+	req.Contains(out.String(), "[web] ")
+}
+
+func TestPrefixWriter(t *testing.T) {
+	req := require.New(t)
+	out := &bytes.Buffer{}
+	w := newPrefixWriter(out, "[web] ")
+	fmt.Fprint(w, "first\nsec")
+	req.Equal("[web] first\n", out.String())
+	fmt.Fprint(w, "ond\nunfinished")
+	w.flush()
+	req.Equal("[web] first\n[web] second\n[web] unfinished\n", out.String())
+}
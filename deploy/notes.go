@@ -0,0 +1,69 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// changeNoteAnnotation tells kubectl users what the last release changed in
+// a resource, e.g. kubectl get deployment web -o yaml. It is left out of the
+// checksum, a new note alone doesn't make a resource look changed.
+const changeNoteAnnotation = "imladris/change-note"
+
+// summarizeChanges counts the changed lines of a diff and lists the images
+// that changed, container by container
+func summarizeChanges(diff []string, liveImages, desiredImages []string) string {
+	added, removed := 0, 0
+	for _, line := range diff {
+		if strings.Contains(line, "imladris/") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "+ "):
+			added++
+		case strings.HasPrefix(line, "- "):
+			removed++
+		}
+	}
+	if added == 0 && removed == 0 {
+		return ""
+	}
+	changes := []string{}
+	if len(liveImages) == len(desiredImages) {
+		for i := range desiredImages {
+			if liveImages[i] != desiredImages[i] {
+				changes = append(changes, fmt.Sprintf("image %s -> %s", liveImages[i], desiredImages[i]))
+			}
+		}
+	} else {
+		changes = append(changes, fmt.Sprintf("images %s -> %s", strings.Join(liveImages, ","), strings.Join(desiredImages, ",")))
+	}
+	return strings.Join(append(changes, fmt.Sprintf("%d lines added, %d removed", added, removed)), "; ")
+}
+
+// changeNote describes what this release changes in the resource. A release
+// that changes nothing keeps the note of the release that did.
+func (p *Project) changeNote(asset *Asset) (string, error) {
+	header := fmt.Sprintf("release %s by %s at %s", p.releaseID(), deployActor(), p.startedAt.UTC().Format(time.RFC3339))
+	live, found, err := p.liveResources(asset).get(asset.Kind, asset.ResourceData.(Meta).GetName())
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return header + ": created", nil
+	}
+	diff, err := p.diffAsset(asset)
+	if err != nil {
+		return "", err
+	}
+	liveImages, _ := getResourceImages(asset.Kind, live)
+	desiredImages, _ := getResourceImages(asset.Kind, asset.ResourceData)
+	summary := summarizeChanges(diff, liveImages, desiredImages)
+	if summary == "" {
+		return live.(apiv1.Object).GetAnnotations()[changeNoteAnnotation], nil
+	}
+	return header + ": " + summary, nil
+}
@@ -0,0 +1,23 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeChanges(t *testing.T) {
+	req := require.New(t)
+	diff := []string{
+		"  metadata:",
+		"-     imladris/checksum: abc",
+		"  spec:",
+		"-         image: web:1.0",
+		"+         image: web:1.1",
+		"+         - name: DEBUG",
+	}
+	req.Equal("image web:1.0 -> web:1.1; 2 lines added, 1 removed", summarizeChanges(diff, []string{"web:1.0", "nginx:1"}, []string{"web:1.1", "nginx:1"}))
+	req.Equal("images web:1.0 -> web:1.1,nginx:1; 2 lines added, 1 removed", summarizeChanges(diff, []string{"web:1.0"}, []string{"web:1.1", "nginx:1"}))
+	req.Equal("", summarizeChanges([]string{"-     imladris/checksum: abc", "+     imladris/checksum: def"}, nil, nil))
+	req.Equal("", summarizeChanges(nil, nil, nil))
+}
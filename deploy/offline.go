@@ -0,0 +1,51 @@
+package deploy
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// offline refuses every network access but the kubernetes api server, for
+// air-gapped clusters. Manifests are always validated strictly against the
+// api types compiled into imladris, values only come from files and flags.
+// Scripts, dns hooks and plugins are arbitrary commands that can't be kept
+// off the network, so they are refused, and docker builds run without one.
+var offline = false
+
+// offlineTransport replaces http.DefaultTransport in offline mode. Kubernetes
+// clients unwrap it in loadKubernetesClient to reach the api server.
+type offlineTransport struct {
+	next http.RoundTripper
+}
+
+func (t *offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, offlineError(req.URL.Host)
+}
+
+func offlineError(what string) error {
+	return validationError(fmt.Errorf("offline mode: refusing network access to %s, only the kubernetes api server is reachable", what))
+}
+
+func enableOffline() {
+	offline = true
+	if _, ok := http.DefaultTransport.(*offlineTransport); !ok {
+		http.DefaultTransport = &offlineTransport{next: http.DefaultTransport}
+	}
+}
+
+// requireNetwork guards the external commands that may reach the network,
+// which the transport can't see
+func requireNetwork(what string) error {
+	if offline {
+		return offlineError(what)
+	}
+	return nil
+}
+
+// apiServerTransport undoes offlineTransport for the api server clients
+func apiServerTransport(rt http.RoundTripper) http.RoundTripper {
+	if transport, ok := rt.(*offlineTransport); ok {
+		return transport.next
+	}
+	return rt
+}
@@ -0,0 +1,69 @@
+package deploy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffline(t *testing.T) {
+	req := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	original := http.DefaultTransport
+	defer func() {
+		offline = false
+		http.DefaultTransport = original
+	}()
+
+	req.Nil(doJSONRequest("GET", server.URL, nil, nil, nil))
+	enableOffline()
+	enableOffline()
+	err := doJSONRequest("GET", server.URL, nil, nil, nil)
+	req.Error(err)
+	req.Contains(err.Error(), "offline mode")
+	req.Equal(original, apiServerTransport(http.DefaultTransport))
+	req.Error(requireNetwork("git remote origin"))
+	req.Error(dockerPush(nil, "example.com/imladris:offline", false))
+}
+
+func TestOfflineCommands(t *testing.T) {
+	req := require.New(t)
+	original := http.DefaultTransport
+	defer func() {
+		offline = false
+		http.DefaultTransport = original
+	}()
+	enableOffline()
+	out := &bytes.Buffer{}
+	p := &Project{
+		config:        &appConfig{},
+		projectConfig: &ProjectConfig{RootFolder: t.TempDir()},
+		printer:       newPrinter(out, out),
+	}
+
+	err := p.runScripts([]string{"echo online"})
+	req.Error(err)
+	req.Contains(err.Error(), "offline mode")
+	req.NotContains(out.String(), "online")
+
+	plugin := &KindPlugin{Kind: "database", Command: "echo {}", project: p}
+	_, err = plugin.call("get", "main", "default", nil)
+	req.Error(err)
+	req.Contains(err.Error(), "offline mode")
+
+	// Offline validates against the compiled api types even without -strict
+	p.projectConfig.Variables = map[string]string{}
+	manifest := filepath.Join(p.projectConfig.RootFolder, "configmap.yml")
+	req.Nil(ioutil.WriteFile(manifest, []byte("kind: ConfigMap\napiVersion: v1\nmetadata:\n  name: app\ndta:\n  key: value\n"), 0600))
+	_, err = p.readAsset(manifest)
+	req.Error(err)
+	req.Contains(err.Error(), "dta")
+}
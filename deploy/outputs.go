@@ -0,0 +1,176 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const outputsPrefix = "imladris-outputs-"
+
+// ProjectOutput is a value published for other projects after a deploy,
+// either a literal or a field of a deployed resource, e.g.
+// resource: service/postgres, field: spec.clusterIP
+type ProjectOutput struct {
+	Name     string `yaml:"name"`
+	Value    string `yaml:"value"`
+	Resource string `yaml:"resource"`
+	Field    string `yaml:"field"`
+}
+
+// ProjectImport reads the outputs of another project. They become template
+// variables named import_<project>_<output>.
+type ProjectImport struct {
+	Project   string `yaml:"project"`
+	Namespace string `yaml:"namespace"`
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func importVariableName(project, output string) string {
+	return nonIdentifierChars.ReplaceAllString("import_"+project+"_"+output, "_")
+}
+
+func (p *Project) readImports() error {
+	for _, projectImport := range p.projectConfig.Imports {
+		namespace := projectImport.Namespace
+		if namespace == "" {
+			namespace = p.projectConfig.Namespace
+		}
+		outputs, err := readOutputs(p.kubeClient, namespace, projectImport.Project)
+		if err == nil && outputs == nil {
+			err = fmt.Errorf("project %q has not published outputs in namespace %q yet", projectImport.Project, namespace)
+		}
+		if err != nil && p.config.optionalImports {
+			p.printer.ErrPrintf(ColorYellow, "Warning: %s, its import variables are not set\n", err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for name, value := range outputs {
+			key := importVariableName(projectImport.Project, name)
+			if _, ok := p.projectConfig.Variables[key]; ok {
+				// -variable overrides what the other project published
+				continue
+			}
+			p.projectConfig.Variables[key] = value
+		}
+	}
+	return nil
+}
+
+func readOutputs(kubeClient *kubernetes.Clientset, namespace, projectName string) (map[string]string, error) {
+	configMap, err := kubeClient.Core().ConfigMaps(namespace).Get(outputsPrefix+projectName, apiv1.GetOptions{})
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return configMap.Data, nil
+}
+
+func (p *Project) publishOutputs() error {
+	if len(p.projectConfig.Outputs) == 0 {
+		return nil
+	}
+	data := make(map[string]string)
+	for _, output := range p.projectConfig.Outputs {
+		value, err := p.outputValue(output)
+		if err != nil {
+			return fmt.Errorf("unable to publish output %q: %s", output.Name, err.Error())
+		}
+		data[output.Name] = value
+	}
+	name := outputsPrefix + p.projectConfig.Name
+	configMaps := p.kubeClient.Core().ConfigMaps(p.projectConfig.Namespace)
+	configMap, err := configMaps.Get(name, apiv1.GetOptions{})
+	if err != nil {
+		if !isResourceNotExist(err) {
+			return err
+		}
+		configMap = &v1.ConfigMap{
+			ObjectMeta: apiv1.ObjectMeta{
+				Name:      name,
+				Namespace: p.projectConfig.Namespace,
+			},
+			Data: data,
+		}
+		_, err = configMaps.Create(configMap)
+		return err
+	}
+	configMap.Data = data
+	_, err = configMaps.Update(configMap)
+	return err
+}
+
+// deleteOutputs removes the outputs of the project when it is taken down
+func (p *Project) deleteOutputs() error {
+	err := p.kubeClient.Core().ConfigMaps(p.projectConfig.Namespace).Delete(outputsPrefix+p.projectConfig.Name, &apiv1.DeleteOptions{})
+	if err != nil && !isResourceNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (p *Project) outputValue(output *ProjectOutput) (string, error) {
+	if output.Resource == "" {
+		return output.Value, nil
+	}
+	pieces := strings.SplitN(output.Resource, "/", 2)
+	if len(pieces) != 2 {
+		return "", fmt.Errorf("invalid resource %q, expected <kind>/<name>", output.Resource)
+	}
+	resource, err := getResource(p.kubeClient, p.plugins, canonicalKind(pieces[0]), pieces[1], p.projectConfig.Namespace)
+	if err != nil {
+		return "", err
+	}
+	return lookupField(resource, output.Field)
+}
+
+// lookupField follows a dotted path through the JSON form of object. List
+// elements are addressed by index, e.g. status.loadBalancer.ingress.0.ip
+func lookupField(object interface{}, path string) (string, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+	var value interface{}
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return "", err
+	}
+	for _, segment := range strings.Split(path, ".") {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			value = current[segment]
+		case []interface{}:
+			index := -1
+			fmt.Sscanf(segment, "%d", &index)
+			if index < 0 || index >= len(current) {
+				return "", fmt.Errorf("field %q not found", path)
+			}
+			value = current[index]
+		default:
+			value = nil
+		}
+		if value == nil {
+			return "", fmt.Errorf("field %q not found", path)
+		}
+	}
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		return string(data), err
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
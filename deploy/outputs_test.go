@@ -0,0 +1,77 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+)
+
+func TestLookupField(t *testing.T) {
+	req := require.New(t)
+	service := &v1.Service{
+		Spec: v1.ServiceSpec{
+			ClusterIP: "10.3.0.12",
+			Ports:     []v1.ServicePort{{Name: "http", Port: 80}},
+		},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{{IP: "35.1.2.3"}},
+			},
+		},
+	}
+	value, err := lookupField(service, "spec.clusterIP")
+	req.NoError(err)
+	req.Equal("10.3.0.12", value)
+	value, err = lookupField(service, "spec.ports.0.port")
+	req.NoError(err)
+	req.Equal("80", value)
+	value, err = lookupField(service, "status.loadBalancer.ingress.0.ip")
+	req.NoError(err)
+	req.Equal("35.1.2.3", value)
+	_, err = lookupField(service, "spec.ports.1.port")
+	req.Error(err)
+	_, err = lookupField(service, "spec.externalName")
+	req.Error(err)
+	req.Equal("import_billing_api_host", importVariableName("billing-api", "host"))
+}
+
+func TestReadImports(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	newProject := func(optional bool) *Project {
+		return &Project{
+			kubeClient: kubeClient,
+			config:     &appConfig{optionalImports: optional},
+			projectConfig: &ProjectConfig{
+				Namespace: "web",
+				Variables: map[string]string{},
+				Imports:   []*ProjectImport{{Project: "billing"}},
+			},
+		}
+	}
+	// Deploying needs the outputs, taking down or diffing does not
+	err := newProject(false).readImports()
+	req.Error(err)
+	req.Contains(err.Error(), "has not published outputs")
+	p := newProject(true)
+	req.Nil(p.readImports())
+	req.Empty(p.projectConfig.Variables)
+
+	cluster.add("/api/v1/namespaces/web/configmaps/imladris-outputs-billing", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"imladris-outputs-billing","namespace":"web"},"data":{"host":"billing.web"}}`)
+	p = newProject(false)
+	req.Nil(p.readImports())
+	req.Equal("billing.web", p.projectConfig.Variables["import_billing_host"])
+}
+
+func TestDownDeletesOutputs(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	path := "/api/v1/namespaces/web/configmaps/imladris-outputs-billing"
+	cluster.add(path, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"imladris-outputs-billing","namespace":"web"},"data":{"host":"billing.web"}}`)
+	p := &Project{kubeClient: kubeClient, config: &appConfig{}, projectConfig: &ProjectConfig{Name: "billing", Namespace: "web"}}
+	req.Nil(p.Down())
+	req.Nil(cluster.get(path))
+	// Nothing published is fine too
+	req.Nil(p.Down())
+}
@@ -0,0 +1,159 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	app "k8s.io/api/apps/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PetSets were removed from the typed client, so they are read through the raw REST client
+const petSetPath = "/apis/apps/v1alpha1/namespaces"
+
+type petSet struct {
+	Metadata apiv1.ObjectMeta `json:"metadata"`
+	Spec     json.RawMessage  `json:"spec"`
+}
+
+type petSetList struct {
+	Items []petSet `json:"items"`
+}
+
+func listPetSets(kubeClient *kubernetes.Clientset, namespace string) ([]petSet, error) {
+	data, err := kubeClient.AppsV1beta1().RESTClient().Get().AbsPath(petSetPath, namespace, "petsets").DoRaw()
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil, fmt.Errorf("cluster does not serve the PetSet API (apps/v1alpha1)")
+		}
+		return nil, err
+	}
+	list := &petSetList{}
+	err = json.Unmarshal(data, list)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode petsets: %s", err.Error())
+	}
+	return list.Items, nil
+}
+
+func petSetToStatefulSet(ps petSet) (*app.StatefulSet, error) {
+	statefulSet := &app.StatefulSet{
+		ObjectMeta: apiv1.ObjectMeta{
+			Name:        ps.Metadata.Name,
+			Namespace:   ps.Metadata.Namespace,
+			Labels:      ps.Metadata.Labels,
+			Annotations: ps.Metadata.Annotations,
+		},
+	}
+	// PetSetSpec and StatefulSetSpec share field names, so the spec converts as is
+	err := json.Unmarshal(ps.Spec, &statefulSet.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert petset %q: %s", ps.Metadata.Name, err.Error())
+	}
+	return statefulSet, nil
+}
+
+func deletePetSetOrphaningPods(kubeClient *kubernetes.Clientset, name, namespace string) error {
+	orphan := true
+	body, err := json.Marshal(&apiv1.DeleteOptions{
+		TypeMeta: apiv1.TypeMeta{
+			Kind:       "DeleteOptions",
+			APIVersion: "v1",
+		},
+		OrphanDependents: &orphan,
+	})
+	if err != nil {
+		return err
+	}
+	return kubeClient.AppsV1beta1().RESTClient().Delete().AbsPath(petSetPath, namespace, "petsets", name).Body(body).Do().Error()
+}
+
+func waitForPetSetDeletion(kubeClient *kubernetes.Clientset, name, namespace string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := kubeClient.AppsV1beta1().RESTClient().Get().AbsPath(petSetPath, namespace, "petsets", name).DoRaw()
+		if err != nil {
+			if isResourceNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if time.Now().After(deadline) {
+			return newTypedError(ErrorTypeTimeout, "timeout while waiting for petset %q to be deleted", name)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// migratePetSets migrates the named petsets, or all of them without names.
+// It fails when a named one is not there, after migrating the others.
+func migratePetSets(kubeClient *kubernetes.Clientset, namespace string, names []string, config *appConfig) error {
+	petSets, err := listPetSets(kubeClient, namespace)
+	if err != nil {
+		return err
+	}
+	selected := make(map[string]struct{})
+	for _, name := range names {
+		selected[name] = struct{}{}
+	}
+	for _, ps := range petSets {
+		if len(selected) > 0 {
+			if _, ok := selected[ps.Metadata.Name]; !ok {
+				continue
+			}
+			delete(selected, ps.Metadata.Name)
+		}
+		err = migratePetSet(kubeClient, ps, namespace, config)
+		if err != nil {
+			return err
+		}
+	}
+	missing := []string{}
+	for _, name := range names {
+		if _, ok := selected[name]; !ok {
+			continue
+		}
+		delete(selected, name)
+		missing = append(missing, name)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("petsets not found in namespace %q: %s", namespace, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func migratePetSet(kubeClient *kubernetes.Clientset, ps petSet, namespace string, config *appConfig) error {
+	name := ps.Metadata.Name
+	config.printer.Printf(ColorYellow, "Migrating petset %q from namespace %q to statefulset\n", name, namespace)
+	existed, err := checkResourceExist(kubeClient, nil, "statefulset", name, namespace)
+	if err != nil {
+		return err
+	}
+	if existed {
+		return fmt.Errorf("statefulset %q already exists in namespace %q", name, namespace)
+	}
+	statefulSet, err := petSetToStatefulSet(ps)
+	if err != nil {
+		return err
+	}
+	if !askConfirmation(config, fmt.Sprintf("Delete petset %q (keeping its pods and volumes) and recreate it as a statefulset?", name)) {
+		config.printer.Println(ColorGray, "====> Skipped")
+		return nil
+	}
+	err = deletePetSetOrphaningPods(kubeClient, name, namespace)
+	if err != nil {
+		return err
+	}
+	err = waitForPetSetDeletion(kubeClient, name, namespace, config.timeout)
+	if err != nil {
+		return err
+	}
+	err = createResource(kubeClient, nil, "statefulset", name, namespace, statefulSet)
+	if err == nil {
+		config.printer.Println(ColorGreen, "====> Success")
+	}
+	return err
+}
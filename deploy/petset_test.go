@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPetSet = `{"apiVersion":"apps/v1alpha1","kind":"PetSet","metadata":{"name":"db","namespace":"web","labels":{"app":"db"}},"spec":{"serviceName":"db","replicas":3,"selector":{"matchLabels":{"app":"db"}},"template":{"metadata":{"labels":{"app":"db"}},"spec":{"containers":[{"name":"postgres","image":"postgres:9.6"}]}},"volumeClaimTemplates":[{"metadata":{"name":"data"},"spec":{"accessModes":["ReadWriteOnce"]}}]}}`
+
+func TestPetSetToStatefulSet(t *testing.T) {
+	req := require.New(t)
+	ps := petSet{}
+	req.Nil(json.Unmarshal([]byte(testPetSet), &ps))
+	statefulSet, err := petSetToStatefulSet(ps)
+	req.Nil(err)
+	req.Equal("db", statefulSet.Name)
+	req.Equal("web", statefulSet.Namespace)
+	req.Equal(map[string]string{"app": "db"}, statefulSet.Labels)
+	req.Equal("db", statefulSet.Spec.ServiceName)
+	req.Equal(int32(3), *statefulSet.Spec.Replicas)
+	req.Equal("postgres:9.6", statefulSet.Spec.Template.Spec.Containers[0].Image)
+	// Pods keep their volumes only when the claim templates keep their names
+	req.Equal("data", statefulSet.Spec.VolumeClaimTemplates[0].Name)
+	req.Empty(statefulSet.ResourceVersion)
+
+	_, err = petSetToStatefulSet(petSet{Spec: json.RawMessage(`{"replicas":"three"}`)})
+	req.Error(err)
+}
+
+func TestMigratePetSets(t *testing.T) {
+	req := require.New(t)
+	config := &appConfig{timeout: time.Minute, yes: true}
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/apis/apps/v1alpha1/namespaces/web/petsets/db", testPetSet)
+	cluster.add("/api/v1/namespaces/web/pods/db-0", `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"db-0","namespace":"web","labels":{"app":"db"}}}`)
+
+	req.Nil(migratePetSets(kubeClient, "web", []string{"db"}, config))
+	req.Nil(cluster.get("/apis/apps/v1alpha1/namespaces/web/petsets/db"))
+	req.NotNil(cluster.get("/apis/apps/v1beta1/namespaces/web/statefulsets/db"))
+	// The pods are orphaned and adopted by the statefulset, not restarted
+	req.NotNil(cluster.get("/api/v1/namespaces/web/pods/db-0"))
+
+	// A second run finds no petset left, which scripts have to know
+	err := migratePetSets(kubeClient, "web", []string{"db", "cache"}, config)
+	req.EqualError(err, `petsets not found in namespace "web": db, cache`)
+	req.Len(cluster.requested("POST", "/apis/apps/v1beta1/namespaces/web/statefulsets"), 1)
+
+	// Without names there is nothing to miss
+	req.Nil(migratePetSets(kubeClient, "web", nil, config))
+}
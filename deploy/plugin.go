@@ -0,0 +1,150 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KindPlugin hands a kind imladris doesn't know, usually a custom resource,
+// to an external command. Every operation runs the command once with a
+// pluginRequest as JSON on stdin and reads a pluginResponse from stdout, so
+// plugins can be written in any language.
+type KindPlugin struct {
+	Kind    string `yaml:"kind"`
+	Command string `yaml:"command"`
+	project *Project
+}
+
+type pluginRequest struct {
+	Action     string                     `json:"action"`
+	Kind       string                     `json:"kind"`
+	Name       string                     `json:"name"`
+	Namespace  string                     `json:"namespace"`
+	Kubeconfig string                     `json:"kubeconfig,omitempty"`
+	Context    string                     `json:"context,omitempty"`
+	Object     *unstructured.Unstructured `json:"object,omitempty"`
+}
+
+type pluginResponse struct {
+	Object   *unstructured.Unstructured `json:"object"`
+	NotFound bool                       `json:"not_found"`
+	Ready    *bool                      `json:"ready"`
+	Error    string                     `json:"error"`
+}
+
+// pluginRegistry maps kinds to their plugin. Each project fills its own from
+// the project file before manifests are read, the kind switches in
+// kubernetes.go fall back to it for kinds they don't handle.
+type pluginRegistry map[string]*KindPlugin
+
+func (p *Project) registerPlugins() error {
+	p.plugins = make(pluginRegistry)
+	for _, plugin := range p.projectConfig.Plugins {
+		if plugin.Kind == "" || plugin.Command == "" {
+			return validationError(fmt.Errorf("plugins need both a kind and a command"))
+		}
+		plugin.Kind = canonicalKind(plugin.Kind)
+		if _, builtin := resourceTypes[plugin.Kind]; builtin {
+			return validationError(fmt.Errorf("plugin for %q cannot replace a built-in kind", plugin.Kind))
+		}
+		plugin.project = p
+		p.plugins[plugin.Kind] = plugin
+	}
+	return nil
+}
+
+func (plugin *KindPlugin) call(action, name, namespace string, object *unstructured.Unstructured) (*pluginResponse, error) {
+	err := requireNetwork(plugin.Kind + " plugin")
+	if err != nil {
+		return nil, err
+	}
+	request := &pluginRequest{
+		Action:     action,
+		Kind:       plugin.Kind,
+		Name:       name,
+		Namespace:  namespace,
+		Kubeconfig: plugin.project.config.configFile,
+		Context:    plugin.project.config.context,
+		Object:     object,
+	}
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd := shellCommand(plugin.Command)
+	cmd.Dir = plugin.project.projectConfig.RootFolder
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	// Plugins get the object, secrets included, so what they print is scrubbed
+	if stderr.Len() > 0 {
+		plugin.project.printer.ErrPrintf(ColorGray, "%s", stderr.String())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s plugin failed to %s %q: %s", plugin.Kind, action, name, err.Error())
+	}
+	response := &pluginResponse{}
+	if stdout.Len() == 0 {
+		return response, nil
+	}
+	err = json.Unmarshal(stdout.Bytes(), response)
+	if err != nil {
+		return nil, fmt.Errorf("%s plugin returned an invalid response to %s %q: %s", plugin.Kind, action, name, err.Error())
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%s plugin failed to %s %q: %s", plugin.Kind, action, name, response.Error)
+	}
+	return response, nil
+}
+
+func (plugin *KindPlugin) get(name, namespace string) (interface{}, error) {
+	response, err := plugin.call("get", name, namespace, nil)
+	if err != nil {
+		return nil, err
+	}
+	if response.NotFound || response.Object == nil {
+		// Reuse the api error so isResourceNotExist works for plugin kinds too
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: plugin.Kind}, name)
+	}
+	return response.Object, nil
+}
+
+func (plugin *KindPlugin) apply(action, name, namespace string, resourceData interface{}) error {
+	_, err := plugin.call(action, name, namespace, resourceData.(*unstructured.Unstructured))
+	return err
+}
+
+// ready lets a plugin say when its resource is usable, plugins that don't
+// answer are treated as ready once applied
+func (plugin *KindPlugin) ready(name, namespace string) (bool, error) {
+	response, err := plugin.call("ready", name, namespace, nil)
+	if err != nil {
+		return false, err
+	}
+	return response.Ready == nil || *response.Ready, nil
+}
+
+// lookupResourceType also answers for plugin kinds, whose api version and
+// kind come from the object itself
+func lookupResourceType(kind string, resource interface{}) (resourceType, bool) {
+	resourceType, ok := resourceTypes[kind]
+	if ok {
+		return resourceType, true
+	}
+	// Only plugin kinds are decoded as unstructured
+	object, ok := resource.(*unstructured.Unstructured)
+	if !ok {
+		return resourceType, false
+	}
+	resourceType.APIVersion = object.GetAPIVersion()
+	resourceType.Kind = object.GetKind()
+	return resourceType, true
+}
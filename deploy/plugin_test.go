@@ -0,0 +1,58 @@
+package deploy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestKindPlugin(t *testing.T) {
+	req := require.New(t)
+	isolateSensitiveValues(t)
+	p := &Project{
+		config: &appConfig{},
+		projectConfig: &ProjectConfig{
+			RootFolder: t.TempDir(),
+			Plugins: []*KindPlugin{
+				{Kind: "Certificate", Command: `cat > request.json; echo "renewing with s3cr3t-token" >&2; echo '{"ready": false}'`},
+			},
+		},
+	}
+	req.NoError(p.registerPlugins())
+
+	manifest := []byte("apiVersion: certmanager.k8s.io/v1alpha1\nkind: Certificate\nmetadata:\n  name: web\nspec:\n  secretName: web-tls\n")
+	asset, err := parseDocument("cert.yml", &manifestDocument{index: 1, line: 1, data: manifest}, true, p.plugins)
+	req.NoError(err)
+	req.Equal("web", asset.ResourceData.(Meta).GetName())
+	_, ok := asset.ResourceData.(apiv1.Object)
+	req.True(ok)
+
+	resourceType, ok := lookupResourceType(asset.Kind, asset.ResourceData)
+	req.True(ok)
+	req.Equal("Certificate", resourceType.Kind)
+
+	// What plugins print goes through the scrubber
+	registerSensitive("s3cr3t-token")
+	output := &bytes.Buffer{}
+	p.printer = newPrinter(nil, output)
+	_, err = getResource(nil, p.plugins, "certificate", "web", "default")
+	req.True(isResourceNotExist(err))
+	req.Contains(output.String(), "renewing with "+redactedValue)
+	req.NotContains(output.String(), "s3cr3t-token")
+	ready, err := workloadReady(nil, p.plugins, "certificate", "web", "default")
+	req.NoError(err)
+	req.False(ready)
+
+	// Plugins stay with the project that declared them
+	other := &Project{config: &appConfig{}, projectConfig: &ProjectConfig{}}
+	req.NoError(other.registerPlugins())
+	_, err = parseDocument("cert.yml", &manifestDocument{index: 1, line: 1, data: manifest}, true, other.plugins)
+	req.Error(err)
+	_, err = parseAsset("cert.yml", manifest)
+	req.Error(err)
+
+	p.projectConfig.Plugins = []*KindPlugin{{Kind: "deploy", Command: "true"}}
+	req.Error(p.registerPlugins())
+}
@@ -0,0 +1,230 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// baselineCapabilities may be added under the baseline pod security standard
+var baselineCapabilities = map[v1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true, "KILL": true, "MKNOD": true,
+	"NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+// restrictedVolumes are the volume types the restricted standard allows
+var restrictedVolumes = map[string]bool{
+	"configMap": true, "downwardAPI": true, "emptyDir": true, "persistentVolumeClaim": true, "projected": true, "secret": true,
+}
+
+func podContainers(podSpec *v1.PodSpec) []v1.Container {
+	return append(append([]v1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+}
+
+// volumeType returns the source of a volume by its json name (hostPath,
+// configMap...), which is also how pod security policies list them. A volume
+// without a source is an emptyDir, the api server defaults it so.
+func volumeType(volume v1.Volume) string {
+	data, err := json.Marshal(volume.VolumeSource)
+	if err != nil {
+		return "emptyDir"
+	}
+	source := make(map[string]interface{})
+	json.Unmarshal(data, &source)
+	for key := range source {
+		return key
+	}
+	return "emptyDir"
+}
+
+// podSecurityStandardViolations checks a pod against the baseline or
+// restricted pod security standard, the way namespace labelled with
+// pod-security.kubernetes.io/enforce admits pods
+func podSecurityStandardViolations(level string, podSpec *v1.PodSpec) []string {
+	if level != "baseline" && level != "restricted" {
+		return nil
+	}
+	violations := []string{}
+	if podSpec.HostNetwork || podSpec.HostPID || podSpec.HostIPC {
+		violations = append(violations, "uses host namespaces")
+	}
+	for _, volume := range podSpec.Volumes {
+		if volume.HostPath != nil {
+			violations = append(violations, fmt.Sprintf("volume %q uses a hostPath", volume.Name))
+		} else if level == "restricted" && !restrictedVolumes[volumeType(volume)] {
+			violations = append(violations, fmt.Sprintf("volume %q has restricted type %s", volume.Name, volumeType(volume)))
+		}
+	}
+	podNonRoot := podSpec.SecurityContext != nil && podSpec.SecurityContext.RunAsNonRoot != nil && *podSpec.SecurityContext.RunAsNonRoot
+	for _, container := range podContainers(podSpec) {
+		context := container.SecurityContext
+		if context == nil {
+			context = &v1.SecurityContext{}
+		}
+		if context.Privileged != nil && *context.Privileged {
+			violations = append(violations, fmt.Sprintf("container %q is privileged", container.Name))
+		}
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				violations = append(violations, fmt.Sprintf("container %q uses host port %d", container.Name, port.HostPort))
+			}
+		}
+		if context.Capabilities != nil {
+			for _, capability := range context.Capabilities.Add {
+				if !baselineCapabilities[capability] || (level == "restricted" && capability != "NET_BIND_SERVICE") {
+					violations = append(violations, fmt.Sprintf("container %q adds capability %s", container.Name, capability))
+				}
+			}
+		}
+		if level != "restricted" {
+			continue
+		}
+		if context.AllowPrivilegeEscalation == nil || *context.AllowPrivilegeEscalation {
+			violations = append(violations, fmt.Sprintf("container %q must set allowPrivilegeEscalation to false", container.Name))
+		}
+		if !podNonRoot && (context.RunAsNonRoot == nil || !*context.RunAsNonRoot) {
+			violations = append(violations, fmt.Sprintf("container %q must set runAsNonRoot", container.Name))
+		}
+		if context.Capabilities == nil || !hasCapability(context.Capabilities.Drop, "ALL") {
+			violations = append(violations, fmt.Sprintf("container %q must drop ALL capabilities", container.Name))
+		}
+	}
+	return violations
+}
+
+func hasCapability(capabilities []v1.Capability, capability v1.Capability) bool {
+	for _, c := range capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// podSecurityPolicyViolations checks what a pod security policy validates at
+// admission. Fields the policy only defaults, like runAsUser under
+// MustRunAsNonRoot, cannot be rejected and are left out.
+func podSecurityPolicyViolations(policy *v1beta1.PodSecurityPolicySpec, podSpec *v1.PodSpec) []string {
+	violations := []string{}
+	if podSpec.HostNetwork && !policy.HostNetwork {
+		violations = append(violations, "host network is not allowed")
+	}
+	if podSpec.HostPID && !policy.HostPID {
+		violations = append(violations, "host PID is not allowed")
+	}
+	if podSpec.HostIPC && !policy.HostIPC {
+		violations = append(violations, "host IPC is not allowed")
+	}
+	allowedVolumes := make(map[string]bool)
+	for _, fsType := range policy.Volumes {
+		allowedVolumes[string(fsType)] = true
+	}
+	for _, volume := range podSpec.Volumes {
+		if !allowedVolumes[string(v1beta1.All)] && !allowedVolumes[volumeType(volume)] {
+			violations = append(violations, fmt.Sprintf("volume %q of type %s is not allowed", volume.Name, volumeType(volume)))
+		}
+	}
+	for _, container := range podContainers(podSpec) {
+		context := container.SecurityContext
+		if context == nil {
+			context = &v1.SecurityContext{}
+		}
+		if context.Privileged != nil && *context.Privileged && !policy.Privileged {
+			violations = append(violations, fmt.Sprintf("container %q: privileged is not allowed", container.Name))
+		}
+		if context.AllowPrivilegeEscalation != nil && *context.AllowPrivilegeEscalation && !policy.AllowPrivilegeEscalation {
+			violations = append(violations, fmt.Sprintf("container %q: privilege escalation is not allowed", container.Name))
+		}
+		for _, port := range container.Ports {
+			if port.HostPort != 0 && !hostPortAllowed(policy.HostPorts, port.HostPort) {
+				violations = append(violations, fmt.Sprintf("container %q: host port %d is not allowed", container.Name, port.HostPort))
+			}
+		}
+		if context.Capabilities != nil {
+			for _, capability := range context.Capabilities.Add {
+				if !hasCapability(policy.AllowedCapabilities, capability) && !hasCapability(policy.AllowedCapabilities, "*") && !hasCapability(policy.DefaultAddCapabilities, capability) {
+					violations = append(violations, fmt.Sprintf("container %q: capability %s is not allowed", container.Name, capability))
+				}
+			}
+		}
+		if policy.ReadOnlyRootFilesystem && context.ReadOnlyRootFilesystem != nil && !*context.ReadOnlyRootFilesystem {
+			violations = append(violations, fmt.Sprintf("container %q: root filesystem must be read only", container.Name))
+		}
+	}
+	return violations
+}
+
+func hostPortAllowed(ranges []v1beta1.HostPortRange, port int32) bool {
+	for _, portRange := range ranges {
+		if port >= portRange.Min && port <= portRange.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// podSecurityPreflight reports every workload the target namespaces would
+// reject, before anything is applied. Pod security policies are only
+// checked when they can be listed, and without RBAC: a pod passes when any
+// policy admits it.
+func (p *Project) podSecurityPreflight() error {
+	rejected := []string{}
+	levels := make(map[string]string)
+	policies := make(map[string][]v1beta1.PodSecurityPolicy)
+	for _, asset := range p.assets() {
+		podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+		if podSpec == nil {
+			continue
+		}
+		kubeClient := p.clientFor(asset)
+		namespaceKey := asset.context + "/" + asset.Namespace()
+		level, ok := levels[namespaceKey]
+		if !ok {
+			namespace, err := kubeClient.Core().Namespaces().Get(asset.Namespace(), apiv1.GetOptions{})
+			if err == nil {
+				level = namespace.Labels[podSecurityEnforceLabel]
+			}
+			levels[namespaceKey] = level
+		}
+		policyList, ok := policies[asset.context]
+		if !ok {
+			list, err := kubeClient.Extensions().PodSecurityPolicies().List(apiv1.ListOptions{})
+			if err == nil {
+				policyList = list.Items
+			}
+			policies[asset.context] = policyList
+		}
+		reasons := podSecurityStandardViolations(level, podSpec)
+		if len(reasons) > 0 {
+			reasons = []string{fmt.Sprintf("namespace enforces %s: %s", level, strings.Join(reasons, ", "))}
+		}
+		policyReasons := []string{}
+		admitted := len(policyList) == 0
+		for _, policy := range policyList {
+			violations := podSecurityPolicyViolations(&policy.Spec, podSpec)
+			if len(violations) == 0 {
+				admitted = true
+				break
+			}
+			policyReasons = append(policyReasons, fmt.Sprintf("policy %s: %s", policy.Name, strings.Join(violations, ", ")))
+		}
+		if !admitted {
+			reasons = append(reasons, policyReasons...)
+		}
+		if len(reasons) > 0 {
+			rejected = append(rejected, fmt.Sprintf("%s would be rejected:\n    %s", assetKey(asset), strings.Join(reasons, "\n    ")))
+		}
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+	sort.Strings(rejected)
+	return validationError(fmt.Errorf("pod security preflight failed:\n  %s", strings.Join(rejected, "\n  ")))
+}
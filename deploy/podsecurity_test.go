@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+)
+
+func TestPodSecurityStandards(t *testing.T) {
+	req := require.New(t)
+	privileged := true
+	podSpec := &v1.PodSpec{
+		Containers: []v1.Container{{
+			Name:            "app",
+			SecurityContext: &v1.SecurityContext{Privileged: &privileged},
+		}},
+		Volumes: []v1.Volume{{Name: "docker", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/var/run/docker.sock"}}}},
+	}
+	req.Empty(podSecurityStandardViolations("privileged", podSpec))
+	req.Len(podSecurityStandardViolations("baseline", podSpec), 2)
+
+	noEscalation, nonRoot := false, true
+	podSpec = &v1.PodSpec{
+		SecurityContext: &v1.PodSecurityContext{RunAsNonRoot: &nonRoot},
+		Containers: []v1.Container{{
+			Name: "app",
+			SecurityContext: &v1.SecurityContext{
+				AllowPrivilegeEscalation: &noEscalation,
+				Capabilities:             &v1.Capabilities{Drop: []v1.Capability{"ALL"}},
+			},
+		}},
+		Volumes: []v1.Volume{{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{}}}},
+	}
+	req.Empty(podSecurityStandardViolations("restricted", podSpec))
+	// A volume without a source is an emptyDir
+	podSpec.Volumes = append(podSpec.Volumes, v1.Volume{Name: "scratch"})
+	req.Empty(podSecurityStandardViolations("restricted", podSpec))
+	podSpec.Containers = append(podSpec.Containers, v1.Container{Name: "sidecar"})
+	req.Len(podSecurityStandardViolations("restricted", podSpec), 2)
+}
+
+func TestPodSecurityPolicy(t *testing.T) {
+	req := require.New(t)
+	policy := &v1beta1.PodSecurityPolicySpec{
+		Volumes:   []v1beta1.FSType{v1beta1.ConfigMap, v1beta1.Secret},
+		HostPorts: []v1beta1.HostPortRange{{Min: 8000, Max: 9000}},
+	}
+	podSpec := &v1.PodSpec{
+		Containers: []v1.Container{{
+			Name:  "app",
+			Ports: []v1.ContainerPort{{ContainerPort: 80, HostPort: 8080}},
+		}},
+		Volumes: []v1.Volume{{Name: "tls", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "tls"}}}},
+	}
+	req.Empty(podSecurityPolicyViolations(policy, podSpec))
+	policy.Volumes = append(policy.Volumes, v1beta1.EmptyDir)
+	podSpec.Volumes = append(podSpec.Volumes, v1.Volume{Name: "scratch"})
+	req.Empty(podSecurityPolicyViolations(policy, podSpec))
+	policy.Volumes = policy.Volumes[:2]
+	podSpec.Volumes = podSpec.Volumes[:1]
+	podSpec.HostNetwork = true
+	podSpec.Volumes = append(podSpec.Volumes, v1.Volume{Name: "cache", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}})
+	podSpec.Containers[0].Ports[0].HostPort = 80
+	req.Equal([]string{
+		"host network is not allowed",
+		`volume "cache" of type emptyDir is not allowed`,
+		`container "app": host port 80 is not allowed`,
+	}, podSecurityPolicyViolations(policy, podSpec))
+}
+
+func TestSkipPreflight(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/api/v1/namespaces/web", `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"web","labels":{"pod-security.kubernetes.io/enforce":"baseline"}}}`)
+	asset, err := parseAsset("web.yml", []byte("apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: web\nspec:\n  template:\n    spec:\n      hostNetwork: true\n      containers:\n      - name: web\n        image: nginx\n"))
+	req.Nil(err)
+	p := &Project{
+		kubeClient:    kubeClient,
+		config:        &appConfig{},
+		projectConfig: &ProjectConfig{Namespace: "web"},
+		services:      []*Asset{asset},
+	}
+	err = p.preflight()
+	req.Error(err)
+	req.Equal(ErrorTypeValidation, classifyError(err))
+	req.Contains(err.Error(), "uses host namespaces")
+
+	p.config.skipPreflight = true
+	req.Nil(p.preflight())
+}
@@ -0,0 +1,164 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ResourcePolicy sets how the resources matching Resources are applied. A
+// failure aborts the release by default, continue applies the remaining
+// resources and fails at the end, rollback restores everything this run
+// applied. Optional resources only warn when they fail.
+type ResourcePolicy struct {
+	Resources  []string `yaml:"resources"`
+	Retries    int      `yaml:"retries"`
+	RetryDelay int      `yaml:"retry_delay"`
+	OnFailure  string   `yaml:"on_failure"`
+	Optional   bool     `yaml:"optional"`
+}
+
+var defaultResourcePolicy = &ResourcePolicy{OnFailure: "abort"}
+
+// assignPolicies gives each asset the first policy matching it
+func (p *Project) assignPolicies() error {
+	filters := []resourceFilter{}
+	for _, policy := range p.projectConfig.ResourcePolicies {
+		filter, err := parseResourceFilter(strings.Join(policy.Resources, ","))
+		if err != nil {
+			return fmt.Errorf("resource policy: %s", err.Error())
+		}
+		switch policy.OnFailure {
+		case "":
+			policy.OnFailure = "abort"
+		case "abort", "continue", "rollback":
+		default:
+			return fmt.Errorf("resource policy for %s: unknown on_failure %q, expected abort, continue or rollback", strings.Join(policy.Resources, ","), policy.OnFailure)
+		}
+		filters = append(filters, filter)
+	}
+	for _, asset := range p.assets() {
+		for i, filter := range filters {
+			if filter.matches(asset) {
+				asset.policy = p.projectConfig.ResourcePolicies[i]
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (asset *Asset) resourcePolicy() *ResourcePolicy {
+	if asset.policy == nil {
+		return defaultResourcePolicy
+	}
+	return asset.policy
+}
+
+// groupPolicy is the strictest policy of the assets of group, for failures
+// of the group as a whole: rollback over abort over continue, and optional
+// only when all of them are
+func groupPolicy(group int, assets []*Asset) *ResourcePolicy {
+	members := []*Asset{}
+	for _, asset := range assets {
+		if asset.group == group {
+			members = append(members, asset)
+		}
+	}
+	return strictestPolicy(members)
+}
+
+func strictestPolicy(assets []*Asset) *ResourcePolicy {
+	strictness := map[string]int{"continue": 0, "abort": 1, "rollback": 2}
+	result := &ResourcePolicy{OnFailure: "continue", Optional: true}
+	for _, asset := range assets {
+		policy := asset.resourcePolicy()
+		if strictness[policy.OnFailure] > strictness[result.OnFailure] {
+			result.OnFailure = policy.OnFailure
+		}
+		result.Optional = result.Optional && policy.Optional
+	}
+	return result
+}
+
+func (p *Project) rollbackEnabled() bool {
+	for _, policy := range p.projectConfig.ResourcePolicies {
+		if policy.OnFailure == "rollback" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Project) applyWithRetries(asset *Asset, apply func(asset *Asset) error) error {
+	policy := asset.resourcePolicy()
+	delay := time.Duration(policy.RetryDelay) * time.Second
+	if delay <= 0 {
+		delay = 5 * time.Second
+	}
+	err := apply(asset)
+	for attempt := 1; err != nil && attempt <= policy.Retries; attempt++ {
+		p.printer.ErrPrintf(ColorPurple, "====> %s, retrying %d/%d in %s\n", err.Error(), attempt, policy.Retries, delay)
+		p.sleep(delay)
+		err = apply(asset)
+	}
+	return err
+}
+
+// rollbackSnapshot is a resource as it was before this run applied it, live
+// is nil when it didn't exist
+type rollbackSnapshot struct {
+	asset *Asset
+	name  string
+	live  interface{}
+}
+
+func (p *Project) takeSnapshot(asset *Asset) (*rollbackSnapshot, error) {
+	snapshot := &rollbackSnapshot{asset: asset, name: asset.ResourceData.(Meta).GetName()}
+	live, err := getResource(p.clientFor(asset), p.plugins, asset.Kind, snapshot.name, asset.Namespace())
+	if err != nil && !isResourceNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		snapshot.live = live
+	}
+	return snapshot, nil
+}
+
+// rollback restores the snapshots newest first, deleting the resources this
+// run created
+func (p *Project) rollback(snapshots []*rollbackSnapshot) error {
+	failures := []string{}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot := snapshots[i]
+		asset := snapshot.asset
+		namespace := asset.Namespace()
+		kubeClient := p.clientFor(asset)
+		var err error
+		if snapshot.live == nil {
+			p.printer.Printf(ColorRed, "Rolling back %s %q from namespace %q by deleting it\n", asset.Kind, snapshot.name, namespace)
+			err = destroyResource(kubeClient, p.plugins, asset.Kind, snapshot.name, namespace)
+			if isResourceNotExist(err) {
+				err = nil
+			}
+		} else {
+			p.printer.Printf(ColorYellow, "Rolling back %s %q from namespace %q\n", asset.Kind, snapshot.name, namespace)
+			var resourceVersion string
+			resourceVersion, err = getResourceVersion(kubeClient, p.plugins, asset.Kind, snapshot.name, namespace)
+			if err == nil {
+				snapshot.live.(Meta).SetResourceVersion(resourceVersion)
+				err = updateResource(kubeClient, p.plugins, asset.Kind, snapshot.name, namespace, snapshot.live)
+			}
+		}
+		if err != nil {
+			p.printer.ErrPrintf(ColorRed, "====> %s\n", err.Error())
+			failures = append(failures, assetKey(asset)+": "+err.Error())
+			continue
+		}
+		p.printer.Println(ColorGreen, "====> Success")
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("rollback failed for %d resources:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourcePolicies(t *testing.T) {
+	req := require.New(t)
+	rootFolder, err := ioutil.TempDir("", "imladris-policy")
+	req.Nil(err)
+	defer os.RemoveAll(rootFolder)
+	p := &Project{
+		config: &appConfig{},
+		projectConfig: &ProjectConfig{
+			RootFolder: rootFolder,
+			ResourcePolicies: []*ResourcePolicy{
+				{Resources: []string{"configmap/monitor"}, Optional: true},
+				{Resources: []string{"configmap/flaky"}, Retries: 2, RetryDelay: 1},
+				{Resources: []string{"configmap/broken"}, OnFailure: "continue"},
+			},
+		},
+	}
+	for _, name := range []string{"monitor", "flaky", "broken", "web"} {
+		asset, err := parseAsset(name+".yml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "+name+"\n"))
+		req.Nil(err)
+		p.resources = append(p.resources, asset)
+	}
+	req.Nil(p.assignPolicies())
+	req.Equal("abort", p.resources[1].resourcePolicy().OnFailure)
+	req.Equal(defaultResourcePolicy, p.resources[3].resourcePolicy())
+
+	attempts := make(map[string]int)
+	err = p.applyAssets(func(asset *Asset) error {
+		name := asset.ResourceData.(Meta).GetName()
+		attempts[name]++
+		if name == "web" || (name == "flaky" && attempts[name] > 2) {
+			return nil
+		}
+		return errors.New(name + " failed")
+	})
+	req.Error(err)
+	req.Contains(err.Error(), "1 of 4 resources failed")
+	req.Contains(err.Error(), "configmap/broken")
+	req.Equal(map[string]int{"monitor": 1, "flaky": 3, "broken": 1, "web": 1}, attempts)
+
+	p.projectConfig.ResourcePolicies[2].OnFailure = "retry"
+	req.Error(p.assignPolicies())
+}
+
+// newPolicyProject applies configmaps and deployments straight to a fake
+// cluster, failing the ones named broken
+func newPolicyProject(t *testing.T, manifests ...string) (*Project, *fakeCluster, func(asset *Asset) error) {
+	cluster, kubeClient := newFakeCluster(t)
+	out := &bytes.Buffer{}
+	p := &Project{
+		kubeClient:    kubeClient,
+		config:        &appConfig{timeout: time.Minute},
+		projectConfig: &ProjectConfig{RootFolder: t.TempDir()},
+		printer:       newPrinter(out, out),
+	}
+	for _, manifest := range manifests {
+		asset, err := parseAsset("asset.yml", []byte(manifest))
+		require.Nil(t, err)
+		p.resources = append(p.resources, asset)
+	}
+	apply := func(asset *Asset) error {
+		name := asset.ResourceData.(Meta).GetName()
+		if name == "broken" {
+			return errors.New("broken is broken")
+		}
+		data, err := json.Marshal(asset.ResourceData)
+		require.Nil(t, err)
+		path := "/api/v1/namespaces/web/configmaps/" + name
+		if asset.Kind == "deployment" {
+			path = "/apis/extensions/v1beta1/namespaces/web/deployments/" + name
+		}
+		cluster.add(path, string(data))
+		return nil
+	}
+	return p, cluster, apply
+}
+
+func configMapColor(cluster *fakeCluster, name string) interface{} {
+	object := cluster.get("/api/v1/namespaces/web/configmaps/" + name)
+	if object == nil {
+		return nil
+	}
+	return object["data"].(map[string]interface{})["color"]
+}
+
+func TestPolicyRollback(t *testing.T) {
+	req := require.New(t)
+	p, cluster, apply := newPolicyProject(t,
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: web\ndata:\n  color: green\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: new\n  namespace: web\ndata:\n  color: green\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: broken\n  namespace: web\n",
+	)
+	cluster.add("/api/v1/namespaces/web/configmaps/web", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web"},"data":{"color":"blue"}}`)
+	p.projectConfig.ResourcePolicies = []*ResourcePolicy{{Resources: []string{"configmap/*"}, OnFailure: "rollback"}}
+	req.Nil(p.assignPolicies())
+
+	err := p.applyAssets(apply)
+	req.Error(err)
+	req.Contains(err.Error(), "configmap/broken failed and the release was rolled back")
+	// Updated resources get their previous version back, created ones go
+	req.Equal("blue", configMapColor(cluster, "web"))
+	req.Nil(configMapColor(cluster, "new"))
+	req.Len(cluster.requested("DELETE", "/api/v1/namespaces/web/configmaps/new"), 1)
+
+	// A failed rollback is reported along with the failure
+	cluster.reject = func(method, path string) *fakeStatus {
+		if method == "PUT" {
+			return &fakeStatus{http.StatusForbidden, "Forbidden", "configmaps is forbidden"}
+		}
+		return nil
+	}
+	err = p.applyAssets(apply)
+	req.Error(err)
+	req.Contains(err.Error(), "broken is broken, then rollback failed for 1 resources")
+	req.Equal("green", configMapColor(cluster, "web"))
+	req.Nil(configMapColor(cluster, "new"))
+}
+
+func TestGroupFailurePolicy(t *testing.T) {
+	req := require.New(t)
+	p, cluster, apply := newPolicyProject(t,
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: web\ndata:\n  color: green\n",
+		"apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: api\n  namespace: web\nspec:\n  replicas: 1\n",
+	)
+	cluster.add("/api/v1/namespaces/web/configmaps/web", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web"},"data":{"color":"blue"}}`)
+	p.config.timeout = time.Second
+	// Polled rather than watched, the deployment is read until the timeout
+	cluster.reject = func(method, path string) *fakeStatus {
+		if method == "WATCH" {
+			return &fakeStatus{http.StatusForbidden, "Forbidden", "deployments is forbidden: cannot watch"}
+		}
+		return nil
+	}
+	p.projectConfig.Groups = []*ResourceGroup{{Name: "backend", Resources: []string{"configmap/web", "deployment/api"}, Wait: true}}
+	p.projectConfig.ResourcePolicies = []*ResourcePolicy{
+		{Resources: []string{"deployment/api"}, OnFailure: "rollback"},
+		{Resources: []string{"configmap/web"}, OnFailure: "continue"},
+	}
+	req.Nil(p.assignGroups())
+	req.Nil(p.assignPolicies())
+
+	// The deployment never becomes ready, the group wait fails the release
+	err := p.applyAssets(apply)
+	req.Error(err)
+	req.Contains(err.Error(), "group backend failed and the release was rolled back")
+	req.Equal("blue", configMapColor(cluster, "web"))
+	req.Nil(cluster.get("/apis/extensions/v1beta1/namespaces/web/deployments/api"))
+
+	// The strictest policy of the group applies to it
+	p.projectConfig.ResourcePolicies[0].OnFailure = "abort"
+	err = p.applyAssets(apply)
+	req.Error(err)
+	req.Contains(err.Error(), "timeout while waiting for deployment api")
+	req.Equal("green", configMapColor(cluster, "web"))
+
+	cluster.add("/api/v1/namespaces/web/configmaps/web", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web"},"data":{"color":"blue"}}`)
+	p.projectConfig.ResourcePolicies[0].OnFailure = "continue"
+	err = p.applyAssets(apply)
+	req.Error(err)
+	req.Contains(err.Error(), "1 of 2 resources failed")
+	req.Contains(err.Error(), "group backend: ")
+	req.Equal("green", configMapColor(cluster, "web"))
+}
@@ -0,0 +1,149 @@
+package deploy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Precondition is something owned by another team or project that has to
+// exist before this project is deployed. Exactly one of Service, CRD,
+// ConfigMap or URL is set.
+type Precondition struct {
+	Service   string `yaml:"service"`
+	CRD       string `yaml:"crd"`
+	ConfigMap string `yaml:"configmap"`
+	Key       string `yaml:"key"`
+	Value     string `yaml:"value"`
+	URL       string `yaml:"url"`
+	Status    int    `yaml:"status"`
+}
+
+func (c *Precondition) String() string {
+	switch {
+	case c.Service != "":
+		return "service " + c.Service
+	case c.CRD != "":
+		return "custom resource definition " + c.CRD
+	case c.ConfigMap != "" && c.Value != "":
+		return fmt.Sprintf("configmap %s with %s=%q", c.ConfigMap, c.Key, c.Value)
+	case c.ConfigMap != "":
+		return fmt.Sprintf("configmap %s with %s set", c.ConfigMap, c.Key)
+	default:
+		return "url " + c.URL
+	}
+}
+
+func (p *Project) waitForPreconditions() error {
+	if len(p.projectConfig.WaitFor) == 0 {
+		return nil
+	}
+	deadline := time.Now().Add(p.config.timeout)
+	for _, precondition := range p.projectConfig.WaitFor {
+		p.printer.Printf(ColorYellow, "==> Waiting for %s\n", precondition)
+		for {
+			ready, err := p.checkPrecondition(precondition)
+			if err != nil {
+				return err
+			}
+			if ready {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timeout waiting for %s", precondition)
+			}
+			time.Sleep(2 * time.Second)
+		}
+		p.printer.Println(ColorGreen, "====> Ready")
+	}
+	return nil
+}
+
+// checkPrecondition reports whether the precondition holds. Missing objects
+// are not errors, they are what we are waiting for.
+func (p *Project) checkPrecondition(precondition *Precondition) (bool, error) {
+	switch {
+	case precondition.Service != "":
+		namespace, name := p.splitNamespacedName(precondition.Service)
+		_, err := p.kubeClient.Core().Services(namespace).Get(name, apiv1.GetOptions{})
+		return p.preconditionResult(err)
+	case precondition.CRD != "":
+		return p.crdEstablished(precondition.CRD)
+	case precondition.ConfigMap != "":
+		namespace, name := p.splitNamespacedName(precondition.ConfigMap)
+		configMap, err := p.kubeClient.Core().ConfigMaps(namespace).Get(name, apiv1.GetOptions{})
+		ready, err := p.preconditionResult(err)
+		if !ready {
+			return false, err
+		}
+		value, ok := configMap.Data[precondition.Key]
+		if precondition.Value == "" {
+			return ok && value != "", nil
+		}
+		return value == precondition.Value, nil
+	case precondition.URL != "":
+		return checkURLStatus(precondition.URL, precondition.Status), nil
+	default:
+		return false, fmt.Errorf("empty precondition in wait_for")
+	}
+}
+
+func (p *Project) preconditionResult(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if isResourceNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// splitNamespacedName accepts namespace/name or a bare name in the project
+// namespace
+func (p *Project) splitNamespacedName(value string) (string, string) {
+	pieces := strings.SplitN(value, "/", 2)
+	if len(pieces) == 2 {
+		return pieces[0], pieces[1]
+	}
+	return p.projectConfig.Namespace, value
+}
+
+// crdEstablished checks discovery, since a CRD only serves its resource once
+// it is established. name is the CRD name, e.g. certificates.certmanager.k8s.io
+func (p *Project) crdEstablished(name string) (bool, error) {
+	pieces := strings.SplitN(name, ".", 2)
+	if len(pieces) != 2 {
+		return false, fmt.Errorf("invalid custom resource definition name %q, expected <plural>.<group>", name)
+	}
+	resourceLists, err := p.kubeClient.Discovery().ServerResources()
+	if err != nil && len(resourceLists) == 0 {
+		return false, err
+	}
+	for _, resourceList := range resourceLists {
+		if !strings.HasPrefix(resourceList.GroupVersion, pieces[1]+"/") {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			if resource.Name == pieces[0] {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func checkURLStatus(url string, status int) bool {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	response, err := client.Get(url)
+	if err != nil {
+		return false
+	}
+	response.Body.Close()
+	return response.StatusCode == status
+}
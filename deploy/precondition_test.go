@@ -0,0 +1,35 @@
+package deploy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreconditionURL(t *testing.T) {
+	req := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	req.True(checkURLStatus(server.URL+"/healthz", 0))
+	req.False(checkURLStatus(server.URL+"/ready", 0))
+	req.True(checkURLStatus(server.URL+"/ready", http.StatusServiceUnavailable))
+
+	p := &Project{projectConfig: &ProjectConfig{Namespace: "app"}}
+	ready, err := p.checkPrecondition(&Precondition{URL: server.URL + "/healthz"})
+	req.NoError(err)
+	req.True(ready)
+	namespace, name := p.splitNamespacedName("infra/postgres")
+	req.Equal("infra", namespace)
+	req.Equal("postgres", name)
+	namespace, name = p.splitNamespacedName("postgres")
+	req.Equal("app", namespace)
+	req.Equal("postgres", name)
+}
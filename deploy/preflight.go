@@ -0,0 +1,20 @@
+package deploy
+
+// preflight runs the cluster checks that can reject a deploy before any
+// resource is applied. With -skip-preflight they only warn, for when the
+// checks get a cluster wrong, e.g. a node pool scaled to zero.
+func (p *Project) preflight() error {
+	p.capacityPreflight()
+	p.estimateCost()
+	for _, check := range []func() error{p.podSecurityPreflight, p.schedulingPreflight} {
+		err := check()
+		if err != nil && p.config.skipPreflight {
+			p.printer.ErrPrintf(ColorYellow, "Warning: %s\n", err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+type Color string
+
+const (
+	colorReset  Color = "\u001B[0m"
+	ColorBlack  Color = "\u001B[30m"
+	ColorRed    Color = "\u001B[31m"
+	ColorGreen  Color = "\u001B[32m"
+	ColorYellow Color = "\u001B[33m"
+	ColorBlue   Color = "\u001B[34m"
+	ColorPurple Color = "\u001B[35m"
+	ColorCyan   Color = "\u001B[36m"
+	ColorWhite  Color = "\u001B[37m"
+	ColorGray   Color = "\u001B[90m"
+)
+
+// printer writes what imladris reports, scrubbed of secrets and colored. Each
+// project has its own, so server mode can send a deploy's output to the
+// client that started it and projects deployed side by side don't write into
+// each other. Without writers, or nil, it prints to the process stdout and
+// stderr.
+type printer struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func newPrinter(stdout, stderr io.Writer) *printer {
+	return &printer{stdout: stdout, stderr: stderr}
+}
+
+// Stdout is where commands we run, like scripts and docker, print
+func (pr *printer) Stdout() io.Writer {
+	if pr == nil || pr.stdout == nil {
+		return os.Stdout
+	}
+	return pr.stdout
+}
+
+func (pr *printer) Stderr() io.Writer {
+	if pr == nil || pr.stderr == nil {
+		return os.Stderr
+	}
+	return pr.stderr
+}
+
+func (pr *printer) Println(color Color, v ...interface{}) {
+	printColored(pr.Stdout(), color, scrub(fmt.Sprint(v...)), true)
+}
+
+func (pr *printer) Printf(color Color, format string, v ...interface{}) {
+	printColored(pr.Stdout(), color, scrub(fmt.Sprintf(format, v...)), false)
+}
+
+func (pr *printer) ErrPrintln(color Color, v ...interface{}) {
+	printColored(pr.Stderr(), color, scrub(fmt.Sprint(v...)), true)
+}
+
+func (pr *printer) ErrPrintf(color Color, format string, v ...interface{}) {
+	printColored(pr.Stderr(), color, scrub(fmt.Sprintf(format, v...)), false)
+}
+
+func printColored(w io.Writer, color Color, message string, newline bool) {
+	end := ""
+	if newline {
+		end = "\n"
+	}
+	if colorDisabled() {
+		fmt.Fprint(w, message+end)
+		return
+	}
+	fmt.Fprint(w, string(color)+message+string(colorReset)+end)
+}
+
+// prefixWriter starts every line with a prefix, for the output of projects
+// deployed side by side. Whole lines are written at once, under a lock shared
+// by every prefixWriter, so lines of different projects never mix.
+type prefixWriter struct {
+	w       io.Writer
+	prefix  string
+	pending []byte
+}
+
+var prefixLock sync.Mutex
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix}
+}
+
+func (pw *prefixWriter) Write(data []byte) (int, error) {
+	prefixLock.Lock()
+	defer prefixLock.Unlock()
+	pw.pending = append(pw.pending, data...)
+	for {
+		end := bytes.IndexByte(pw.pending, '\n')
+		if end < 0 {
+			return len(data), nil
+		}
+		_, err := fmt.Fprintf(pw.w, "%s%s", pw.prefix, pw.pending[:end+1])
+		pw.pending = pw.pending[end+1:]
+		if err != nil {
+			return len(data), err
+		}
+	}
+}
+
+// flush writes what is left of an unfinished line
+func (pw *prefixWriter) flush() {
+	prefixLock.Lock()
+	defer prefixLock.Unlock()
+	if len(pw.pending) > 0 {
+		fmt.Fprintf(pw.w, "%s%s\n", pw.prefix, pw.pending)
+		pw.pending = nil
+	}
+}
+
+var noColor bool
+
+// console prints for code that runs outside of a project, the command line
+// itself mostly, to the process stdout and stderr
+var console = &printer{}
+
+func Println(color Color, v ...interface{}) {
+	console.Println(color, v...)
+}
+
+func Printf(color Color, format string, v ...interface{}) {
+	console.Printf(color, format, v...)
+}
+
+func ErrPrintln(color Color, v ...interface{}) {
+	console.ErrPrintln(color, v...)
+}
+
+func ErrPrintf(color Color, format string, v ...interface{}) {
+	console.ErrPrintf(color, format, v...)
+}
+
+// windowsANSITerminal recognizes the windows consoles that understand color
+// escapes, the classic console prints them as garbage
+func windowsANSITerminal() bool {
+	return os.Getenv("WT_SESSION") != "" || os.Getenv("ANSICON") != "" || os.Getenv("ConEmuANSI") == "ON" || os.Getenv("TERM") != ""
+}
+
+// colorDisabled is true with -no-color, IMLADRIS_NO_COLOR=1 or on windows
+// consoles without escapes. NO_COLOR follows https://no-color.org and turns
+// colors off whatever its value.
+func colorDisabled() bool {
+	if noColor || os.Getenv("IMLADRIS_NO_COLOR") == "1" {
+		return true
+	}
+	if runtime.GOOS == "windows" && !windowsANSITerminal() {
+		return true
+	}
+	_, ok := os.LookupEnv("NO_COLOR")
+	return ok
+}
@@ -0,0 +1,41 @@
+package deploy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestColorDisabled(t *testing.T) {
+	if colorDisabled() {
+		t.Skip("colors are off in this environment")
+	}
+	req := require.New(t)
+	noColor = true
+	req.True(colorDisabled())
+	noColor = false
+
+	t.Setenv("NO_COLOR", "")
+	req.True(colorDisabled())
+}
+
+func TestProjectPrinter(t *testing.T) {
+	req := require.New(t)
+	noColor = true
+	defer func() { noColor = false }()
+	isolateSensitiveValues(t)
+	registerSensitive("hunter22")
+	first, second := &bytes.Buffer{}, &bytes.Buffer{}
+	p := &Project{
+		config:        &appConfig{},
+		projectConfig: &ProjectConfig{RootFolder: t.TempDir()},
+		printer:       newPrinter(first, first),
+	}
+	other := &Project{printer: newPrinter(second, second)}
+
+	req.Nil(p.runScripts([]string{"echo from the script"}))
+	other.printer.ErrPrintf(ColorRed, "password %s\n", "hunter22")
+	req.Equal("Running script \"echo from the script\"\nfrom the script\n", first.String())
+	req.Equal("password <redacted>\n", second.String())
+}
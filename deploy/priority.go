@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+
+	scheduling "k8s.io/api/scheduling/v1alpha1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PriorityConfig assigns priority classes to workloads: Default to all of
+// them, Overrides by kind/name pattern. Classes are created when missing.
+type PriorityConfig struct {
+	Default   string               `yaml:"default"`
+	Overrides map[string]string    `yaml:"overrides"`
+	Classes   []*PriorityClassSpec `yaml:"classes"`
+}
+
+type PriorityClassSpec struct {
+	Name          string `yaml:"name"`
+	Value         int32  `yaml:"value"`
+	GlobalDefault bool   `yaml:"global_default"`
+	Description   string `yaml:"description"`
+}
+
+// assignPriorityClasses sets priorityClassName on workloads whose manifest
+// doesn't choose one
+func (p *Project) assignPriorityClasses() error {
+	priority := p.projectConfig.Priority
+	if priority == nil {
+		return nil
+	}
+	patterns := []string{}
+	for pattern := range priority.Overrides {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	filters := []resourceFilter{}
+	for _, pattern := range patterns {
+		filter, err := parseResourceFilter(pattern)
+		if err != nil {
+			return fmt.Errorf("priority: %s", err.Error())
+		}
+		filters = append(filters, filter)
+	}
+	for _, asset := range p.assets() {
+		podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+		if podSpec == nil || podSpec.PriorityClassName != "" {
+			continue
+		}
+		className := priority.Default
+		for i, filter := range filters {
+			if filter.matches(asset) {
+				className = priority.Overrides[patterns[i]]
+				break
+			}
+		}
+		podSpec.PriorityClassName = className
+	}
+	return nil
+}
+
+// ensurePriorityClasses creates the declared classes. Classes are cluster
+// wide and shared between projects, so they are never updated or deleted;
+// a class whose value differs from the project file is only reported.
+// Review environments don't create them either, they use the ones there.
+func (p *Project) ensurePriorityClasses() error {
+	if p.projectConfig.Priority == nil {
+		return nil
+	}
+	priorityClasses := p.kubeClient.SchedulingV1alpha1().PriorityClasses()
+	for _, spec := range p.projectConfig.Priority.Classes {
+		live, err := priorityClasses.Get(spec.Name, apiv1.GetOptions{})
+		if err == nil {
+			if live.Value != spec.Value {
+				p.printer.ErrPrintf(ColorYellow, "Warning: priority class %q has value %d, project file says %d\n", spec.Name, live.Value, spec.Value)
+			}
+			continue
+		}
+		if !isResourceNotExist(err) {
+			return err
+		}
+		if p.config.reviewNamespace != "" {
+			return validationError(fmt.Errorf("priority class %q does not exist, review environments do not create cluster wide classes", spec.Name))
+		}
+		p.printer.Printf(ColorGreen, "Creating priority class %q\n", spec.Name)
+		_, err = priorityClasses.Create(&scheduling.PriorityClass{
+			ObjectMeta:    apiv1.ObjectMeta{Name: spec.Name},
+			Value:         spec.Value,
+			GlobalDefault: spec.GlobalDefault,
+			Description:   spec.Description,
+		})
+		if err != nil {
+			return err
+		}
+		p.printer.Println(ColorGreen, "====> Success")
+	}
+	return nil
+}
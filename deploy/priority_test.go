@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAssignPriorityClasses(t *testing.T) {
+	req := require.New(t)
+	web := &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "web"}}
+	admin := &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "admin"}}
+	admin.Spec.Template.Spec.PriorityClassName = "internal"
+	report := &v1batch.Job{ObjectMeta: apiv1.ObjectMeta{Name: "report"}}
+	p := &Project{
+		projectConfig: &ProjectConfig{Priority: &PriorityConfig{
+			Default:   "production-high",
+			Overrides: map[string]string{"job/*": "batch-low"},
+		}},
+		jobs: []*Asset{{Kind: "job", ResourceData: report}},
+		services: []*Asset{
+			{Kind: "deployment", ResourceData: web},
+			{Kind: "deployment", ResourceData: admin},
+		},
+	}
+	req.NoError(p.assignPriorityClasses())
+	req.Equal("production-high", web.Spec.Template.Spec.PriorityClassName)
+	req.Equal("internal", admin.Spec.Template.Spec.PriorityClassName)
+	req.Equal("batch-low", report.Spec.Template.Spec.PriorityClassName)
+}
+
+func TestEnsurePriorityClassesInReview(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	p := &Project{
+		kubeClient: kubeClient,
+		config:     &appConfig{reviewNamespace: "shop-feature-login"},
+		projectConfig: &ProjectConfig{Priority: &PriorityConfig{
+			Classes: []*PriorityClassSpec{{Name: "batch-low", Value: 100}},
+		}},
+	}
+	err := p.ensurePriorityClasses()
+	req.Error(err)
+	req.Contains(err.Error(), "review environments")
+	req.Empty(cluster.requested("POST", "/apis/scheduling.k8s.io"))
+
+	p.config.reviewNamespace = ""
+	req.Nil(p.ensurePriorityClasses())
+	req.NotNil(cluster.get("/apis/scheduling.k8s.io/v1alpha1/priorityclasses/batch-low"))
+}
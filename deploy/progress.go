@@ -0,0 +1,181 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func assetKey(asset *Asset) string {
+	return asset.Kind + "/" + asset.ResourceData.(Meta).GetName()
+}
+
+// progressFilename is kept in ~/.imladris, out of the project folder, one
+// per project folder, context and namespace
+func (p *Project) progressFilename() string {
+	rootFolder, err := filepath.Abs(p.projectConfig.RootFolder)
+	if err != nil {
+		rootFolder = p.projectConfig.RootFolder
+	}
+	hash := sha256.Sum256([]byte(strings.Join([]string{rootFolder, contextName(p.config), p.projectConfig.Namespace}, "\x00")))
+	return filepath.Join(homeDir(), ".imladris", "progress", p.projectConfig.Name+"-"+hex.EncodeToString(hash[:8])+".json")
+}
+
+func (p *Project) loadProgress() (map[string]string, error) {
+	progress := make(map[string]string)
+	data, err := ioutil.ReadFile(p.progressFilename())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return progress, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, &progress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read progress file %q: %s", p.progressFilename(), err.Error())
+	}
+	return progress, nil
+}
+
+func (p *Project) saveProgress(progress map[string]string) {
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(p.progressFilename()), os.FileMode(0700))
+	}
+	if err == nil {
+		err = ioutil.WriteFile(p.progressFilename(), data, os.FileMode(0600))
+	}
+	if err != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot save progress: %s\n", err.Error())
+	}
+}
+
+func (p *Project) applyAssets(apply func(asset *Asset) error) error {
+	progress := make(map[string]string)
+	if p.config.resume {
+		var err error
+		progress, err = p.loadProgress()
+		if err != nil {
+			return err
+		}
+	}
+	assets := p.assets()
+	var board *dashboard
+	if p.config.dashboard {
+		board = p.startDashboard(assets)
+		defer board.finish()
+	}
+	failures := []string{}
+	snapshots := []*rollbackSnapshot{}
+	rollbackEnabled := p.rollbackEnabled()
+	// fail applies the policy to a failure, and tells whether the release
+	// stops with the returned error
+	fail := func(key string, policy *ResourcePolicy, err error) (bool, error) {
+		if policy.Optional {
+			p.printer.ErrPrintf(ColorYellow, "====> Optional %s failed, continuing: %s\n", key, err.Error())
+			return false, nil
+		}
+		if policy.OnFailure == "rollback" {
+			p.printer.ErrPrintf(ColorRed, "====> %s\n", err.Error())
+			rollbackErr := p.rollback(snapshots)
+			if rollbackErr != nil {
+				return true, fmt.Errorf("%s, then %s", err.Error(), rollbackErr.Error())
+			}
+			return true, fmt.Errorf("%s failed and the release was rolled back: %s", key, err.Error())
+		}
+		if !p.config.keepGoing && policy.OnFailure != "continue" {
+			p.saveProgress(progress)
+			return true, err
+		}
+		p.printer.ErrPrintf(ColorRed, "====> %s\n", err.Error())
+		failures = append(failures, key+": "+err.Error())
+		return false, nil
+	}
+	failed := make(map[*Asset]bool)
+	waitForGroup := func(group int) (bool, error) {
+		err := p.waitForGroup(group, assets)
+		if err == nil {
+			return false, nil
+		}
+		return fail("group "+p.projectConfig.Groups[group].Name, groupPolicy(group, assets), err)
+	}
+	for i, asset := range assets {
+		if i > 0 && assets[i-1].group != asset.group && p.groupWaits(assets[i-1].group) {
+			stop, err := waitForGroup(assets[i-1].group)
+			if stop {
+				return err
+			}
+		}
+		key := assetKey(asset)
+		checksum := asset.Checksum()
+		if p.config.resume && progress[key] == checksum {
+			p.printer.Printf(ColorGray, "Skipping %s, already applied by previous run\n", key)
+			continue
+		}
+		board.setState(asset, "applying")
+		if p.config.waitRollout || p.groupWaits(asset.group) {
+			err := p.recordPods(asset)
+			if err != nil {
+				return err
+			}
+		}
+		if rollbackEnabled {
+			snapshot, err := p.takeSnapshot(asset)
+			if err != nil {
+				return err
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		err := p.applyWithRetries(asset, apply)
+		if err == nil {
+			board.setState(asset, "done")
+			progress[key] = checksum
+			continue
+		}
+		board.setState(asset, "failed")
+		failed[asset] = true
+		stop, err := fail(key, asset.resourcePolicy(), err)
+		if stop {
+			return err
+		}
+	}
+	if len(assets) > 0 && p.groupWaits(assets[len(assets)-1].group) {
+		stop, err := waitForGroup(assets[len(assets)-1].group)
+		if stop {
+			return err
+		}
+	}
+	if p.config.waitRollout && len(assets) > 0 {
+		// What groups did not wait for, the last one or all without groups
+		pending := []*Asset{}
+		for _, asset := range assets {
+			if !failed[asset] && !p.groupWaits(asset.group) {
+				pending = append(pending, asset)
+			}
+		}
+		p.printer.Println(ColorYellow, "==> Waiting for the rollout")
+		err := p.waitForRollout(pending)
+		if err == nil {
+			p.printer.Println(ColorGreen, "====> Ready")
+		} else {
+			stop, err := fail("rollout", strictestPolicy(pending), err)
+			if stop {
+				return err
+			}
+		}
+	}
+	if len(failures) > 0 {
+		p.saveProgress(progress)
+		return newTypedError(ErrorTypePartialFailure, "%d of %d resources failed:\n%s", len(failures), len(assets), strings.Join(failures, "\n"))
+	}
+	err := os.Remove(p.progressFilename())
+	if err != nil && !os.IsNotExist(err) {
+		p.printer.ErrPrintf(ColorRed, "Cannot remove progress file: %s\n", err.Error())
+	}
+	return nil
+}
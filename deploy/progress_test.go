@@ -0,0 +1,59 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeProgress(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	assets := []*Asset{}
+	for _, name := range []string{"a", "b", "c"} {
+		asset, err := parseAsset(name+".yml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "+name+"\n"))
+		req.Nil(err)
+		assets = append(assets, asset)
+	}
+	newProject := func() *Project {
+		return &Project{config: &appConfig{context: "staging", resume: true}, projectConfig: &ProjectConfig{Name: "web", Namespace: "web", RootFolder: root}, resources: assets}
+	}
+	applied := []string{}
+	apply := func(failing string) func(asset *Asset) error {
+		return func(asset *Asset) error {
+			name := asset.ResourceData.(Meta).GetName()
+			if name == failing {
+				return fmt.Errorf("%s failed", name)
+			}
+			applied = append(applied, name)
+			return nil
+		}
+	}
+
+	p := newProject()
+	req.EqualError(p.applyAssets(apply("b")), "b failed")
+	req.Equal([]string{"a"}, applied)
+	filename := p.progressFilename()
+	req.True(strings.HasPrefix(filename, filepath.Join(os.Getenv("HOME"), ".imladris", "progress", "web-")))
+	info, err := os.Stat(filename)
+	req.Nil(err)
+	req.Equal(os.FileMode(0600), info.Mode().Perm())
+	_, err = os.Stat(filepath.Join(root, ".imladris-progress.json"))
+	req.True(os.IsNotExist(err))
+
+	// Another context has its own progress
+	other := newProject()
+	other.config.context = "production"
+	req.NotEqual(filename, other.progressFilename())
+
+	applied = nil
+	req.Nil(newProject().applyAssets(apply("")))
+	req.Equal([]string{"b", "c"}, applied)
+	_, err = os.Stat(filename)
+	req.True(os.IsNotExist(err))
+}
@@ -0,0 +1,328 @@
+package deploy
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// canaryTrackLabel tells the pods of a canary apart from the stable ones.
+// Services select both, so traffic follows the share of pods.
+const canaryTrackLabel = "imladris/track"
+
+// ProgressiveRollout ramps a new image up through a <name>-canary deployment
+// running Steps percent of the replicas, e.g. [10, 50], before the deployment
+// itself is updated. At each step the canary runs Pause seconds, then every
+// check must pass or the canary is removed and the deploy fails.
+type ProgressiveRollout struct {
+	Steps      []int          `yaml:"steps"`
+	Pause      int            `yaml:"pause"`
+	Prometheus string         `yaml:"prometheus"`
+	Checks     []*MetricCheck `yaml:"checks"`
+}
+
+// MetricCheck is a prometheus query whose values must stay within Min and
+// Max, e.g. the error rate or the latency of the canary
+type MetricCheck struct {
+	Name  string   `yaml:"name"`
+	Query string   `yaml:"query"`
+	Min   *float64 `yaml:"min"`
+	Max   *float64 `yaml:"max"`
+}
+
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Result []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// values parses the samples of an instant vector query
+func (r *prometheusResponse) values() ([]float64, error) {
+	values := []float64{}
+	for _, sample := range r.Data.Result {
+		if len(sample.Value) != 2 {
+			return nil, fmt.Errorf("unexpected sample %v", sample.Value)
+		}
+		value, err := strconv.ParseFloat(fmt.Sprint(sample.Value[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// check fails when a value is out of bounds. A query without data fails too,
+// a canary nobody sends traffic to proves nothing.
+func (c *MetricCheck) check(values []float64) error {
+	name := c.Name
+	if name == "" {
+		name = c.Query
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("%s returned no data", name)
+	}
+	for _, value := range values {
+		if c.Max != nil && value > *c.Max {
+			return fmt.Errorf("%s is %g, above %g", name, value, *c.Max)
+		}
+		if c.Min != nil && value < *c.Min {
+			return fmt.Errorf("%s is %g, below %g", name, value, *c.Min)
+		}
+	}
+	return nil
+}
+
+func (c *MetricCheck) evaluate(prometheus string) error {
+	response := &prometheusResponse{}
+	queryURL := strings.TrimRight(prometheus, "/") + "/api/v1/query?query=" + url.QueryEscape(c.Query)
+	err := doJSONRequest("GET", queryURL, nil, nil, response)
+	if err != nil {
+		return err
+	}
+	if response.Status != "success" {
+		return fmt.Errorf("query %q: status %s", c.Query, response.Status)
+	}
+	values, err := response.values()
+	if err != nil {
+		return fmt.Errorf("query %q: %s", c.Query, err.Error())
+	}
+	return c.check(values)
+}
+
+// canaryReplicas splits the replicas of a step, the canary gets at least one
+// and the stable deployment keeps one until the last step
+func canaryReplicas(total int32, percent int) (int32, int32) {
+	canary := (total*int32(percent) + 99) / 100
+	if canary < 1 {
+		canary = 1
+	}
+	stable := total - canary
+	if stable < 1 && percent < 100 {
+		stable = 1
+	}
+	if stable < 0 {
+		stable = 0
+	}
+	return canary, stable
+}
+
+func canaryDeployment(deployment *v1beta1.Deployment) *v1beta1.Deployment {
+	canary := deployment.DeepCopy()
+	canary.Name = deployment.Name + "-canary"
+	canary.ResourceVersion = ""
+	canary.Status = v1beta1.DeploymentStatus{}
+	if canary.Labels == nil {
+		canary.Labels = make(map[string]string)
+	}
+	canary.Labels[canaryTrackLabel] = "canary"
+	if canary.Spec.Template.Labels == nil {
+		canary.Spec.Template.Labels = make(map[string]string)
+	}
+	canary.Spec.Template.Labels[canaryTrackLabel] = "canary"
+	if canary.Spec.Selector != nil {
+		if canary.Spec.Selector.MatchLabels == nil {
+			canary.Spec.Selector.MatchLabels = make(map[string]string)
+		}
+		canary.Spec.Selector.MatchLabels[canaryTrackLabel] = "canary"
+	}
+	return canary
+}
+
+// canaryAsset stands for the canary of a deployment asset, to wait for it
+// and watch its pods like the asset itself
+func canaryAsset(asset *Asset, canary *v1beta1.Deployment) *Asset {
+	copied := *asset
+	copied.ResourceData = canary
+	return &copied
+}
+
+// progressive wraps apply so deployments of the progressive section of the
+// project file ramp up when their images change. Rollbacks go straight
+// through, they restore a release that already ran.
+func (p *Project) progressive(command string, apply func(asset *Asset) error) func(asset *Asset) error {
+	if command == "rollback" || len(p.projectConfig.Progressive) == 0 {
+		return apply
+	}
+	return func(asset *Asset) error {
+		deployment, ok := asset.ResourceData.(*v1beta1.Deployment)
+		if !ok || p.projectConfig.Progressive[deployment.Name] == nil {
+			return apply(asset)
+		}
+		replicas, err := p.rampCanary(asset, deployment, p.projectConfig.Progressive[deployment.Name])
+		if err != nil || replicas == nil {
+			if err == nil {
+				err = apply(asset)
+			}
+			return err
+		}
+		// the stable deployment gets its replicas back before the update, a
+		// pinned replica count would otherwise keep the ramped down one
+		err = p.scaleDeployment(asset, deployment.Name, replicas)
+		if err == nil {
+			err = p.recordPods(asset)
+		}
+		if err == nil {
+			err = apply(asset)
+		}
+		if err == nil {
+			err = p.waitForDeployment(asset)
+		}
+		return p.removeCanary(asset, deployment.Name, replicas, err)
+	}
+}
+
+// rampCanary runs the steps of the rollout and returns the replicas the
+// deployment had. It does nothing on the first deploy and when the images did
+// not change.
+func (p *Project) rampCanary(asset *Asset, deployment *v1beta1.Deployment, rollout *ProgressiveRollout) (*int32, error) {
+	deployments := p.clientFor(asset).Extensions().Deployments(asset.Namespace())
+	live, err := deployments.Get(deployment.Name, apiv1.GetOptions{})
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	liveImages, _ := getResourceImages("deployment", live)
+	desiredImages, _ := getResourceImages("deployment", deployment)
+	if strings.Join(liveImages, ",") == strings.Join(desiredImages, ",") {
+		return nil, nil
+	}
+	total, err := p.rolloutReplicas(asset, deployment, live)
+	if err != nil {
+		return nil, err
+	}
+	canary := canaryDeployment(deployment)
+	for _, percent := range rollout.Steps {
+		if percent <= 0 || percent >= 100 {
+			continue
+		}
+		canaryCount, stableCount := canaryReplicas(total, percent)
+		p.printer.Printf(ColorYellow, "====> Canary of deployment %q at %d%%: %d canary, %d stable replicas\n", deployment.Name, percent, canaryCount, stableCount)
+		err = p.recordPods(canaryAsset(asset, canary))
+		if err == nil {
+			err = p.scaleCanary(asset, canary, canaryCount, stableCount)
+		}
+		if err == nil {
+			err = p.waitForDeployment(canaryAsset(asset, canary))
+		}
+		if err == nil {
+			p.sleep(time.Duration(rollout.Pause) * time.Second)
+			for _, check := range rollout.Checks {
+				err = check.evaluate(rollout.Prometheus)
+				if err != nil {
+					err = newTypedError(ErrorTypeRolloutFailure, "canary of deployment %q failed at %d%%: %s", deployment.Name, percent, err.Error())
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, p.removeCanary(asset, deployment.Name, &total, err)
+		}
+		p.printer.Printf(ColorGreen, "====> Canary of deployment %q passed %d%%\n", deployment.Name, percent)
+	}
+	return &total, nil
+}
+
+// rolloutReplicas is the replica count the steps split: the one of the
+// project file, or else the live one plus the replicas of a canary left by an
+// interrupted rollout, so that the stable deployment does not shrink a little
+// more on every retry
+func (p *Project) rolloutReplicas(asset *Asset, deployment, live *v1beta1.Deployment) (int32, error) {
+	if deployment.Spec.Replicas != nil {
+		return *deployment.Spec.Replicas, nil
+	}
+	total := int32(1)
+	if live.Spec.Replicas != nil {
+		total = *live.Spec.Replicas
+	}
+	deployments := p.clientFor(asset).Extensions().Deployments(asset.Namespace())
+	leftover, err := deployments.Get(deployment.Name+"-canary", apiv1.GetOptions{})
+	if err != nil {
+		if isResourceNotExist(err) {
+			return total, nil
+		}
+		return 0, err
+	}
+	if leftover.Spec.Replicas != nil {
+		return total + *leftover.Spec.Replicas, nil
+	}
+	return total + 1, nil
+}
+
+// scaleCanary creates the canary or, when one is there, replaces all of its
+// spec: a canary left by an interrupted rollout runs an older template, the
+// checks would judge the wrong version
+func (p *Project) scaleCanary(asset *Asset, canary *v1beta1.Deployment, canaryCount, stableCount int32) error {
+	deployments := p.clientFor(asset).Extensions().Deployments(asset.Namespace())
+	live, err := deployments.Get(canary.Name, apiv1.GetOptions{})
+	switch {
+	case err == nil:
+		updated := canary.DeepCopy()
+		updated.ResourceVersion = live.ResourceVersion
+		updated.Spec.Replicas = &canaryCount
+		_, err = deployments.Update(updated)
+	case isResourceNotExist(err):
+		canary.Spec.Replicas = &canaryCount
+		_, err = deployments.Create(canary)
+	}
+	if err != nil {
+		return err
+	}
+	return p.scaleDeployment(asset, strings.TrimSuffix(canary.Name, "-canary"), &stableCount)
+}
+
+func (p *Project) scaleDeployment(asset *Asset, name string, replicas *int32) error {
+	deployments := p.clientFor(asset).Extensions().Deployments(asset.Namespace())
+	deployment, err := deployments.Get(name, apiv1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	deployment.Spec.Replicas = replicas
+	updated, err := deployments.Update(deployment)
+	if err != nil {
+		return err
+	}
+	if name == asset.ResourceData.(Meta).GetName() {
+		p.trackVersion(asset, updated.ResourceVersion)
+	}
+	return nil
+}
+
+// waitForDeployment waits until the deployment of asset is rolled out,
+// failing as soon as a pod started since recordPods cannot run
+func (p *Project) waitForDeployment(asset *Asset) error {
+	return p.waitForAssets([]*Asset{asset}, time.Now().Add(p.config.timeout))
+}
+
+// removeCanary deletes the canary along with its pods and, when the rollout
+// failed, gives the stable deployment its replicas back
+func (p *Project) removeCanary(asset *Asset, name string, replicas *int32, rolloutErr error) error {
+	if rolloutErr != nil {
+		p.printer.ErrPrintf(ColorRed, "====> %s, removing the canary\n", rolloutErr.Error())
+		err := p.scaleDeployment(asset, name, replicas)
+		if err != nil {
+			return fmt.Errorf("%s, then cannot restore deployment %q: %s", rolloutErr.Error(), name, err.Error())
+		}
+	}
+	propagation := apiv1.DeletePropagationBackground
+	deployments := p.clientFor(asset).Extensions().Deployments(asset.Namespace())
+	err := deployments.Delete(name+"-canary", &apiv1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !isResourceNotExist(err) {
+		if rolloutErr != nil {
+			return fmt.Errorf("%s, then cannot remove the canary: %s", rolloutErr.Error(), err.Error())
+		}
+		return err
+	}
+	return rolloutErr
+}
@@ -0,0 +1,195 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCanaryReplicas(t *testing.T) {
+	req := require.New(t)
+	for _, split := range []struct {
+		total   int32
+		percent int
+		canary  int32
+		stable  int32
+	}{
+		{10, 10, 1, 9},
+		{10, 50, 5, 5},
+		{4, 10, 1, 3},
+		{3, 50, 2, 1},
+		{1, 10, 1, 1},
+		{2, 90, 2, 1},
+	} {
+		canary, stable := canaryReplicas(split.total, split.percent)
+		req.Equal(split.canary, canary, "%d%% of %d", split.percent, split.total)
+		req.Equal(split.stable, stable, "%d%% of %d", split.percent, split.total)
+	}
+}
+
+func TestCanaryDeployment(t *testing.T) {
+	req := require.New(t)
+	deployment := &v1beta1.Deployment{}
+	deployment.Name = "web"
+	deployment.ResourceVersion = "42"
+	deployment.Spec.Selector = &apiv1.LabelSelector{MatchLabels: map[string]string{"name": "web"}}
+	deployment.Spec.Template.Labels = map[string]string{"name": "web"}
+	canary := canaryDeployment(deployment)
+	req.Equal("web-canary", canary.Name)
+	req.Empty(canary.ResourceVersion)
+	req.Equal(map[string]string{"name": "web", canaryTrackLabel: "canary"}, canary.Spec.Selector.MatchLabels)
+	req.Equal(map[string]string{"name": "web", canaryTrackLabel: "canary"}, canary.Spec.Template.Labels)
+	req.Equal(map[string]string{"name": "web"}, deployment.Spec.Template.Labels)
+}
+
+func TestMetricCheck(t *testing.T) {
+	req := require.New(t)
+	results := map[string]string{
+		"error_rate": `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"0.002"]}]}}`,
+		"latency":    `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"pod":"a"},"value":[1700000000,"0.3"]},{"metric":{"pod":"b"},"value":[1700000000,"1.7"]}]}}`,
+		"absent":     `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req.Equal("/api/v1/query", r.URL.Path)
+		fmt.Fprint(w, results[r.URL.Query().Get("query")])
+	}))
+	defer server.Close()
+
+	low, high := 0.01, 1.0
+	req.Nil((&MetricCheck{Query: "error_rate", Max: &low}).evaluate(server.URL))
+	err := (&MetricCheck{Name: "p99 latency", Query: "latency", Max: &high}).evaluate(server.URL + "/")
+	req.EqualError(err, "p99 latency is 1.7, above 1")
+	err = (&MetricCheck{Query: "error_rate", Min: &low}).evaluate(server.URL)
+	req.EqualError(err, "error_rate is 0.002, below 0.01")
+	err = (&MetricCheck{Query: "absent", Max: &low}).evaluate(server.URL)
+	req.EqualError(err, "absent returned no data")
+}
+
+// progressiveCluster serves a stable web deployment running image web:1 on
+// replicas, and the canary when canaryReplicas is not zero
+func progressiveCluster(t *testing.T, replicas, canaryReplicas int) (*fakeCluster, *Project, *Asset) {
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.ready = true
+	deployment := func(name string, labels string, replicas int) string {
+		return fmt.Sprintf(`{"apiVersion":"extensions/v1beta1","kind":"Deployment","metadata":{"name":%q,"namespace":"web","labels":{%s}},`+
+			`"spec":{"replicas":%d,"selector":{"matchLabels":{%s}},"template":{"metadata":{"labels":{%s}},"spec":{"containers":[{"name":"web","image":"web:1"}]}}}}`,
+			name, labels, replicas, labels, labels)
+	}
+	cluster.add("/apis/extensions/v1beta1/namespaces/web/deployments/web", deployment("web", `"name":"web"`, replicas))
+	if canaryReplicas > 0 {
+		cluster.add("/apis/extensions/v1beta1/namespaces/web/deployments/web-canary", deployment("web-canary", `"name":"web","imladris/track":"canary"`, canaryReplicas))
+	}
+	asset, err := parseAsset("web.yml", []byte("apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: web\nspec:\n"+
+		"  selector:\n    matchLabels:\n      name: web\n  template:\n    metadata:\n      labels:\n        name: web\n"+
+		"    spec:\n      containers:\n      - name: web\n        image: web:2\n"))
+	require.Nil(t, err)
+	project := &Project{
+		kubeClient:    kubeClient,
+		config:        &appConfig{timeout: time.Minute, sleep: func(time.Duration) {}},
+		projectConfig: &ProjectConfig{},
+		printer:       newPrinter(ioutil.Discard, ioutil.Discard),
+	}
+	return cluster, project, asset
+}
+
+func fakeDeployment(cluster *fakeCluster, name string) (image string, replicas float64) {
+	object := cluster.get("/apis/extensions/v1beta1/namespaces/web/deployments/" + name)
+	if object == nil {
+		return "", 0
+	}
+	spec := object["spec"].(map[string]interface{})
+	container := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0]
+	return container.(map[string]interface{})["image"].(string), spec["replicas"].(float64)
+}
+
+func TestRampCanary(t *testing.T) {
+	req := require.New(t)
+	// an interrupted rollout left a canary running web:1 on 2 of the 4
+	// replicas
+	cluster, project, asset := progressiveCluster(t, 2, 2)
+	checked := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		image, _ := fakeDeployment(cluster, "web-canary")
+		checked = append(checked, image)
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"0"]}]}}`)
+	}))
+	defer server.Close()
+	slept := []time.Duration{}
+	project.config.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	max := 0.01
+	replicas, err := project.rampCanary(asset, asset.ResourceData.(*v1beta1.Deployment), &ProgressiveRollout{
+		Steps:      []int{25, 50},
+		Pause:      30,
+		Prometheus: server.URL,
+		Checks:     []*MetricCheck{{Query: "error_rate", Max: &max}},
+	})
+	req.Nil(err)
+	req.Equal(int32(4), *replicas)
+	req.Equal([]string{"web:2", "web:2"}, checked)
+	req.Equal([]time.Duration{30 * time.Second, 30 * time.Second}, slept)
+	image, canaryReplicas := fakeDeployment(cluster, "web-canary")
+	req.Equal("web:2", image)
+	req.Equal(2.0, canaryReplicas)
+	_, stableReplicas := fakeDeployment(cluster, "web")
+	req.Equal(2.0, stableReplicas)
+
+	req.Nil(project.removeCanary(asset, "web", replicas, nil))
+	req.Nil(cluster.get("/apis/extensions/v1beta1/namespaces/web/deployments/web-canary"))
+	_, stableReplicas = fakeDeployment(cluster, "web")
+	req.Equal(2.0, stableReplicas)
+}
+
+func TestRampCanaryFailsOnCrashingPod(t *testing.T) {
+	req := require.New(t)
+	cluster, project, asset := progressiveCluster(t, 4, 0)
+	// the canary pods crash as soon as the canary is created
+	cluster.reject = func(method, path string) *fakeStatus {
+		if method == "POST" && path == "/apis/extensions/v1beta1/namespaces/web/deployments" {
+			cluster.store("/api/v1/namespaces/web/pods/web-canary-1", map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "web-canary-1", "uid": "1", "labels": map[string]interface{}{"name": "web", canaryTrackLabel: "canary"}},
+				"status": map[string]interface{}{"containerStatuses": []interface{}{map[string]interface{}{
+					"name": "web", "state": map[string]interface{}{"waiting": map[string]interface{}{"reason": "CrashLoopBackOff"}},
+				}}},
+			})
+		}
+		return nil
+	}
+	project.config.sleep = func(time.Duration) { t.Fatal("a crashing canary must not pause") }
+
+	started := time.Now()
+	replicas, err := project.rampCanary(asset, asset.ResourceData.(*v1beta1.Deployment), &ProgressiveRollout{Steps: []int{50}, Pause: 30})
+	req.Nil(replicas)
+	req.Contains(err.Error(), `pod "web-canary-1" container "web" is in CrashLoopBackOff`)
+	req.True(time.Since(started) < 10*time.Second)
+	req.Nil(cluster.get("/apis/extensions/v1beta1/namespaces/web/deployments/web-canary"))
+	_, stableReplicas := fakeDeployment(cluster, "web")
+	req.Equal(4.0, stableReplicas)
+}
+
+func TestRemoveCanary(t *testing.T) {
+	req := require.New(t)
+	cluster, project, asset := progressiveCluster(t, 2, 2)
+	cluster.reject = func(method, path string) *fakeStatus {
+		if method == "DELETE" {
+			return &fakeStatus{http.StatusForbidden, "Forbidden", "deployments are protected"}
+		}
+		return nil
+	}
+	replicas := int32(4)
+	err := project.removeCanary(asset, "web", &replicas, fmt.Errorf("error rate is 0.2, above 0.01"))
+	req.EqualError(err, "error rate is 0.2, above 0.01, then cannot remove the canary: deployments are protected")
+	_, stableReplicas := fakeDeployment(cluster, "web")
+	req.Equal(4.0, stableReplicas)
+
+	cluster.reject = nil
+	req.Nil(project.removeCanary(asset, "web", &replicas, nil))
+	req.Nil(cluster.get("/apis/extensions/v1beta1/namespaces/web/deployments/web-canary"))
+}
@@ -0,0 +1,1414 @@
+package deploy
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Project struct {
+	kubeClient    *kubernetes.Clientset
+	config        *appConfig
+	projectConfig *ProjectConfig
+	projectFolder string
+	resources     []*Asset
+	services      []*Asset
+	jobs          []*Asset
+	excludes      map[string]struct{}
+	backedUp      map[string]bool
+	reconciling   bool
+	rollingBack   bool
+	startedAt     time.Time
+	deployment    deploymentReporter
+	cluster       string
+	skipUnchanged bool
+	caches        map[string]*resourceCache
+	targetClients map[string]*kubernetes.Clientset
+	ci            *ciEnvironment
+	lint          *templateLint
+	partials      map[string]string
+	versions      map[string]string
+	redactionKeys map[string][]byte
+	plugins       pluginRegistry
+	printer       *printer
+	// sources are the manifest and partial files as read, before rendering
+	sources map[string][]byte
+	// podsBefore are the pods of each workload before it was applied, by
+	// versionKey, pod failures only look at the newer ones
+	podsBefore map[string]map[string]bool
+}
+
+type ProjectConfig struct {
+	Name                  string                         `yaml:"name"`
+	Extends               string                         `yaml:"extends"`
+	DependsOn             []string                       `yaml:"depends_on"`
+	RootFolder            string                         `yaml:"root_folder"`
+	Pulls                 []string                       `yaml:"pulls"`
+	InitUp                []string                       `yaml:"init_up"`
+	InitDown              []string                       `yaml:"init_down"`
+	FinalizeUp            []string                       `yaml:"finalize_up"`
+	FinalizeDown          []string                       `yaml:"finalize_down"`
+	Services              []string                       `yaml:"services"`
+	Jobs                  []string                       `yaml:"jobs"`
+	Resources             []string                       `yaml:"resources"`
+	Excludes              []string                       `yaml:"excludes"`
+	Namespace             string                         `yaml:"namespace"`
+	ManifestNamespaces    bool                           `yaml:"manifest_namespaces"`
+	Variables             map[string]string              `yaml:"variables"`
+	ValuesSchema          string                         `yaml:"values_schema"`
+	Envsubst              *EnvsubstConfig                `yaml:"envsubst"`
+	Partials              []string                       `yaml:"partials"`
+	Build                 []*ProjectBuild                `yaml:"build"`
+	Credentials           []*DockerCredential            `yaml:"credentials"`
+	DeleteNamespace       bool                           `yaml:"delete_namespace"`
+	AutoUpdates           []*AutoUpdate                  `yaml:"auto_updates"`
+	AutoUpdateCredentials []*AutoUpdateCredential        `yaml:"auto_update_credentials"`
+	JobPolicies           []*JobPolicy                   `yaml:"job_policies"`
+	DeploymentStatus      *DeploymentStatusConfig        `yaml:"deployment_status"`
+	DeployMarkers         []*DeployMarker                `yaml:"deploy_markers"`
+	Audit                 *AuditConfig                   `yaml:"audit"`
+	Cluster               *ClusterAssertion              `yaml:"cluster"`
+	ConfigMaps            []*DataGenerator               `yaml:"config_maps"`
+	Secrets               []*DataGenerator               `yaml:"secrets"`
+	Certificates          []*CertificateConfig           `yaml:"certificates"`
+	WaitFor               []*Precondition                `yaml:"wait_for"`
+	Outputs               []*ProjectOutput               `yaml:"outputs"`
+	Imports               []*ProjectImport               `yaml:"imports"`
+	TerraformOutputs      []string                       `yaml:"terraform_outputs"`
+	LoadBalancers         map[string]string              `yaml:"load_balancers"`
+	DNS                   []*DNSRecord                   `yaml:"dns"`
+	Strategies            StrategyOverrides              `yaml:"strategies"`
+	PreserveReplicas      bool                           `yaml:"preserve_replicas"`
+	Groups                []*ResourceGroup               `yaml:"groups"`
+	When                  map[string]string              `yaml:"when"`
+	Inject                *InjectConfig                  `yaml:"inject"`
+	CommonEnv             []*CommonEnv                   `yaml:"common_env"`
+	Stamp                 *StampConfig                   `yaml:"stamp"`
+	Affinity              map[string]string              `yaml:"affinity"`
+	Priority              *PriorityConfig                `yaml:"priority"`
+	Plugins               []*KindPlugin                  `yaml:"plugins"`
+	Signing               *SigningConfig                 `yaml:"signing"`
+	DeployWindows         []*DeployWindow                `yaml:"deploy_windows"`
+	ResourcePolicies      []*ResourcePolicy              `yaml:"resource_policies"`
+	Cost                  *CostConfig                    `yaml:"cost"`
+	Changelog             *ChangelogConfig               `yaml:"changelog"`
+	History               *HistoryConfig                 `yaml:"history"`
+	AutoRollback          *AutoRollbackConfig            `yaml:"auto_rollback"`
+	Progressive           map[string]*ProgressiveRollout `yaml:"progressive"`
+}
+
+type ProjectBuild struct {
+	Name       string `yaml:"name"`
+	VarName    string `yaml:"var_name"`
+	Tag        string `yaml:"tag"`
+	From       string `yaml:"from"`
+	Push       bool   `yaml:"push"`
+	PushLatest bool   `yaml:"push_latest"`
+	AutoClean  bool   `yaml:"auto_clean"`
+}
+
+type DockerCredential struct {
+	Host         string `yaml:"host"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+type AutoUpdate struct {
+	Name       string                 `yaml:"name"`
+	Containers []*AutoUpdateContainer `yaml:"containers"`
+}
+
+type AutoUpdateContainer struct {
+	Name       string `yaml:"name"`
+	Credential string `yaml:"credential"`
+}
+
+type AutoUpdateCredential struct {
+	Name         string `yaml:"name"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+type JobPolicy struct {
+	Name         string `yaml:"name"`
+	Recreate     bool   `yaml:"recreate"`
+	UniqueName   bool   `yaml:"unique_name"`
+	HistoryLimit int    `yaml:"history_limit"`
+}
+
+func readProject(kubeClient *kubernetes.Clientset, assetRoot string, config *appConfig) (*Project, error) {
+	p, err := loadProject(kubeClient, assetRoot, config)
+	if err != nil {
+		return nil, projectError(err)
+	}
+	if kubeClient != nil {
+		err = p.assertCluster()
+		if err != nil {
+			return nil, err
+		}
+		err = p.checkVersionSkew()
+		if err != nil {
+			return nil, err
+		}
+		err = p.loadTargetClients()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func loadProject(kubeClient *kubernetes.Clientset, assetRoot string, config *appConfig) (*Project, error) {
+	p := &Project{
+		kubeClient:    kubeClient,
+		config:        config,
+		startedAt:     time.Now(),
+		skipUnchanged: config.skipUnchanged,
+		projectConfig: &ProjectConfig{},
+		printer:       config.printer,
+	}
+	if config.templateLint {
+		p.lint = &templateLint{references: make(map[string][]string)}
+	}
+	variables, err := readValueFiles(config.valueFiles, config.variables)
+	if err != nil {
+		return nil, err
+	}
+	err = p.readProjectConfig(assetRoot, variables)
+	if err != nil {
+		return nil, err
+	}
+	p.resolveNamespace()
+	if p.projectConfig.RootFolder != "" {
+		p.projectConfig.RootFolder = translateFilePath(p.projectFolder, p.projectConfig.RootFolder)
+	} else {
+		p.projectConfig.RootFolder = p.projectFolder
+	}
+	if p.projectConfig.Name == "" {
+		p.projectConfig.Name = defaultProjectName(p.projectConfig.RootFolder)
+	}
+	if p.projectConfig.Variables == nil {
+		p.projectConfig.Variables = make(map[string]string)
+	}
+	for key, value := range variables {
+		p.projectConfig.Variables[key] = value
+	}
+	for key, value := range config.secrets {
+		registerSensitive(value)
+		p.projectConfig.Variables[key] = value
+	}
+	p.projectConfig.Variables["app_var_namespace"] = p.projectConfig.Namespace
+	p.projectConfig.Variables["app_var_home"] = homeDir()
+	p.projectConfig.Variables["app_var_data_dir"] = dataPath
+	p.projectConfig.Variables["app_var_cwd"] = p.projectConfig.RootFolder
+	p.setCIVariables()
+	err = p.readTerraformVariables()
+	if err != nil {
+		return nil, err
+	}
+	// Offline commands like render have no cluster to read imports from, the
+	// values can still be given with -variable
+	if kubeClient != nil {
+		err = p.readImports()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = p.validateValues()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read build info
+	err = p.readBuild()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read excludes
+	err = p.readExcludes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.registerPlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.readPartials()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read assets
+	p.resources, err = p.readAssets(p.projectConfig.RootFolder, p.projectConfig.Resources, "resources/*")
+	if err != nil {
+		return nil, err
+	}
+	p.jobs, err = p.readAssets(p.projectConfig.RootFolder, p.projectConfig.Jobs, "jobs/*")
+	if err != nil {
+		return nil, err
+	}
+	p.services, err = p.readAssets(p.projectConfig.RootFolder, p.projectConfig.Services, "services/*")
+	if err != nil {
+		return nil, err
+	}
+	// Generated config goes first so everything referencing it can start
+	generated, err := p.generateDataAssets()
+	if err != nil {
+		return nil, err
+	}
+	p.resources = append(generated, p.resources...)
+	// Project file settings layered on the manifests, selection last so
+	// everything else sees the full project
+	for _, transform := range []func() error{
+		p.applyLoadBalancerPresets,
+		p.annotateExternalDNS,
+		p.applyStrategyOverrides,
+		p.injectContainers,
+		p.applyCommonEnv,
+		p.stampMetadata,
+		p.applyAffinityPresets,
+		p.assignPriorityClasses,
+		p.applyConditions,
+		p.assignGroups,
+		p.assignPolicies,
+		p.filterGroups,
+		p.filterAssets,
+	} {
+		err = transform()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// resolveNamespace picks the project namespace: -namespace wins over the
+// project file, which wins over the kubeconfig context. With
+// manifest_namespaces, manifests setting metadata.namespace keep it.
+func (p *Project) resolveNamespace() {
+	source := "project file"
+	if p.config.namespace != "" {
+		p.projectConfig.Namespace = p.config.namespace
+		source = "-namespace flag"
+	}
+	if p.config.reviewNamespace != "" {
+		p.projectConfig.Namespace = p.config.reviewNamespace
+		source = "review environment"
+	}
+	if p.projectConfig.Namespace == "" {
+		p.projectConfig.Namespace = contextNamespace(p.config)
+		source = "kubeconfig context"
+	}
+	if p.projectConfig.Namespace == "" {
+		p.projectConfig.Namespace = "default"
+		source = "default"
+	}
+	p.printer.ErrPrintf(ColorBlue, "Using namespace %q from %s\n", p.projectConfig.Namespace, source)
+}
+
+func (p *Project) readProjectConfig(assetRoot string, variables variableMap) error {
+	projectFile := assetRoot
+	p.projectFolder = filepath.Dir(assetRoot)
+	info, err := os.Stat(projectFile)
+	if err != nil && os.IsNotExist(err) {
+		return err
+	}
+	if err != nil || info.IsDir() {
+		projectFile = filepath.Join(assetRoot, "project.yml")
+		p.projectFolder = assetRoot
+		_, err := os.Stat(projectFile)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		}
+	}
+	document, err := readProjectFile(projectFile, variables, nil)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(document)
+	if err != nil {
+		return err
+	}
+	projectConfig := &ProjectConfig{}
+	err = yaml.Unmarshal(data, projectConfig)
+	if err != nil {
+		return fmt.Errorf("unable to read project config: %s", err.Error())
+	}
+	p.projectConfig = projectConfig
+	return nil
+}
+
+func (p *Project) readBuild() error {
+	invalidChar := regexp.MustCompile("[^a-zA-Z0-9_]")
+	underscores := regexp.MustCompile("_+")
+	for _, build := range p.projectConfig.Build {
+		varName := build.VarName
+		if varName == "" {
+			varName = "build_var_" + underscores.ReplaceAllString(invalidChar.ReplaceAllString(build.Name, "_"), "_")
+		}
+		tagName := build.Name + ":" + build.Tag
+		p.projectConfig.Variables[varName] = tagName
+	}
+	return nil
+}
+
+func (p *Project) readExcludes() error {
+	p.excludes = make(map[string]struct{})
+	for _, glob := range p.projectConfig.Excludes {
+		glob = translateFilePath(p.projectConfig.RootFolder, glob)
+		excludes, err := filepath.Glob(glob)
+		if err != nil {
+			return err
+		}
+		for _, exclude := range excludes {
+			p.excludes[exclude] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (p *Project) readAssets(rootFolder string, globs []string, defaultGlob string) ([]*Asset, error) {
+	if len(globs) == 0 {
+		globs = []string{defaultGlob}
+	}
+	assetFiles := []string{}
+	for _, glob := range globs {
+		glob = translateFilePath(rootFolder, glob)
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, err
+		}
+		assetFiles = append(assetFiles, matches...)
+	}
+	assets := []*Asset{}
+	parseErrors := manifestErrors{}
+	for _, filename := range assetFiles {
+		_, ok := p.excludes[filename]
+		if ok {
+			continue
+		}
+		fileAssets, err := p.readAsset(filename)
+		if errs, ok := err.(manifestErrors); ok {
+			parseErrors = append(parseErrors, errs...)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, fileAssets...)
+	}
+	if len(parseErrors) > 0 {
+		return nil, parseErrors
+	}
+	return assets, nil
+}
+
+// renderManifest runs a manifest through text/template, or through ${VAR}
+// substitution for projects in envsubst mode. Lint mode only records the
+// variables and renders nothing.
+func (p *Project) renderManifest(filename string, data []byte) (*bytes.Buffer, error) {
+	if p.projectConfig.Envsubst != nil {
+		return p.envsubst(filename, data)
+	}
+	t, err := p.parseManifest(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	if p.lint != nil {
+		p.lint.record(templateReferences(t))
+		return nil, nil
+	}
+	// Undefined variables are reported as rendering reaches them. Only
+	// {{ index . "optional" }} reads a variable that may be missing,
+	// {{ .optional }} fails even under an if.
+	t = t.Option("missingkey=error")
+	buf := &bytes.Buffer{}
+	err = t.Execute(buf, p.projectConfig.Variables)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readAsset renders a manifest file and decodes its documents one by one,
+// collecting a located error for every document that fails to parse
+func (p *Project) readAsset(filename string) ([]*Asset, error) {
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	p.recordSource(filename, data)
+	buf, err := p.renderManifest(filename, data)
+	if buf == nil || err != nil {
+		return nil, err
+	}
+	assets := []*Asset{}
+	parseErrors := manifestErrors{}
+	documents := newDocumentReader(buf)
+	for {
+		document, err := documents.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		asset, err := parseDocument(filename, document, p.config.strict || offline, p.plugins)
+		if err != nil {
+			parseErrors = append(parseErrors, err)
+			continue
+		}
+		p.placeAsset(asset)
+		asset.registerSecretValues()
+		asset.context = asset.ResourceData.(apiv1.Object).GetAnnotations()[contextAnnotation]
+		assets = append(assets, asset)
+	}
+	if len(parseErrors) > 0 {
+		return nil, parseErrors
+	}
+	return assets, nil
+}
+
+// placeAsset puts the asset in the project namespace, unless manifest
+// namespaces are kept and the manifest has one
+func (p *Project) placeAsset(asset *Asset) {
+	if p.projectConfig.ManifestNamespaces {
+		asset.DefaultNamespace(p.projectConfig.Namespace)
+		return
+	}
+	asset.UpdateNamespace(p.projectConfig.Namespace)
+}
+
+func (p *Project) assets() []*Asset {
+	assets := []*Asset{}
+	assets = append(assets, p.resources...)
+	assets = append(assets, p.jobs...)
+	assets = append(assets, p.services...)
+	if len(p.projectConfig.Groups) > 0 {
+		return orderByGroup(assets, len(p.projectConfig.Groups))
+	}
+	return assets
+}
+
+func (p *Project) findAsset(kind, name string) *Asset {
+	for _, asset := range p.assets() {
+		if asset.Kind == kind && asset.ResourceData.(Meta).GetName() == name {
+			return asset
+		}
+	}
+	return nil
+}
+
+func (p *Project) runScripts(scripts []string) error {
+	for _, script := range scripts {
+		err := requireNetwork(fmt.Sprintf("script %q", script))
+		if err != nil {
+			return err
+		}
+		p.printer.Printf(ColorYellow, "Running script %q\n", script)
+		cmd := shellCommand(script)
+		cmd.Dir = p.projectConfig.RootFolder
+		cmd.Stdout = p.printer.Stdout()
+		cmd.Stderr = p.printer.Stderr()
+		err = cmd.Run()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Project) dockerLogin() error {
+	for _, credential := range p.projectConfig.Credentials {
+		err := dockerLogin(p.printer, p.projectConfig.RootFolder, credential)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Project) Up() error {
+	return p.deploy("up", p.createAsset)
+}
+
+// deploy runs the steps shared by up and update, apply decides what happens
+// to each resource
+func (p *Project) deploy(command string, apply func(asset *Asset) error) error {
+	err := p.recordVersions()
+	if err != nil {
+		return err
+	}
+	err = p.checkGates()
+	if err != nil {
+		return err
+	}
+	err = p.waitForPreconditions()
+	if err != nil {
+		return err
+	}
+	err = p.preflight()
+	if err != nil {
+		return err
+	}
+	if len(p.projectConfig.Pulls) > 0 {
+		err := p.pullImages()
+		if err != nil {
+			return err
+		}
+	}
+	err = p.runScripts(p.projectConfig.InitUp)
+	if err != nil {
+		return err
+	}
+	err = p.dockerLogin()
+	if err != nil {
+		return err
+	}
+	err = p.build()
+	if err != nil {
+		return err
+	}
+	err = p.createNamespaces()
+	if err != nil {
+		return err
+	}
+	err = p.ensurePriorityClasses()
+	if err != nil {
+		return err
+	}
+	err = p.applyAssets(p.progressive(command, apply))
+	if err != nil {
+		return err
+	}
+	err = p.updateDNSRecords()
+	if err != nil {
+		return err
+	}
+	err = p.publishOutputs()
+	if err != nil {
+		return err
+	}
+	p.recordRelease(command)
+	err = p.reportIngressURLs()
+	if err != nil {
+		return err
+	}
+	if p.config.usageReport {
+		err = p.ReportUsage()
+		if err != nil {
+			p.printer.ErrPrintf(ColorYellow, "Warning: cannot report resource usage: %s\n", err.Error())
+		}
+	}
+	return p.runScripts(p.projectConfig.FinalizeUp)
+}
+
+// createNamespaces creates the project namespace, and the namespaces assets
+// go to on the clusters they are routed to
+func (p *Project) createNamespaces() error {
+	err := createNamespace(p.kubeClient, p.projectConfig.Namespace)
+	if err != nil {
+		return err
+	}
+	created := map[string]bool{"/" + p.projectConfig.Namespace: true}
+	for _, asset := range p.assets() {
+		key := asset.context + "/" + asset.Namespace()
+		if asset.Namespace() == "" || created[key] {
+			continue
+		}
+		err = createNamespace(p.clientFor(asset), asset.Namespace())
+		if err != nil {
+			return fmt.Errorf("unable to create namespace %q for %s: %s", asset.Namespace(), assetKey(asset), err.Error())
+		}
+		created[key] = true
+	}
+	return nil
+}
+
+func (p *Project) pullImages() error {
+	imagesToPull := make(map[string]struct{})
+	for _, imageName := range p.projectConfig.Pulls {
+		imagesToPull[imageName] = struct{}{}
+	}
+	for _, resource := range p.resources {
+		err := p.pullImage(resource, imagesToPull)
+		if err != nil {
+			return err
+		}
+	}
+	for _, job := range p.jobs {
+		err := p.pullImage(job, imagesToPull)
+		if err != nil {
+			return err
+		}
+	}
+	for _, service := range p.services {
+		err := p.pullImage(service, imagesToPull)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Project) pullImage(asset *Asset, imagesToPull map[string]struct{}) error {
+	images, err := getResourceImages(asset.Kind, asset.ResourceData)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		pieces := strings.Split(image, ":")
+		imageName := pieces[0]
+		_, ok := imagesToPull[imageName]
+		if ok {
+			err = dockerPull(p.printer, image)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Project) build() error {
+	for _, build := range p.projectConfig.Build {
+		err := p.buildDockerImage(build)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Project) buildDockerImage(build *ProjectBuild) error {
+	buildContext := translateFilePath(p.projectConfig.RootFolder, build.From)
+	tagName := build.Name + ":" + build.Tag
+	err := dockerBuildImage(p.printer, buildContext, tagName)
+	if err != nil {
+		return err
+	}
+	if !build.Push {
+		return nil
+	}
+	return dockerPush(p.printer, tagName, build.PushLatest)
+}
+
+func (p *Project) Down() error {
+	err := p.checkFreeze()
+	if err != nil {
+		return err
+	}
+	err = p.enforceDeployWindow()
+	if err != nil {
+		return err
+	}
+	err = p.runScripts(p.projectConfig.InitDown)
+	if err != nil {
+		return err
+	}
+	for _, service := range p.services {
+		err := p.destroyAsset(service)
+		if err != nil {
+			return err
+		}
+	}
+	for _, job := range p.jobs {
+		err := p.destroyAsset(job)
+		if err != nil {
+			return err
+		}
+	}
+	for _, resource := range p.resources {
+		err := p.destroyAsset(resource)
+		if err != nil {
+			return err
+		}
+	}
+	err = p.deleteOutputs()
+	if err != nil {
+		return err
+	}
+	if p.projectConfig.DeleteNamespace {
+		err = deleteNamespace(p.kubeClient, p.projectConfig.Namespace)
+		if err != nil {
+			return err
+		}
+	}
+	for _, build := range p.projectConfig.Build {
+		if build.AutoClean {
+			err = dockerRmi(p.printer, build.Name+":"+build.Tag)
+			if err != nil {
+				// Bail error here
+				p.printer.ErrPrintln(ColorRed, err)
+			}
+			if build.Push && build.PushLatest {
+				err = dockerRmi(p.printer, build.Name+":latest")
+				if err != nil {
+					// Also Bail error here
+					p.printer.ErrPrintln(ColorRed, err)
+				}
+			}
+		}
+	}
+	return p.runScripts(p.projectConfig.FinalizeDown)
+}
+
+func (p *Project) DownServices() error {
+	err := p.checkFreeze()
+	if err != nil {
+		return err
+	}
+	err = p.enforceDeployWindow()
+	if err != nil {
+		return err
+	}
+	err = p.runScripts(p.projectConfig.InitDown)
+	if err != nil {
+		return err
+	}
+	for _, service := range p.services {
+		err := p.destroyAsset(service)
+		if err != nil {
+			return err
+		}
+	}
+	return p.runScripts(p.projectConfig.FinalizeDown)
+}
+
+func (p *Project) DownJobs() error {
+	err := p.checkFreeze()
+	if err != nil {
+		return err
+	}
+	err = p.enforceDeployWindow()
+	if err != nil {
+		return err
+	}
+	err = p.runScripts(p.projectConfig.InitDown)
+	if err != nil {
+		return err
+	}
+	for _, job := range p.jobs {
+		err := p.destroyAsset(job)
+		if err != nil {
+			return err
+		}
+	}
+	return p.runScripts(p.projectConfig.FinalizeDown)
+}
+
+func (p *Project) Debug() {
+	p.printer.Println(ColorGreen, "=========> Resources  <=========")
+	for _, asset := range p.resources {
+		asset.Debug()
+	}
+	p.printer.Println(ColorGreen, "=========>  Services  <=========")
+	for _, asset := range p.services {
+		asset.Debug()
+	}
+	p.printer.Println(ColorGreen, "=========>    Jobs    <=========")
+	for _, asset := range p.jobs {
+		asset.Debug()
+	}
+}
+
+func (p *Project) createAsset(asset *Asset) error {
+	objectMeta := asset.ResourceData.(Meta)
+	assetName := objectMeta.GetName()
+	namespace := asset.Namespace()
+	if p.hasUniqueJobName(asset) {
+		return p.runUniqueJob(asset)
+	}
+	if p.shouldRecreateJob(asset) {
+		return p.recreateJob(asset)
+	}
+	p.printer.Printf(ColorGreen, "Creating %s %q from namespace %q\n", asset.Kind, assetName, namespace)
+	existed, err := p.liveResources(asset).exists(asset.Kind, assetName)
+	if err != nil {
+		return err
+	}
+	if existed {
+		p.printer.Println(ColorGray, "====> Existed")
+		return nil
+	}
+	p.annotateAsset(asset)
+	err = createResource(p.clientFor(asset), p.plugins, asset.Kind, assetName, namespace, asset.ResourceData)
+	p.resourceApplied("create", asset, err)
+	if err == nil {
+		p.printer.Println(ColorGreen, "====> Success")
+	}
+	return err
+}
+
+// applyAsset creates the resource when it is missing and updates it
+// otherwise, for callers that don't know whether the project was deployed
+func (p *Project) applyAsset(asset *Asset) error {
+	existed, err := p.liveResources(asset).exists(asset.Kind, asset.ResourceData.(Meta).GetName())
+	if err != nil {
+		return err
+	}
+	if existed {
+		return p.updateAsset(asset)
+	}
+	return p.createAsset(asset)
+}
+
+func (p *Project) destroyAsset(asset *Asset) error {
+	objectMeta := asset.ResourceData.(Meta)
+	assetName := objectMeta.GetName()
+	namespace := asset.Namespace()
+	if p.hasUniqueJobName(asset) {
+		p.printer.Printf(ColorRed, "Destroying all runs of job %q from namespace %q\n", assetName, namespace)
+		err := pruneJobRuns(p.clientFor(asset), p.printer, assetName, namespace, 0)
+		p.resourceDestroyed(asset, err)
+		if err == nil {
+			p.printer.Println(ColorGreen, "====> Success")
+		}
+		return err
+	}
+	p.printer.Printf(ColorRed, "Destroying %s %q from namespace %q\n", asset.Kind, assetName, namespace)
+	existed, err := p.liveResources(asset).exists(asset.Kind, assetName)
+	if err != nil {
+		return err
+	}
+	if !existed && asset.Kind != "pod" {
+		p.printer.Println(ColorGray, "====> Not existed")
+		return nil
+	}
+	err = p.backupResource(asset, assetName)
+	if err != nil {
+		return err
+	}
+	err = destroyResource(p.clientFor(asset), p.plugins, asset.Kind, assetName, namespace)
+	if err == nil {
+		err = p.waitForDeletion(asset, assetName)
+	}
+	p.resourceDestroyed(asset, err)
+	if err == nil {
+		p.printer.Println(ColorGreen, "====> Success")
+	}
+	return err
+}
+
+func (p *Project) Update() error {
+	return p.deploy("update", p.updateAsset)
+}
+
+func (p *Project) updateAsset(asset *Asset) error {
+	if p.hasUniqueJobName(asset) {
+		return p.runUniqueJob(asset)
+	}
+	if p.shouldRecreateJob(asset) {
+		return p.recreateJob(asset)
+	}
+	if asset.Kind == "job" {
+		return p.updateJob(asset)
+	}
+	if asset.Kind != "pod" && asset.Kind != "deployment" && asset.Kind != "configmap" && asset.Kind != "secret" && asset.Kind != "service" && asset.Kind != "cronjob" && asset.Kind != "daemonset" && asset.Kind != "statefulset" && asset.Kind != "horizontalpodautoscaler" && p.plugins[asset.Kind] == nil {
+		return nil
+	}
+	objectMeta := asset.ResourceData.(Meta)
+	assetName := objectMeta.GetName()
+	namespace := asset.Namespace()
+	p.printer.Printf(ColorYellow, "Updating %s %q from namespace %q\n", asset.Kind, assetName, namespace)
+	if p.skipUnchanged && p.isUnchanged(asset) {
+		p.printer.Println(ColorGray, "====> Unchanged")
+		return nil
+	}
+	existed, err := p.liveResources(asset).exists(asset.Kind, assetName)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		p.printer.Println(ColorGray, "====> Not existed")
+		return nil
+	}
+	if asset.Kind == "secret" {
+		p.logSecretChanges(asset)
+	}
+	err = p.backupResource(asset, assetName)
+	if err != nil {
+		return err
+	}
+	p.annotateAsset(asset)
+	autoscaled, err := p.autoscaled(asset)
+	if err != nil {
+		return err
+	}
+	resourceVersion, planned := p.versions[versionKey(asset)]
+	for retry := 0; ; retry++ {
+		if !planned {
+			resourceVersion, err = getResourceVersion(p.clientFor(asset), p.plugins, asset.Kind, assetName, namespace)
+			if err != nil {
+				return err
+			}
+		}
+		// Pinned after checksumming, the annotation tracks the manifest
+		if autoscaled {
+			err = p.pinReplicas(asset)
+			if err != nil {
+				return err
+			}
+		}
+		err = p.staggerForQuota(asset)
+		if err != nil {
+			return err
+		}
+		objectMeta.SetResourceVersion(resourceVersion)
+		err = updateResource(p.clientFor(asset), p.plugins, asset.Kind, assetName, namespace, asset.ResourceData)
+		if err == nil {
+			p.resourceApplied("update", asset, nil)
+			p.printer.Println(ColorGreen, "====> Success")
+			return nil
+		}
+		if isImmutableFieldChange(err) {
+			return p.recreateAsset(asset, err)
+		}
+		if !isResourceConflict(err) {
+			p.resourceApplied("update", asset, err)
+			return err
+		}
+		current, err := getResourceVersion(p.clientFor(asset), p.plugins, asset.Kind, assetName, namespace)
+		if err != nil {
+			return err
+		}
+		if p.config.onConflict != "retry" || retry >= 5 {
+			return fmt.Errorf("%s %q was modified by someone else since the deploy started (resource version %s, now %s), aborting to avoid overwriting their changes; re-run update or pass -on-conflict=retry", asset.Kind, assetName, resourceVersion, current)
+		}
+		p.printer.ErrPrintf(ColorPurple, "====> %s %q was modified by someone else since the deploy started (resource version %s, now %s), overwriting their changes:\n", asset.Kind, assetName, resourceVersion, current)
+		p.liveResources(asset).invalidate(asset.Kind, assetName)
+		objectMeta.SetResourceVersion(current)
+		lines, err := p.diffAsset(asset)
+		if err == nil {
+			for _, line := range lines {
+				p.printer.ErrPrintln(ColorPurple, line)
+			}
+		}
+		resourceVersion, planned = current, true
+	}
+}
+
+func versionKey(asset *Asset) string {
+	return asset.context + "/" + asset.Namespace() + "/" + assetKey(asset)
+}
+
+// recordVersions notes the resource version of the live resources when the
+// deploy starts. Updates are sent against it, so edits made by someone else
+// since then are caught, not only the ones racing the update itself.
+func (p *Project) recordVersions() error {
+	p.versions = make(map[string]string)
+	for _, asset := range p.assets() {
+		live, found, err := p.liveResources(asset).get(asset.Kind, asset.ResourceData.(Meta).GetName())
+		if err != nil {
+			return err
+		}
+		if found {
+			p.versions[versionKey(asset)] = live.(Meta).GetResourceVersion()
+		}
+	}
+	return nil
+}
+
+// trackVersion follows the changes imladris makes itself before updating a
+// resource, so they don't count as someone else's
+func (p *Project) trackVersion(asset *Asset, version string) {
+	if _, ok := p.versions[versionKey(asset)]; ok {
+		p.versions[versionKey(asset)] = version
+	}
+}
+
+func (p *Project) liveResources(asset *Asset) *resourceCache {
+	if p.caches == nil {
+		p.caches = make(map[string]*resourceCache)
+	}
+	key := asset.context + "/" + asset.Namespace()
+	cache, ok := p.caches[key]
+	if !ok {
+		cache = newResourceCache(p.clientFor(asset), p.plugins, p.printer, asset.Namespace())
+		p.caches[key] = cache
+	}
+	return cache
+}
+
+// clientFor returns the client for the context an asset is routed to with
+// the imladris/context annotation, or the project client
+func (p *Project) clientFor(asset *Asset) *kubernetes.Clientset {
+	kubeClient, ok := p.targetClients[asset.context]
+	if ok {
+		return kubeClient
+	}
+	return p.kubeClient
+}
+
+func (p *Project) loadTargetClients() error {
+	p.targetClients = make(map[string]*kubernetes.Clientset)
+	for _, asset := range p.assets() {
+		if asset.context == "" {
+			continue
+		}
+		_, ok := p.targetClients[asset.context]
+		if ok {
+			continue
+		}
+		targetConfig := *p.config
+		targetConfig.context = asset.context
+		kubeClient, err := loadKubernetesClient(&targetConfig)
+		if err != nil {
+			return fmt.Errorf("unable to load context %q for %s %q: %s", asset.context, asset.Kind, asset.ResourceData.(Meta).GetName(), err.Error())
+		}
+		err = p.assertTargetCluster(&targetConfig, kubeClient)
+		if err != nil {
+			return err
+		}
+		p.targetClients[asset.context] = kubeClient
+		p.printer.Printf(ColorBlue, "Routing resources annotated with %s=%s to cluster %s\n", contextAnnotation, asset.context, describeCluster(&targetConfig))
+	}
+	return nil
+}
+
+func (p *Project) isUnchanged(asset *Asset) bool {
+	live, found, err := p.liveResources(asset).get(asset.Kind, asset.ResourceData.(Meta).GetName())
+	if err != nil || !found {
+		return false
+	}
+	return live.(apiv1.Object).GetAnnotations()[checksumAnnotation] == asset.Checksum()
+}
+
+func (p *Project) recreateAsset(asset *Asset, updateErr error) error {
+	objectMeta := asset.ResourceData.(Meta)
+	assetName := objectMeta.GetName()
+	namespace := asset.Namespace()
+	if !p.config.forceRecreate {
+		return fmt.Errorf("%s %q cannot be updated in place because an immutable field changed (%s), pass -force-recreate to delete and recreate it", asset.Kind, assetName, updateErr.Error())
+	}
+	if !askConfirmation(p.config, fmt.Sprintf("%s %q has immutable field changes, delete and recreate it?", asset.Kind, assetName)) {
+		return fmt.Errorf("recreating %s %q was cancelled", asset.Kind, assetName)
+	}
+	p.printer.Printf(ColorYellow, "Recreating %s %q from namespace %q\n", asset.Kind, assetName, namespace)
+	err := p.backupBeforeRecreate(asset, assetName)
+	if err != nil {
+		return err
+	}
+	err = destroyResource(p.clientFor(asset), p.plugins, asset.Kind, assetName, namespace)
+	if err != nil {
+		return err
+	}
+	err = p.waitForDeletion(asset, assetName)
+	if err != nil {
+		return err
+	}
+	objectMeta.SetResourceVersion("")
+	err = createResource(p.clientFor(asset), p.plugins, asset.Kind, assetName, namespace, asset.ResourceData)
+	p.resourceApplied("recreate", asset, err)
+	if err == nil {
+		p.printer.Println(ColorGreen, "====> Recreated")
+	}
+	return err
+}
+
+// updateJob recreates a job whose pod template changed when -force-recreate
+// is set, the template of a job can't be updated in place. Without it the
+// job is left as it is, with a warning.
+func (p *Project) updateJob(asset *Asset) error {
+	name := asset.ResourceData.(Meta).GetName()
+	p.printer.Printf(ColorYellow, "Updating job %q from namespace %q\n", name, asset.Namespace())
+	live, found, err := p.liveResources(asset).get(asset.Kind, name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		p.printer.Println(ColorGray, "====> Not existed")
+		return nil
+	}
+	if !jobTemplateChanged(live.(*v1batch.Job), asset.ResourceData.(*v1batch.Job)) {
+		p.printer.Println(ColorGray, "====> Unchanged")
+		return nil
+	}
+	if !p.config.forceRecreate {
+		p.printer.ErrPrintf(ColorYellow, "====> Skipped, the template of job %q changed and can't be updated in place, pass -force-recreate to delete and recreate it\n", name)
+		return nil
+	}
+	return p.recreateAsset(asset, fmt.Errorf("spec.template of a job is immutable"))
+}
+
+// jobTemplateChanged compares what the containers of a job run. The rest of
+// the live template holds defaults the manifest doesn't, env included.
+func jobTemplateChanged(live, desired *v1batch.Job) bool {
+	liveContainers := live.Spec.Template.Spec.Containers
+	desiredContainers := desired.Spec.Template.Spec.Containers
+	if len(liveContainers) != len(desiredContainers) {
+		return true
+	}
+	for i, container := range desiredContainers {
+		current := liveContainers[i]
+		if container.Name != current.Name || container.Image != current.Image ||
+			!reflect.DeepEqual(container.Command, current.Command) ||
+			!reflect.DeepEqual(container.Args, current.Args) ||
+			!reflect.DeepEqual(normalizedEnv(container.Env), normalizedEnv(current.Env)) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizedEnv fills in the api version the api server defaults field
+// references to
+func normalizedEnv(env []v1.EnvVar) []v1.EnvVar {
+	normalized := []v1.EnvVar{}
+	for _, envVar := range env {
+		envVar = *envVar.DeepCopy()
+		if envVar.ValueFrom != nil && envVar.ValueFrom.FieldRef != nil && envVar.ValueFrom.FieldRef.APIVersion == "" {
+			envVar.ValueFrom.FieldRef.APIVersion = "v1"
+		}
+		normalized = append(normalized, envVar)
+	}
+	return normalized
+}
+
+func (p *Project) jobPolicy(name string) *JobPolicy {
+	for _, policy := range p.projectConfig.JobPolicies {
+		if policy.Name == name {
+			return policy
+		}
+	}
+	return nil
+}
+
+func (p *Project) shouldRecreateJob(asset *Asset) bool {
+	if asset.Kind != "job" {
+		return false
+	}
+	policy := p.jobPolicy(asset.ResourceData.(Meta).GetName())
+	return policy != nil && policy.Recreate
+}
+
+func (p *Project) hasUniqueJobName(asset *Asset) bool {
+	if asset.Kind != "job" {
+		return false
+	}
+	policy := p.jobPolicy(asset.ResourceData.(Meta).GetName())
+	return policy != nil && policy.UniqueName
+}
+
+func (p *Project) runUniqueJob(asset *Asset) error {
+	job := asset.ResourceData.(*v1batch.Job)
+	baseName := job.Name
+	namespace := asset.Namespace()
+	policy := p.jobPolicy(baseName)
+	job.Name = uniqueJobName(baseName, asset.data, time.Now())
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[jobRunLabel] = baseName
+	p.annotateAsset(asset)
+	p.printer.Printf(ColorGreen, "Creating job %q as %q from namespace %q\n", baseName, job.Name, namespace)
+	err := createResource(p.clientFor(asset), p.plugins, asset.Kind, job.Name, namespace, job)
+	p.resourceApplied("create", asset, err)
+	// Restore the manifest name so later lookups (down, debug) still match the policy
+	job.Name = baseName
+	if err != nil {
+		return err
+	}
+	p.printer.Println(ColorGreen, "====> Success")
+	if policy.HistoryLimit <= 0 {
+		return nil
+	}
+	return pruneJobRuns(p.clientFor(asset), p.printer, baseName, namespace, policy.HistoryLimit)
+}
+
+func (p *Project) recreateJob(asset *Asset) error {
+	jobName := asset.ResourceData.(Meta).GetName()
+	namespace := asset.Namespace()
+	p.printer.Printf(ColorYellow, "Recreating job %q from namespace %q\n", jobName, namespace)
+	existed, err := p.liveResources(asset).exists(asset.Kind, jobName)
+	if err != nil {
+		return err
+	}
+	if existed {
+		err = p.backupBeforeRecreate(asset, jobName)
+		if err != nil {
+			return err
+		}
+		err = destroyJob(p.clientFor(asset), jobName, namespace)
+		if err != nil && !isResourceNotExist(err) {
+			return err
+		}
+		err = p.waitForDeletion(asset, jobName)
+		if err != nil {
+			return err
+		}
+		err = waitForJobPodsDeletion(p.clientFor(asset), jobName, namespace, p.config.timeout)
+		if err != nil {
+			return err
+		}
+	}
+	p.annotateAsset(asset)
+	err = createResource(p.clientFor(asset), p.plugins, asset.Kind, jobName, namespace, asset.ResourceData)
+	p.resourceApplied("recreate", asset, err)
+	if err == nil {
+		p.printer.Println(ColorGreen, "====> Success")
+	}
+	return err
+}
+
+func (p *Project) AutoUpdate(version string) error {
+	return p.autoUpdate("", version)
+}
+
+// autoUpdate only touches containers running repository when it is set, so
+// a registry push updates the workloads using the pushed image
+func (p *Project) autoUpdate(repository, version string) error {
+	err := p.checkFreeze()
+	if err != nil {
+		return err
+	}
+	err = p.enforceDeployWindow()
+	if err != nil {
+		return err
+	}
+	if version == "" || version == "auto" {
+		p.printer.Println(ColorYellow, "Will automatically search for latest version")
+	} else {
+		p.printer.Printf(ColorYellow, "Autoupdate to %s\n", version)
+	}
+	autoUpdates := make(map[string]*AutoUpdate)
+	for _, autoUpdate := range p.projectConfig.AutoUpdates {
+		autoUpdates[autoUpdate.Name] = autoUpdate
+	}
+	credentials := make(map[string]*AutoUpdateCredential)
+	for _, credential := range p.projectConfig.AutoUpdateCredentials {
+		credentials[credential.Name] = credential
+	}
+	for _, resource := range p.resources {
+		err := p.autoupdateAsset(resource, autoUpdates, credentials, repository, version)
+		if err != nil {
+			return err
+		}
+	}
+	for _, job := range p.jobs {
+		err := p.autoupdateAsset(job, autoUpdates, credentials, repository, version)
+		if err != nil {
+			return err
+		}
+	}
+	for _, service := range p.services {
+		err := p.autoupdateAsset(service, autoUpdates, credentials, repository, version)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Project) autoupdateAsset(asset *Asset, autoUpdates map[string]*AutoUpdate, autoUpdateCredentials map[string]*AutoUpdateCredential, repository, newTag string) error {
+	if asset.Kind != "deployment" {
+		return nil
+	}
+	objectMeta := asset.ResourceData.(Meta)
+	assetName := objectMeta.GetName()
+	namespace := asset.Namespace()
+	autoUpdateInfo, ok := autoUpdates[assetName]
+	if !ok {
+		return nil
+	}
+	p.printer.Printf(ColorYellow, "Autoupdate %s %q from namespace %q\n", asset.Kind, assetName, namespace)
+	existed, err := p.liveResources(asset).exists(asset.Kind, assetName)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		p.printer.Println(ColorGray, "====> Not existed")
+		return nil
+	}
+	deploymentInfo, err := getDeployment(p.clientFor(asset), assetName, namespace)
+	if err != nil {
+		return err
+	}
+	newContainers := make(map[string]string)
+	for _, containerInfo := range autoUpdateInfo.Containers {
+		oldContainer := deploymentInfo.Containers[containerInfo.Name]
+		if oldContainer == nil {
+			p.printer.ErrPrintf(ColorRed, "====> Container not found: %q\n", containerInfo.Name)
+			continue
+		}
+		if repository != "" && !imageFromRepository(oldContainer.Image, repository) {
+			continue
+		}
+		// We only support gcr.io at the moment
+		if !strings.HasPrefix(oldContainer.Image, "gcr.io") && (newTag == "" || newTag == "auto") {
+			p.printer.ErrPrintf(ColorPurple, "====> We only support gcr.io at the moment, skipping container %q (%q)\n", containerInfo.Name, oldContainer.Image)
+			return nil
+		}
+		if newTag == "" || newTag == "auto" {
+			credential := autoUpdateCredentials[containerInfo.Credential]
+			var username, password string
+			if credential != nil {
+				username, password, err = readAutoupdateCredential(p.projectConfig.RootFolder, credential)
+				if err != nil {
+					return err
+				}
+			}
+			newTag, err = findNewImageTag(oldContainer.Image, username, password)
+			if err != nil {
+				return err
+			}
+		}
+		if newTag == oldContainer.Tag {
+			p.printer.Printf(ColorPurple, "====> Same tag %q, skipping container %q (%q)\n", containerInfo.Name, oldContainer.Image)
+			continue
+		}
+		newContainers[oldContainer.Name] = oldContainer.Image + ":" + newTag
+	}
+	if len(newContainers) == 0 {
+		p.printer.Println(ColorGray, "====> No new container found")
+		return nil
+	}
+	err = p.backupResource(asset, assetName)
+	if err != nil {
+		return err
+	}
+	for i, container := range deploymentInfo.Deployment.Spec.Template.Spec.Containers {
+		newImage, ok := newContainers[container.Name]
+		if ok {
+			container.Image = newImage
+			deploymentInfo.Deployment.Spec.Template.Spec.Containers[i] = container
+		}
+	}
+	_, err = p.clientFor(asset).Extensions().Deployments(namespace).Update(deploymentInfo.Deployment)
+	if err == nil {
+		p.printer.Printf(ColorGreen, "====> Updated deployment %q:\n", assetName)
+		for _, containerName := range sortedKeys(newContainers) {
+			p.printer.Printf(ColorGreen, "====> %q to %q\n", containerName, newContainers[containerName])
+		}
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestUpdateConflict(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	path := "/api/v1/namespaces/web/configmaps/web"
+	cluster.add(path, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web"},"data":{"color":"blue"}}`)
+	manifest := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: web\ndata:\n  color: green\n")
+	newProject := func(onConflict string) (*Project, *Asset) {
+		asset, err := parseAsset("web.yml", manifest)
+		req.Nil(err)
+		p := &Project{kubeClient: kubeClient, config: &appConfig{onConflict: onConflict}, projectConfig: &ProjectConfig{}, resources: []*Asset{asset}}
+		req.Nil(p.recordVersions())
+		return p, asset
+	}
+
+	// Edited by someone else after the deploy started
+	p, asset := newProject("abort")
+	cluster.add(path, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web"},"data":{"color":"red"}}`)
+	err := p.updateAsset(asset)
+	req.Error(err)
+	req.Contains(err.Error(), "modified by someone else since the deploy started")
+	req.Equal("red", cluster.get(path)["data"].(map[string]interface{})["color"])
+
+	p, asset = newProject("retry")
+	cluster.add(path, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web"},"data":{"color":"red"}}`)
+	req.Nil(p.updateAsset(asset))
+	req.Equal("green", cluster.get(path)["data"].(map[string]interface{})["color"])
+
+	// Untouched since the deploy started
+	p, asset = newProject("abort")
+	req.Nil(p.updateAsset(asset))
+}
+
+func TestValidateFlags(t *testing.T) {
+	req := require.New(t)
+	req.Nil(validateFlags(&appConfig{onConflict: "abort", output: "text"}))
+	req.Nil(validateFlags(&appConfig{onConflict: "retry", output: "json"}))
+	err := validateFlags(&appConfig{onConflict: "force", output: "text"})
+	req.Error(err)
+	req.Equal(ErrorTypeValidation, classifyError(err))
+	err = validateFlags(&appConfig{onConflict: "abort", output: "jsno"})
+	req.EqualError(err, `unknown -output "jsno", expected text or json`)
+	req.Equal(ErrorTypeValidation, classifyError(err))
+}
+
+func TestUpdateJob(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("HOME", t.TempDir())
+	cluster, kubeClient := newFakeCluster(t)
+	path := "/apis/batch/v1/namespaces/web/jobs/migrate"
+	liveJob := `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"migrate","namespace":"web"},"spec":{"template":{"spec":{"containers":[{"name":"migrate","image":"web:1","terminationMessagePath":"/dev/termination-log"}],"restartPolicy":"Never"}}}}`
+	newProject := func(image string, forceRecreate bool) (*Project, *Asset) {
+		manifest := []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  namespace: web\nspec:\n  template:\n    spec:\n      containers:\n      - name: migrate\n        image: " + image + "\n      restartPolicy: Never\n")
+		asset, err := parseAsset("migrate.yml", manifest)
+		req.Nil(err)
+		config := &appConfig{forceRecreate: forceRecreate, timeout: time.Minute}
+		return &Project{kubeClient: kubeClient, config: config, projectConfig: &ProjectConfig{}, startedAt: time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)}, asset
+	}
+
+	cluster.add(path, liveJob)
+	p, asset := newProject("web:1", false)
+	req.Nil(p.updateAsset(asset))
+	req.Empty(cluster.requested("DELETE", path))
+
+	p, asset = newProject("web:2", false)
+	req.Nil(p.updateAsset(asset))
+	req.Empty(cluster.requested("DELETE", path))
+	req.Equal("web:1", jobImage(cluster.get(path)))
+
+	p, asset = newProject("web:2", true)
+	p.config.yes = true
+	req.Nil(p.updateAsset(asset))
+	req.Equal("web:2", jobImage(cluster.get(path)))
+	backup, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".imladris", "backups", "20170102-030405", "web", "job-migrate.yml"))
+	req.Nil(err)
+	req.Contains(string(backup), "image: web:1")
+}
+
+func TestJobTemplateChanged(t *testing.T) {
+	req := require.New(t)
+	newJob := func(apiVersion string) *v1batch.Job {
+		job := &v1batch.Job{}
+		job.Spec.Template.Spec.Containers = []v1.Container{{
+			Name:  "migrate",
+			Image: "web:1",
+			Env: []v1.EnvVar{
+				{Name: "COLOR", Value: "blue"},
+				{Name: "POD_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{APIVersion: apiVersion, FieldPath: "metadata.name"}}},
+			},
+		}}
+		return job
+	}
+	// the api server defaults the api version of field references
+	req.False(jobTemplateChanged(newJob("v1"), newJob("")))
+	changed := newJob("")
+	changed.Spec.Template.Spec.Containers[0].Env[0].Value = "green"
+	req.True(jobTemplateChanged(newJob("v1"), changed))
+}
+
+func jobImage(job map[string]interface{}) interface{} {
+	spec := job["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	return spec["containers"].([]interface{})[0].(map[string]interface{})["image"]
+}
+
+func TestRecreateJob(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	jobPath := "/apis/batch/v1/namespaces/web/jobs/migrate"
+	podPath := "/api/v1/namespaces/web/pods/migrate-x1"
+	manifest := []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  namespace: web\nspec:\n  template:\n    spec:\n      containers:\n      - name: migrate\n        image: web:1\n      restartPolicy: Never\n")
+	newProject := func() (*Project, *Asset) {
+		asset, err := parseAsset("migrate.yml", manifest)
+		req.Nil(err)
+		config := &appConfig{backupDir: t.TempDir(), timeout: time.Minute}
+		projectConfig := &ProjectConfig{JobPolicies: []*JobPolicy{{Name: "migrate", Recreate: true}}}
+		return &Project{kubeClient: kubeClient, config: config, projectConfig: projectConfig, startedAt: time.Now()}, asset
+	}
+
+	// First deploy, nothing to delete
+	p, asset := newProject()
+	req.Nil(p.createAsset(asset))
+	req.NotNil(cluster.get(jobPath))
+	req.Empty(cluster.requested("DELETE", jobPath))
+
+	// Every later deploy runs the job again, its pods go along
+	cluster.add(podPath, `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"migrate-x1","namespace":"web","labels":{"job-name":"migrate"}}}`)
+	p, asset = newProject()
+	req.Nil(p.updateAsset(asset))
+	req.Len(cluster.requested("DELETE", jobPath), 1)
+	req.Len(cluster.requested("POST", "/apis/batch/v1/namespaces/web/jobs"), 2)
+	req.Nil(cluster.get(podPath))
+	req.NotNil(cluster.get(jobPath))
+}
+
+func TestCreateNamespaces(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	infra, infraClient := newFakeCluster(t)
+	newAsset := func(kind, namespace, context string) *Asset {
+		asset, err := parseAsset("web.yml", []byte("apiVersion: v1\nkind: "+kind+"\nmetadata:\n  name: web\n  namespace: "+namespace+"\n"))
+		req.Nil(err)
+		asset.context = context
+		return asset
+	}
+	p := &Project{
+		kubeClient:    kubeClient,
+		targetClients: map[string]*kubernetes.Clientset{"infra": infraClient},
+		config:        &appConfig{},
+		projectConfig: &ProjectConfig{Namespace: "web"},
+		resources:     []*Asset{newAsset("ConfigMap", "web", ""), newAsset("ConfigMap", "web", "infra")},
+		services:      []*Asset{newAsset("Service", "ingress", "infra")},
+	}
+	req.Nil(p.createNamespaces())
+	req.Equal([]string{"/api/v1/namespaces/web"}, cluster.paths("/api/v1/namespaces"))
+	req.Equal([]string{"/api/v1/namespaces/ingress", "/api/v1/namespaces/web"}, infra.paths("/api/v1/namespaces"))
+}
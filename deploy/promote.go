@@ -0,0 +1,42 @@
+package deploy
+
+import "fmt"
+
+func (p *Project) Promote(release *ReleaseRecord) error {
+	err := p.useReleaseImages(release, "source environment", "Promoting", false)
+	if err != nil {
+		return err
+	}
+	return p.Update()
+}
+
+// useReleaseImages sets every container to the image it ran in release. A
+// container the release never ran is refused, or dropped when going back to
+// the release before it was added.
+func (p *Project) useReleaseImages(release *ReleaseRecord, source, verb string, dropAdded bool) error {
+	for _, asset := range p.assets() {
+		podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+		if podSpec == nil {
+			continue
+		}
+		assetName := asset.ResourceData.(Meta).GetName()
+		containers := podSpec.Containers[:0]
+		for _, container := range podSpec.Containers {
+			image, ok := release.Images[releaseImageKey(asset.Kind, assetName, container.Name)]
+			if !ok && !dropAdded {
+				return fmt.Errorf("%s never ran container %q of %s %q, refusing to %s", source, container.Name, asset.Kind, assetName, verb)
+			}
+			if !ok {
+				p.printer.Printf(ColorYellow, "%s %s %q: removing container %q, %s never ran it\n", verb, asset.Kind, assetName, container.Name, source)
+				continue
+			}
+			if image != container.Image {
+				p.printer.Printf(ColorYellow, "%s %s %q container %q to %q\n", verb, asset.Kind, assetName, container.Name, image)
+			}
+			container.Image = image
+			containers = append(containers, container)
+		}
+		podSpec.Containers = containers
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package deploy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/apps/v1beta1"
+	extensions "k8s.io/api/extensions/v1beta1"
+)
+
+const promoteDaemonSet = "apiVersion: extensions/v1beta1\nkind: DaemonSet\nmetadata:\n  name: agent\n  namespace: web\nspec:\n  template:\n    metadata:\n      labels:\n        app: agent\n    spec:\n      containers:\n      - name: agent\n        image: agent:1\n"
+const promoteStatefulSet = "apiVersion: apps/v1beta1\nkind: StatefulSet\nmetadata:\n  name: db\n  namespace: web\nspec:\n  serviceName: db\n  template:\n    metadata:\n      labels:\n        app: db\n    spec:\n      containers:\n      - name: postgres\n        image: postgres:9.5\n"
+
+func TestUseReleaseImages(t *testing.T) {
+	req := require.New(t)
+	daemonSet, err := parseAsset("agent.yml", []byte(promoteDaemonSet))
+	req.Nil(err)
+	statefulSet, err := parseAsset("db.yml", []byte(promoteStatefulSet))
+	req.Nil(err)
+	p := &Project{projectConfig: &ProjectConfig{}, services: []*Asset{daemonSet, statefulSet}}
+	release := &ReleaseRecord{Images: map[string]string{
+		"daemonset/agent/agent":   "agent:2",
+		"statefulset/db/postgres": "postgres:9.6",
+	}}
+	req.Nil(p.useReleaseImages(release, "staging", "Promoting", false))
+	req.Equal("agent:2", daemonSet.ResourceData.(*extensions.DaemonSet).Spec.Template.Spec.Containers[0].Image)
+	req.Equal("postgres:9.6", statefulSet.ResourceData.(*v1beta1.StatefulSet).Spec.Template.Spec.Containers[0].Image)
+
+	// Production only gets what staging ran
+	delete(release.Images, "statefulset/db/postgres")
+	err = p.useReleaseImages(release, "staging", "promote", false)
+	req.Error(err)
+	req.Contains(err.Error(), `staging never ran container "postgres" of statefulset "db"`)
+}
+
+func TestUpdatePromotedWorkloads(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/apis/extensions/v1beta1/namespaces/web/daemonsets/agent", `{"apiVersion":"extensions/v1beta1","kind":"DaemonSet","metadata":{"name":"agent","namespace":"web"}}`)
+	cluster.add("/apis/apps/v1beta1/namespaces/web/statefulsets/db", `{"apiVersion":"apps/v1beta1","kind":"StatefulSet","metadata":{"name":"db","namespace":"web"}}`)
+	for _, manifest := range []string{promoteDaemonSet, promoteStatefulSet} {
+		asset, err := parseAsset("asset.yml", []byte(manifest))
+		req.Nil(err)
+		p := &Project{kubeClient: kubeClient, config: &appConfig{timeout: time.Minute}, projectConfig: &ProjectConfig{}}
+		req.Nil(p.updateAsset(asset))
+	}
+	req.Len(cluster.requested("PUT", "/apis/extensions/v1beta1/namespaces/web/daemonsets/agent"), 1)
+	req.Len(cluster.requested("PUT", "/apis/apps/v1beta1/namespaces/web/statefulsets/db"), 1)
+}
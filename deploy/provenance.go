@@ -0,0 +1,86 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const provenancePrefix = "imladris/provenance-"
+
+// provenanceFields are the provenance annotations, minus the prefix, in the
+// order the provenance command prints them
+var provenanceFields = []string{"pipeline", "commit", "builder", "digest"}
+
+var provenanceKinds = map[string]struct{}{
+	"pod":         {},
+	"deployment":  {},
+	"daemonset":   {},
+	"statefulset": {},
+	"job":         {},
+	"cronjob":     {},
+}
+
+// isProvenanceAnnotation tells the annotations left out of the checksum, a
+// new pipeline run alone shouldn't make a resource look changed
+func isProvenanceAnnotation(key string) bool {
+	return strings.HasPrefix(key, provenancePrefix)
+}
+
+func (p *Project) provenance(asset *Asset) map[string]string {
+	provenance := map[string]string{
+		"builder": fmt.Sprintf("imladris %s (%s)", imladrisVersion, deployActor()),
+		"digest":  "sha256:" + asset.Checksum(),
+	}
+	if p.ci != nil {
+		provenance["pipeline"] = p.ci.pipelineURL
+		provenance["commit"] = p.ci.sha
+	}
+	return provenance
+}
+
+// annotateAsset stamps what's needed to tell later whether the live resource
+// is still the one sent, what it changed, and for workloads where it came from
+func (p *Project) annotateAsset(asset *Asset) {
+	// Noted before stamping, so the diff only holds manifest changes. Without
+	// a cluster there is nothing to compare with.
+	note := ""
+	if p.kubeClient != nil {
+		var err error
+		note, err = p.changeNote(asset)
+		if err != nil {
+			p.printer.ErrPrintf(ColorYellow, "Warning: cannot write the change note of %s: %s\n", assetKey(asset), err.Error())
+		}
+	}
+	asset.annotateChecksum()
+	objectMeta := asset.ResourceData.(apiv1.Object)
+	annotations := objectMeta.GetAnnotations()
+	if note != "" {
+		annotations[changeNoteAnnotation] = note
+		objectMeta.SetAnnotations(annotations)
+	}
+	if _, ok := provenanceKinds[asset.Kind]; !ok {
+		return
+	}
+	for field, value := range p.provenance(asset) {
+		if value != "" {
+			annotations[provenancePrefix+field] = value
+		}
+	}
+	objectMeta.SetAnnotations(annotations)
+}
+
+// readProvenance lists the provenance of a live resource as field and value
+// pairs, fields that were never stamped are left out
+func readProvenance(resource interface{}) [][2]string {
+	annotations := resource.(apiv1.Object).GetAnnotations()
+	result := [][2]string{}
+	for _, field := range provenanceFields {
+		value, ok := annotations[provenancePrefix+field]
+		if ok {
+			result = append(result, [2]string{field, value})
+		}
+	}
+	return result
+}
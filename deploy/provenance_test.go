@@ -0,0 +1,111 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProvenance(t *testing.T) {
+	req := require.New(t)
+	p := &Project{ci: &ciEnvironment{sha: "0c0bce5", pipelineURL: "https://ci.example.com/runs/42"}}
+	deployment, err := parseAsset("web.yml", []byte("apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: web\n"))
+	req.Nil(err)
+	checksum := deployment.Checksum()
+	p.annotateAsset(deployment)
+	req.Equal(checksum, deployment.Checksum())
+
+	provenance := readProvenance(deployment.ResourceData)
+	req.Len(provenance, 4)
+	req.Equal([2]string{"pipeline", "https://ci.example.com/runs/42"}, provenance[0])
+	req.Equal([2]string{"commit", "0c0bce5"}, provenance[1])
+	req.Equal([2]string{"digest", "sha256:" + checksum}, provenance[3])
+
+	configMap, err := parseAsset("web-cm.yml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n"))
+	req.Nil(err)
+	p.annotateAsset(configMap)
+	req.Empty(readProvenance(configMap.ResourceData))
+	req.Equal(configMap.Checksum(), configMap.ResourceData.(apiv1.Object).GetAnnotations()[checksumAnnotation])
+}
+
+func TestProvenanceFields(t *testing.T) {
+	req := require.New(t)
+	clearCIEnvironment(t)
+	t.Setenv("USER", "deployer")
+	hostname, err := os.Hostname()
+	req.Nil(err)
+	manifest := []byte("apiVersion: batch/v2alpha1\nkind: CronJob\nmetadata:\n  name: report\nspec:\n  schedule: \"0 * * * *\"\n")
+
+	// Local deploys have no pipeline or commit to vouch for
+	local, err := parseAsset("report.yml", manifest)
+	req.Nil(err)
+	(&Project{}).annotateAsset(local)
+	req.Equal([][2]string{
+		{"builder", fmt.Sprintf("imladris %s (deployer@%s)", imladrisVersion, hostname)},
+		{"digest", "sha256:" + local.Checksum()},
+	}, readProvenance(local.ResourceData))
+
+	// A new pipeline run alone changes the provenance, not the digest
+	first, err := parseAsset("report.yml", manifest)
+	req.Nil(err)
+	(&Project{ci: &ciEnvironment{sha: "0c0bce5", pipelineURL: "https://ci.example.com/runs/42"}}).annotateAsset(first)
+	second, err := parseAsset("report.yml", manifest)
+	req.Nil(err)
+	(&Project{ci: &ciEnvironment{sha: "5ecb0c0", pipelineURL: "https://ci.example.com/runs/43"}}).annotateAsset(second)
+	req.Equal(readProvenance(local.ResourceData)[1], readProvenance(first.ResourceData)[3])
+	req.Equal(readProvenance(first.ResourceData)[3], readProvenance(second.ResourceData)[3])
+	req.NotEqual(readProvenance(first.ResourceData)[1], readProvenance(second.ResourceData)[1])
+
+	changed, err := parseAsset("report.yml", append(manifest, []byte("  suspend: true\n")...))
+	req.Nil(err)
+	(&Project{}).annotateAsset(changed)
+	req.NotEqual(readProvenance(local.ResourceData)[1], readProvenance(changed.ResourceData)[1])
+}
+
+func TestReadLiveProvenance(t *testing.T) {
+	req := require.New(t)
+	cluster, clientset := newFakeCluster(t)
+	asset, err := parseAsset("web.yml", []byte("apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: web\n"))
+	req.Nil(err)
+	(&Project{ci: &ciEnvironment{sha: "0c0bce5", pipelineURL: "https://ci.example.com/runs/42"}}).annotateAsset(asset)
+	data, err := json.Marshal(asset.ResourceData)
+	req.Nil(err)
+	cluster.add("/apis/extensions/v1beta1/namespaces/web/deployments/web", string(data))
+
+	live, err := getResource(clientset, nil, "deployment", "web", "web")
+	req.Nil(err)
+	req.Equal(readProvenance(asset.ResourceData), readProvenance(live))
+
+	cluster.add("/apis/extensions/v1beta1/namespaces/web/deployments/legacy", `{"apiVersion":"extensions/v1beta1","kind":"Deployment","metadata":{"name":"legacy","namespace":"web","annotations":{"team":"core"}}}`)
+	live, err = getResource(clientset, nil, "deployment", "legacy", "web")
+	req.Nil(err)
+	req.Empty(readProvenance(live))
+}
+
+func TestChecksumLeavesResourceAlone(t *testing.T) {
+	req := require.New(t)
+	asset, err := parseAsset("web.yml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  resourceVersion: \"42\"\n  annotations:\n    team: core\n    imladris/checksum: stale\n"))
+	req.Nil(err)
+	checksum := asset.Checksum()
+	objectMeta := asset.ResourceData.(apiv1.Object)
+	req.Equal("42", objectMeta.GetResourceVersion())
+	req.Equal(map[string]string{"team": "core", checksumAnnotation: "stale"}, objectMeta.GetAnnotations())
+
+	// Read concurrently by deploy-all, diff and the dashboard
+	done := make(chan string)
+	for i := 0; i < 8; i++ {
+		go func() { done <- asset.Checksum() }()
+	}
+	for i := 0; i < 8; i++ {
+		req.Equal(checksum, <-done)
+	}
+
+	// Stamps and versions are not part of the manifest
+	objectMeta.SetResourceVersion("43")
+	objectMeta.SetAnnotations(map[string]string{"team": "core"})
+	req.Equal(checksum, asset.Checksum())
+}
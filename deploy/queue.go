@@ -0,0 +1,163 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// serverJobLimit is how many finished jobs the server keeps for polling
+const serverJobLimit = 100
+
+// deployJob is a deploy waiting for, or done with, its turn on a target
+type deployJob struct {
+	ID         string        `json:"id"`
+	Project    string        `json:"project"`
+	Action     string        `json:"action"`
+	Target     string        `json:"target"`
+	Status     string        `json:"status"`
+	Position   int           `json:"position"`
+	QueuedAt   time.Time     `json:"queued_at"`
+	StartedAt  *time.Time    `json:"started_at,omitempty"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Result     *deployResult `json:"result,omitempty"`
+	Output     string        `json:"output,omitempty"`
+
+	run    func(out *printer) *deployResult
+	output *lockedBuffer
+	stream io.Writer
+	done   chan struct{}
+}
+
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(data []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(data)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+// deployQueue runs the jobs of a target, a context and namespace, one after
+// the other in the order they came, so overlapping CI triggers never race
+// each other. Each target has its own worker while it has jobs.
+type deployQueue struct {
+	lock     sync.Mutex
+	targets  map[string][]*deployJob
+	jobs     map[string]*deployJob
+	finished []string
+	sequence int
+	execute  func(job *deployJob) *deployResult
+}
+
+func newDeployQueue(execute func(job *deployJob) *deployResult) *deployQueue {
+	return &deployQueue{
+		targets: make(map[string][]*deployJob),
+		jobs:    make(map[string]*deployJob),
+		execute: execute,
+	}
+}
+
+// enqueue adds a job to the queue of its target and returns how many jobs
+// are ahead of it
+func (q *deployQueue) enqueue(job *deployJob) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.sequence++
+	job.ID = fmt.Sprintf("%s-%d", time.Now().UTC().Format("20060102150405"), q.sequence)
+	job.Status = "queued"
+	job.QueuedAt = time.Now().UTC()
+	job.output = &lockedBuffer{}
+	job.done = make(chan struct{})
+	q.jobs[job.ID] = job
+	q.targets[job.Target] = append(q.targets[job.Target], job)
+	ahead := len(q.targets[job.Target]) - 1
+	if ahead == 0 {
+		go q.work(job.Target)
+	}
+	return ahead
+}
+
+func (q *deployQueue) work(target string) {
+	for {
+		q.lock.Lock()
+		job := q.targets[target][0]
+		started := time.Now().UTC()
+		job.Status = "running"
+		job.StartedAt = &started
+		q.lock.Unlock()
+
+		result := q.execute(job)
+
+		q.lock.Lock()
+		finished := time.Now().UTC()
+		job.FinishedAt = &finished
+		job.Result = result
+		job.Status = result.Result
+		q.targets[target] = q.targets[target][1:]
+		q.finished = append(q.finished, job.ID)
+		if len(q.finished) > serverJobLimit {
+			delete(q.jobs, q.finished[0])
+			q.finished = q.finished[1:]
+		}
+		idle := len(q.targets[target]) == 0
+		if idle {
+			delete(q.targets, target)
+		}
+		q.lock.Unlock()
+		close(job.done)
+		if idle {
+			return
+		}
+	}
+}
+
+// snapshot copies a job for the status endpoints, with its position in the
+// queue of its target and the output so far
+func (q *deployQueue) snapshot(job *deployJob, withOutput bool) *deployJob {
+	copied := *job
+	copied.Position = 0
+	for i, queued := range q.targets[job.Target] {
+		if queued == job {
+			copied.Position = i
+		}
+	}
+	copied.Output = ""
+	if withOutput {
+		copied.Output = scrub(job.output.String())
+	}
+	return &copied
+}
+
+func (q *deployQueue) get(id string) (*deployJob, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return q.snapshot(job, true), true
+}
+
+// pending lists the running and queued jobs of every target
+func (q *deployQueue) pending() map[string][]*deployJob {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	pending := make(map[string][]*deployJob)
+	for target, jobs := range q.targets {
+		for _, job := range jobs {
+			pending[target] = append(pending[target], q.snapshot(job, false))
+		}
+	}
+	return pending
+}
@@ -0,0 +1,124 @@
+package deploy
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func podLimits(podSpec *v1.PodSpec) v1.ResourceList {
+	total := v1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		addResources(total, container.Resources.Limits, 1)
+	}
+	return total
+}
+
+// surgePods is how many pods a rolling update starts above the replica
+// count, extensions/v1beta1 surges by one when nothing is set
+func surgePods(strategy *v1beta1.DeploymentStrategy, replicas int) int {
+	if strategy.Type == v1beta1.RecreateDeploymentStrategyType {
+		return 0
+	}
+	if strategy.RollingUpdate == nil || strategy.RollingUpdate.MaxSurge == nil {
+		return 1
+	}
+	surge, err := intstr.GetValueFromIntOrPercent(strategy.RollingUpdate.MaxSurge, replicas, true)
+	if err != nil {
+		return 1
+	}
+	return surge
+}
+
+// quotaShortfall describes the first quota without room for that many more
+// pods with the given requests and limits, or returns an empty string
+func quotaShortfall(quotas []v1.ResourceQuota, requests, limits v1.ResourceList, pods int64) string {
+	needed := v1.ResourceList{v1.ResourcePods: *resource.NewQuantity(pods, resource.DecimalSI)}
+	for _, check := range []struct {
+		quota  v1.ResourceName
+		source v1.ResourceList
+		name   v1.ResourceName
+	}{
+		{v1.ResourceRequestsCPU, requests, v1.ResourceCPU},
+		{v1.ResourceRequestsMemory, requests, v1.ResourceMemory},
+		{v1.ResourceCPU, requests, v1.ResourceCPU},
+		{v1.ResourceMemory, requests, v1.ResourceMemory},
+		{v1.ResourceLimitsCPU, limits, v1.ResourceCPU},
+		{v1.ResourceLimitsMemory, limits, v1.ResourceMemory},
+	} {
+		quantity, ok := check.source[check.name]
+		if !ok {
+			continue
+		}
+		total := v1.ResourceList{}
+		addResources(total, v1.ResourceList{check.quota: quantity}, pods)
+		needed[check.quota] = total[check.quota]
+	}
+	for _, quota := range quotas {
+		for name, quantity := range needed {
+			hard, ok := quota.Status.Hard[name]
+			if !ok {
+				hard, ok = quota.Spec.Hard[name]
+			}
+			if !ok {
+				continue
+			}
+			left := hard.DeepCopy()
+			if used, ok := quota.Status.Used[name]; ok {
+				left.Sub(used)
+			}
+			if quantity.Cmp(left) > 0 {
+				return fmt.Sprintf("quota %q has %s %s left, the surge needs %s", quota.Name, left.String(), name, quantity.String())
+			}
+		}
+	}
+	return ""
+}
+
+// staggerForQuota makes a rolling update scale old pods down before starting
+// new ones when the namespace quota has no room for the surge pods, which
+// would otherwise never be created and stall the rollout
+func (p *Project) staggerForQuota(asset *Asset) error {
+	deployment, ok := asset.ResourceData.(*v1beta1.Deployment)
+	if !ok {
+		return nil
+	}
+	replicas := 1
+	if deployment.Spec.Replicas != nil {
+		replicas = int(*deployment.Spec.Replicas)
+	}
+	surge := surgePods(&deployment.Spec.Strategy, replicas)
+	if surge == 0 {
+		return nil
+	}
+	quotas, err := p.clientFor(asset).Core().ResourceQuotas(deployment.Namespace).List(apiv1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	podSpec := &deployment.Spec.Template.Spec
+	shortfall := quotaShortfall(quotas.Items, podRequests(podSpec), podLimits(podSpec), int64(surge))
+	if shortfall == "" {
+		return nil
+	}
+	p.printer.ErrPrintf(ColorYellow, "====> %s, scaling old pods down before starting new ones\n", shortfall)
+	if deployment.Spec.Strategy.RollingUpdate == nil {
+		deployment.Spec.Strategy.RollingUpdate = &v1beta1.RollingUpdateDeployment{}
+	}
+	rollingUpdate := deployment.Spec.Strategy.RollingUpdate
+	maxSurge := intstr.FromInt(0)
+	rollingUpdate.MaxSurge = &maxSurge
+	unavailable := 1
+	if rollingUpdate.MaxUnavailable != nil {
+		unavailable, err = intstr.GetValueFromIntOrPercent(rollingUpdate.MaxUnavailable, replicas, false)
+		if err != nil || unavailable < 1 {
+			unavailable = 1
+		}
+	}
+	maxUnavailable := intstr.FromInt(unavailable)
+	rollingUpdate.MaxUnavailable = &maxUnavailable
+	return nil
+}
@@ -0,0 +1,40 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestSurgePods(t *testing.T) {
+	req := require.New(t)
+	strategy := &v1beta1.DeploymentStrategy{}
+	req.Equal(1, surgePods(strategy, 4))
+	maxSurge := intstr.FromString("50%")
+	strategy.RollingUpdate = &v1beta1.RollingUpdateDeployment{MaxSurge: &maxSurge}
+	req.Equal(3, surgePods(strategy, 5))
+	strategy.Type = v1beta1.RecreateDeploymentStrategyType
+	req.Equal(0, surgePods(strategy, 5))
+}
+
+func TestQuotaShortfall(t *testing.T) {
+	req := require.New(t)
+	quota := v1.ResourceQuota{}
+	quota.Name = "compute"
+	quota.Status.Hard = v1.ResourceList{
+		v1.ResourceRequestsCPU: resource.MustParse("4"),
+		v1.ResourcePods:        resource.MustParse("10"),
+	}
+	quota.Status.Used = v1.ResourceList{
+		v1.ResourceRequestsCPU: resource.MustParse("3"),
+		v1.ResourcePods:        resource.MustParse("6"),
+	}
+	requests := v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")}
+	req.Equal("", quotaShortfall([]v1.ResourceQuota{quota}, requests, v1.ResourceList{}, 2))
+	req.Equal(`quota "compute" has 1 requests.cpu left, the surge needs 1500m`, quotaShortfall([]v1.ResourceQuota{quota}, requests, v1.ResourceList{}, 3))
+	req.Contains(quotaShortfall([]v1.ResourceQuota{quota}, v1.ResourceList{}, v1.ResourceList{}, 5), "pods")
+}
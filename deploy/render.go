@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// renderManifest serializes a resource exactly as it will be sent, with the
+// empty fields the typed objects carry (status, null timestamps) left out
+func renderManifest(kind string, resource interface{}) ([]byte, error) {
+	manifest, err := manifestMapSlice(kind, resource)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(manifest)
+}
+
+func removeNullValues(value interface{}) interface{} {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		result := yaml.MapSlice{}
+		for _, item := range value {
+			if item.Value == nil {
+				continue
+			}
+			result = append(result, yaml.MapItem{Key: item.Key, Value: removeNullValues(item.Value)})
+		}
+		return result
+	case []interface{}:
+		for i := range value {
+			value[i] = removeNullValues(value[i])
+		}
+		return value
+	}
+	return value
+}
+
+// Render writes the final manifests to out, or one file per resource under
+// outputFolder when it is set
+func (p *Project) Render(out io.Writer, outputFolder string) error {
+	for i, asset := range p.assets() {
+		data, err := renderManifest(asset.Kind, asset.ResourceData)
+		if err != nil {
+			return err
+		}
+		if outputFolder == "" {
+			if i > 0 {
+				out.Write([]byte("---\n"))
+			}
+			_, err = io.Copy(out, bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		folder := filepath.Join(outputFolder, asset.Namespace())
+		err = os.MkdirAll(folder, os.FileMode(0755))
+		if err != nil {
+			return err
+		}
+		filename := filepath.Join(folder, asset.Kind+"-"+asset.ResourceData.(Meta).GetName()+".yml")
+		err = ioutil.WriteFile(filename, data, os.FileMode(0644))
+		if err != nil {
+			return err
+		}
+		p.printer.Printf(ColorGreen, "====> Written to %q\n", filename)
+	}
+	return nil
+}
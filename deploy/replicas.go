@@ -0,0 +1,50 @@
+package deploy
+
+import (
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// autoscaled tells whether the replicas of a deployment are to be pinned:
+// -preserve-replicas is on and a horizontal pod autoscaler scales it
+func (p *Project) autoscaled(asset *Asset) (bool, error) {
+	if !p.config.preserveReplicas && !p.projectConfig.PreserveReplicas {
+		return false, nil
+	}
+	deployment, ok := asset.ResourceData.(*v1beta1.Deployment)
+	if !ok {
+		return false, nil
+	}
+	autoscalers, err := p.clientFor(asset).Autoscaling().HorizontalPodAutoscalers(deployment.Namespace).List(apiv1.ListOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, autoscaler := range autoscalers.Items {
+		target := autoscaler.Spec.ScaleTargetRef
+		if target.Kind == "Deployment" && target.Name == deployment.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pinReplicas keeps the live replica count of an autoscaled deployment, so an
+// update doesn't reset it to the manifest value and make the autoscaler scale
+// back up right after. It is read again on every attempt, the autoscaler may
+// have moved it meanwhile.
+func (p *Project) pinReplicas(asset *Asset) error {
+	deployment := asset.ResourceData.(*v1beta1.Deployment)
+	live, err := p.clientFor(asset).Extensions().Deployments(deployment.Namespace).Get(deployment.Name, apiv1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if live.Spec.Replicas == nil {
+		return nil
+	}
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != *live.Spec.Replicas {
+		p.printer.Printf(ColorBlue, "====> Keeping %d replicas set by the autoscaler\n", *live.Spec.Replicas)
+	}
+	replicas := *live.Spec.Replicas
+	deployment.Spec.Replicas = &replicas
+	return nil
+}
@@ -0,0 +1,48 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinReplicas(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	path := "/apis/extensions/v1beta1/namespaces/web/deployments/web"
+	live := func(replicas string) string {
+		return `{"apiVersion":"extensions/v1beta1","kind":"Deployment","metadata":{"name":"web","namespace":"web"},"spec":{"replicas":` + replicas + `,"template":{"spec":{"containers":[{"name":"web","image":"nginx:1.0"}]}}}}`
+	}
+	cluster.add(path, live("7"))
+	manifest := []byte("apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: web\nspec:\n  replicas: 2\n  template:\n    spec:\n      containers:\n      - name: web\n        image: nginx:1.1\n")
+	newProject := func(preserveReplicas bool) (*Project, *Asset) {
+		asset, err := parseAsset("web.yml", manifest)
+		req.Nil(err)
+		p := &Project{kubeClient: kubeClient, config: &appConfig{onConflict: "retry", preserveReplicas: preserveReplicas}, projectConfig: &ProjectConfig{}, services: []*Asset{asset}}
+		req.Nil(p.recordVersions())
+		return p, asset
+	}
+	replicas := func() float64 {
+		return cluster.get(path)["spec"].(map[string]interface{})["replicas"].(float64)
+	}
+
+	// Not autoscaled, the manifest wins
+	p, asset := newProject(true)
+	req.Nil(p.updateAsset(asset))
+	req.Equal(float64(2), replicas())
+
+	cluster.add("/apis/autoscaling/v1/namespaces/web/horizontalpodautoscalers/web", `{"apiVersion":"autoscaling/v1","kind":"HorizontalPodAutoscaler","metadata":{"name":"web","namespace":"web"},"spec":{"maxReplicas":10,"scaleTargetRef":{"kind":"Deployment","name":"web"}}}`)
+	cluster.add(path, live("7"))
+	p, asset = newProject(false)
+	req.Nil(p.updateAsset(asset))
+	req.Equal(float64(2), replicas())
+
+	// The autoscaler scales while the update retries, the latest count is kept
+	cluster.add(path, live("7"))
+	p, asset = newProject(true)
+	cluster.add(path, live("9"))
+	listed := len(cluster.requested("GET", "/apis/autoscaling/v1/namespaces/web/horizontalpodautoscalers"))
+	req.Nil(p.updateAsset(asset))
+	req.Equal(float64(9), replicas())
+	req.Len(cluster.requested("GET", "/apis/autoscaling/v1/namespaces/web/horizontalpodautoscalers"), listed+1)
+}
@@ -0,0 +1,201 @@
+package deploy
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Review environments are namespaces holding a full copy of a project for a
+// branch. The expiry is a label in unix seconds, label values cannot hold a
+// RFC3339 time, so expired environments can be selected by anything watching
+// namespaces.
+const (
+	reviewLabel             = "imladris/review-environment"
+	reviewExpiresLabel      = "imladris/expires"
+	reviewProjectAnnotation = "imladris/project"
+	reviewOwnerAnnotation   = "imladris/owner"
+	reviewBranchAnnotation  = "imladris/branch"
+	defaultReviewTTL        = 72 * time.Hour
+)
+
+// reviewSlug makes a branch name usable in a namespace name
+func reviewSlug(name string) string {
+	name = regexp.MustCompile("[^a-z0-9-]+").ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
+
+// reviewNamespace names the review environment of a branch. Names too long
+// for a namespace are cut and suffixed with a hash so branches sharing a long
+// prefix still get their own namespace.
+func reviewNamespace(project, branch string) string {
+	name := reviewSlug(project + "-" + branch)
+	if len(name) <= 63 {
+		return name
+	}
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(project+"/"+branch)))[:8]
+	return strings.TrimRight(name[:54], "-") + "-" + hash
+}
+
+type reviewEnvironment struct {
+	namespace string
+	project   string
+	branch    string
+	owner     string
+	created   time.Time
+	expires   time.Time
+}
+
+func reviewEnvironmentOf(namespace *v1.Namespace) *reviewEnvironment {
+	env := &reviewEnvironment{
+		namespace: namespace.Name,
+		project:   namespace.Annotations[reviewProjectAnnotation],
+		branch:    namespace.Annotations[reviewBranchAnnotation],
+		owner:     namespace.Annotations[reviewOwnerAnnotation],
+		created:   namespace.CreationTimestamp.Time,
+	}
+	seconds, err := strconv.ParseInt(namespace.Labels[reviewExpiresLabel], 10, 64)
+	if err == nil {
+		env.expires = time.Unix(seconds, 0)
+	}
+	return env
+}
+
+func (env *reviewEnvironment) expired(now time.Time) bool {
+	return !env.expires.IsZero() && now.After(env.expires)
+}
+
+func listReviewEnvironments(kubeClient *kubernetes.Clientset) ([]*reviewEnvironment, error) {
+	namespaces, err := kubeClient.Core().Namespaces().List(apiv1.ListOptions{LabelSelector: reviewLabel + "=true"})
+	if err != nil {
+		return nil, err
+	}
+	envs := []*reviewEnvironment{}
+	for i := range namespaces.Items {
+		envs = append(envs, reviewEnvironmentOf(&namespaces.Items[i]))
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].created.Before(envs[j].created)
+	})
+	return envs, nil
+}
+
+// ensureReviewNamespace creates the namespace of a review environment, or
+// pushes back the expiry of an existing one. It refuses namespaces that are
+// not review environments, so a branch name cannot point a create at a real
+// environment.
+func ensureReviewNamespace(kubeClient *kubernetes.Clientset, namespace, project, branch string, ttl time.Duration) error {
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	namespaces := kubeClient.Core().Namespaces()
+	ns, err := namespaces.Get(namespace, apiv1.GetOptions{})
+	if isResourceNotExist(err) {
+		ns = &v1.Namespace{
+			ObjectMeta: apiv1.ObjectMeta{
+				Name: namespace,
+				Labels: map[string]string{
+					reviewLabel:        "true",
+					reviewExpiresLabel: expires,
+				},
+				Annotations: map[string]string{
+					reviewProjectAnnotation: project,
+					reviewOwnerAnnotation:   deployActor(),
+					reviewBranchAnnotation:  branch,
+				},
+			},
+		}
+		_, err = namespaces.Create(ns)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if ns.Labels[reviewLabel] != "true" {
+		return validationError(fmt.Errorf("namespace %q exists and is not a review environment", namespace))
+	}
+	ns.Labels[reviewExpiresLabel] = expires
+	_, err = namespaces.Update(ns)
+	return err
+}
+
+func destroyReviewEnvironment(kubeClient *kubernetes.Clientset, out *printer, namespace string) error {
+	ns, err := kubeClient.Core().Namespaces().Get(namespace, apiv1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if ns.Labels[reviewLabel] != "true" {
+		return validationError(fmt.Errorf("namespace %q is not a review environment", namespace))
+	}
+	out.Printf(ColorRed, "Destroying review environment %q\n", namespace)
+	return deleteNamespace(kubeClient, namespace)
+}
+
+// pruneReviewEnvironments destroys the review environments whose expiry is
+// past
+func pruneReviewEnvironments(kubeClient *kubernetes.Clientset, now time.Time) ([]*reviewEnvironment, error) {
+	envs, err := listReviewEnvironments(kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	pruned := []*reviewEnvironment{}
+	for _, env := range envs {
+		if !env.expired(now) {
+			continue
+		}
+		err = destroyReviewEnvironment(kubeClient, console, env.namespace)
+		if err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, env)
+	}
+	return pruned, nil
+}
+
+// reviewProjectName reads the project name the way loadProject does, it is
+// needed to name the namespace before the project is loaded into it
+func reviewProjectName(assetRoot string, config *appConfig) (string, error) {
+	p := &Project{config: config, projectConfig: &ProjectConfig{}}
+	err := p.readProjectConfig(assetRoot, config.variables)
+	if err != nil {
+		return "", err
+	}
+	if p.projectConfig.Name != "" {
+		return p.projectConfig.Name, nil
+	}
+	if p.projectConfig.RootFolder != "" {
+		return defaultProjectName(translateFilePath(p.projectFolder, p.projectConfig.RootFolder)), nil
+	}
+	return defaultProjectName(p.projectFolder), nil
+}
+
+// reviewClusterScopedKinds have no namespace, a review environment would
+// apply them over the ones of the whole cluster
+var reviewClusterScopedKinds = map[string]bool{
+	"clusterrole":        true,
+	"clusterrolebinding": true,
+}
+
+// checkReviewNamespace makes sure every resource goes into the review
+// environment: none sets a namespace of its own, is cluster wide or is
+// routed to another context
+func (p *Project) checkReviewNamespace(namespace string) error {
+	for _, asset := range p.assets() {
+		if reviewClusterScopedKinds[asset.Kind] {
+			return validationError(fmt.Errorf("%s is cluster wide, review environments cannot deploy it without changing it for the whole cluster; exclude it from review environments", assetKey(asset)))
+		}
+		if asset.context != "" {
+			return validationError(fmt.Errorf("%s is routed to context %q, review environments only deploy into their own namespace; exclude it from review environments", assetKey(asset), asset.context))
+		}
+		if asset.Namespace() != "" && asset.Namespace() != namespace {
+			return validationError(fmt.Errorf("%s is deployed to namespace %q, review environments need resources without a namespace of their own", assetKey(asset), asset.Namespace()))
+		}
+	}
+	return nil
+}
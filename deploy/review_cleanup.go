@@ -0,0 +1,118 @@
+package deploy
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// reviewNotifiedAnnotation holds the expiry the owner was warned about, a
+// create pushing the expiry back gets a new warning
+const reviewNotifiedAnnotation = "imladris/expiry-notified"
+
+// reviewCleanup destroys expired review environments for the server, after
+// warning their owner notifyBefore the expiry. The server sets destroy to
+// queue the destroy on the target of the namespace, it then never runs while
+// a deploy into it does.
+type reviewCleanup struct {
+	kubeClient   *kubernetes.Clientset
+	notifyURL    string
+	notifyBefore time.Duration
+	out          *printer
+	destroy      func(env *reviewEnvironment) error
+}
+
+// reviewNotification is posted to the notify url, text makes it readable as
+// a slack or mattermost incoming webhook
+type reviewNotification struct {
+	Text      string `json:"text"`
+	Event     string `json:"event"`
+	Namespace string `json:"namespace"`
+	Project   string `json:"project"`
+	Branch    string `json:"branch"`
+	Owner     string `json:"owner"`
+	Expires   string `json:"expires"`
+}
+
+func (c *reviewCleanup) notify(event string, env *reviewEnvironment, text string) {
+	c.out.Printf(ColorPurple, "%s\n", text)
+	if c.notifyURL == "" {
+		return
+	}
+	err := doJSONRequest("POST", c.notifyURL, nil, &reviewNotification{
+		Text:      text,
+		Event:     event,
+		Namespace: env.namespace,
+		Project:   env.project,
+		Branch:    env.branch,
+		Owner:     env.owner,
+		Expires:   env.expires.UTC().Format(time.RFC3339),
+	}, nil)
+	if err != nil {
+		c.out.ErrPrintf(ColorRed, "Cannot notify %s about review environment %q: %s\n", env.owner, env.namespace, err.Error())
+	}
+}
+
+func (c *reviewCleanup) reconcile(now time.Time) error {
+	namespaces, err := c.kubeClient.Core().Namespaces().List(apiv1.ListOptions{LabelSelector: reviewLabel + "=true"})
+	if err != nil {
+		return err
+	}
+	for i := range namespaces.Items {
+		ns := &namespaces.Items[i]
+		env := reviewEnvironmentOf(ns)
+		if env.expires.IsZero() || ns.DeletionTimestamp != nil {
+			continue
+		}
+		if env.expired(now) {
+			// Notified once destroyed, a failed destroy is retried next time
+			// without telling the owner again
+			err = c.destroyEnvironment(env)
+			if err != nil {
+				c.out.ErrPrintf(ColorRed, "Cannot destroy review environment %q: %s\n", env.namespace, err.Error())
+				continue
+			}
+			c.notify("destroyed", env, fmt.Sprintf("Review environment %q of branch %q owned by %s expired and was destroyed", env.namespace, env.branch, env.owner))
+			continue
+		}
+		if env.expires.Sub(now) > c.notifyBefore || ns.Annotations[reviewNotifiedAnnotation] == ns.Labels[reviewExpiresLabel] {
+			continue
+		}
+		c.notify("expiring", env, fmt.Sprintf("Review environment %q of branch %q owned by %s expires in %s, run env create again to keep it", env.namespace, env.branch, env.owner, env.expires.Sub(now).Round(time.Minute)))
+		err = c.markNotified(ns)
+		if err != nil {
+			c.out.ErrPrintf(ColorRed, "Cannot annotate review environment %q: %s\n", env.namespace, err.Error())
+		}
+	}
+	return nil
+}
+
+func (c *reviewCleanup) destroyEnvironment(env *reviewEnvironment) error {
+	if c.destroy != nil {
+		return c.destroy(env)
+	}
+	return destroyReviewEnvironment(c.kubeClient, c.out, env.namespace)
+}
+
+func (c *reviewCleanup) markNotified(ns *v1.Namespace) error {
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[reviewNotifiedAnnotation] = ns.Labels[reviewExpiresLabel]
+	_, err := c.kubeClient.Core().Namespaces().Update(ns)
+	return err
+}
+
+func (c *reviewCleanup) run(interval time.Duration) {
+	c.out.Printf(ColorYellow, "Destroying expired review environments, checking every %s\n", interval)
+	for {
+		err := c.reconcile(time.Now())
+		if err != nil {
+			c.out.ErrPrintf(ColorRed, "Cannot list review environments: %s\n", err.Error())
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,102 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestReviewCleanup(t *testing.T) {
+	req := require.New(t)
+	now := time.Unix(1700000000, 0)
+	namespace := func(name string, expires time.Time, notified string) string {
+		return fmt.Sprintf(`{"metadata":{"name":%q,"labels":{%q:"true",%q:"%d"},"annotations":{%q:"alice",%q:%q}}}`,
+			name, reviewLabel, reviewExpiresLabel, expires.Unix(), reviewOwnerAnnotation, reviewNotifiedAnnotation, notified)
+	}
+	expiring := now.Add(time.Hour)
+	deleted := []string{}
+	updated := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v1/namespaces":
+			fmt.Fprintf(w, `{"kind":"NamespaceList","apiVersion":"v1","items":[%s,%s,%s,%s]}`,
+				namespace("shop-expired", now.Add(-time.Minute), ""),
+				namespace("shop-expiring", expiring, ""),
+				namespace("shop-warned", expiring, fmt.Sprint(expiring.Unix())),
+				namespace("shop-fresh", now.Add(72*time.Hour), ""))
+		case r.Method == "GET" && r.URL.Path == "/api/v1/namespaces/shop-expired":
+			w.Write([]byte(namespace("shop-expired", now.Add(-time.Minute), "")))
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Success"}`))
+		case r.Method == "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			updated = append(updated, r.URL.Path)
+			w.Write(body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+		}
+	}))
+	defer server.Close()
+	notifications := []*reviewNotification{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notification := &reviewNotification{}
+		req.Nil(json.NewDecoder(r.Body).Decode(notification))
+		notifications = append(notifications, notification)
+	}))
+	defer receiver.Close()
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	req.Nil(err)
+
+	// A failed destroy tells nobody, it is retried next time
+	cleanup := &reviewCleanup{kubeClient: kubeClient, notifyURL: receiver.URL, notifyBefore: 24 * time.Hour, out: newPrinter(ioutil.Discard, ioutil.Discard)}
+	cleanup.destroy = func(env *reviewEnvironment) error {
+		return fmt.Errorf("forbidden")
+	}
+	req.Nil(cleanup.reconcile(now))
+	req.Empty(deleted)
+	req.Len(notifications, 1)
+	req.Equal("expiring", notifications[0].Event)
+	notifications = notifications[:0]
+	updated = updated[:0]
+
+	cleanup.destroy = nil
+	req.Nil(cleanup.reconcile(now))
+	req.Equal([]string{"/api/v1/namespaces/shop-expired"}, deleted)
+	req.Equal([]string{"/api/v1/namespaces/shop-expiring"}, updated)
+	req.Len(notifications, 2)
+	req.Equal("destroyed", notifications[0].Event)
+	req.Equal("shop-expired", notifications[0].Namespace)
+	req.Equal("alice", notifications[0].Owner)
+	req.Equal("expiring", notifications[1].Event)
+	req.Equal("shop-expiring", notifications[1].Namespace)
+
+	// In the server the destroy waits for the deploys into the namespace
+	deleted = deleted[:0]
+	s := &deployServer{config: &appConfig{}}
+	s.queue = newDeployQueue(s.execute)
+	release := make(chan struct{})
+	s.queue.enqueue(&deployJob{Target: s.target("", "shop-expired"), run: func(out *printer) *deployResult {
+		<-release
+		return &deployResult{Result: "succeeded"}
+	}})
+	destroyed := make(chan error)
+	go func() {
+		destroyed <- s.destroyReviewEnvironment(kubeClient, &reviewEnvironment{namespace: "shop-expired"})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	req.Empty(deleted)
+	close(release)
+	req.Nil(<-destroyed)
+	req.Equal([]string{"/api/v1/namespaces/shop-expired"}, deleted)
+}
@@ -0,0 +1,64 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReviewNamespace(t *testing.T) {
+	req := require.New(t)
+	req.Equal("shop-feature-login-form", reviewNamespace("shop", "feature/Login_Form"))
+	long := "feature/" + strings.Repeat("very-long-branch-name-", 4)
+	first := reviewNamespace("shop", long+"a")
+	second := reviewNamespace("shop", long+"b")
+	req.True(len(first) <= 63)
+	req.NotEqual(first, second)
+	req.True(strings.HasPrefix(first, "shop-feature-very-long-branch-name-"))
+}
+
+func TestCheckReviewNamespace(t *testing.T) {
+	req := require.New(t)
+	asset := func(manifest string) *Asset {
+		asset, err := parseAsset("asset.yml", []byte(manifest))
+		req.Nil(err)
+		asset.DefaultNamespace("shop-feature-login")
+		return asset
+	}
+	p := &Project{projectConfig: &ProjectConfig{}, resources: []*Asset{asset("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n")}}
+	req.Nil(p.checkReviewNamespace("shop-feature-login"))
+
+	p.resources = append(p.resources, asset("apiVersion: rbac.authorization.k8s.io/v1beta1\nkind: ClusterRole\nmetadata:\n  name: reader\n"))
+	err := p.checkReviewNamespace("shop-feature-login")
+	req.Error(err)
+	req.Contains(err.Error(), "cluster wide")
+
+	routed := asset("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: edge\n")
+	routed.context = "edge-cluster"
+	p.resources = []*Asset{routed}
+	err = p.checkReviewNamespace("shop-feature-login")
+	req.Error(err)
+	req.Contains(err.Error(), "edge-cluster")
+}
+
+func TestReviewEnvironmentExpiry(t *testing.T) {
+	req := require.New(t)
+	namespace := &v1.Namespace{
+		ObjectMeta: apiv1.ObjectMeta{
+			Name:        "shop-feature-login",
+			Labels:      map[string]string{reviewLabel: "true", reviewExpiresLabel: "1700000000"},
+			Annotations: map[string]string{reviewOwnerAnnotation: "alice", reviewBranchAnnotation: "feature/login"},
+		},
+	}
+	env := reviewEnvironmentOf(namespace)
+	req.Equal("alice", env.owner)
+	req.Equal("feature/login", env.branch)
+	req.False(env.expired(time.Unix(1699999999, 0)))
+	req.True(env.expired(time.Unix(1700000001, 0)))
+	delete(namespace.Labels, reviewExpiresLabel)
+	req.False(reviewEnvironmentOf(namespace).expired(time.Now()))
+}
@@ -0,0 +1,166 @@
+package deploy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// rollbackTarget picks the release to go back to: the last one before the
+// latest that ran other images, or applied other manifests when it recorded
+// them. A rollback is never rolled back itself, so an alert firing again
+// cannot bring the bad release back.
+func rollbackTarget(records []*ReleaseRecord) (*ReleaseRecord, error) {
+	latest := latestRelease(records)
+	if latest == nil {
+		return nil, fmt.Errorf("no release recorded")
+	}
+	if latest.Command == "rollback" {
+		return nil, fmt.Errorf("release %s is already a rollback", latest.Release)
+	}
+	for i := len(records) - 2; i >= 0; i-- {
+		if !reflect.DeepEqual(records[i].Images, latest.Images) {
+			return records[i], nil
+		}
+		if records[i].Manifests != "" && latest.Checksums != nil && !reflect.DeepEqual(records[i].Checksums, latest.Checksums) {
+			return records[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no release before %s ran other images or kept its manifests", latest.Release)
+}
+
+// Rollback redeploys the release before the latest one: its manifests when
+// the release recorded them, otherwise the manifests on disk with its images.
+// Secrets stay as on disk and jobs don't run. It is an emergency path that
+// skips the gates of a deploy, see checkGates.
+func (p *Project) Rollback() error {
+	backend, err := p.historyBackend()
+	if err != nil {
+		return err
+	}
+	records, err := backend.read(p.projectConfig.Name)
+	if err != nil {
+		return err
+	}
+	target, err := rollbackTarget(records)
+	if err != nil {
+		return validationError(fmt.Errorf("cannot roll back %q: %s", p.projectConfig.Name, err.Error()))
+	}
+	p.printer.Printf(ColorYellow, "Rolling back %q to release %s from %s\n", p.projectConfig.Name, target.Release, target.Time.Format("2006-01-02 15:04:05"))
+	if target.Manifests != "" {
+		err = p.restoreManifests(target)
+	} else {
+		err = p.useReleaseImages(target, "release "+target.Release, "roll back", true)
+	}
+	if err != nil {
+		return err
+	}
+	p.jobs = nil
+	p.rollingBack = true
+	return p.deploy("rollback", p.applyAsset)
+}
+
+// restoreManifests puts back the resources of a release. Resources added
+// since are left as they are in the cluster, removed ones are created again.
+func (p *Project) restoreManifests(release *ReleaseRecord) error {
+	recorded, err := p.decodeManifests(release)
+	if err != nil {
+		return err
+	}
+	restore := func(assets []*Asset) []*Asset {
+		kept := []*Asset{}
+		for _, asset := range assets {
+			key := assetKey(asset)
+			previous, ok := recorded[key]
+			switch {
+			case asset.Kind == "secret":
+			case ok:
+				asset.ResourceData = previous.ResourceData
+				asset.data = previous.data
+				delete(recorded, key)
+			default:
+				p.printer.Printf(ColorYellow, "%s was added after release %s, leaving it as it is\n", key, release.Release)
+				continue
+			}
+			kept = append(kept, asset)
+		}
+		return kept
+	}
+	p.resources = restore(p.resources)
+	p.services = restore(p.services)
+	removed := []string{}
+	for key := range recorded {
+		removed = append(removed, key)
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		p.printer.Printf(ColorYellow, "Restoring %s, removed after release %s\n", key, release.Release)
+		p.resources = append(p.resources, recorded[key])
+	}
+	return nil
+}
+
+func (p *Project) decodeManifests(release *ReleaseRecord) (map[string]*Asset, error) {
+	fail := func(err error) (map[string]*Asset, error) {
+		return nil, fmt.Errorf("cannot read the manifests of release %s: %s", release.Release, err.Error())
+	}
+	compressed, err := base64.StdEncoding.DecodeString(release.Manifests)
+	if err != nil {
+		return fail(err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return fail(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return fail(err)
+	}
+	manifests := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &manifests)
+	if err != nil {
+		return fail(err)
+	}
+	assets := make(map[string]*Asset)
+	for key, manifest := range manifests {
+		asset := &Asset{filename: "release " + release.Release, data: manifest}
+		err = yaml.Unmarshal(manifest, asset)
+		if err == nil {
+			asset.Kind = canonicalKind(asset.Kind)
+			err = asset.parseResource(manifest, false, p.plugins)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("%s: %s", key, err.Error()))
+		}
+		assets[key] = asset
+	}
+	return assets, nil
+}
+
+// checkGates runs the checks a deploy waits for or is refused by. A rollback
+// is the way out of a bad release and skips them, audited: a change freeze or
+// a closed deploy window must not keep the bad release running, nobody is
+// there to approve an automatic rollback and the signature covers the
+// manifests on disk, not the restored ones. The cluster pin was checked when
+// the project was read.
+func (p *Project) checkGates() error {
+	if p.rollingBack {
+		p.printer.ErrPrintf(ColorYellow, "Rolling back, skipping the change freeze, deploy windows, signature and approval\n")
+		p.audit("rollback-bypass", "", "", nil, map[string]string{"gates": "freeze,windows,signature,approval", "context": contextName(p.config)})
+		return nil
+	}
+	for _, gate := range []func() error{p.checkFreeze, p.enforceDeployWindow, p.verifySignature, p.waitForApproval} {
+		err := gate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackTarget(t *testing.T) {
+	req := require.New(t)
+	_, err := rollbackTarget(nil)
+	req.Error(err)
+
+	records := []*ReleaseRecord{
+		{Release: "a", Command: "up", Images: map[string]string{"deployment/web/web": "web:1"}},
+		{Release: "b", Command: "update", Images: map[string]string{"deployment/web/web": "web:2"}},
+		{Release: "c", Command: "update", Images: map[string]string{"deployment/web/web": "web:2"}},
+	}
+	target, err := rollbackTarget(records)
+	req.NoError(err)
+	req.Equal("a", target.Release)
+
+	_, err = rollbackTarget(records[1:])
+	req.Error(err, "no release with other images")
+
+	records = append(records, &ReleaseRecord{Release: "d", Command: "rollback", Images: map[string]string{"deployment/web/web": "web:1"}})
+	_, err = rollbackTarget(records)
+	req.Error(err)
+	req.Contains(err.Error(), "already a rollback")
+}
+
+func TestRollback(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("HOME", t.TempDir())
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.serveDiscovery()
+	cluster.add("/api/v1/namespaces/web", `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"web"}}`)
+	root := t.TempDir()
+	req.NoError(os.MkdirAll(filepath.Join(root, "services"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(root, "project.yml"), []byte("name: web\nnamespace: web\naudit:\n  file: audit.log\n"), 0644))
+	write := func(version, containers string) {
+		deployment := "apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    metadata:\n      labels:\n        app: web\n    spec:\n      containers:\n" + containers
+		configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  version: \"" + version + "\"\n"
+		req.NoError(ioutil.WriteFile(filepath.Join(root, "services", "web.yml"), []byte(deployment+"---\n"+configMap), 0644))
+	}
+	deploy := func() *Project {
+		p, err := readProject(kubeClient, root, &appConfig{yes: true})
+		req.NoError(err)
+		return p
+	}
+	write("1", "        - name: web\n          image: web:1\n")
+	p := deploy()
+	req.NoError(p.deploy("apply", p.applyAsset))
+	write("2", "        - name: web\n          image: web:2\n        - name: sidecar\n          image: proxy:1\n")
+	p = deploy()
+	req.NoError(p.deploy("apply", p.applyAsset))
+
+	// A freeze and the signature policy don't hold a rollback back
+	cluster.add("/api/v1/namespaces/web", `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"web","annotations":{"imladris/freeze":"incident"}}}`)
+	t.Setenv(signatureContextsEnv, "*")
+	p = deploy()
+	req.Error(p.deploy("apply", p.applyAsset))
+	req.NoError(p.Rollback())
+
+	deployment := cluster.get("/apis/extensions/v1beta1/namespaces/web/deployments/web")
+	containers := deployment["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+	req.Len(containers, 1)
+	req.Equal("web:1", containers[0].(map[string]interface{})["image"])
+	req.Equal("1", cluster.get("/api/v1/namespaces/web/configmaps/web")["data"].(map[string]interface{})["version"])
+	audit, err := ioutil.ReadFile(filepath.Join(root, "audit.log"))
+	req.NoError(err)
+	req.Contains(string(audit), "rollback-bypass")
+}
+
+func TestRollbackImagesOnly(t *testing.T) {
+	req := require.New(t)
+	asset, err := parseAsset("web.yml", []byte("apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    spec:\n      containers:\n        - name: web\n          image: web:2\n        - name: sidecar\n          image: proxy:1\n"))
+	req.NoError(err)
+	p := &Project{services: []*Asset{asset}, projectConfig: &ProjectConfig{}}
+	release := &ReleaseRecord{Release: "a", Images: map[string]string{"deployment/web/web": "web:1"}}
+	req.Error(p.useReleaseImages(release, "release a", "promote", false))
+	req.NoError(p.useReleaseImages(release, "release a", "roll back", true))
+	containers := getPodSpec(asset.Kind, asset.ResourceData).Containers
+	req.Len(containers, 1)
+	req.Equal("web:1", containers[0].Image)
+}
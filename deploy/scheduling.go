@@ -0,0 +1,154 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeSelectorRequirementMatches evaluates one match expression of a
+// required node affinity term against node labels
+func nodeSelectorRequirementMatches(requirement v1.NodeSelectorRequirement, labels map[string]string) bool {
+	value, ok := labels[requirement.Key]
+	switch requirement.Operator {
+	case v1.NodeSelectorOpIn:
+		return ok && containsString(requirement.Values, value)
+	case v1.NodeSelectorOpNotIn:
+		return !ok || !containsString(requirement.Values, value)
+	case v1.NodeSelectorOpExists:
+		return ok
+	case v1.NodeSelectorOpDoesNotExist:
+		return !ok
+	case v1.NodeSelectorOpGt, v1.NodeSelectorOpLt:
+		if !ok || len(requirement.Values) != 1 {
+			return false
+		}
+		actual, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		expected, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if requirement.Operator == v1.NodeSelectorOpGt {
+			return actual > expected
+		}
+		return actual < expected
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeRejection explains why the manifest keeps a pod off node, or returns an
+// empty string when it does not. Cordoned nodes and the taints kubernetes
+// sets for node conditions pass: they come and go, a manifest matching such
+// a node is not wrong.
+func nodeRejection(podSpec *v1.PodSpec, node *v1.Node) string {
+	for key, value := range podSpec.NodeSelector {
+		if node.Labels[key] != value {
+			return fmt.Sprintf("node selector %s=%s", key, value)
+		}
+	}
+	if podSpec.Affinity != nil && podSpec.Affinity.NodeAffinity != nil && podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		terms := podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		matched := len(terms) == 0
+		for _, term := range terms {
+			termMatched := true
+			for _, requirement := range term.MatchExpressions {
+				if !nodeSelectorRequirementMatches(requirement, node.Labels) {
+					termMatched = false
+					break
+				}
+			}
+			if termMatched {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "required node affinity"
+		}
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect != v1.TaintEffectNoSchedule && taint.Effect != v1.TaintEffectNoExecute {
+			continue
+		}
+		if strings.HasPrefix(taint.Key, "node.kubernetes.io/") || strings.HasPrefix(taint.Key, "node.cloudprovider.kubernetes.io/") {
+			continue
+		}
+		tolerated := false
+		for j := range podSpec.Tolerations {
+			if podSpec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return "untolerated taint " + taint.ToString()
+		}
+	}
+	return ""
+}
+
+// schedulingPreflight fails when a workload matches no existing node, which
+// would otherwise leave its pods pending until the rollout times out. Daemon
+// sets matching no node just run nowhere, they are left out.
+func (p *Project) schedulingPreflight() error {
+	nodesByContext := make(map[string][]v1.Node)
+	unschedulable := []string{}
+	for _, asset := range p.assets() {
+		podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+		if podSpec == nil || podSpec.NodeName != "" || asset.Kind == "daemonset" {
+			continue
+		}
+		nodes, ok := nodesByContext[asset.context]
+		if !ok {
+			nodeList, err := p.clientFor(asset).Core().Nodes().List(apiv1.ListOptions{})
+			if err == nil {
+				nodes = nodeList.Items
+			}
+			nodesByContext[asset.context] = nodes
+		}
+		if len(nodes) == 0 {
+			// Nodes are not visible to us, nothing to check against
+			continue
+		}
+		reasons := make(map[string]int)
+		fits := false
+		for i := range nodes {
+			reason := nodeRejection(podSpec, &nodes[i])
+			if reason == "" {
+				fits = true
+				break
+			}
+			reasons[reason]++
+		}
+		if fits {
+			continue
+		}
+		summary := []string{}
+		for reason, count := range reasons {
+			summary = append(summary, fmt.Sprintf("%d node(s) rejected by %s", count, reason))
+		}
+		sort.Strings(summary)
+		unschedulable = append(unschedulable, fmt.Sprintf("%s matches no node: %s", assetKey(asset), strings.Join(summary, ", ")))
+	}
+	if len(unschedulable) == 0 {
+		return nil
+	}
+	return validationError(fmt.Errorf("scheduling preflight failed:\n  %s", strings.Join(unschedulable, "\n  ")))
+}
@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeRejection(t *testing.T) {
+	req := require.New(t)
+	node := &v1.Node{
+		ObjectMeta: apiv1.ObjectMeta{Labels: map[string]string{"pool": "gpu", "cores": "16"}},
+		Spec: v1.NodeSpec{Taints: []v1.Taint{
+			{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule},
+			{Key: "spot", Effect: v1.TaintEffectPreferNoSchedule},
+		}},
+	}
+	podSpec := &v1.PodSpec{NodeSelector: map[string]string{"pool": "gpu"}}
+	req.Equal("untolerated taint dedicated=gpu:NoSchedule", nodeRejection(podSpec, node))
+	podSpec.Tolerations = []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "gpu", Effect: v1.TaintEffectNoSchedule}}
+	req.Equal("", nodeRejection(podSpec, node))
+	podSpec.NodeSelector["pool"] = "cpu"
+	req.Equal("node selector pool=cpu", nodeRejection(podSpec, node))
+	delete(podSpec.NodeSelector, "pool")
+
+	podSpec.Affinity = &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{
+			{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "cores", Operator: v1.NodeSelectorOpGt, Values: []string{"32"}}}},
+		}},
+	}}
+	req.Equal("required node affinity", nodeRejection(podSpec, node))
+	podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms = append(
+		podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms,
+		v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "pool", Operator: v1.NodeSelectorOpIn, Values: []string{"gpu", "highmem"}}}},
+	)
+	req.Equal("", nodeRejection(podSpec, node))
+}
+
+func TestNodeRejectionTransientState(t *testing.T) {
+	req := require.New(t)
+	node := &v1.Node{Spec: v1.NodeSpec{
+		Unschedulable: true,
+		Taints: []v1.Taint{
+			{Key: "node.kubernetes.io/unschedulable", Effect: v1.TaintEffectNoSchedule},
+			{Key: "node.kubernetes.io/not-ready", Effect: v1.TaintEffectNoExecute},
+			{Key: "node.cloudprovider.kubernetes.io/uninitialized", Value: "true", Effect: v1.TaintEffectNoSchedule},
+		},
+	}}
+	req.Equal("", nodeRejection(&v1.PodSpec{}, node))
+}
+
+func TestSchedulingPreflight(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.add("/api/v1/nodes/gpu-1", `{"apiVersion":"v1","kind":"Node","metadata":{"name":"gpu-1","labels":{"pool":"gpu"}}}`)
+	newAsset := func(kind, apiVersion string) *Asset {
+		asset, err := parseAsset("web.yml", []byte("apiVersion: "+apiVersion+"\nkind: "+kind+"\nmetadata:\n  name: web\n  namespace: web\nspec:\n  template:\n    spec:\n      nodeSelector:\n        pool: cpu\n      containers:\n      - name: web\n        image: nginx\n"))
+		req.Nil(err)
+		return asset
+	}
+	p := &Project{
+		kubeClient:    kubeClient,
+		config:        &appConfig{},
+		projectConfig: &ProjectConfig{Namespace: "web"},
+		services:      []*Asset{newAsset("DaemonSet", "extensions/v1beta1")},
+	}
+	// A daemon set matching no node is not an error
+	req.Nil(p.schedulingPreflight())
+
+	p.services = append(p.services, newAsset("Deployment", "extensions/v1beta1"))
+	err := p.schedulingPreflight()
+	req.Error(err)
+	req.Contains(err.Error(), "deployment/web matches no node: 1 node(s) rejected by node selector pool=cpu")
+}
@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	redactedValue      = "<redacted>"
+	minSensitiveLength = 6
+	// maxSensitiveValues bounds what a long running server remembers, the
+	// values of a project are registered again each time it loads
+	maxSensitiveValues = 1024
+)
+
+// scrubber holds the secret values we know of (-set-secret values, Secret
+// manifest data) so they can be scrubbed from anything we print or send
+type scrubber struct {
+	sync.Mutex
+	// values from the least to the most recently registered
+	values []string
+	// longest first, so a value containing another is replaced whole
+	ordered []string
+}
+
+var sensitiveValues = &scrubber{}
+
+func registerSensitive(value string) {
+	sensitiveValues.register(value)
+}
+
+func scrub(s string) string {
+	return sensitiveValues.scrub(s)
+}
+
+func (s *scrubber) register(value string) {
+	if len(value) < minSensitiveLength {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	// API errors echo Secret data base64 encoded, so scrub that form too
+	for _, form := range []string{value, base64.StdEncoding.EncodeToString([]byte(value))} {
+		for i, known := range s.values {
+			if known == form {
+				s.values = append(s.values[:i], s.values[i+1:]...)
+				break
+			}
+		}
+		s.values = append(s.values, form)
+	}
+	if len(s.values) > maxSensitiveValues {
+		s.values = s.values[len(s.values)-maxSensitiveValues:]
+	}
+	s.ordered = append([]string{}, s.values...)
+	sort.SliceStable(s.ordered, func(i, j int) bool {
+		return len(s.ordered[i]) > len(s.ordered[j])
+	})
+}
+
+func (s *scrubber) scrub(text string) string {
+	s.Lock()
+	defer s.Unlock()
+	for _, value := range s.ordered {
+		text = replaceToken(text, value)
+	}
+	return text
+}
+
+// replaceToken replaces value where it stands on its own rather than inside a
+// longer word, a secret "foobar" leaves "foobarbaz" alone
+func replaceToken(text, value string) string {
+	if !strings.Contains(text, value) {
+		return text
+	}
+	result := &strings.Builder{}
+	for {
+		i := strings.Index(text, value)
+		if i < 0 {
+			result.WriteString(text)
+			return result.String()
+		}
+		end := i + len(value)
+		if (i > 0 && isWordByte(value[0]) && isWordByte(text[i-1])) || (end < len(text) && isWordByte(value[len(value)-1]) && isWordByte(text[end])) {
+			result.WriteString(text[:i+1])
+			text = text[i+1:]
+			continue
+		}
+		result.WriteString(text[:i])
+		result.WriteString(redactedValue)
+		text = text[end:]
+	}
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
@@ -0,0 +1,55 @@
+package deploy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// isolateSensitiveValues gives the test its own scrubber, so values it
+// registers don't leak into other tests
+func isolateSensitiveValues(t *testing.T) {
+	previous := sensitiveValues
+	sensitiveValues = &scrubber{}
+	t.Cleanup(func() { sensitiveValues = previous })
+}
+
+func TestScrub(t *testing.T) {
+	req := require.New(t)
+	s := &scrubber{}
+	s.register("s3cr3t-token")
+	s.register("abc")
+	s.register("admin1")
+	req.Equal("token is <redacted>", s.scrub("token is s3cr3t-token"))
+	req.Equal("data: <redacted>", s.scrub("data: "+base64.StdEncoding.EncodeToString([]byte("s3cr3t-token"))))
+	req.Equal("abc is too short to scrub", s.scrub("abc is too short to scrub"))
+
+	// Only whole words, not pieces of longer ones
+	req.Equal("user <redacted> logged in as admin12", s.scrub("user admin1 logged in as admin12"))
+	req.Equal("postgres://<redacted>:<redacted>@db", s.scrub("postgres://admin1:s3cr3t-token@db"))
+}
+
+func TestScrubBound(t *testing.T) {
+	req := require.New(t)
+	s := &scrubber{}
+	for i := 0; i < maxSensitiveValues; i++ {
+		s.register(fmt.Sprintf("secret-%d", i))
+	}
+	req.Len(s.values, maxSensitiveValues)
+	// The oldest values go first, unless they are registered again
+	s.register("secret-0")
+	s.register("secret-new")
+	req.Len(s.values, maxSensitiveValues)
+	req.Equal("<redacted>", s.scrub("secret-0"))
+	req.Equal("<redacted>", s.scrub("secret-new"))
+	req.Equal("secret-1", s.scrub("secret-1"))
+}
+
+func TestRegisterSensitive(t *testing.T) {
+	req := require.New(t)
+	isolateSensitiveValues(t)
+	registerSensitive("hunter2")
+	req.Equal("password <redacted>", scrub("password hunter2"))
+}
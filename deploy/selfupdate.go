@@ -0,0 +1,216 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultReleaseURL = "https://api.github.com/repos/anduintransaction/imladris/releases/latest"
+	checksumsAsset    = "SHA256SUMS"
+	signatureAsset    = "SHA256SUMS.sig"
+)
+
+// updatePublicKey is the base64 ed25519 key release checksums are signed
+// with. It is set at build time with -ldflags "-X ...updatePublicKey=...",
+// only the release build can vouch for the key.
+var updatePublicKey = ""
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (release *githubRelease) assetURL(name string) (string, error) {
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			return asset.URL, nil
+		}
+	}
+	return "", fmt.Errorf("release %s has no %s", release.TagName, name)
+}
+
+// newerVersion compares dotted versions like 0.13.1, a leading v is ignored
+func newerVersion(current, latest string) bool {
+	currentParts := strings.Split(strings.TrimPrefix(current, "v"), ".")
+	latestParts := strings.Split(strings.TrimPrefix(latest, "v"), ".")
+	for i := 0; i < len(currentParts) || i < len(latestParts); i++ {
+		var currentPart, latestPart int
+		if i < len(currentParts) {
+			currentPart, _ = strconv.Atoi(currentParts[i])
+		}
+		if i < len(latestParts) {
+			latestPart, _ = strconv.Atoi(latestParts[i])
+		}
+		if latestPart != currentPart {
+			return latestPart > currentPart
+		}
+	}
+	return false
+}
+
+func releaseArchiveName(version string) string {
+	return fmt.Sprintf("imladris-%s-%s-%s.tar.gz", strings.TrimPrefix(version, "v"), runtime.GOOS, runtime.GOARCH)
+}
+
+func verifyChecksums(checksums, signature []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid update public key")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("invalid checksums signature: %s", err.Error())
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), checksums, decoded) {
+		return fmt.Errorf("checksums signature does not match the update public key")
+	}
+	return nil
+}
+
+// parseChecksums reads the output of sha256sum
+func parseChecksums(data []byte) map[string]string {
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			checksums[strings.TrimPrefix(fields[1], "*")] = fields[0]
+		}
+	}
+	return checksums
+}
+
+func extractBinary(archive []byte, name string) ([]byte, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in release archive", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Base(header.Name) == name {
+			return ioutil.ReadAll(tarReader)
+		}
+	}
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot download %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func selfUpdate(currentVersion string) error {
+	publicKey := updatePublicKey
+	if publicKey == "" {
+		return validationError(fmt.Errorf("this build has no update public key, download releases by hand"))
+	}
+	releaseURL := defaultReleaseURL
+	if os.Getenv("IMLADRIS_UPDATE_URL") != "" {
+		releaseURL = os.Getenv("IMLADRIS_UPDATE_URL")
+	}
+	release := &githubRelease{}
+	err := doJSONRequest("GET", releaseURL, nil, nil, release)
+	if err != nil {
+		return err
+	}
+	if !newerVersion(currentVersion, release.TagName) {
+		Printf(ColorGray, "Already at the latest version %s\n", currentVersion)
+		return nil
+	}
+	Printf(ColorYellow, "Updating from %s to %s\n", currentVersion, release.TagName)
+	files := make(map[string][]byte)
+	archiveName := releaseArchiveName(release.TagName)
+	for _, name := range []string{checksumsAsset, signatureAsset, archiveName} {
+		url, err := release.assetURL(name)
+		if err != nil {
+			return err
+		}
+		files[name], err = download(url)
+		if err != nil {
+			return err
+		}
+	}
+	err = verifyChecksums(files[checksumsAsset], files[signatureAsset], publicKey)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(files[archiveName])
+	if parseChecksums(files[checksumsAsset])[archiveName] != hex.EncodeToString(hash[:]) {
+		return fmt.Errorf("checksum of %s does not match the signed checksums", archiveName)
+	}
+	binaryName := "imladris"
+	if runtime.GOOS == "windows" {
+		binaryName += ".exe"
+	}
+	binary, err := extractBinary(files[archiveName], binaryName)
+	if err != nil {
+		return err
+	}
+	return replaceExecutable(binary)
+}
+
+// replaceExecutable writes next to the running binary and renames over it.
+// Windows can't replace a running executable but can rename it away.
+func replaceExecutable(binary []byte) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return err
+	}
+	newFile := executable + ".new"
+	err = ioutil.WriteFile(newFile, binary, os.FileMode(0755))
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		oldFile := executable + ".old"
+		os.Remove(oldFile)
+		err = os.Rename(executable, oldFile)
+		if err != nil {
+			os.Remove(newFile)
+			return err
+		}
+	}
+	err = os.Rename(newFile, executable)
+	if err != nil {
+		os.Remove(newFile)
+		return err
+	}
+	Printf(ColorGreen, "Replaced %s\n", executable)
+	return nil
+}
@@ -0,0 +1,54 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewerVersion(t *testing.T) {
+	req := require.New(t)
+	req.True(newerVersion("0.13.1", "v0.14.0"))
+	req.True(newerVersion("0.13.1", "0.13.10"))
+	req.False(newerVersion("0.13.1", "0.13.1"))
+	req.False(newerVersion("0.13.1", "0.9.9"))
+	req.True(newerVersion("0.13", "0.13.1"))
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	req := require.New(t)
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	req.NoError(err)
+	encodedKey := base64.StdEncoding.EncodeToString(publicKey)
+	checksums := []byte("abc123  imladris-0.14.0-linux-amd64.tar.gz\n")
+	signature := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, checksums)) + "\n")
+
+	req.NoError(verifyChecksums(checksums, signature, encodedKey))
+	req.Error(verifyChecksums([]byte("evil  imladris-0.14.0-linux-amd64.tar.gz\n"), signature, encodedKey))
+	req.Error(verifyChecksums(checksums, signature, "not a key"))
+	req.Equal(map[string]string{"imladris-0.14.0-linux-amd64.tar.gz": "abc123"}, parseChecksums(checksums))
+}
+
+func TestExtractBinary(t *testing.T) {
+	req := require.New(t)
+	buf := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	req.NoError(tarWriter.WriteHeader(&tar.Header{Name: "imladris", Mode: 0755, Size: 6}))
+	_, err := tarWriter.Write([]byte("binary"))
+	req.NoError(err)
+	req.NoError(tarWriter.Close())
+	req.NoError(gzipWriter.Close())
+
+	binary, err := extractBinary(buf.Bytes(), "imladris")
+	req.NoError(err)
+	req.Equal("binary", string(binary))
+	_, err = extractBinary(buf.Bytes(), "imladris.exe")
+	req.Error(err)
+}
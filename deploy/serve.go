@@ -0,0 +1,132 @@
+package deploy
+
+import (
+	"path/filepath"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	pauseAnnotation  = "imladris/paused"
+	syncStatusPrefix = "imladris-status-"
+)
+
+type syncStatus struct {
+	Commit   string
+	Result   string
+	Message  string
+	LastSync time.Time
+}
+
+func serveProject(kubeClient *kubernetes.Clientset, repoFolder, projectPath string, config *appConfig) {
+	Printf(ColorYellow, "Reconciling %q from %q every %s\n", projectPath, config.gitRef, config.syncInterval)
+	assetRoot := filepath.Join(repoFolder, projectPath)
+	var lastCommit string
+	var lastLive map[string]string
+	for {
+		lastCommit, lastLive = syncProject(kubeClient, repoFolder, assetRoot, config, lastCommit, lastLive)
+		time.Sleep(config.syncInterval)
+	}
+}
+
+// syncProject checks out the ref and applies the project when the commit
+// changed or the cluster drifted, and returns the commit and fingerprints the
+// next pass compares with. It applies rather than updates, resources new in
+// the commit or deleted from the cluster get created.
+func syncProject(kubeClient *kubernetes.Clientset, repoFolder, assetRoot string, config *appConfig, lastCommit string, lastLive map[string]string) (string, map[string]string) {
+	commit, err := gitSync(repoFolder, config.gitRef)
+	if err != nil {
+		ErrPrintf(ColorRed, "Cannot sync %q: %s\n", config.gitRef, err.Error())
+		return lastCommit, lastLive
+	}
+	project, err := readProject(kubeClient, assetRoot, config)
+	if err != nil {
+		ErrPrintln(ColorRed, err)
+		return lastCommit, lastLive
+	}
+	paused, err := isNamespacePaused(kubeClient, project.projectConfig.Namespace)
+	if err != nil {
+		ErrPrintln(ColorRed, err)
+		return lastCommit, lastLive
+	}
+	if paused {
+		Printf(ColorPurple, "Namespace %q is paused, skipping reconcile\n", project.projectConfig.Namespace)
+		project.reportSyncStatus(&syncStatus{Commit: lastCommit, Result: "paused", LastSync: time.Now().UTC()})
+		return lastCommit, lastLive
+	}
+	live, err := project.liveFingerprints()
+	if err != nil {
+		ErrPrintln(ColorRed, err)
+		return lastCommit, lastLive
+	}
+	if commit != lastCommit || !sameFingerprints(live, lastLive) {
+		if commit == lastCommit {
+			project.observeDrift()
+			project.skipUnchanged = false
+			project.reconciling = true
+		}
+		Printf(ColorYellow, "Reconciling commit %s\n", commit)
+		status := &syncStatus{Commit: commit, Result: "synced", LastSync: time.Now().UTC()}
+		started := project.startDeploy("apply")
+		err = project.deploy("apply", project.applyAsset)
+		project.finishDeploy("apply", started, err)
+		if err != nil {
+			ErrPrintln(ColorRed, err)
+			status.Result = "failed"
+			status.Message = err.Error()
+		} else {
+			lastCommit = commit
+		}
+		project.reportSyncStatus(status)
+		live, err = project.liveFingerprints()
+		if err != nil {
+			ErrPrintln(ColorRed, err)
+		}
+	}
+	return lastCommit, live
+}
+
+func isNamespacePaused(kubeClient *kubernetes.Clientset, namespace string) (bool, error) {
+	ns, err := kubeClient.Core().Namespaces().Get(namespace, apiv1.GetOptions{})
+	if err != nil {
+		if isResourceNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return ns.Annotations[pauseAnnotation] == "true", nil
+}
+
+func (p *Project) reportSyncStatus(status *syncStatus) {
+	data := map[string]string{
+		"commit":    status.Commit,
+		"result":    status.Result,
+		"message":   status.Message,
+		"last_sync": status.LastSync.Format(time.RFC3339),
+	}
+	name := syncStatusPrefix + p.projectConfig.Name
+	configMaps := p.kubeClient.Core().ConfigMaps(p.projectConfig.Namespace)
+	configMap, err := configMaps.Get(name, apiv1.GetOptions{})
+	if err != nil {
+		if !isResourceNotExist(err) {
+			p.printer.ErrPrintf(ColorRed, "Cannot report sync status: %s\n", err.Error())
+			return
+		}
+		_, err = configMaps.Create(&v1.ConfigMap{
+			ObjectMeta: apiv1.ObjectMeta{
+				Name:      name,
+				Namespace: p.projectConfig.Namespace,
+			},
+			Data: data,
+		})
+	} else {
+		configMap.Data = data
+		_, err = configMaps.Update(configMap)
+	}
+	if err != nil {
+		p.printer.ErrPrintf(ColorRed, "Cannot report sync status: %s\n", err.Error())
+	}
+}
@@ -0,0 +1,111 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func gitCommitFile(t *testing.T, folder, name, content string) string {
+	require.Nil(t, ioutil.WriteFile(filepath.Join(folder, name), []byte(content), 0644))
+	_, err := runGit(folder, "add", name)
+	require.Nil(t, err)
+	_, err = runGit(folder, "commit", "-q", "-m", "update "+name)
+	require.Nil(t, err)
+	commit, err := runGit(folder, "rev-parse", "HEAD")
+	require.Nil(t, err)
+	return commit
+}
+
+func TestGitSync(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("GIT_AUTHOR_NAME", "imladris")
+	t.Setenv("GIT_AUTHOR_EMAIL", "imladris@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "imladris")
+	t.Setenv("GIT_COMMITTER_EMAIL", "imladris@example.com")
+	origin := t.TempDir()
+	_, err := runGit(origin, "init", "-q")
+	req.Nil(err)
+	_, err = runGit(origin, "symbolic-ref", "HEAD", "refs/heads/master")
+	req.Nil(err)
+	first := gitCommitFile(t, origin, "project.yml", "name: web\n")
+	clone := filepath.Join(t.TempDir(), "clone")
+	_, err = runGit(origin, "clone", "-q", origin, clone)
+	req.Nil(err)
+
+	commit, err := gitSync(clone, "origin/master")
+	req.Nil(err)
+	req.Equal(first, commit)
+
+	second := gitCommitFile(t, origin, "project.yml", "name: api\n")
+	commit, err = gitSync(clone, "origin/master")
+	req.Nil(err)
+	req.Equal(second, commit)
+	data, err := ioutil.ReadFile(filepath.Join(clone, "project.yml"))
+	req.Nil(err)
+	req.Equal("name: api\n", string(data))
+
+	_, err = gitSync(clone, "origin/missing")
+	req.Error(err)
+}
+
+func TestSyncProjectCreatesNewResources(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("GIT_AUTHOR_NAME", "imladris")
+	t.Setenv("GIT_AUTHOR_EMAIL", "imladris@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "imladris")
+	t.Setenv("GIT_COMMITTER_EMAIL", "imladris@example.com")
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.serveDiscovery()
+	cluster.add("/api/v1/namespaces/web", `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"web"}}`)
+	origin := t.TempDir()
+	_, err := runGit(origin, "init", "-q")
+	req.Nil(err)
+	_, err = runGit(origin, "symbolic-ref", "HEAD", "refs/heads/master")
+	req.Nil(err)
+	req.Nil(os.Mkdir(filepath.Join(origin, "services"), 0755))
+	gitCommitFile(t, origin, "project.yml", "name: web\nnamespace: web\n")
+	first := gitCommitFile(t, origin, "services/web.yml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  color: blue\n")
+	clone := filepath.Join(t.TempDir(), "clone")
+	_, err = runGit(origin, "clone", "-q", origin, clone)
+	req.Nil(err)
+	config := &appConfig{gitRef: "origin/master", printer: newPrinter(ioutil.Discard, ioutil.Discard)}
+
+	commit, live := syncProject(kubeClient, clone, clone, config, "", nil)
+	req.Equal(first, commit)
+	req.NotNil(cluster.get("/api/v1/namespaces/web/configmaps/web"))
+
+	second := gitCommitFile(t, origin, "services/api.yml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: api\ndata:\n  color: green\n")
+	commit, live = syncProject(kubeClient, clone, clone, config, commit, live)
+	req.Equal(second, commit)
+	req.NotNil(cluster.get("/api/v1/namespaces/web/configmaps/api"))
+	req.Equal("synced", cluster.get("/api/v1/namespaces/web/configmaps/imladris-status-web")["data"].(map[string]interface{})["result"])
+
+	// Deleted from the cluster, the next pass creates it again
+	req.Nil(kubeClient.Core().ConfigMaps("web").Delete("api", nil))
+	syncProject(kubeClient, clone, clone, config, commit, live)
+	req.NotNil(cluster.get("/api/v1/namespaces/web/configmaps/api"))
+}
+
+func TestSyncStatus(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	paused, err := isNamespacePaused(kubeClient, "web")
+	req.Nil(err)
+	req.False(paused)
+	cluster.add("/api/v1/namespaces/web", `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"web","annotations":{"imladris/paused":"true"}}}`)
+	paused, err = isNamespacePaused(kubeClient, "web")
+	req.Nil(err)
+	req.True(paused)
+
+	p := &Project{kubeClient: kubeClient, projectConfig: &ProjectConfig{Name: "web", Namespace: "web"}}
+	synced := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	p.reportSyncStatus(&syncStatus{Commit: "abc123", Result: "synced", LastSync: synced})
+	p.reportSyncStatus(&syncStatus{Commit: "def456", Result: "failed", Message: "timeout", LastSync: synced})
+	status := cluster.get("/api/v1/namespaces/web/configmaps/imladris-status-web")["data"]
+	req.Equal(map[string]interface{}{"commit": "def456", "result": "failed", "message": "timeout", "last_sync": "2017-01-02T03:04:05Z"}, status)
+}
@@ -0,0 +1,373 @@
+package deploy
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const serverTokenEnv = "IMLADRIS_SERVER_TOKEN"
+
+// deployRequest asks the server to run one action on a project found under
+// its projects folder, against any context of its kubeconfig
+type deployRequest struct {
+	Project   string            `json:"project"`
+	Action    string            `json:"action"`
+	Context   string            `json:"context"`
+	Namespace string            `json:"namespace"`
+	Variables map[string]string `json:"variables"`
+	Secrets   map[string]string `json:"secrets"`
+}
+
+// deployResult is the last line of every streamed response
+type deployResult struct {
+	Result  string    `json:"result"`
+	Error   string    `json:"error,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Changes []*Change `json:"changes,omitempty"`
+}
+
+type deployServer struct {
+	projectsFolder string
+	token          string
+	config         *appConfig
+	queue          *deployQueue
+}
+
+func newDeployServer(projectsFolder string, config *appConfig) (*deployServer, error) {
+	token := os.Getenv(serverTokenEnv)
+	if token == "" {
+		return nil, validationError(fmt.Errorf("%s must be set, the server refuses unauthenticated deploys", serverTokenEnv))
+	}
+	server := &deployServer{
+		projectsFolder: projectsFolder,
+		token:          token,
+		config:         config,
+	}
+	server.queue = newDeployQueue(server.execute)
+	return server, nil
+}
+
+func (s *deployServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/deploy", s.handleDeploy)
+	mux.HandleFunc("/queue", s.handleQueue)
+	mux.HandleFunc("/jobs/", s.handleJob)
+	mux.HandleFunc("/rollback", s.handleRollback)
+	mux.HandleFunc("/webhook/registry", s.handleRegistryWebhook)
+	mux.HandleFunc("/webhook/alertmanager", s.handleAlertmanagerWebhook)
+	return mux
+}
+
+// authorized checks the bearer token of the Authorization header
+func (s *deployServer) authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	return s.validToken(strings.TrimPrefix(header, "Bearer "))
+}
+
+// webhookAuthorized also takes the token from the query string, registries
+// and alertmanager can't always set headers on their webhooks. Query strings
+// end up in access logs, so the other routes don't accept it.
+func (s *deployServer) webhookAuthorized(r *http.Request) bool {
+	if s.authorized(r) {
+		return true
+	}
+	return s.validToken(r.URL.Query().Get("token"))
+}
+
+func (s *deployServer) validToken(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// projectFolder keeps the requested project inside the projects folder
+func (s *deployServer) projectFolder(project string) string {
+	return filepath.Join(s.projectsFolder, filepath.Clean("/"+project))
+}
+
+// readDeployRequest decodes and checks the request of /deploy and /queue,
+// answering the client itself when it is invalid
+func (s *deployServer) readDeployRequest(w http.ResponseWriter, r *http.Request) (*deployRequest, bool) {
+	if r.Method != "POST" {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if !s.authorized(r) {
+		http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+		return nil, false
+	}
+	request := &deployRequest{}
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	switch request.Action {
+	case "":
+		request.Action = "apply"
+	case "plan", "apply", "destroy":
+	default:
+		http.Error(w, fmt.Sprintf("unknown action %q, expected plan, apply or destroy", request.Action), http.StatusBadRequest)
+		return nil, false
+	}
+	if request.Project == "" {
+		http.Error(w, "project is required", http.StatusBadRequest)
+		return nil, false
+	}
+	return request, true
+}
+
+func (s *deployServer) deployJob(request *deployRequest, remoteAddr string) *deployJob {
+	return &deployJob{
+		Project: request.Project,
+		Action:  request.Action,
+		Target:  s.target(request.Context, s.namespace(request)),
+		run: func(out *printer) *deployResult {
+			out.Printf(ColorYellow, "Running %s of %q requested from %s\n", request.Action, request.Project, remoteAddr)
+			return s.run(request, out)
+		},
+	}
+}
+
+// handleDeploy queues a deploy and streams its output once its turn comes
+func (s *deployServer) handleDeploy(w http.ResponseWriter, r *http.Request) {
+	request, ok := s.readDeployRequest(w, r)
+	if !ok {
+		return
+	}
+	s.stream(w, s.deployJob(request, r.RemoteAddr))
+}
+
+// handleQueue queues a deploy and answers right away with the job to poll
+// at /jobs/<id>. GET lists the running and queued jobs of every target.
+func (s *deployServer) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		if !s.authorized(r) {
+			http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.queue.pending())
+		return
+	}
+	request, ok := s.readDeployRequest(w, r)
+	if !ok {
+		return
+	}
+	s.accept(w, s.deployJob(request, r.RemoteAddr))
+}
+
+// handleRollback queues a rollback of the latest release of a project, for
+// alerting systems to revert on an SLO breach. It answers with the job to
+// poll like /queue.
+func (s *deployServer) handleRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+		return
+	}
+	request := &deployRequest{}
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Project == "" {
+		http.Error(w, "project is required", http.StatusBadRequest)
+		return
+	}
+	request.Action = "rollback"
+	s.accept(w, s.deployJob(request, r.RemoteAddr))
+}
+
+// accept queues a job and answers right away with it
+func (s *deployServer) accept(w http.ResponseWriter, job *deployJob) {
+	s.queue.enqueue(job)
+	snapshot, _ := s.queue.get(job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// handleJob reports the status of a job, with its output so far
+func (s *deployServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+		return
+	}
+	job, ok := s.queue.get(strings.TrimPrefix(r.URL.Path, "/jobs/"))
+	if !ok {
+		http.Error(w, "unknown job", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// target is what jobs are serialized on: the context and namespace they
+// deploy to. Jobs of different targets run side by side.
+func (s *deployServer) target(context, namespace string) string {
+	if context == "" {
+		context = s.config.context
+	}
+	return context + "/" + namespace
+}
+
+// namespace resolves the namespace of a request before it is queued, like
+// resolveNamespace does once the project is read, so a request naming the
+// namespace of the project and one leaving it out wait in the same queue
+func (s *deployServer) namespace(request *deployRequest) string {
+	if request.Namespace != "" {
+		return request.Namespace
+	}
+	projectFile := s.projectFolder(request.Project)
+	if info, err := os.Stat(projectFile); err == nil && info.IsDir() {
+		projectFile = filepath.Join(projectFile, "project.yml")
+	}
+	document, err := readProjectFile(projectFile, variableMap(request.Variables), nil)
+	if namespace, _ := document["namespace"].(string); err == nil && namespace != "" {
+		return namespace
+	}
+	if namespace := contextNamespace(newAppConfig(s.options(request.Context, "", nil))); namespace != "" {
+		return namespace
+	}
+	return "default"
+}
+
+// webhookTarget is the target of a webhook job, resolved like the one of a
+// deploy from the project, context and namespace of the query
+func (s *deployServer) webhookTarget(query url.Values) string {
+	request := &deployRequest{Project: query.Get("project"), Context: query.Get("context"), Namespace: query.Get("namespace")}
+	return s.target(request.Context, s.namespace(request))
+}
+
+// stream queues a job and sends everything printed while it runs back to
+// the client, followed by the result as a JSON line
+func (s *deployServer) stream(w http.ResponseWriter, job *deployJob) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	output := &flushWriter{w: w}
+	output.flusher, _ = w.(http.Flusher)
+	job.stream = output
+	ahead := s.queue.enqueue(job)
+	if ahead > 0 {
+		fmt.Fprintf(output, "Job %s waits for %d jobs on %q\n", job.ID, ahead, job.Target)
+	}
+	<-job.done
+	data, _ := json.Marshal(job.Result)
+	fmt.Fprintln(output, scrub(string(data)))
+}
+
+// execute runs a job with everything it prints also going to its output
+// and, for /deploy, to the client
+func (s *deployServer) execute(job *deployJob) *deployResult {
+	writers := []io.Writer{job.output}
+	if job.stream != nil {
+		writers = append(writers, job.stream)
+	}
+	out := newPrinter(
+		io.MultiWriter(append([]io.Writer{os.Stdout}, writers...)...),
+		io.MultiWriter(append([]io.Writer{os.Stderr}, writers...)...),
+	)
+	return job.run(out)
+}
+
+// destroyReviewEnvironment destroys an expired review environment as a job
+// on the target of its namespace, after the deploys queued into it
+func (s *deployServer) destroyReviewEnvironment(kubeClient *kubernetes.Clientset, env *reviewEnvironment) error {
+	job := &deployJob{
+		Project: env.project,
+		Action:  "env-destroy",
+		Target:  s.target("", env.namespace),
+		run: func(out *printer) *deployResult {
+			err := destroyReviewEnvironment(kubeClient, out, env.namespace)
+			if err != nil {
+				return &deployResult{Result: "failed", Error: err.Error()}
+			}
+			return &deployResult{Result: "succeeded"}
+		},
+	}
+	s.queue.enqueue(job)
+	<-job.done
+	if job.Result.Error != "" {
+		return errors.New(job.Result.Error)
+	}
+	return nil
+}
+
+// load reads a project for a job
+func (s *deployServer) load(project string, options *Options) (*Deployment, error) {
+	return load(s.projectFolder(project), newAppConfig(options))
+}
+
+func (s *deployServer) options(context, namespace string, out *printer) *Options {
+	options := &Options{
+		Kubeconfig: s.config.configFile,
+		Context:    s.config.context,
+		Namespace:  namespace,
+		Timeout:    s.config.timeout,
+		Stdout:     out.Stdout(),
+		Stderr:     out.Stderr(),
+	}
+	if context != "" {
+		options.Context = context
+	}
+	return options
+}
+
+func (s *deployServer) run(request *deployRequest, out *printer) *deployResult {
+	options := s.options(request.Context, request.Namespace, out)
+	options.Variables = request.Variables
+	options.Secrets = request.Secrets
+	result := &deployResult{Result: "succeeded"}
+	deployment, err := s.load(request.Project, options)
+	if err == nil {
+		switch request.Action {
+		case "plan":
+			result.Changes, err = deployment.Plan()
+		case "apply":
+			err = deployment.Apply()
+		case "destroy":
+			err = deployment.Destroy()
+		case "rollback":
+			err = deployment.Rollback()
+		}
+	}
+	if err != nil {
+		out.ErrPrintln(ColorRed, err)
+		result.Result = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (fw *flushWriter) Write(data []byte) (int, error) {
+	n, err := fw.w.Write(data)
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,148 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeployServerRequests(t *testing.T) {
+	req := require.New(t)
+	t.Setenv(serverTokenEnv, "s3cret")
+	server, err := newDeployServer("/srv/projects", &appConfig{})
+	req.NoError(err)
+	req.Equal("/srv/projects/billing", server.projectFolder("billing"))
+	req.Equal("/srv/projects/etc/passwd", server.projectFolder("../../etc/passwd"))
+
+	send := func(token, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("POST", "/deploy", strings.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		server.handler().ServeHTTP(recorder, request)
+		return recorder
+	}
+	req.Equal(http.StatusUnauthorized, send("", `{"project": "billing"}`).Code)
+	req.Equal(http.StatusUnauthorized, send("guess", `{"project": "billing"}`).Code)
+	req.Equal(http.StatusBadRequest, send("s3cret", `{"project": "billing", "action": "rollback"}`).Code)
+	req.Equal(http.StatusBadRequest, send("s3cret", `{"action": "plan"}`).Code)
+
+	rollback := httptest.NewRequest("POST", "/rollback", strings.NewReader(`{"context": "prod"}`))
+	rollback.Header.Set("Authorization", "Bearer s3cret")
+	recorder := httptest.NewRecorder()
+	server.handler().ServeHTTP(recorder, rollback)
+	req.Equal(http.StatusBadRequest, recorder.Code, "rollback needs a project")
+
+	request := httptest.NewRequest("POST", "/webhook/registry?token=s3cret", strings.NewReader(`{}`))
+	recorder = httptest.NewRecorder()
+	server.handler().ServeHTTP(recorder, request)
+	req.Equal(http.StatusBadRequest, recorder.Code, "token in the query is accepted, the project is missing")
+
+	// Only webhooks take the token from the query string
+	for _, path := range []string{"/deploy", "/queue", "/rollback", "/jobs/1"} {
+		request = httptest.NewRequest("POST", path+"?token=s3cret", strings.NewReader(`{"project": "billing"}`))
+		recorder = httptest.NewRecorder()
+		server.handler().ServeHTTP(recorder, request)
+		req.Equal(http.StatusUnauthorized, recorder.Code, path)
+	}
+
+	t.Setenv(serverTokenEnv, "")
+	_, err = newDeployServer("/srv/projects", &appConfig{})
+	req.Error(err)
+}
+
+func TestDeployQueue(t *testing.T) {
+	req := require.New(t)
+	lock := sync.Mutex{}
+	order := []string{}
+	release := make(chan struct{})
+	queue := newDeployQueue(func(job *deployJob) *deployResult {
+		if job.Project == "first" {
+			<-release
+		}
+		lock.Lock()
+		order = append(order, job.Project)
+		lock.Unlock()
+		fmt.Fprintf(job.output, "deployed %s\n", job.Project)
+		return &deployResult{Result: "succeeded"}
+	})
+	first := &deployJob{Project: "first", Target: "prod/web"}
+	second := &deployJob{Project: "second", Target: "prod/web"}
+	other := &deployJob{Project: "other", Target: "staging/web"}
+	req.Equal(0, queue.enqueue(first))
+	req.Equal(1, queue.enqueue(second))
+	req.Equal(0, queue.enqueue(other))
+	<-other.done
+
+	status, ok := queue.get(second.ID)
+	req.True(ok)
+	req.Equal("queued", status.Status)
+	req.Equal(1, status.Position)
+	req.Len(queue.pending()["prod/web"], 2)
+
+	close(release)
+	<-second.done
+	req.Equal([]string{"other", "first", "second"}, order)
+	status, _ = queue.get(second.ID)
+	req.Equal("succeeded", status.Status)
+	req.Equal("deployed second\n", status.Output)
+	req.Empty(queue.pending())
+	_, ok = queue.get("unknown")
+	req.False(ok)
+}
+
+func TestServerTargets(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv(serverTokenEnv, "s3cret")
+	folder := t.TempDir()
+	req.NoError(os.MkdirAll(filepath.Join(folder, "billing"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(folder, "billing", "project.yml"), []byte("name: billing\nnamespace: money\n"), 0644))
+	s, err := newDeployServer(folder, &appConfig{context: "prod"})
+	req.NoError(err)
+
+	// The namespace of the project is resolved before the job is queued
+	req.Equal("prod/money", s.deployJob(&deployRequest{Project: "billing"}, "").Target)
+	req.Equal("prod/money", s.deployJob(&deployRequest{Project: "billing", Namespace: "money"}, "").Target)
+	req.Equal("staging/money", s.deployJob(&deployRequest{Project: "billing", Context: "staging"}, "").Target)
+	req.Equal("prod/other", s.deployJob(&deployRequest{Project: "billing", Namespace: "other"}, "").Target)
+	req.Equal("prod/default", s.deployJob(&deployRequest{Project: "unknown"}, "").Target)
+	// and so is the one of webhooks, they wait behind deploys of the project
+	req.Equal("prod/money", s.webhookTarget(url.Values{"project": {"billing"}}))
+	req.Equal("staging/other", s.webhookTarget(url.Values{"project": {"billing"}, "context": {"staging"}, "namespace": {"other"}}))
+
+	// Jobs of different targets run side by side
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	run := func(out *printer) *deployResult {
+		started <- "started"
+		<-release
+		return &deployResult{Result: "succeeded"}
+	}
+	first := &deployJob{Project: "billing", Target: "prod/money", run: run}
+	second := &deployJob{Project: "billing", Target: "staging/money", run: run}
+	s.queue.enqueue(first)
+	s.queue.enqueue(second)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("jobs of different targets wait for each other")
+		}
+	}
+	close(release)
+	<-first.done
+	<-second.done
+}
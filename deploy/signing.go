@@ -0,0 +1,212 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	signatureKeyEnv      = "IMLADRIS_SIGNATURE_KEY"
+	signatureContextsEnv = "IMLADRIS_SIGNATURE_CONTEXTS"
+	signatureMethodEnv   = "IMLADRIS_SIGNATURE_METHOD"
+)
+
+// SigningConfig tells `imladris sign` how to sign the manifests and where
+// the signature goes. Which contexts require a signature and the key that
+// verifies it are set on the deploy host, see signaturePolicy.
+type SigningConfig struct {
+	Method    string `yaml:"method"`
+	Key       string `yaml:"key"`
+	Signature string `yaml:"signature"`
+}
+
+// signaturePolicy is what the deploy host requires. It is read from the
+// environment, a project file can't turn off the verification of itself.
+type signaturePolicy struct {
+	method    string
+	publicKey string
+	contexts  []string
+}
+
+func readSignaturePolicy() *signaturePolicy {
+	policy := &signaturePolicy{
+		method:    os.Getenv(signatureMethodEnv),
+		publicKey: os.Getenv(signatureKeyEnv),
+		contexts:  contextPatterns(os.Getenv(signatureContextsEnv)),
+	}
+	if policy.method == "" {
+		policy.method = "cosign"
+	}
+	return policy
+}
+
+func (s *signaturePolicy) requires(context string) (bool, error) {
+	if len(s.contexts) == 0 {
+		return false, nil
+	}
+	return matchContexts(s.contexts, context)
+}
+
+func (s *SigningConfig) signatureFile(rootFolder string) string {
+	if s.Signature == "" {
+		return translateFilePath(rootFolder, "manifests.sig")
+	}
+	return translateFilePath(rootFolder, s.Signature)
+}
+
+func (s *SigningConfig) signCommand(key, manifestFile, signatureFile string) (*exec.Cmd, error) {
+	switch s.Method {
+	case "cosign":
+		return exec.Command("cosign", "sign-blob", "--yes", "--key", key, "--output-signature", signatureFile, manifestFile), nil
+	case "gpg":
+		args := []string{"--batch", "--yes", "--armor", "--detach-sign", "--output", signatureFile}
+		if key != "" {
+			args = append(args, "--local-user", key)
+		}
+		return exec.Command("gpg", append(args, manifestFile)...), nil
+	default:
+		return nil, fmt.Errorf("unsupported signing method %q, expected cosign or gpg", s.Method)
+	}
+}
+
+func (s *SigningConfig) verifyCommand(publicKey, manifestFile, signatureFile string) (*exec.Cmd, error) {
+	switch s.Method {
+	case "cosign":
+		return exec.Command("cosign", "verify-blob", "--key", publicKey, "--signature", signatureFile, manifestFile), nil
+	case "gpg":
+		args := []string{"--batch"}
+		if publicKey != "" {
+			args = append(args, "--no-default-keyring", "--keyring", publicKey)
+		}
+		return exec.Command("gpg", append(args, "--verify", signatureFile, manifestFile)...), nil
+	default:
+		return nil, fmt.Errorf("unsupported signing method %q, expected cosign or gpg", s.Method)
+	}
+}
+
+func (p *Project) recordSource(filename string, data []byte) {
+	if p.sources == nil {
+		p.sources = make(map[string][]byte)
+	}
+	p.sources[filename] = data
+}
+
+// canonicalManifests is what gets signed: the manifest and partial files as
+// written, sorted by their path in the project with unix line endings. The
+// rendered manifests change with every deploy, timestamps and generated
+// certificates included, the sources only change with the project.
+func (p *Project) canonicalManifests() []byte {
+	files := make(map[string][]byte)
+	names := []string{}
+	for filename, data := range p.sources {
+		name, err := filepath.Rel(p.projectConfig.RootFolder, filename)
+		if err != nil {
+			name = filename
+		}
+		name = filepath.ToSlash(name)
+		files[name] = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf := &bytes.Buffer{}
+	for _, name := range names {
+		fmt.Fprintf(buf, "--- %s %d\n", name, len(files[name]))
+		buf.Write(files[name])
+	}
+	return buf.Bytes()
+}
+
+// withManifestFile writes the canonical manifests to a temporary file for
+// the signing tools, which read blobs from disk
+func (p *Project) withManifestFile(f func(filename string) error) error {
+	file, err := ioutil.TempFile("", "imladris-manifests-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(p.canonicalManifests())
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return f(file.Name())
+}
+
+func runSigningCommand(cmd *exec.Cmd) error {
+	errBuffer := &bytes.Buffer{}
+	cmd.Stdout = errBuffer
+	cmd.Stderr = errBuffer
+	err := cmd.Run()
+	if err != nil {
+		return errors.New(errBuffer.String())
+	}
+	return nil
+}
+
+// Sign writes the signature of the manifest sources, key overrides the key
+// from the project file
+func (p *Project) Sign(key string) error {
+	signing := p.projectConfig.Signing
+	if signing == nil {
+		return validationError(fmt.Errorf("project %q has no signing section", p.projectConfig.Name))
+	}
+	if key == "" {
+		key = signing.Key
+	}
+	signatureFile := signing.signatureFile(p.projectConfig.RootFolder)
+	err := p.withManifestFile(func(manifestFile string) error {
+		cmd, err := signing.signCommand(key, manifestFile, signatureFile)
+		if err != nil {
+			return err
+		}
+		return runSigningCommand(cmd)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot sign manifests: %s", err.Error())
+	}
+	p.printer.Printf(ColorGreen, "====> Signed manifests to %q\n", signatureFile)
+	return nil
+}
+
+// verifySignature refuses to deploy to the contexts of the host signature
+// policy unless the manifests carry a signature made with its key. The
+// project file only says where the signature is.
+func (p *Project) verifySignature() error {
+	policy := readSignaturePolicy()
+	context := contextName(p.config)
+	required, err := policy.requires(context)
+	if err != nil || !required {
+		return err
+	}
+	p.printer.Printf(ColorPurple, "Verifying manifest signature for context %q\n", context)
+	if policy.publicKey == "" && policy.method == "cosign" {
+		return validationError(fmt.Errorf("context %q requires signed manifests but %s is not set", context, signatureKeyEnv))
+	}
+	signing := &SigningConfig{}
+	if p.projectConfig.Signing != nil {
+		signing.Signature = p.projectConfig.Signing.Signature
+	}
+	signing.Method = policy.method
+	signatureFile := signing.signatureFile(p.projectConfig.RootFolder)
+	if _, err := os.Stat(signatureFile); err != nil {
+		return validationError(fmt.Errorf("refusing to deploy unsigned manifests to %q: %s", context, err.Error()))
+	}
+	err = p.withManifestFile(func(manifestFile string) error {
+		cmd, err := signing.verifyCommand(policy.publicKey, manifestFile, signatureFile)
+		if err != nil {
+			return err
+		}
+		return runSigningCommand(cmd)
+	})
+	if err != nil {
+		return validationError(fmt.Errorf("refusing to deploy to %q, manifest signature does not verify: %s", context, err.Error()))
+	}
+	p.printer.Printf(ColorGreen, "====> Signature verified\n")
+	return nil
+}
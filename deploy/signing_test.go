@@ -0,0 +1,81 @@
+package deploy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningCommands(t *testing.T) {
+	req := require.New(t)
+	signing := &SigningConfig{Method: "cosign"}
+	cmd, err := signing.signCommand("cosign.key", "manifests.yml", "manifests.sig")
+	req.Nil(err)
+	req.Equal([]string{"cosign", "sign-blob", "--yes", "--key", "cosign.key", "--output-signature", "manifests.sig", "manifests.yml"}, cmd.Args)
+	cmd, err = signing.verifyCommand("cosign.pub", "manifests.yml", "manifests.sig")
+	req.Nil(err)
+	req.Equal([]string{"cosign", "verify-blob", "--key", "cosign.pub", "--signature", "manifests.sig", "manifests.yml"}, cmd.Args)
+
+	signing.Method = "gpg"
+	cmd, err = signing.verifyCommand("", "manifests.yml", "manifests.sig")
+	req.Nil(err)
+	req.Equal([]string{"gpg", "--batch", "--verify", "manifests.sig", "manifests.yml"}, cmd.Args)
+
+	signing.Method = "md5"
+	_, err = signing.signCommand("", "manifests.yml", "manifests.sig")
+	req.Error(err)
+}
+
+func TestVerifySignature(t *testing.T) {
+	req := require.New(t)
+	config := &appConfig{context: "dev"}
+	project, err := readProject(nil, "test-assets/config-tests/simple", config)
+	req.Nil(err)
+	project.projectConfig.Signing = &SigningConfig{Signature: "missing.sig"}
+	t.Setenv(signatureContextsEnv, "prod-.*, live")
+	t.Setenv(signatureKeyEnv, "cosign.pub")
+	req.Nil(project.verifySignature())
+
+	config.context = "preprod-eu"
+	req.Nil(project.verifySignature())
+
+	// Dropping the signing section doesn't turn verification off
+	project.projectConfig.Signing = nil
+	for _, context := range []string{"prod-eu", "live"} {
+		config.context = context
+		err = project.verifySignature()
+		req.Error(err)
+		req.Contains(err.Error(), "unsigned")
+	}
+
+	t.Setenv(signatureKeyEnv, "")
+	err = project.verifySignature()
+	req.Error(err)
+	req.Contains(err.Error(), signatureKeyEnv)
+}
+
+func TestCanonicalManifests(t *testing.T) {
+	req := require.New(t)
+	config := &appConfig{context: "dev", variables: variableMap{"timestamp": "1"}}
+	project, err := readProject(nil, "test-assets/config-tests/simple", config)
+	req.Nil(err)
+	signed := project.canonicalManifests()
+	req.Contains(string(signed), "--- services/simple.yml ")
+
+	// What varies between deploys doesn't change what is signed
+	config.variables["timestamp"] = "2"
+	project, err = readProject(nil, "test-assets/config-tests/simple", config)
+	req.Nil(err)
+	req.Equal(signed, project.canonicalManifests())
+
+	for filename, data := range project.sources {
+		project.sources[filename] = bytes.Replace(data, []byte("\n"), []byte("\r\n"), -1)
+	}
+	req.Equal(signed, project.canonicalManifests())
+	for filename, data := range project.sources {
+		project.sources[filename] = append(data, '#')
+		break
+	}
+	req.NotEqual(signed, project.canonicalManifests())
+}
@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The API types and client are those of kubernetes 1.8, deploys have been
+// tested against the clusters in this range
+const (
+	minTestedMinor = 7
+	maxTestedMinor = 9
+)
+
+var leadingDigits = regexp.MustCompile(`^[0-9]+`)
+
+// versionSkew describes why a cluster version is untested, hosted clusters
+// report minors like "8+" so only the leading digits count
+func versionSkew(major, minor string) string {
+	minorNumber, err := strconv.Atoi(leadingDigits.FindString(minor))
+	if err != nil || leadingDigits.FindString(major) != "1" {
+		return fmt.Sprintf("cluster version %s.%s is not recognized", major, minor)
+	}
+	switch {
+	case minorNumber < minTestedMinor:
+		return fmt.Sprintf("cluster version 1.%d is older than the tested 1.%d to 1.%d", minorNumber, minTestedMinor, maxTestedMinor)
+	case minorNumber > maxTestedMinor:
+		return fmt.Sprintf("cluster version 1.%d is newer than the tested 1.%d to 1.%d", minorNumber, minTestedMinor, maxTestedMinor)
+	}
+	return ""
+}
+
+// unservedAPIVersions lists the resources whose api version the cluster
+// doesn't serve, usually groups removed in newer releases
+func unservedAPIVersions(served map[string]bool, assets []*Asset) []string {
+	unserved := []string{}
+	for _, asset := range assets {
+		resourceType, ok := resourceTypes[asset.Kind]
+		if !ok || served[resourceType.APIVersion] {
+			continue
+		}
+		unserved = append(unserved, fmt.Sprintf("%s (%s)", assetKey(asset), resourceType.APIVersion))
+	}
+	return unserved
+}
+
+func (p *Project) checkVersionSkew() error {
+	info, err := p.kubeClient.Discovery().ServerVersion()
+	if err != nil {
+		return err
+	}
+	skew := versionSkew(info.Major, info.Minor)
+	if skew != "" {
+		if p.config.strictVersion {
+			return newTypedError(ErrorTypeValidation, "refusing to deploy to %s: %s", describeCluster(p.config), skew)
+		}
+		p.printer.ErrPrintf(ColorPurple, "Warning: %s, pass -strict-version to refuse untested clusters\n", skew)
+	}
+	groups, err := p.kubeClient.Discovery().ServerGroups()
+	if err != nil {
+		return err
+	}
+	served := make(map[string]bool)
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			served[version.GroupVersion] = true
+		}
+	}
+	unserved := unservedAPIVersions(served, p.assets())
+	if len(unserved) > 0 {
+		return newTypedError(ErrorTypeValidation, "cluster %s does not serve the api versions of %s", describeCluster(p.config), strings.Join(unserved, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionSkew(t *testing.T) {
+	req := require.New(t)
+	req.Equal("", versionSkew("1", "8"))
+	req.Equal("", versionSkew("1", "9+"))
+	req.Contains(versionSkew("1", "6"), "older")
+	req.Contains(versionSkew("1", "16"), "newer")
+	req.Contains(versionSkew("2", "0"), "not recognized")
+}
+
+func TestUnservedAPIVersions(t *testing.T) {
+	req := require.New(t)
+	deployment, err := parseAsset("web.yml", []byte("apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: web\n"))
+	req.NoError(err)
+	configMap, err := parseAsset("config.yml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n"))
+	req.NoError(err)
+	served := map[string]bool{"v1": true, "apps/v1": true}
+	req.Equal([]string{"deployment/web (extensions/v1beta1)"}, unservedAPIVersions(served, []*Asset{configMap, deployment}))
+}
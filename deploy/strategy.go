@@ -0,0 +1,89 @@
+package deploy
+
+import (
+	"fmt"
+
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// StrategyOverride replaces the rollout strategy of a deployment. Since the
+// project file is a template, overrides can differ per environment, e.g.
+// Recreate on a single replica staging and a slow rolling update in
+// production, without copying the deployment manifest.
+type StrategyOverride struct {
+	Type           string `yaml:"type"`
+	MaxSurge       string `yaml:"max_surge"`
+	MaxUnavailable string `yaml:"max_unavailable"`
+}
+
+// StrategyOverrides maps deployment names, or "*" for all of them, to
+// their override
+type StrategyOverrides map[string]*StrategyOverride
+
+// applyStrategyOverrides applies the override for each deployment by name,
+// falling back to the "*" entry
+func (p *Project) applyStrategyOverrides() error {
+	if len(p.projectConfig.Strategies) == 0 {
+		return nil
+	}
+	for name := range p.projectConfig.Strategies {
+		if name == "*" {
+			continue
+		}
+		if p.findAsset("deployment", name) == nil {
+			return fmt.Errorf("strategy override for deployment %q, which is not in the project", name)
+		}
+	}
+	for _, asset := range p.assets() {
+		deployment, ok := asset.ResourceData.(*v1beta1.Deployment)
+		if !ok {
+			continue
+		}
+		override, ok := p.projectConfig.Strategies[deployment.Name]
+		if !ok {
+			override, ok = p.projectConfig.Strategies["*"]
+		}
+		if !ok {
+			continue
+		}
+		err := override.apply(&deployment.Spec.Strategy)
+		if err != nil {
+			return fmt.Errorf("strategy override for deployment %q: %s", deployment.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+func (override *StrategyOverride) apply(strategy *v1beta1.DeploymentStrategy) error {
+	if override.Type != "" {
+		switch v1beta1.DeploymentStrategyType(override.Type) {
+		case v1beta1.RecreateDeploymentStrategyType, v1beta1.RollingUpdateDeploymentStrategyType:
+			strategy.Type = v1beta1.DeploymentStrategyType(override.Type)
+		default:
+			return fmt.Errorf("unknown strategy type %q, expected Recreate or RollingUpdate", override.Type)
+		}
+	}
+	if strategy.Type == v1beta1.RecreateDeploymentStrategyType {
+		if override.MaxSurge != "" || override.MaxUnavailable != "" {
+			return fmt.Errorf("max_surge and max_unavailable only apply to RollingUpdate")
+		}
+		strategy.RollingUpdate = nil
+		return nil
+	}
+	if override.MaxSurge == "" && override.MaxUnavailable == "" {
+		return nil
+	}
+	if strategy.RollingUpdate == nil {
+		strategy.RollingUpdate = &v1beta1.RollingUpdateDeployment{}
+	}
+	if override.MaxSurge != "" {
+		maxSurge := intstr.Parse(override.MaxSurge)
+		strategy.RollingUpdate.MaxSurge = &maxSurge
+	}
+	if override.MaxUnavailable != "" {
+		maxUnavailable := intstr.Parse(override.MaxUnavailable)
+		strategy.RollingUpdate.MaxUnavailable = &maxUnavailable
+	}
+	return nil
+}
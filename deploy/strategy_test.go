@@ -0,0 +1,36 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/extensions/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStrategyOverrides(t *testing.T) {
+	req := require.New(t)
+	api := &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "api"}}
+	worker := &v1beta1.Deployment{ObjectMeta: apiv1.ObjectMeta{Name: "worker"}}
+	p := &Project{
+		projectConfig: &ProjectConfig{Strategies: StrategyOverrides{
+			"*":      {Type: "RollingUpdate", MaxSurge: "25%", MaxUnavailable: "0"},
+			"worker": {Type: "Recreate"},
+		}},
+		services: []*Asset{
+			{Kind: "deployment", ResourceData: api},
+			{Kind: "deployment", ResourceData: worker},
+		},
+	}
+	req.NoError(p.applyStrategyOverrides())
+	req.Equal(v1beta1.RollingUpdateDeploymentStrategyType, api.Spec.Strategy.Type)
+	req.Equal("25%", api.Spec.Strategy.RollingUpdate.MaxSurge.String())
+	req.Equal(0, api.Spec.Strategy.RollingUpdate.MaxUnavailable.IntValue())
+	req.Equal(v1beta1.RecreateDeploymentStrategyType, worker.Spec.Strategy.Type)
+	req.Nil(worker.Spec.Strategy.RollingUpdate)
+
+	p.projectConfig.Strategies = StrategyOverrides{"web": {Type: "Recreate"}}
+	req.Error(p.applyStrategyOverrides())
+	p.projectConfig.Strategies = StrategyOverrides{"api": {Type: "BlueGreen"}}
+	req.Error(p.applyStrategyOverrides())
+}
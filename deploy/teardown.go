@@ -0,0 +1,283 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// teardownOrder deletes what starts pods before the pods, and what pods use
+// after them. Claims come last, once no pod mounts them anymore.
+var teardownOrder = []string{
+	"cronjob", "horizontalpodautoscaler", "poddisruptionbudget", "deployment", "statefulset", "daemonset", "job", "pod",
+	"ingress", "service", "endpoints", "networkpolicy", "configmap", "secret", "rolebinding", "role", "serviceaccount",
+}
+
+// systemNamespaces are never torn down, whatever the confirmation says
+var systemNamespaces = map[string]bool{
+	"default":         true,
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+}
+
+// managedResource tells the resources imladris applied, they carry the
+// checksum annotation. Owned resources go with their owner.
+func managedResource(resource interface{}) bool {
+	object := resource.(apiv1.Object)
+	_, ok := object.GetAnnotations()[checksumAnnotation]
+	return ok && len(object.GetOwnerReferences()) == 0
+}
+
+type teardownOptions struct {
+	deletePVCs      bool
+	deleteNamespace bool
+	timeout         time.Duration
+	// project is the project of the current folder, if any. What it declares
+	// goes too, applied before the checksum annotation existed or of a
+	// plugin kind teardown cannot list.
+	project *Project
+}
+
+// declared lists the names the project declares in the namespace by kind,
+// resources routed to another context are not in this cluster
+func (options *teardownOptions) declared(namespace string) map[string]map[string]bool {
+	declared := make(map[string]map[string]bool)
+	if options.project == nil {
+		return declared
+	}
+	for _, asset := range options.project.assets() {
+		if asset.Namespace() != namespace || asset.context != "" {
+			continue
+		}
+		if declared[asset.Kind] == nil {
+			declared[asset.Kind] = make(map[string]bool)
+		}
+		declared[asset.Kind][asset.ResourceData.(Meta).GetName()] = true
+	}
+	return declared
+}
+
+func declaredKinds(declared map[string]map[string]bool) map[string]bool {
+	kinds := make(map[string]bool)
+	for kind := range declared {
+		kinds[kind] = true
+	}
+	return kinds
+}
+
+func (options *teardownOptions) plugins() pluginRegistry {
+	if options.project == nil {
+		return nil
+	}
+	return options.project.plugins
+}
+
+func teardownNamespace(kubeClient *kubernetes.Clientset, namespace string, config *appConfig, options *teardownOptions) error {
+	if systemNamespaces[namespace] {
+		return newTypedError(ErrorTypeValidation, "refusing to tear down system namespace %q", namespace)
+	}
+	deadline := time.Now().Add(options.timeout)
+	declared := options.declared(namespace)
+	// Plugin kinds can't be listed, their declared resources go first as
+	// they may start pods through an operator
+	for _, kind := range pendingNames(declaredKinds(declared)) {
+		if _, ok := options.plugins()[kind]; !ok {
+			continue
+		}
+		destroyed := []string{}
+		for _, name := range pendingNames(declared[kind]) {
+			Printf(ColorRed, "Destroying %s %q from namespace %q\n", kind, name, namespace)
+			_, err := getResource(kubeClient, options.plugins(), kind, name, namespace)
+			if isResourceNotExist(err) {
+				Println(ColorGray, "====> Not existed")
+				continue
+			}
+			if err == nil {
+				err = destroyResource(kubeClient, options.plugins(), kind, name, namespace)
+			}
+			if err != nil {
+				return err
+			}
+			Println(ColorGreen, "====> Success")
+			destroyed = append(destroyed, name)
+		}
+		err := waitForTeardown(kubeClient, options.plugins(), config, kind, namespace, destroyed)
+		if err != nil {
+			return err
+		}
+	}
+	// A kind this cluster doesn't serve, or we may not list, is skipped
+	// rather than stopping half way, and reported at the end
+	unlisted := []string{}
+	for _, kind := range teardownOrder {
+		resources, err := listResources(kubeClient, kind, namespace, "")
+		if err != nil {
+			ErrPrintf(ColorYellow, "Skipping %s, unable to list them: %s\n", kind, err.Error())
+			unlisted = append(unlisted, kind)
+			continue
+		}
+		destroyed := []string{}
+		for _, resource := range resources {
+			object := resource.(apiv1.Object)
+			if !managedResource(resource) && !(declared[kind][object.GetName()] && len(object.GetOwnerReferences()) == 0) {
+				continue
+			}
+			name := object.GetName()
+			Printf(ColorRed, "Destroying %s %q from namespace %q\n", kind, name, namespace)
+			err = destroyResource(kubeClient, nil, kind, name, namespace)
+			if err != nil {
+				return err
+			}
+			Println(ColorGreen, "====> Success")
+			destroyed = append(destroyed, name)
+		}
+		// A kind is gone before the next one goes, stuck finalizers are
+		// reported instead of leaving the namespace half torn down
+		err = waitForTeardown(kubeClient, nil, config, kind, namespace, destroyed)
+		if err != nil {
+			return err
+		}
+	}
+	err := waitForPodsTermination(kubeClient, namespace, deadline)
+	if err != nil {
+		return err
+	}
+	if options.deletePVCs {
+		claims, err := kubeClient.Core().PersistentVolumeClaims(namespace).List(apiv1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		destroyed := []string{}
+		for _, claim := range claims.Items {
+			Printf(ColorRed, "Destroying persistentvolumeclaim %q from namespace %q\n", claim.Name, namespace)
+			err = destroyResource(kubeClient, nil, "persistentvolumeclaim", claim.Name, namespace)
+			if err != nil {
+				return err
+			}
+			Println(ColorGreen, "====> Success")
+			destroyed = append(destroyed, claim.Name)
+		}
+		err = waitForTeardown(kubeClient, nil, config, "persistentvolumeclaim", namespace, destroyed)
+		if err != nil {
+			return err
+		}
+	}
+	if len(unlisted) > 0 {
+		return fmt.Errorf("namespace %q was not torn down completely, unable to list %s", namespace, strings.Join(unlisted, ", "))
+	}
+	if options.deleteNamespace {
+		Printf(ColorRed, "Destroying namespace %q\n", namespace)
+		return deleteNamespace(kubeClient, namespace)
+	}
+	leftovers, err := namespaceLeftovers(kubeClient, namespace)
+	if err != nil {
+		return err
+	}
+	if len(leftovers) == 0 {
+		Printf(ColorGreen, "Namespace %q is empty\n", namespace)
+		return nil
+	}
+	ErrPrintf(ColorYellow, "Left behind in namespace %q, not applied by imladris or still terminating:\n", namespace)
+	for _, leftover := range leftovers {
+		ErrPrintf(ColorYellow, "  %s\n", leftover)
+	}
+	return nil
+}
+
+func waitForTeardown(kubeClient *kubernetes.Clientset, plugins pluginRegistry, config *appConfig, kind, namespace string, names []string) error {
+	for _, name := range names {
+		_, err := waitForDeletionOf(kubeClient, plugins, config, console, kind, name, namespace)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForPodsTermination waits until no pod is terminating or left without
+// the controller that started it
+func waitForPodsTermination(kubeClient *kubernetes.Clientset, namespace string, deadline time.Time) error {
+	Printf(ColorYellow, "Waiting for pods of namespace %q to terminate\n", namespace)
+	for {
+		pods, err := kubeClient.Core().Pods(namespace).List(apiv1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		terminating := []string{}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.DeletionTimestamp != nil || orphaned(kubeClient, namespace, pod.OwnerReferences) {
+				terminating = append(terminating, pod.Name)
+			}
+		}
+		if len(terminating) == 0 {
+			Println(ColorGreen, "====> Success")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return newTypedError(ErrorTypeTimeout, "timeout while waiting for pods to terminate: %s", strings.Join(terminating, ", "))
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// orphaned tells if one of the owners is gone or going, directly or through
+// its own owners, the garbage collector then deletes the dependent
+func orphaned(kubeClient *kubernetes.Clientset, namespace string, owners []apiv1.OwnerReference) bool {
+	for _, owner := range owners {
+		var object apiv1.Object
+		kind := strings.ToLower(owner.Kind)
+		if kind == "replicaset" {
+			replicaSet, err := kubeClient.Extensions().ReplicaSets(namespace).Get(owner.Name, apiv1.GetOptions{})
+			if isResourceNotExist(err) {
+				return true
+			}
+			if err != nil {
+				continue
+			}
+			object = replicaSet
+		} else if _, ok := resourceTypes[kind]; ok {
+			resource, err := getResource(kubeClient, nil, kind, owner.Name, namespace)
+			if isResourceNotExist(err) {
+				return true
+			}
+			if err != nil {
+				continue
+			}
+			object = resource.(apiv1.Object)
+		} else {
+			continue
+		}
+		if object.GetUID() != owner.UID || object.GetDeletionTimestamp() != nil || orphaned(kubeClient, namespace, object.GetOwnerReferences()) {
+			return true
+		}
+	}
+	return false
+}
+
+func namespaceLeftovers(kubeClient *kubernetes.Clientset, namespace string) ([]string, error) {
+	leftovers := []string{}
+	for _, kind := range append(teardownOrder, "persistentvolumeclaim") {
+		resources, err := listResources(kubeClient, kind, namespace, "")
+		if err != nil {
+			return nil, fmt.Errorf("unable to list %s: %s", kind, err.Error())
+		}
+		for _, resource := range resources {
+			object := resource.(apiv1.Object)
+			// Every namespace has these
+			if kind == "serviceaccount" && object.GetName() == "default" {
+				continue
+			}
+			if kind == "secret" && resource.(*v1.Secret).Type == v1.SecretTypeServiceAccountToken {
+				continue
+			}
+			leftovers = append(leftovers, kind+"/"+object.GetName())
+		}
+	}
+	return leftovers, nil
+}
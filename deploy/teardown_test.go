@@ -0,0 +1,69 @@
+package deploy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestManagedResource(t *testing.T) {
+	req := require.New(t)
+	configMap := &v1.ConfigMap{}
+	req.False(managedResource(configMap))
+	configMap.Annotations = map[string]string{checksumAnnotation: "abc"}
+	req.True(managedResource(configMap))
+	pod := &v1.Pod{}
+	pod.Annotations = map[string]string{checksumAnnotation: "abc"}
+	pod.OwnerReferences = []apiv1.OwnerReference{{Kind: "ReplicaSet", Name: "web-1234"}}
+	req.False(managedResource(pod))
+}
+
+func TestTeardownOrder(t *testing.T) {
+	req := require.New(t)
+	position := make(map[string]int)
+	for i, kind := range teardownOrder {
+		position[kind] = i
+	}
+	for _, controller := range []string{"cronjob", "deployment", "statefulset", "daemonset", "job"} {
+		req.True(position[controller] < position["pod"], controller)
+	}
+	req.True(position["pod"] < position["configmap"])
+	req.True(position["rolebinding"] < position["serviceaccount"])
+}
+
+func TestTeardownNamespace(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	config := &appConfig{timeout: time.Second}
+	options := &teardownOptions{timeout: time.Second, deleteNamespace: true}
+	err := teardownNamespace(kubeClient, "kube-system", config, options)
+	req.Error(err)
+	req.Contains(err.Error(), "system namespace")
+
+	cluster.reject = func(method, path string) *fakeStatus {
+		if method == "WATCH" || path == "/apis/batch/v1beta1/namespaces/web/cronjobs" {
+			return &fakeStatus{http.StatusNotFound, "NotFound", "the server could not find the requested resource"}
+		}
+		return nil
+	}
+	cluster.add("/api/v1/namespaces/web/configmaps/legacy", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"legacy","namespace":"web"}}`)
+	cluster.add("/api/v1/namespaces/web/configmaps/other", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"other","namespace":"web"}}`)
+	cluster.add("/apis/policy/v1beta1/namespaces/web/poddisruptionbudgets/web", `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget","metadata":{"name":"web","namespace":"web","annotations":{"imladris/checksum":"abc"}}}`)
+	legacy, err := parseAsset("legacy.yml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: legacy\n  namespace: web\n"))
+	req.Nil(err)
+	options.project = &Project{kubeClient: kubeClient, config: config, projectConfig: &ProjectConfig{}, resources: []*Asset{legacy}}
+
+	// Declared by the project in the folder without the annotation, swept.
+	// Cronjobs can't be listed, the rest still goes and the namespace stays.
+	err = teardownNamespace(kubeClient, "web", config, options)
+	req.Error(err)
+	req.Contains(err.Error(), "unable to list cronjob")
+	req.Nil(cluster.get("/api/v1/namespaces/web/configmaps/legacy"))
+	req.NotNil(cluster.get("/api/v1/namespaces/web/configmaps/other"))
+	req.Nil(cluster.get("/apis/policy/v1beta1/namespaces/web/poddisruptionbudgets/web"))
+	req.NotContains(cluster.requested("DELETE", "/api/v1/namespaces/web"), "DELETE /api/v1/namespaces/web")
+}
@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+func makePath(segments ...string) (string, error) {
+	if len(segments) > 0 && (filepath.IsAbs(segments[0]) || strings.HasPrefix(segments[0], "/")) {
+		return filepath.Join(segments...), nil
+	}
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	paths := append([]string{pwd}, segments...)
+	return filepath.Join(paths...), nil
+}
+
+func getFuncMap() template.FuncMap {
+	return template.FuncMap{
+		"makePath": makePath,
+		"indent":   indent,
+	}
+}
+
+// indent prefixes every line of text with spaces, to nest an included
+// partial in the YAML around it
+func indent(spaces int, text string) string {
+	padding := strings.Repeat(" ", spaces)
+	return padding + strings.Replace(text, "\n", "\n"+padding, -1)
+}
+
+// readPartials reads the shared snippets manifests can include, named after
+// their file without extension: partials/probes.yml is "probes"
+func (p *Project) readPartials() error {
+	globs := p.projectConfig.Partials
+	if len(globs) == 0 {
+		globs = []string{"partials/*"}
+	}
+	p.partials = make(map[string]string)
+	for _, glob := range globs {
+		matches, err := filepath.Glob(translateFilePath(p.projectConfig.RootFolder, glob))
+		if err != nil {
+			return err
+		}
+		for _, filename := range matches {
+			name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+			if _, ok := p.partials[name]; ok {
+				return fmt.Errorf("partial %q is defined twice, the second time in %q", name, filename)
+			}
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+			p.recordSource(filename, data)
+			p.partials[name] = string(data)
+		}
+	}
+	return nil
+}
+
+// parseManifest parses a manifest along with the partials, which it renders
+// with {{ include "probes" . | indent 8 }}. The template action works too,
+// but cannot be indented.
+func (p *Project) parseManifest(filename string, data []byte) (*template.Template, error) {
+	t := template.New(filename)
+	t.Funcs(getFuncMap()).Funcs(template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			buf := &bytes.Buffer{}
+			err := t.ExecuteTemplate(buf, name, data)
+			return strings.TrimSuffix(buf.String(), "\n"), err
+		},
+	})
+	for name, partial := range p.partials {
+		_, err := t.New(name).Parse(partial)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t.Parse(string(data))
+}
@@ -0,0 +1,25 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+)
+
+func TestIndent(t *testing.T) {
+	req := require.New(t)
+	req.Equal("  a:\n    b: c", indent(2, "a:\n  b: c"))
+}
+
+func TestPartials(t *testing.T) {
+	req := require.New(t)
+	project, err := readProject(nil, "test-assets/partials-tests", &appConfig{})
+	req.NoError(err)
+	req.Contains(project.partials, "probe")
+	pod := project.services[0].ResourceData.(*v1.Pod)
+	probe := pod.Spec.Containers[0].LivenessProbe
+	req.NotNil(probe)
+	req.Equal("/healthz", probe.HTTPGet.Path)
+	req.Equal(8080, probe.HTTPGet.Port.IntValue())
+}
@@ -0,0 +1,100 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v1batch "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// stuckAfter is how long a delete may take before we look for what blocks it
+const stuckAfter = time.Minute
+
+// terminatingBlockers describes why a deleted resource is still there
+func terminatingBlockers(resource interface{}) string {
+	object := resource.(apiv1.Object)
+	if object.GetDeletionTimestamp() == nil {
+		return "not marked for deletion"
+	}
+	finalizers := object.GetFinalizers()
+	if len(finalizers) == 0 {
+		return fmt.Sprintf("terminating since %s without finalizers", object.GetDeletionTimestamp().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("terminating since %s, blocked by finalizers %s", object.GetDeletionTimestamp().Format(time.RFC3339), strings.Join(finalizers, ", "))
+}
+
+func removeFinalizers(kubeClient *kubernetes.Clientset, plugins pluginRegistry, kind, name, namespace string) error {
+	resource, err := getResource(kubeClient, plugins, kind, name, namespace)
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	resource.(apiv1.Object).SetFinalizers(nil)
+	// updateResource leaves claims and jobs alone, their specs are immutable
+	switch kind {
+	case "persistentvolumeclaim":
+		_, err = kubeClient.Core().PersistentVolumeClaims(namespace).Update(resource.(*v1.PersistentVolumeClaim))
+		return err
+	case "job":
+		_, err = kubeClient.Batch().Jobs(namespace).Update(resource.(*v1batch.Job))
+		return err
+	}
+	return updateResource(kubeClient, plugins, kind, name, namespace, resource)
+}
+
+// waitForDeletion waits for a deleted resource to go away, see
+// waitForDeletionOf
+func (p *Project) waitForDeletion(asset *Asset, name string) error {
+	removed, err := waitForDeletionOf(p.clientFor(asset), p.plugins, p.config, p.printer, asset.Kind, name, asset.Namespace())
+	if removed != nil {
+		p.audit("force-finalize", asset.Kind, name, err, map[string]string{"finalizers": strings.Join(removed, ",")})
+	}
+	return err
+}
+
+// waitForDeletionOf waits for a deleted resource to go away. When it takes
+// longer than stuckAfter, it reports what blocks the deletion and with
+// -force-finalize offers to remove the finalizers instead of hanging until
+// -timeout. -yes does not answer that question, it needs someone at the
+// terminal. It returns the finalizers it removed.
+func waitForDeletionOf(kubeClient *kubernetes.Clientset, plugins pluginRegistry, config *appConfig, out *printer, kind, name, namespace string) ([]string, error) {
+	deadline := time.Now().Add(config.timeout)
+	wait := stuckAfter
+	if config.timeout < wait {
+		wait = config.timeout
+	}
+	err := waitForResourceDeletion(kubeClient, plugins, kind, name, namespace, wait)
+	if err == nil || classifyError(err) != ErrorTypeTimeout {
+		return nil, err
+	}
+	resource, err := getResource(kubeClient, plugins, kind, name, namespace)
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	out.ErrPrintf(ColorRed, "====> %s %q is stuck: %s\n", kind, name, terminatingBlockers(resource))
+	finalizers := resource.(apiv1.Object).GetFinalizers()
+	if len(finalizers) == 0 {
+		return nil, waitForResourceDeletion(kubeClient, plugins, kind, name, namespace, time.Until(deadline))
+	}
+	if !config.forceFinalize {
+		return nil, newTypedError(ErrorTypeTimeout, "%s %q is stuck terminating, blocked by finalizers %s; fix what they wait for or pass -force-finalize to remove them", kind, name, strings.Join(finalizers, ", "))
+	}
+	if !askExplicitConfirmation(config, fmt.Sprintf("Remove finalizers %s from %s %q? What they guard will not be cleaned up", strings.Join(finalizers, ", "), kind, name)) {
+		return nil, fmt.Errorf("removing the finalizers of %s %q was cancelled", kind, name)
+	}
+	err = removeFinalizers(kubeClient, plugins, kind, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	out.ErrPrintf(ColorYellow, "====> Removed finalizers %s from %s %q\n", strings.Join(finalizers, ", "), kind, name)
+	return finalizers, waitForResourceDeletion(kubeClient, plugins, kind, name, namespace, time.Until(deadline))
+}
@@ -0,0 +1,85 @@
+package deploy
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTerminatingBlockers(t *testing.T) {
+	req := require.New(t)
+	pvc := &v1.PersistentVolumeClaim{}
+	req.Equal("not marked for deletion", terminatingBlockers(pvc))
+	deleted := apiv1.NewTime(time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC))
+	pvc.DeletionTimestamp = &deleted
+	req.Equal("terminating since 2026-10-15T08:00:00Z without finalizers", terminatingBlockers(pvc))
+	pvc.Finalizers = []string{"kubernetes.io/pvc-protection", "example.com/backup"}
+	req.Equal("terminating since 2026-10-15T08:00:00Z, blocked by finalizers kubernetes.io/pvc-protection, example.com/backup", terminatingBlockers(pvc))
+}
+
+const stuckClaim = `{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"name":"data","namespace":"web","finalizers":["example.com/backup"],"annotations":{"imladris/checksum":"abc"}}}`
+
+func TestWaitForDeletionStuck(t *testing.T) {
+	req := require.New(t)
+	defer func(reader *bufio.Reader) { stdinReader = reader }(stdinReader)
+	config := &appConfig{}
+	p, cluster, asset := newBackupProject(t, config, "apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata:\n  name: data\n  namespace: web\n")
+	config.timeout = time.Second
+	cluster.reject = func(method, path string) *fakeStatus {
+		if method == "WATCH" {
+			return &fakeStatus{http.StatusForbidden, "Forbidden", "no watch"}
+		}
+		return nil
+	}
+	path := "/api/v1/namespaces/web/persistentvolumeclaims/data"
+	cluster.add(path, stuckClaim)
+
+	// down reports the finalizers instead of calling it a success
+	err := p.destroyAsset(asset)
+	req.Error(err)
+	req.Contains(err.Error(), "example.com/backup")
+	req.Contains(err.Error(), "-force-finalize")
+	req.NotNil(cluster.get(path)["metadata"].(map[string]interface{})["deletionTimestamp"])
+
+	// -yes does not remove finalizers, neither does -non-interactive
+	config.forceFinalize = true
+	config.yes = true
+	err = p.waitForDeletion(asset, "data")
+	req.Error(err)
+	req.Contains(err.Error(), "cancelled")
+	req.NotNil(cluster.get(path))
+	config.yes = false
+	config.nonInteractive = true
+	req.Error(p.waitForDeletion(asset, "data"))
+	req.NotNil(cluster.get(path))
+
+	config.nonInteractive = false
+	stdinReader = bufio.NewReader(strings.NewReader("y\n"))
+	req.Nil(p.waitForDeletion(asset, "data"))
+	req.Nil(cluster.get(path))
+}
+
+func TestTeardownStuck(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.reject = func(method, path string) *fakeStatus {
+		if method == "WATCH" {
+			return &fakeStatus{http.StatusForbidden, "Forbidden", "no watch"}
+		}
+		return nil
+	}
+	cluster.add("/api/v1/namespaces/web/configmaps/web", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"web","annotations":{"imladris/checksum":"abc"}}}`)
+	cluster.add("/api/v1/namespaces/web/persistentvolumeclaims/data", stuckClaim)
+	config := &appConfig{timeout: time.Second, forceFinalize: true, yes: true}
+	err := teardownNamespace(kubeClient, "web", config, &teardownOptions{deletePVCs: true, timeout: time.Second})
+	req.Error(err)
+	req.Contains(err.Error(), "cancelled")
+	req.Nil(cluster.get("/api/v1/namespaces/web/configmaps/web"))
+	req.NotNil(cluster.get("/api/v1/namespaces/web/persistentvolumeclaims/data"))
+}
@@ -0,0 +1,69 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// terraformOutput is one entry of `terraform output -json`
+type terraformOutput struct {
+	Sensitive bool            `json:"sensitive"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// readTerraformOutputs turns a `terraform output -json` file into template
+// variables named tf_<output>. Lists and maps are kept as JSON.
+func readTerraformOutputs(filename string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make(map[string]*terraformOutput)
+	err = json.Unmarshal(data, &outputs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read terraform outputs %q: %s", filename, err.Error())
+	}
+	variables := make(map[string]string)
+	for name, output := range outputs {
+		var value string
+		err = json.Unmarshal(output.Value, &value)
+		if err != nil {
+			compact := &bytes.Buffer{}
+			err = json.Compact(compact, output.Value)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read terraform output %q: %s", name, err.Error())
+			}
+			value = compact.String()
+		}
+		if output.Sensitive {
+			registerSensitive(value)
+		}
+		variables[nonIdentifierChars.ReplaceAllString("tf_"+name, "_")] = value
+	}
+	return variables, nil
+}
+
+func (p *Project) readTerraformVariables() error {
+	files := []string{}
+	for _, file := range p.projectConfig.TerraformOutputs {
+		files = append(files, translateFilePath(p.projectConfig.RootFolder, file))
+	}
+	if p.config.terraformOutputs != "" {
+		files = append(files, p.config.terraformOutputs)
+	}
+	for _, file := range files {
+		variables, err := readTerraformOutputs(file)
+		if err != nil {
+			return err
+		}
+		for key, value := range variables {
+			if _, ok := p.config.variables[key]; ok {
+				continue
+			}
+			p.projectConfig.Variables[key] = value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTerraformOutputs(t *testing.T) {
+	req := require.New(t)
+	isolateSensitiveValues(t)
+	file, err := ioutil.TempFile("", "terraform-outputs")
+	req.NoError(err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`{
+  "rds_endpoint": {"sensitive": false, "type": "string", "value": "db.abc.rds.amazonaws.com"},
+  "db_password": {"sensitive": true, "type": "string", "value": "hunter2-secret"},
+  "subnet-ids": {"sensitive": false, "type": ["list", "string"], "value": ["subnet-1", "subnet-2"]},
+  "replicas": {"sensitive": false, "type": "number", "value": 3}
+}`)
+	req.NoError(err)
+	file.Close()
+	variables, err := readTerraformOutputs(file.Name())
+	req.NoError(err)
+	req.Equal(map[string]string{
+		"tf_rds_endpoint": "db.abc.rds.amazonaws.com",
+		"tf_db_password":  "hunter2-secret",
+		"tf_subnet_ids":   `["subnet-1","subnet-2"]`,
+		"tf_replicas":     "3",
+	}, variables)
+	req.Equal("password is <redacted>", scrub("password is hunter2-secret"))
+}
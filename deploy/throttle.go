@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	minThrottleDelay = 100 * time.Millisecond
+	maxThrottleDelay = 10 * time.Second
+)
+
+// adaptiveThrottle slows every request down once the API server starts
+// answering 429, and recovers gradually as requests succeed again
+type adaptiveThrottle struct {
+	next  http.RoundTripper
+	lock  sync.Mutex
+	delay time.Duration
+}
+
+func newAdaptiveThrottle(next http.RoundTripper) http.RoundTripper {
+	return &adaptiveThrottle{next: next}
+}
+
+func (t *adaptiveThrottle) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	delay := t.delay
+	t.lock.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	throttled := resp.StatusCode == http.StatusTooManyRequests
+	t.lock.Lock()
+	if throttled {
+		t.delay *= 2
+		if t.delay < minThrottleDelay {
+			t.delay = minThrottleDelay
+		}
+		if t.delay > maxThrottleDelay {
+			t.delay = maxThrottleDelay
+		}
+	} else if t.delay > 0 {
+		t.delay /= 2
+		if t.delay < minThrottleDelay {
+			t.delay = 0
+		}
+	}
+	delay = t.delay
+	t.lock.Unlock()
+	// Printed unlocked, other requests must not wait on the terminal
+	if throttled {
+		ErrPrintf(ColorPurple, "API server is throttling requests, waiting %s before each request\n", delay)
+	}
+	return resp, nil
+}
@@ -0,0 +1,32 @@
+package deploy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestAdaptiveThrottle(t *testing.T) {
+	req := require.New(t)
+	statuses := []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK, http.StatusOK, http.StatusOK}
+	throttle := newAdaptiveThrottle(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		status := statuses[0]
+		statuses = statuses[1:]
+		return &http.Response{StatusCode: status}, nil
+	})).(*adaptiveThrottle)
+	request, err := http.NewRequest("GET", "http://kubernetes/api", nil)
+	req.Nil(err)
+	for _, delay := range []time.Duration{minThrottleDelay, 2 * minThrottleDelay, minThrottleDelay, 0, 0} {
+		_, err = throttle.RoundTrip(request)
+		req.Nil(err)
+		req.Equal(delay, throttle.delay)
+	}
+}
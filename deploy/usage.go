@@ -0,0 +1,193 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	app "k8s.io/api/apps/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The metrics API is served by metrics-server and has no typed client here
+const podMetricsPath = "/apis/metrics.k8s.io/v1beta1/namespaces"
+
+// Containers using less than overProvisioned of their request, or more than
+// their request or nearLimit of their limit, are flagged
+const (
+	overProvisioned = 0.2
+	nearLimit       = 0.9
+)
+
+type podMetrics struct {
+	Metadata   apiv1.ObjectMeta `json:"metadata"`
+	Containers []struct {
+		Name  string            `json:"name"`
+		Usage map[string]string `json:"usage"`
+	} `json:"containers"`
+}
+
+type podMetricsList struct {
+	Items []podMetrics `json:"items"`
+}
+
+func listPodMetrics(kubeClient *kubernetes.Clientset, namespace, selector string) ([]podMetrics, error) {
+	data, err := kubeClient.Core().RESTClient().Get().AbsPath(podMetricsPath, namespace, "pods").Param("labelSelector", selector).DoRaw()
+	if err != nil {
+		if isResourceNotExist(err) {
+			return nil, fmt.Errorf("cluster does not serve the metrics API (metrics.k8s.io), is metrics-server installed?")
+		}
+		return nil, err
+	}
+	list := &podMetricsList{}
+	err = json.Unmarshal(data, list)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode pod metrics: %s", err.Error())
+	}
+	return list.Items, nil
+}
+
+// workloadSelector selects the pods of a workload, from its selector or the
+// labels of its pod template
+func workloadSelector(asset *Asset) (string, error) {
+	var selector *apiv1.LabelSelector
+	var template *v1.PodTemplateSpec
+	switch resource := asset.ResourceData.(type) {
+	case *v1.Pod:
+		return labels.SelectorFromSet(resource.Labels).String(), nil
+	case *v1beta1.Deployment:
+		selector, template = resource.Spec.Selector, &resource.Spec.Template
+	case *v1beta1.DaemonSet:
+		selector, template = resource.Spec.Selector, &resource.Spec.Template
+	case *app.StatefulSet:
+		selector, template = resource.Spec.Selector, &resource.Spec.Template
+	default:
+		return "", nil
+	}
+	if selector == nil {
+		return labels.SelectorFromSet(template.Labels).String(), nil
+	}
+	parsed, err := apiv1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
+
+// containerUsage is the average usage of a container over the pods of a
+// workload, next to what the manifest requests
+type containerUsage struct {
+	container string
+	pods      int64
+	usage     v1.ResourceList
+	requests  v1.ResourceList
+	limits    v1.ResourceList
+}
+
+func (u *containerUsage) findings() []string {
+	findings := []string{}
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		used, ok := u.usage[name]
+		if !ok || u.pods == 0 {
+			continue
+		}
+		usage := float64(used.MilliValue()) / float64(u.pods)
+		requested, hasRequest := u.requests[name]
+		limit, hasLimit := u.limits[name]
+		average := resource.NewMilliQuantity(int64(usage), resource.DecimalSI)
+		if name == v1.ResourceMemory {
+			average = resource.NewQuantity(int64(usage/1000)>>20<<20, resource.BinarySI)
+		}
+		switch {
+		case hasLimit && usage > nearLimit*float64(limit.MilliValue()):
+			findings = append(findings, fmt.Sprintf("%s uses %s, close to its %s limit", name, average.String(), limit.String()))
+		case !hasRequest:
+			findings = append(findings, fmt.Sprintf("%s uses %s without a request", name, average.String()))
+		case usage > float64(requested.MilliValue()):
+			findings = append(findings, fmt.Sprintf("%s uses %s, more than the %s requested", name, average.String(), requested.String()))
+		case usage < overProvisioned*float64(requested.MilliValue()):
+			findings = append(findings, fmt.Sprintf("%s uses %s of the %s requested", name, average.String(), requested.String()))
+		}
+	}
+	return findings
+}
+
+func (p *Project) workloadUsage(asset *Asset) ([]*containerUsage, error) {
+	podSpec := getPodSpec(asset.Kind, asset.ResourceData)
+	selector, err := workloadSelector(asset)
+	if podSpec == nil || selector == "" || err != nil {
+		return nil, err
+	}
+	metrics, err := listPodMetrics(p.clientFor(asset), asset.Namespace(), selector)
+	if err != nil {
+		return nil, err
+	}
+	usages := []*containerUsage{}
+	for _, container := range podSpec.Containers {
+		usage := &containerUsage{
+			container: container.Name,
+			usage:     v1.ResourceList{},
+			requests:  container.Resources.Requests,
+			limits:    container.Resources.Limits,
+		}
+		for _, pod := range metrics {
+			for _, containerMetrics := range pod.Containers {
+				if containerMetrics.Name != container.Name {
+					continue
+				}
+				usage.pods++
+				for name, value := range containerMetrics.Usage {
+					quantity, err := resource.ParseQuantity(value)
+					if err != nil {
+						return nil, fmt.Errorf("unable to parse %s usage %q of pod %q: %s", name, value, pod.Metadata.Name, err.Error())
+					}
+					addResources(usage.usage, v1.ResourceList{v1.ResourceName(name): quantity}, 1)
+				}
+			}
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// ReportUsage compares the cpu and memory the pods of each workload use with
+// what their containers request, flagging badly provisioned containers. The
+// metrics API averages over a short window, so right after a rollout the new
+// pods may not be reported yet.
+func (p *Project) ReportUsage() error {
+	flagged := 0
+	for _, asset := range p.assets() {
+		if !longRunningKinds[asset.Kind] {
+			continue
+		}
+		usages, err := p.workloadUsage(asset)
+		if err != nil {
+			return err
+		}
+		sort.Slice(usages, func(i, j int) bool { return usages[i].container < usages[j].container })
+		for _, usage := range usages {
+			if usage.pods == 0 {
+				p.printer.Printf(ColorGray, "%s container %q: no metrics yet\n", assetKey(asset), usage.container)
+				continue
+			}
+			findings := usage.findings()
+			if len(findings) == 0 {
+				p.printer.Printf(ColorGreen, "%s container %q: usage matches its requests\n", assetKey(asset), usage.container)
+				continue
+			}
+			flagged++
+			for _, finding := range findings {
+				p.printer.Printf(ColorYellow, "%s container %q: %s\n", assetKey(asset), usage.container, finding)
+			}
+		}
+	}
+	if flagged > 0 {
+		p.printer.ErrPrintf(ColorYellow, "Warning: %d containers are over or under provisioned\n", flagged)
+	}
+	return nil
+}
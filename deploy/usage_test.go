@@ -0,0 +1,45 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestContainerUsageFindings(t *testing.T) {
+	req := require.New(t)
+	usage := &containerUsage{
+		container: "web",
+		pods:      2,
+		usage: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("100m"),
+			v1.ResourceMemory: resource.MustParse("1900Mi"),
+		},
+		requests: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("1"),
+			v1.ResourceMemory: resource.MustParse("512Mi"),
+		},
+		limits: v1.ResourceList{
+			v1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}
+	req.Equal([]string{
+		"cpu uses 50m of the 1 requested",
+		"memory uses 950Mi, close to its 1Gi limit",
+	}, usage.findings())
+
+	usage.usage = v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("1200m"),
+		v1.ResourceMemory: resource.MustParse("800Mi"),
+	}
+	req.Equal([]string{}, usage.findings())
+
+	usage.usage[v1.ResourceCPU] = resource.MustParse("3")
+	req.Equal([]string{"cpu uses 1500m, more than the 1 requested"}, usage.findings())
+
+	usage.requests = v1.ResourceList{}
+	usage.limits = v1.ResourceList{}
+	req.Equal([]string{"cpu uses 1500m without a request", "memory uses 400Mi without a request"}, usage.findings())
+}
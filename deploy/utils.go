@@ -0,0 +1,187 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dataPath = "/mnt/sda1/var/data"
+)
+
+func translateFilePath(rootFolder, file string) string {
+	if strings.HasPrefix(file, "~/") {
+		return filepath.Join(homeDir(), file[2:])
+	}
+	// A leading slash is also absolute on windows, where it means the current drive
+	if filepath.IsAbs(file) || strings.HasPrefix(file, "/") {
+		return file
+	}
+	return filepath.Join(rootFolder, file)
+}
+
+func homeDir() string {
+	home := os.Getenv("HOME")
+	if home == "" && runtime.GOOS == "windows" {
+		home = os.Getenv("USERPROFILE")
+	}
+	return home
+}
+
+// shellCommand runs script with the platform shell, sh or cmd on windows
+func shellCommand(script string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", script)
+	}
+	return exec.Command("sh", "-c", script)
+}
+
+// inFolder makes a shellCommand script run from folder
+func inFolder(folder, script string) string {
+	if runtime.GOOS == "windows" {
+		return fmt.Sprintf("cd /d \"%s\" && %s", folder, script)
+	}
+	return fmt.Sprintf("cd '%s' && %s", strings.Replace(folder, "'", `'\''`, -1), script)
+}
+
+// stdinReader is shared by every prompt, a reader per prompt would drop the
+// answers it buffered when several are piped in
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// askConfirmation asks a yes/no question on stdin. Without a terminal to
+// answer it, -non-interactive, the answer is no unless -yes is given.
+func askConfirmation(config *appConfig, question string) bool {
+	if config.yes {
+		config.printer.Printf(ColorPurple, "%s [y/N]: yes (-yes)\n", question)
+		return true
+	}
+	if config.nonInteractive {
+		config.printer.Printf(ColorPurple, "%s [y/N]: no (non-interactive, pass -yes to confirm)\n", question)
+		return false
+	}
+	config.printer.Printf(ColorPurple, "%s [y/N]: ", question)
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// askExplicitConfirmation is askConfirmation for what -yes must not answer,
+// it always needs someone at the terminal
+func askExplicitConfirmation(config *appConfig, question string) bool {
+	if config.yes || config.nonInteractive {
+		config.printer.Printf(ColorPurple, "%s [y/N]: no (needs an answer at the terminal, -yes does not cover it)\n", question)
+		return false
+	}
+	return askConfirmation(config, question)
+}
+
+// pickOne asks to choose among options, in -non-interactive mode it fails
+// instead of blocking on stdin
+func pickOne(config *appConfig, question string, options []string) (string, error) {
+	if config.nonInteractive {
+		return "", fmt.Errorf("%s: cannot prompt in non-interactive mode, candidates are %s", question, strings.Join(options, ", "))
+	}
+	for i, option := range options {
+		config.printer.Printf(ColorWhite, "  %d) %s\n", i+1, option)
+	}
+	config.printer.Printf(ColorPurple, "%s [1-%d]: ", question, len(options))
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("no choice made")
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || choice < 1 || choice > len(options) {
+		return "", fmt.Errorf("invalid choice %q", strings.TrimSpace(answer))
+	}
+	return options[choice-1], nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func defaultProjectName(rootFolder string) string {
+	absFolder, err := filepath.Abs(rootFolder)
+	if err != nil {
+		absFolder = rootFolder
+	}
+	name := strings.ToLower(filepath.Base(absFolder))
+	name = regexp.MustCompile("[^a-z0-9-]+").ReplaceAllString(name, "-")
+	return strings.Trim(name, "-")
+}
+
+// sleep waits like time.Sleep, through the hook of the config when it has one
+func (p *Project) sleep(d time.Duration) {
+	if p.config.sleep != nil {
+		p.config.sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+func deployActor() string {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = os.Getenv("USERNAME")
+	}
+	if user == "" {
+		user = "unknown"
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return user
+	}
+	return user + "@" + hostname
+}
+
+func doJSONRequest(method, url string, headers map[string]string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %s: %s", method, url, resp.Status, string(content))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
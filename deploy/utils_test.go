@@ -0,0 +1,46 @@
+package deploy
+
+import (
+	"bufio"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonInteractivePrompts(t *testing.T) {
+	req := require.New(t)
+	config := &appConfig{nonInteractive: true}
+	req.False(askConfirmation(config, "Delete everything?"))
+	_, err := pickOne(config, "Context \"prod\" is ambiguous, pick one", []string{"prod-eu", "prod-us"})
+	req.Error(err)
+	req.Contains(err.Error(), "prod-eu, prod-us")
+
+	config.yes = true
+	req.True(askConfirmation(config, "Delete everything?"))
+}
+
+func TestPipedPrompts(t *testing.T) {
+	req := require.New(t)
+	defer func(reader *bufio.Reader) { stdinReader = reader }(stdinReader)
+	stdinReader = bufio.NewReader(strings.NewReader("y\n2\nn\n"))
+	config := &appConfig{}
+	req.True(askConfirmation(config, "Recreate job \"migrate\"?"))
+	picked, err := pickOne(config, "Pick a context", []string{"prod-eu", "prod-us"})
+	req.Nil(err)
+	req.Equal("prod-us", picked)
+	req.False(askConfirmation(config, "Recreate job \"seed\"?"))
+}
+
+func TestSortedKeys(t *testing.T) {
+	require.Equal(t, []string{"api", "web", "worker"}, sortedKeys(map[string]string{"worker": "", "api": "", "web": ""}))
+}
+
+func TestTranslateFilePath(t *testing.T) {
+	req := require.New(t)
+	t.Setenv("HOME", "/home/deployer")
+	req.Equal(filepath.Join("project", "secrets", "key.pem"), translateFilePath("project", "secrets/key.pem"))
+	req.Equal("/etc/ssl/ca.pem", translateFilePath("project", "/etc/ssl/ca.pem"))
+	req.Equal(filepath.Join("/home/deployer", ".docker", "config.json"), translateFilePath("project", "~/.docker/config.json"))
+}
@@ -0,0 +1,186 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// valuesSchema is the part of JSON Schema that makes sense for template
+// variables, which are strings: the type says what the string must parse as.
+// Schemas can be written in JSON or YAML.
+type valuesSchema struct {
+	Type                 string                   `yaml:"type"`
+	Description          string                   `yaml:"description"`
+	Properties           map[string]*valuesSchema `yaml:"properties"`
+	Required             []string                 `yaml:"required"`
+	AdditionalProperties *bool                    `yaml:"additionalProperties"`
+	Enum                 []interface{}            `yaml:"enum"`
+	Pattern              string                   `yaml:"pattern"`
+	Minimum              *float64                 `yaml:"minimum"`
+	Maximum              *float64                 `yaml:"maximum"`
+	MinLength            *int                     `yaml:"minLength"`
+	MaxLength            *int                     `yaml:"maxLength"`
+}
+
+func readValuesSchema(filename string) (*valuesSchema, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	schema := &valuesSchema{}
+	err = yaml.Unmarshal(data, schema)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse values schema %q: %s", filename, err)
+	}
+	if schema.Type != "" && schema.Type != "object" {
+		return nil, fmt.Errorf("values schema %q must describe an object, not %q", filename, schema.Type)
+	}
+	return schema, nil
+}
+
+// readValueFiles merges the -values files in order, then the -variable
+// overrides on top. Values files are flat maps of scalars.
+func readValueFiles(filenames []string, overrides variableMap) (variableMap, error) {
+	variables := make(variableMap)
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]interface{})
+		err = yaml.Unmarshal(data, &values)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse values file %q: %s", filename, err)
+		}
+		for key, value := range values {
+			switch value.(type) {
+			case map[interface{}]interface{}, []interface{}:
+				return nil, fmt.Errorf("values file %q: %q must be a string, number or boolean", filename, key)
+			case nil:
+				variables[key] = ""
+			default:
+				variables[key] = fmt.Sprint(value)
+			}
+		}
+	}
+	for key, value := range overrides {
+		variables[key] = value
+	}
+	return variables, nil
+}
+
+// check lists what is wrong with a value, empty when it matches
+func (s *valuesSchema) check(value string) []string {
+	problems := []string{}
+	var number float64
+	var err error
+	switch s.Type {
+	case "integer":
+		var integer int64
+		integer, err = strconv.ParseInt(value, 10, 64)
+		number = float64(integer)
+	case "number":
+		number, err = strconv.ParseFloat(value, 64)
+	case "boolean":
+		_, err = strconv.ParseBool(value)
+	case "", "string":
+	default:
+		return []string{fmt.Sprintf("unsupported type %q in schema", s.Type)}
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("%q is not a valid %s", value, s.Type)}
+	}
+	if len(s.Enum) > 0 {
+		allowed := []string{}
+		for _, option := range s.Enum {
+			allowed = append(allowed, fmt.Sprint(option))
+		}
+		found := false
+		for _, option := range allowed {
+			found = found || option == value
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%q is not one of %s", value, strings.Join(allowed, ", ")))
+		}
+	}
+	if s.Pattern != "" {
+		matched, err := regexp.MatchString(s.Pattern, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid pattern %q in schema: %s", s.Pattern, err))
+		} else if !matched {
+			problems = append(problems, fmt.Sprintf("%q does not match %q", value, s.Pattern))
+		}
+	}
+	if s.Type == "integer" || s.Type == "number" {
+		if s.Minimum != nil && number < *s.Minimum {
+			problems = append(problems, fmt.Sprintf("%s is less than the minimum %v", value, *s.Minimum))
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			problems = append(problems, fmt.Sprintf("%s is more than the maximum %v", value, *s.Maximum))
+		}
+	}
+	if s.MinLength != nil && len(value) < *s.MinLength {
+		problems = append(problems, fmt.Sprintf("%q is shorter than %d characters", value, *s.MinLength))
+	}
+	if s.MaxLength != nil && len(value) > *s.MaxLength {
+		problems = append(problems, fmt.Sprintf("%q is longer than %d characters", value, *s.MaxLength))
+	}
+	return problems
+}
+
+// validate lists every variable that breaks the schema. The variables
+// imladris sets itself are never reported as additional properties.
+func (s *valuesSchema) validate(variables map[string]string) []string {
+	problems := []string{}
+	for _, name := range s.Required {
+		if _, ok := variables[name]; !ok {
+			description := ""
+			if property := s.Properties[name]; property != nil && property.Description != "" {
+				description = " (" + property.Description + ")"
+			}
+			problems = append(problems, fmt.Sprintf("%s: required but not set%s", name, description))
+		}
+	}
+	for _, name := range sortedKeys(variables) {
+		property, ok := s.Properties[name]
+		if !ok {
+			builtin := false
+			for _, prefix := range builtinVariablePrefixes {
+				builtin = builtin || strings.HasPrefix(name, prefix)
+			}
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties && !builtin {
+				problems = append(problems, fmt.Sprintf("%s: not allowed by the schema", name))
+			}
+			continue
+		}
+		for _, problem := range property.check(variables[name]) {
+			problems = append(problems, name+": "+problem)
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// validateValues checks the variables against the values_schema of the
+// project file before anything is rendered
+func (p *Project) validateValues() error {
+	if p.projectConfig.ValuesSchema == "" {
+		return nil
+	}
+	filename := translateFilePath(p.projectConfig.RootFolder, p.projectConfig.ValuesSchema)
+	schema, err := readValuesSchema(filename)
+	if err != nil {
+		return err
+	}
+	problems := schema.validate(p.projectConfig.Variables)
+	if len(problems) == 0 {
+		return nil
+	}
+	return validationError(fmt.Errorf("values do not match the schema %q:\n  %s", filename, strings.Join(problems, "\n  ")))
+}
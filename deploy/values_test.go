@@ -0,0 +1,40 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadValueFiles(t *testing.T) {
+	req := require.New(t)
+	variables, err := readValueFiles([]string{"test-assets/values-tests/values.yml"}, variableMap{"replicas": "5"})
+	req.NoError(err)
+	req.Equal(variableMap{"replicas": "5", "image_tag": "1.2", "debug": "true"}, variables)
+}
+
+func TestValuesSchema(t *testing.T) {
+	req := require.New(t)
+	schema, err := readValuesSchema("test-assets/values-tests/values.schema.json")
+	req.NoError(err)
+	req.Empty(schema.validate(map[string]string{"replicas": "3", "image_tag": "1.2", "log_level": "warn", "app_var_home": "/root"}))
+	req.Equal([]string{
+		"debug: \"yes\" is not a valid boolean",
+		"image_tag: required but not set (release to deploy)",
+		"log_level: \"trace\" is not one of debug, info, warn",
+		"replicas: 12 is more than the maximum 10",
+		"unknown: not allowed by the schema",
+	}, schema.validate(map[string]string{"replicas": "12", "log_level": "trace", "debug": "yes", "unknown": "x"}))
+}
+
+func TestValuesSchemaValidatesProject(t *testing.T) {
+	req := require.New(t)
+	_, err := readProject(nil, "test-assets/values-tests", &appConfig{valueFiles: fileList{"test-assets/values-tests/values.yml"}})
+	req.NoError(err)
+	_, err = readProject(nil, "test-assets/values-tests", &appConfig{
+		valueFiles: fileList{"test-assets/values-tests/values.yml"},
+		variables:  variableMap{"replicas": "many"},
+	})
+	req.Error(err)
+	req.Contains(err.Error(), `replicas: "many" is not a valid integer`)
+}
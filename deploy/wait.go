@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"time"
+
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+func watchResources(kubeClient *kubernetes.Clientset, kind, namespace string, options apiv1.ListOptions) (watch.Interface, error) {
+	switch kind {
+	case "pod":
+		return kubeClient.Core().Pods(namespace).Watch(options)
+	case "deployment":
+		return kubeClient.Extensions().Deployments(namespace).Watch(options)
+	case "service":
+		return kubeClient.Core().Services(namespace).Watch(options)
+	case "job":
+		return kubeClient.Batch().Jobs(namespace).Watch(options)
+	case "persistentvolumeclaim":
+		return kubeClient.Core().PersistentVolumeClaims(namespace).Watch(options)
+	case "configmap":
+		return kubeClient.Core().ConfigMaps(namespace).Watch(options)
+	case "secret":
+		return kubeClient.Core().Secrets(namespace).Watch(options)
+	case "ingress":
+		return kubeClient.Extensions().Ingresses(namespace).Watch(options)
+	case "endpoints":
+		return kubeClient.Core().Endpoints(namespace).Watch(options)
+	case "daemonset":
+		return kubeClient.Extensions().DaemonSets(namespace).Watch(options)
+	case "serviceaccount":
+		return kubeClient.Core().ServiceAccounts(namespace).Watch(options)
+	case "role":
+		return kubeClient.RbacV1beta1().Roles(namespace).Watch(options)
+	case "clusterrole":
+		return kubeClient.RbacV1beta1().ClusterRoles().Watch(options)
+	case "rolebinding":
+		return kubeClient.RbacV1beta1().RoleBindings(namespace).Watch(options)
+	case "clusterrolebinding":
+		return kubeClient.RbacV1beta1().ClusterRoleBindings().Watch(options)
+	case "statefulset":
+		return kubeClient.AppsV1beta1().StatefulSets(namespace).Watch(options)
+	case "cronjob":
+		return kubeClient.BatchV1beta1().CronJobs(namespace).Watch(options)
+	case "horizontalpodautoscaler":
+		return kubeClient.Autoscaling().HorizontalPodAutoscalers(namespace).Watch(options)
+	default:
+		return nil, UnsupportedResource(kind)
+	}
+}
+
+// watchUntil blocks until done accepts an event, the deadline passes or the
+// server closes the watch. Callers re-read the state afterwards, so a failed
+// watch only degrades to a slow poll.
+func watchUntil(kubeClient *kubernetes.Clientset, kind, namespace string, options apiv1.ListOptions, deadline time.Time, done func(event watch.Event) bool) {
+	watcher, err := watchResources(kubeClient, kind, namespace, options)
+	if err != nil {
+		time.Sleep(time.Second)
+		return
+	}
+	defer watcher.Stop()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok || event.Type == watch.Error {
+				return
+			}
+			if done(event) {
+				return
+			}
+		case <-timer.C:
+			return
+		}
+	}
+}
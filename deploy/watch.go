@@ -0,0 +1,231 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// watchDebounce lets an editor or a git checkout finish writing before the
+// manifests are read again
+const watchDebounce = 500 * time.Millisecond
+
+func watchProject(kubeClient *kubernetes.Clientset, assetRoot string, config *appConfig) {
+	project, err := readProject(kubeClient, assetRoot, config)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	watcher, err := newFolderWatcher(project.projectConfig.RootFolder)
+	if err != nil {
+		exitWithError(config, err)
+	}
+	defer watcher.close()
+	Printf(ColorYellow, "Watching %q for changes, checking the cluster for drift every %s\n", project.projectConfig.RootFolder, config.watchInterval)
+	ticker := time.NewTicker(config.watchInterval)
+	defer ticker.Stop()
+	var lastLive map[string]string
+	changed := true
+	for {
+		if project == nil {
+			project, err = readProject(kubeClient, assetRoot, config)
+		}
+		if err == nil {
+			lastLive, err = reconcileProject(project, changed, lastLive)
+		}
+		if err != nil {
+			ErrPrintln(ColorRed, err)
+		}
+		project = nil
+		select {
+		case <-watcher.changes:
+			changed = true
+		case <-ticker.C:
+			changed = false
+		case err = <-watcher.errors:
+			ErrPrintln(ColorRed, err)
+			changed = false
+		}
+	}
+}
+
+// reconcileProject re-applies the project when its manifests changed or the
+// cluster drifted from them. It applies rather than updates, resources
+// deleted from the cluster or new in the manifests get created. Undoing a
+// drift re-applies what was released already, so only manifest changes record
+// a release.
+func reconcileProject(project *Project, changed bool, lastLive map[string]string) (map[string]string, error) {
+	live, err := project.liveFingerprints()
+	if err != nil {
+		return lastLive, err
+	}
+	drifted := lastLive != nil && !sameFingerprints(live, lastLive)
+	if !changed && !drifted {
+		return live, nil
+	}
+	if changed {
+		if lastLive != nil {
+			Println(ColorPurple, "Manifests changed, re-applying")
+		}
+		project.reconciling = false
+	} else {
+		Println(ColorPurple, "Cluster drifted from manifests, re-applying")
+		project.observeDrift()
+		// Drifted objects still carry the old checksum, so they must be re-applied
+		project.skipUnchanged = false
+		project.reconciling = true
+	}
+	started := project.startDeploy("apply")
+	err = project.deploy("apply", project.applyAsset)
+	project.finishDeploy("apply", started, err)
+	if err != nil {
+		ErrPrintln(ColorRed, err)
+	}
+	return project.liveFingerprints()
+}
+
+// folderWatcher reports changes under a folder, watching the folders created
+// later too. Changes come out debounced, a checkout touching many files
+// triggers one deploy.
+type folderWatcher struct {
+	watcher *fsnotify.Watcher
+	changes chan struct{}
+	errors  chan error
+	done    chan struct{}
+}
+
+func newFolderWatcher(root string) (*folderWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &folderWatcher{
+		watcher: watcher,
+		changes: make(chan struct{}, 1),
+		errors:  make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+	err = w.addTree(root)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *folderWatcher) addTree(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && isHiddenFile(path) {
+			return filepath.SkipDir
+		}
+		return w.watcher.Add(path)
+	})
+}
+
+func (w *folderWatcher) run() {
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod || isHiddenFile(event.Name) {
+				continue
+			}
+			if event.Op&fsnotify.Create != 0 {
+				info, err := os.Stat(event.Name)
+				if err == nil && info.IsDir() {
+					err = w.addTree(event.Name)
+					if err != nil {
+						w.report(err)
+					}
+				}
+			}
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			debounce = nil
+			select {
+			case w.changes <- struct{}{}:
+			default:
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.report(err)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// isHiddenFile tells the files no manifest lives in: .git, editor swap files
+// and the state imladris keeps next to the project
+func isHiddenFile(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
+
+func (w *folderWatcher) report(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
+
+func (w *folderWatcher) close() {
+	close(w.done)
+	w.watcher.Close()
+}
+
+func (p *Project) liveFingerprints() (map[string]string, error) {
+	fingerprints := make(map[string]string)
+	for _, asset := range p.assets() {
+		switch asset.Kind {
+		case "deployment", "configmap", "secret", "service":
+		default:
+			continue
+		}
+		assetName := asset.ResourceData.(Meta).GetName()
+		live, err := getResource(p.clientFor(asset), p.plugins, asset.Kind, assetName, asset.Namespace())
+		if err != nil {
+			if isResourceNotExist(err) {
+				fingerprints[asset.Kind+"/"+assetName] = ""
+				continue
+			}
+			return nil, err
+		}
+		objectMeta := live.(apiv1.Object)
+		// Status updates bump resourceVersion, so prefer generation when the kind has one
+		if objectMeta.GetGeneration() > 0 {
+			fingerprints[asset.Kind+"/"+assetName] = fmt.Sprintf("g%d", objectMeta.GetGeneration())
+		} else {
+			fingerprints[asset.Kind+"/"+assetName] = objectMeta.GetResourceVersion()
+		}
+	}
+	return fingerprints, nil
+}
+
+func sameFingerprints(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if b[key] != value {
+			return false
+		}
+	}
+	return true
+}
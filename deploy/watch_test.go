@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func waitForChange(w *folderWatcher) bool {
+	select {
+	case <-w.changes:
+		return true
+	case <-time.After(5 * watchDebounce):
+		return false
+	}
+}
+
+func TestFolderWatcher(t *testing.T) {
+	req := require.New(t)
+	root := t.TempDir()
+	req.Nil(os.Mkdir(filepath.Join(root, ".git"), 0755))
+	w, err := newFolderWatcher(root)
+	req.Nil(err)
+	defer w.close()
+
+	req.Nil(ioutil.WriteFile(filepath.Join(root, "project.yml"), []byte("name: web\n"), 0644))
+	req.True(waitForChange(w))
+
+	// Folders created later are watched too
+	req.Nil(os.Mkdir(filepath.Join(root, "services"), 0755))
+	req.True(waitForChange(w))
+	req.Nil(ioutil.WriteFile(filepath.Join(root, "services", "web.yml"), []byte("kind: Service\n"), 0644))
+	req.True(waitForChange(w))
+
+	// A burst of writes is one change
+	for i := 0; i < 5; i++ {
+		req.Nil(ioutil.WriteFile(filepath.Join(root, "services", "web.yml"), []byte("kind: Service\n"), 0644))
+	}
+	req.True(waitForChange(w))
+	req.False(waitForChange(w))
+
+	// Neither git nor editor swap files trigger a deploy
+	req.Nil(ioutil.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("ref: refs/heads/master\n"), 0644))
+	req.Nil(ioutil.WriteFile(filepath.Join(root, "services", ".web.yml.swp"), []byte("swap"), 0600))
+	req.False(waitForChange(w))
+}
+
+func TestReconcileCreatesMissingResources(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	cluster.serveDiscovery()
+	project := writeMonorepoProject(t, cluster, "web")
+	config := &appConfig{printer: newPrinter(ioutil.Discard, ioutil.Discard)}
+	reconcile := func(changed bool, lastLive map[string]string) map[string]string {
+		p, err := readProject(kubeClient, project.file, config)
+		req.Nil(err)
+		live, err := reconcileProject(p, changed, lastLive)
+		req.Nil(err)
+		return live
+	}
+	live := reconcile(true, nil)
+	req.Equal("2", configMapVersion(cluster, "web"))
+
+	// Deleted from the cluster, it drifted
+	req.Nil(kubeClient.Core().ConfigMaps("web").Delete("web", nil))
+	live = reconcile(false, live)
+	req.Equal("2", configMapVersion(cluster, "web"))
+
+	// New in the manifests
+	req.Nil(ioutil.WriteFile(filepath.Join(project.file, "services", "extra.yml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: extra\ndata:\n  version: \"1\"\n"), 0644))
+	live = reconcile(true, live)
+	req.NotNil(cluster.get("/api/v1/namespaces/web/configmaps/extra"))
+	req.NotEmpty(live["configmap/extra"])
+}
+
+func TestReconcilingRecordsNoRelease(t *testing.T) {
+	req := require.New(t)
+	cluster, kubeClient := newFakeCluster(t)
+	p := &Project{kubeClient: kubeClient, config: &appConfig{}, projectConfig: &ProjectConfig{Name: "web", Namespace: "web"}, reconciling: true}
+	p.recordRelease("update")
+	req.Empty(cluster.paths("/"))
+
+	p.reconciling = false
+	p.recordRelease("update")
+	req.NotNil(cluster.get("/api/v1/namespaces/web/configmaps/" + releaseHistoryPrefix + "web"))
+}
@@ -0,0 +1,113 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// imagePush is one tag pushed to a repository, e.g. team/app:v1.2
+type imagePush struct {
+	Repository string
+	Tag        string
+}
+
+// registryPayload covers the notifications of a docker distribution
+// registry and the webhooks of Docker Hub
+type registryPayload struct {
+	Events []struct {
+		Action string `json:"action"`
+		Target struct {
+			Repository string `json:"repository"`
+			Tag        string `json:"tag"`
+		} `json:"target"`
+	} `json:"events"`
+	PushData struct {
+		Tag string `json:"tag"`
+	} `json:"push_data"`
+	Repository struct {
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+}
+
+func parseRegistryPushes(data []byte) ([]*imagePush, error) {
+	payload := &registryPayload{}
+	err := json.Unmarshal(data, payload)
+	if err != nil {
+		return nil, err
+	}
+	pushes := []*imagePush{}
+	for _, event := range payload.Events {
+		// Pushes by digest only and pulls don't name a tag to roll out
+		if event.Action != "push" || event.Target.Tag == "" {
+			continue
+		}
+		pushes = append(pushes, &imagePush{Repository: event.Target.Repository, Tag: event.Target.Tag})
+	}
+	if payload.Repository.RepoName != "" && payload.PushData.Tag != "" {
+		pushes = append(pushes, &imagePush{Repository: payload.Repository.RepoName, Tag: payload.PushData.Tag})
+	}
+	return pushes, nil
+}
+
+// imageFromRepository accepts the image with or without the registry host,
+// registries only report the repository path
+func imageFromRepository(image, repository string) bool {
+	return image == repository || strings.HasSuffix(image, "/"+repository)
+}
+
+// handleRegistryWebhook redeploys the containers listed in auto_updates of
+// the project given in the url that run a pushed repository. Docker Hub can't
+// send headers, so the token may also come as a query parameter.
+func (s *deployServer) handleRegistryWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.webhookAuthorized(r) {
+		http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	project := query.Get("project")
+	if project == "" {
+		http.Error(w, "project query parameter is required", http.StatusBadRequest)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pushes, err := parseRegistryPushes(data)
+	if err != nil {
+		http.Error(w, "invalid registry payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	job := &deployJob{
+		Project: project,
+		Action:  "auto-update",
+		Target:  s.webhookTarget(query),
+	}
+	job.run = func(out *printer) *deployResult {
+		options := s.options(query.Get("context"), query.Get("namespace"), out)
+		result := &deployResult{Result: "succeeded"}
+		for _, push := range pushes {
+			out.Printf(ColorYellow, "Registry pushed %s:%s for %q\n", push.Repository, push.Tag, project)
+			deployment, err := s.load(project, options)
+			if err == nil {
+				err = deployment.project.autoUpdate(push.Repository, push.Tag)
+			}
+			if err != nil {
+				out.ErrPrintln(ColorRed, err)
+				result.Result = "failed"
+				result.Error = fmt.Sprintf("%s:%s: %s", push.Repository, push.Tag, err.Error())
+				break
+			}
+		}
+		return result
+	}
+	s.stream(w, job)
+}
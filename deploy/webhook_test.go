@@ -0,0 +1,33 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRegistryPushes(t *testing.T) {
+	req := require.New(t)
+	pushes, err := parseRegistryPushes([]byte(`{"events": [
+		{"action": "push", "target": {"repository": "team/api", "tag": "v1.2"}},
+		{"action": "push", "target": {"repository": "team/api", "digest": "sha256:abc"}},
+		{"action": "pull", "target": {"repository": "team/web", "tag": "v3"}}
+	]}`))
+	req.NoError(err)
+	req.Equal([]*imagePush{{Repository: "team/api", Tag: "v1.2"}}, pushes)
+
+	pushes, err = parseRegistryPushes([]byte(`{"push_data": {"tag": "latest"}, "repository": {"repo_name": "anduin/web"}}`))
+	req.NoError(err)
+	req.Equal([]*imagePush{{Repository: "anduin/web", Tag: "latest"}}, pushes)
+
+	_, err = parseRegistryPushes([]byte(`not json`))
+	req.Error(err)
+}
+
+func TestImageFromRepository(t *testing.T) {
+	req := require.New(t)
+	req.True(imageFromRepository("registry.example.com/team/api", "team/api"))
+	req.True(imageFromRepository("anduin/web", "anduin/web"))
+	req.False(imageFromRepository("registry.example.com/team/api-worker", "team/api"))
+	req.False(imageFromRepository("registry.example.com/otherteam/api", "team/api"))
+}
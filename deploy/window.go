@@ -0,0 +1,214 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// DeployWindow allows deploys to the contexts matching Contexts only during
+// the minutes matched by Schedule, a cron expression read in Timezone.
+// Contexts without any window can be deployed to at any time. With Queue,
+// deploys outside the window wait for it to open instead of failing.
+type DeployWindow struct {
+	Contexts []string `yaml:"contexts"`
+	Schedule string   `yaml:"schedule"`
+	Timezone string   `yaml:"timezone"`
+	Queue    bool     `yaml:"queue"`
+}
+
+// cronSchedule holds the allowed values of each cron field: minute, hour,
+// day of month, month and day of week
+type cronSchedule struct {
+	fields [5]map[int]bool
+	// Like cron, when both days are restricted either one matching is enough
+	anyDay bool
+}
+
+var cronRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+func parseCronSchedule(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q, expected 5 fields: minute hour day month weekday", expression)
+	}
+	schedule := &cronSchedule{}
+	for i, field := range fields {
+		values, err := parseCronField(field, cronRanges[i][0], cronRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s", expression, err.Error())
+		}
+		schedule.fields[i] = values
+	}
+	// Sunday is both 0 and 7
+	if schedule.fields[4][7] {
+		schedule.fields[4][0] = true
+	}
+	schedule.anyDay = fields[2] != "*" && fields[4] != "*"
+	return schedule, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if pieces := strings.SplitN(part, "/", 2); len(pieces) == 2 {
+			var err error
+			step, err = strconv.Atoi(pieces[1])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			part = pieces[0]
+		}
+		start, end := min, max
+		if part != "*" {
+			pieces := strings.SplitN(part, "-", 2)
+			var err error
+			start, err = strconv.Atoi(pieces[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			end = start
+			if len(pieces) == 2 {
+				end, err = strconv.Atoi(pieces[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+func (s *cronSchedule) matches(t time.Time) bool {
+	if !s.fields[0][t.Minute()] || !s.fields[1][t.Hour()] || !s.fields[3][int(t.Month())] {
+		return false
+	}
+	day := s.fields[2][t.Day()]
+	weekday := s.fields[4][int(t.Weekday())]
+	if s.anyDay {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// next returns the first minute from t on that matches, looking a year ahead
+func (s *cronSchedule) next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for i := 0; i < 366*24*60; i++ {
+		if s.matches(t) {
+			return t, true
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}, false
+}
+
+func (w *DeployWindow) location() (*time.Location, error) {
+	if w.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(w.Timezone)
+}
+
+// nextWindow returns when deploying to context is allowed from now on: now
+// itself when a window is open, and whether any window wants to wait for it
+func nextWindow(windows []*DeployWindow, context string, now time.Time) (time.Time, bool, bool, error) {
+	restricted := false
+	queue := false
+	var opening time.Time
+	for _, window := range windows {
+		applies, err := matchContexts(window.Contexts, context)
+		if err != nil {
+			return now, false, false, err
+		}
+		if !applies {
+			continue
+		}
+		restricted = true
+		schedule, err := parseCronSchedule(window.Schedule)
+		if err != nil {
+			return now, false, false, err
+		}
+		location, err := window.location()
+		if err != nil {
+			return now, false, false, fmt.Errorf("invalid deploy window timezone %q: %s", window.Timezone, err.Error())
+		}
+		if schedule.matches(now.In(location)) {
+			return now, true, false, nil
+		}
+		next, ok := schedule.next(now.In(location))
+		if ok && (opening.IsZero() || next.Before(opening)) {
+			opening = next
+			queue = window.Queue
+		}
+	}
+	if !restricted {
+		return now, true, false, nil
+	}
+	return opening, false, queue, nil
+}
+
+// enforceDeployWindow refuses, or waits for the next window when the window
+// queues, deploys outside the windows of the current context.
+// -override-window deploys anyway and records its reason in the audit log.
+func (p *Project) enforceDeployWindow() error {
+	if len(p.projectConfig.DeployWindows) == 0 {
+		return nil
+	}
+	context := contextName(p.config)
+	opening, open, queue, err := nextWindow(p.projectConfig.DeployWindows, context, time.Now())
+	if err != nil || open {
+		return err
+	}
+	if p.config.overrideWindow != "" {
+		p.printer.ErrPrintf(ColorYellow, "Deploying to %q outside its deploy windows: %s\n", context, p.config.overrideWindow)
+		p.audit("override-window", "", "", nil, map[string]string{"reason": p.config.overrideWindow, "context": context})
+		return nil
+	}
+	if opening.IsZero() {
+		return validationError(fmt.Errorf("refusing to deploy to %q, none of its deploy windows ever opens; pass -override-window with a reason to deploy anyway", context))
+	}
+	if !queue {
+		return validationError(fmt.Errorf("refusing to deploy to %q outside its deploy windows, the next one opens at %s; pass -override-window with a reason to deploy anyway", context, opening.Format(time.RFC1123)))
+	}
+	p.printer.Printf(ColorPurple, "Queued until the next deploy window of %q opens at %s\n", context, opening.Format(time.RFC1123))
+	p.sleep(time.Until(opening))
+	return nil
+}
+
+// enforceFolderWindows enforces the deploy windows of the project in folder,
+// when there is one, for the commands that work on a namespace or a backup
+// rather than on a project
+func enforceFolderWindows(kubeClient *kubernetes.Clientset, folder string, config *appConfig) error {
+	_, err := readFolderProject(kubeClient, folder, config)
+	return err
+}
+
+// readFolderProject reads the project in folder, nil without one. Reading it
+// checks its cluster pin, then its deploy windows are enforced.
+func readFolderProject(kubeClient *kubernetes.Clientset, folder string, config *appConfig) (*Project, error) {
+	if _, err := os.Stat(filepath.Join(folder, "project.yml")); err != nil {
+		return nil, nil
+	}
+	project, err := readProject(kubeClient, folder, config)
+	if err != nil {
+		return nil, err
+	}
+	err = project.enforceDeployWindow()
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
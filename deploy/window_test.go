@@ -0,0 +1,83 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronSchedule(t *testing.T) {
+	req := require.New(t)
+	schedule, err := parseCronSchedule("*/15 9-17 * * 1-5")
+	req.Nil(err)
+	// 2026-10-15 is a Thursday
+	req.True(schedule.matches(time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)))
+	req.False(schedule.matches(time.Date(2026, 10, 15, 9, 31, 0, 0, time.UTC)))
+	req.False(schedule.matches(time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)))
+	next, ok := schedule.next(time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC))
+	req.True(ok)
+	req.Equal(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), next)
+
+	schedule, err = parseCronSchedule("0 0 1 * 0")
+	req.Nil(err)
+	req.True(schedule.matches(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)))
+	req.True(schedule.matches(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)))
+
+	_, err = parseCronSchedule("* 24 * * *")
+	req.Error(err)
+	_, err = parseCronSchedule("* * *")
+	req.Error(err)
+}
+
+func TestNextWindow(t *testing.T) {
+	req := require.New(t)
+	windows := []*DeployWindow{
+		{Contexts: []string{"prod-.*"}, Schedule: "* 9-16 * * 1-4", Timezone: "UTC", Queue: true},
+	}
+	now := time.Date(2026, 10, 15, 20, 0, 0, 0, time.UTC)
+	_, open, _, err := nextWindow(windows, "staging", now)
+	req.Nil(err)
+	req.True(open)
+	opening, open, queue, err := nextWindow(windows, "prod-eu", now)
+	req.Nil(err)
+	req.False(open)
+	req.True(queue)
+	req.Equal(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), opening.UTC())
+	_, open, _, err = nextWindow(windows, "prod-eu", time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC))
+	req.Nil(err)
+	req.True(open)
+
+	// Patterns match the whole context name
+	_, open, _, err = nextWindow(windows, "preprod-eu", now)
+	req.Nil(err)
+	req.True(open)
+}
+
+func TestEnforceDeployWindow(t *testing.T) {
+	req := require.New(t)
+	// A window opening at a minute that is half an hour away
+	minute := (time.Now().UTC().Minute() + 30) % 60
+	out := &bytes.Buffer{}
+	slept := []time.Duration{}
+	p := &Project{
+		config: &appConfig{context: "prod-eu", sleep: func(d time.Duration) { slept = append(slept, d) }},
+		projectConfig: &ProjectConfig{
+			DeployWindows: []*DeployWindow{{Contexts: []string{"prod-.*"}, Schedule: fmt.Sprintf("%d * * * *", minute), Timezone: "UTC"}},
+		},
+		printer: newPrinter(out, out),
+	}
+
+	for _, command := range []func() error{p.Down, p.DownServices, p.DownJobs} {
+		err := command()
+		req.Error(err)
+		req.Contains(err.Error(), "outside its deploy windows")
+	}
+
+	p.projectConfig.DeployWindows[0].Queue = true
+	req.Nil(p.enforceDeployWindow())
+	req.Len(slept, 1)
+	req.True(slept[0] > 0 && slept[0] <= 31*time.Minute)
+}
@@ -1,6 +1,7 @@
 // Code generated by go-bindata.
 // sources:
 // templates/files/configmap.yml
+// templates/files/cronjob.yml
 // templates/files/deployment.yml
 // templates/files/job.yml
 // templates/files/persistentvolumeclaim.yml
@@ -94,7 +95,27 @@ func templatesFilesConfigmapYml() (*asset, error) {
 	return a, nil
 }
 
-var _templatesFilesDeploymentYml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x9c\x94\xcf\x8e\x9b\x30\x10\xc6\xef\x3c\x85\x5f\xa0\xda\x4d\x7b\xe3\x16\x6d\xb6\x5b\xa9\x4b\x1a\x6d\xd4\x5c\x10\x8a\x26\x30\x09\xa3\xf8\x9f\xec\x01\x95\xb7\xaf\x0c\x24\x35\x90\xaa\x52\x7d\x32\xf3\xfd\x3c\xdf\x8c\xc7\x02\x2c\x1d\xd0\x79\x32\x3a\x15\xf8\x8b\x51\x87\xad\x7f\x6a\x57\x27\x64\x58\x25\x57\xd2\x55\x2a\x36\x68\xa5\xe9\x14\x6a\x4e\x14\x32\x54\xc0\x90\x26\x42\x68\x50\x98\x8a\xbc\xba\xab\xc7\x10\x29\x12\x6f\xb1\x0c\xba\x43\x2b\xa9\x04\x3f\x65\xc6\x68\xd1\x03\x2d\x05\xbf\x6f\xe4\xd9\xb8\xee\x9d\x14\x71\x2a\x56\xcf\x89\x10\x8c\xca\x4a\x60\x0c\x79\x84\x88\x5d\xc3\x92\x70\x42\xe9\x6f\x5f\x7f\xaf\x24\x68\x7d\x35\x03\x36\xf2\x50\x32\xb5\xb8\x41\xa8\x24\x69\xdc\x63\x69\x74\x15\x8a\x84\xca\x17\x23\x42\x0a\x2e\xb8\x6b\xa4\xdc\x63\xe9\x90\x23\xab\x4f\x37\x33\xdf\x2b\xb7\x03\xa5\xd1\x0c\xa4\xd1\x3d\x42\xef\x62\x54\x56\xe4\x33\x21\xfa\x48\x8c\x80\xbb\x44\x39\x87\xbc\xf9\xfa\xe3\x6d\x1f\x43\xa5\x51\x0a\x74\xb5\xe0\x5e\x7e\x64\xd9\x7a\xbb\x89\x51\x49\x2d\x6a\xf4\x7e\xe7\xcc\x09\xa7\x07\x6a\x66\xfb\x86\x3c\x0d\x0a\x61\x81\xeb\x54\x3c\xcd\xa3\xc6\x71\x2a\xf2\x1a\x41\x72\x7d\x0c\x5f\xc5\x84\x20\x4d\x4c\x20\x37\x28\xa1\xbb\xdf\xf1\xea\xf3\xf3\x04\x62\x52\x68\x1a\xbe\xeb\x5f\x62\x19\x75\x3b\x6f\x68\xbc\xd0\xd7\xed\xe1\xb8\x5d\x67\xaf\xc5\xac\xa6\x16\x64\x83\x5f\x9d\x51\xf3\x16\xfa\x01\x9d\xe9\x92\x81\xfd\x8e\xdd\x07\x9e\x97\x80\x88\xa6\x75\xa6\xcb\x62\x54\xb7\x75\xc5\xee\x0f\x74\xc5\xae\xf8\x8f\x12\x47\xfd\xb0\x7e\xff\x39\x01\x5a\x23\x1b\x85\x99\x69\x34\x2f\x66\xae\x42\x74\xd7\x8f\x22\xef\xf7\xc7\x30\x97\x79\xfa\xd1\x7d\x00\xe2\x16\x86\xd4\x8f\x5e\xe7\x92\x0d\xcb\x86\xbf\x82\x67\xd4\x7c\xe8\x4f\xbe\x48\xa0\xd9\xb5\x96\x21\xb4\x1d\x2f\x2d\xec\x67\x49\xfe\xe1\x50\x1b\x3f\xf4\x33\x49\x3a\x3c\xb6\x7c\xe8\xed\x77\x00\x00\x00\xff\xff\xe8\xcd\xd7\x50\x9e\x04\x00\x00")
+var _templatesFilesCronjobYml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x7d\x91\x4b\x4b\x03\x31\x14\x85\xf7\xfd\x15\xa1\x4b\x41\x9d\x56\x5a\x24\x5b\x45\x54\xb4\x08\x8a\x9b\xd2\xc5\x4d\x72\xa7\x8d\xcd\x63\xcc\xa3\xd0\x7f\xef\x4d\xdb\x81\xb1\x33\x7a\x66\x13\xce\x47\x4e\xce\xdc\x0b\x8d\xfe\xc4\x10\xb5\x77\x9c\x09\x48\x72\x73\xbd\x9b\x08\x4c\x30\x19\x6d\xb5\x53\x9c\xdd\x05\xef\x9e\xbd\x18\x59\xf2\x14\x24\xe0\x23\xc6\x1c\x58\xe4\x6c\xb9\xbc\x5a\xd0\x61\xb5\x22\xc7\x80\x40\x13\x0b\x63\x0c\x9a\xa6\x0b\x63\x83\xb2\x80\x28\x37\xa8\xb2\xa1\x8b\xe3\x8a\x5d\x1c\xbf\x31\xf9\xd2\x3b\x99\x43\x40\x27\xf7\x6f\xde\x68\xb9\xe7\xec\xc1\x07\xa1\x55\xb9\x93\xa5\xc4\x18\xeb\x6c\xa8\x42\x7c\xd4\x31\xf9\xb0\x7f\xd1\x56\x27\xce\x6e\x88\xd7\xa0\x0d\xaa\x61\x16\x13\x84\xa4\xdd\xfa\x1e\x41\x19\xed\xf0\x1d\xe9\x25\x15\x09\x56\x15\xe1\x2f\x2f\x3e\xd0\x36\x06\x12\x1e\x6b\xb7\x3d\x8b\x04\xc8\xad\xaf\xeb\x53\xda\xf4\xe4\x82\x4c\x7a\x87\xfd\xbc\xf9\x21\xb0\x28\xfd\x4a\x2c\xea\x8e\xad\x55\x77\x58\xad\xce\x86\xd6\xda\xdd\x52\x45\x01\x0f\xbf\xd5\x0e\x6a\x81\x3b\x0c\x1d\x4c\x8d\x12\x50\xb7\x70\x96\x7e\x39\xb0\xb2\xae\xb4\x85\xf5\x11\x3f\x95\x53\x8f\xd3\xb3\x3e\x07\x5a\x05\x3f\x03\x05\x7d\x67\x2a\x35\x40\xa8\x4e\x93\x39\x9b\x54\x95\x1d\x60\x16\x2d\xed\x8b\xf0\xf4\xf6\x55\xf7\xb8\x29\x93\xff\x3b\x73\xf6\x7f\xe6\x74\x36\xa7\xcc\x1f\x62\xae\x4e\xac\xda\x02\x00\x00")
+
+func templatesFilesCronjobYmlBytes() ([]byte, error) {
+	return bindataRead(
+		_templatesFilesCronjobYml,
+		"templates/files/cronjob.yml",
+	)
+}
+
+func templatesFilesCronjobYml() (*asset, error) {
+	bytes, err := templatesFilesCronjobYmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "templates/files/cronjob.yml", size: 730, mode: os.FileMode(420), modTime: time.Unix(1792046100, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _templatesFilesDeploymentYml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xc5\x52\xcb\x4e\xc3\x30\x10\xbc\xf7\x2b\xfc\x03\x40\x52\x54\x54\xe5\x5c\x09\x90\x00\x55\x20\xb8\x54\x3d\x6c\x9b\x55\xba\xc2\x2f\xec\x4d\x45\xf8\x7a\xd6\xad\xda\x3a\xa1\x48\xdc\xf0\xc9\x9e\xf1\xce\xee\x8c\x0d\x9e\xde\x30\x44\x72\xb6\x52\xf8\xc9\x68\xd3\x36\x5e\x6d\xcb\x15\x32\x94\xa3\x77\xb2\x75\xa5\x66\xe8\xb5\xeb\x0c\x5a\x1e\x19\x81\x6b\x60\xa8\x46\x4a\x59\x30\x58\xa9\xc5\xe2\xf2\x49\x36\xcb\xa5\x20\x1a\x56\xa8\x63\xe2\x94\x02\xef\x73\x32\x7a\x5c\x27\x22\x88\x16\xad\x21\x56\x6a\xbc\x3b\x6d\x29\x75\xbc\xa3\xc8\x2e\x74\x0f\x64\x88\x2b\x55\x16\x42\x45\x0e\xc0\xd8\x74\x7b\x35\xee\xbc\xf4\x7a\x76\x5a\x93\x6d\x5e\xbd\x8c\x80\x3b\x3c\xe4\xc8\xfe\xaa\x52\x06\x3e\x5f\xda\xd0\x48\x41\x79\x42\x5e\x2d\x6c\x81\x64\x42\x2d\x78\x6a\xc0\x68\xbc\x3e\x56\xe5\xc6\xd2\xca\xad\x9c\xb1\x93\xa0\x83\xa5\xb4\xd6\xce\x32\x90\x95\x28\x4f\x25\x17\x67\x12\x3a\x2c\x32\xd0\xec\xa9\xfb\xb4\xeb\x71\xde\x05\xce\x64\x72\xa9\x0d\xb3\xef\x11\x59\xe7\xb9\x94\x55\x6a\x5a\x4c\x8b\xec\x46\xc0\xe8\xda\xb0\xc6\x81\x5e\xc0\x8f\x16\xe3\xb0\x8b\x88\xf9\x36\xc5\x5f\x98\x01\x6e\xd0\xc8\xf3\x08\x35\x9e\x3e\x52\x8f\xd3\xe9\xc9\xce\xeb\x4c\x7e\xd7\x19\x4f\x6e\x7a\x3a\x01\xa1\x16\x0f\x31\xce\x83\x5b\x61\x5f\x2d\x79\xbe\x45\x1e\xb6\xf0\xc0\x9b\x4a\x5d\x6d\x10\x34\x6f\xbe\x86\xe4\x2e\x8b\x1f\x69\x91\x25\x26\xd0\x33\xd4\xd0\xbd\xa0\x24\x57\xcb\x3f\x9c\xf4\xae\x78\x0c\xe4\xea\x23\x59\xe6\x61\x6a\xda\xe2\x3f\x0d\x79\x5d\xfc\x79\x4a\xf9\xd9\x64\xd0\xb5\x9c\x39\xfc\x06\x5d\x08\xca\x0d\xe7\x03\x00\x00")
 
 func templatesFilesDeploymentYmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -109,7 +130,7 @@ func templatesFilesDeploymentYml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "templates/files/deployment.yml", size: 1182, mode: os.FileMode(420), modTime: time.Unix(1486617623, 0)}
+	info := bindataFileInfo{name: "templates/files/deployment.yml", size: 999, mode: os.FileMode(420), modTime: time.Unix(1792046100, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -194,7 +215,7 @@ func templatesFilesProjectYml() (*asset, error) {
 	return a, nil
 }
 
-var _templatesFilesServiceYml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x6c\x8e\x41\x0a\xc2\x40\x0c\x45\xf7\x73\x8a\x5c\xc0\x85\xdb\x6c\xbd\x40\x41\x71\x53\x8a\x84\xe9\x47\x06\xdb\x66\x48\x42\xcf\x2f\xd3\x11\x41\x70\x95\xfc\xf7\x79\x21\x52\xcb\x1d\xe6\x45\x37\xa6\xfd\x9c\x5e\x65\x9b\x99\xae\xb0\xbd\x64\xa4\x15\x21\xb3\x84\x70\x22\xda\x64\x05\xd3\xe8\xbd\x7a\xb4\x38\x25\xaf\xc8\xad\xac\x6a\xe1\x6d\x21\x3a\x1d\x81\x69\x6c\x63\x3a\xd0\x57\xee\x52\x47\xd5\x34\x34\xeb\xc2\x74\xbb\x0c\x1f\x16\x62\x4f\xc4\xf0\xeb\x3b\x16\xe4\x50\xeb\xe7\xff\xbe\xf1\x0e\x00\x00\xff\xff\x7c\x49\xca\x57\xc5\x00\x00\x00")
+var _templatesFilesServiceYml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x6d\x8e\x31\x0e\xc2\x30\x0c\x45\xf7\x9e\xc2\x17\x00\xc1\x86\xb2\x76\x62\x41\x91\x40\x2c\x55\x07\x93\x5a\x10\x91\x26\x96\xe3\x56\xe2\xf6\xa4\x25\x48\x0c\x6c\xf6\x7b\xfe\xd6\x47\xf6\x57\x92\xec\x53\x34\x30\xef\x9b\xa7\x8f\x83\x81\x33\xc9\xec\x1d\x35\x23\x29\x0e\xa8\x68\x1a\x80\x88\x23\x19\xe8\xba\xed\xa9\x0c\x7d\x5f\x48\xc0\x1b\x85\xbc\x38\x00\x64\xfe\x95\x99\xc9\x2d\x42\x5f\x5c\x42\x6d\x98\xb2\x92\x1c\x6d\x21\x9c\x44\x6b\x66\x53\x7f\x3e\x54\x79\x05\x1f\x6b\xe0\xb0\xfb\xae\x92\x34\xb9\x14\x0c\x5c\x5a\x5b\x99\xa2\xdc\x49\xed\x7a\x58\x93\x99\x02\x39\x4d\xf2\xb7\xca\x1b\x2e\xef\x13\xf1\xe1\x00\x00\x00")
 
 func templatesFilesServiceYmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -209,7 +230,7 @@ func templatesFilesServiceYml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "templates/files/service.yml", size: 197, mode: os.FileMode(420), modTime: time.Unix(1485149110, 0)}
+	info := bindataFileInfo{name: "templates/files/service.yml", size: 225, mode: os.FileMode(420), modTime: time.Unix(1792046100, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -267,6 +288,7 @@ func AssetNames() []string {
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
 	"templates/files/configmap.yml": templatesFilesConfigmapYml,
+	"templates/files/cronjob.yml": templatesFilesCronjobYml,
 	"templates/files/deployment.yml": templatesFilesDeploymentYml,
 	"templates/files/job.yml": templatesFilesJobYml,
 	"templates/files/persistentvolumeclaim.yml": templatesFilesPersistentvolumeclaimYml,
@@ -318,6 +340,7 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"templates": &bintree{nil, map[string]*bintree{
 		"files": &bintree{nil, map[string]*bintree{
 			"configmap.yml": &bintree{templatesFilesConfigmapYml, map[string]*bintree{}},
+			"cronjob.yml": &bintree{templatesFilesCronjobYml, map[string]*bintree{}},
 			"deployment.yml": &bintree{templatesFilesDeploymentYml, map[string]*bintree{}},
 			"job.yml": &bintree{templatesFilesJobYml, map[string]*bintree{}},
 			"persistentvolumeclaim.yml": &bintree{templatesFilesPersistentvolumeclaimYml, map[string]*bintree{}},